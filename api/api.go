@@ -2,22 +2,32 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bradtumy/authorization-service/internal/logger"
 	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/bundle"
 	"github.com/bradtumy/authorization-service/pkg/contextprovider"
 	"github.com/bradtumy/authorization-service/pkg/graph"
 	"github.com/bradtumy/authorization-service/pkg/identity"
 	"github.com/bradtumy/authorization-service/pkg/policy"
 	"github.com/bradtumy/authorization-service/pkg/policycompiler"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
 	"github.com/bradtumy/authorization-service/pkg/store"
 	"github.com/bradtumy/authorization-service/pkg/tenant"
 	"github.com/bradtumy/authorization-service/pkg/validator"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,11 +37,16 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultTenant is the tenant created at startup, whose policies are read
+// from POLICY_FILE or POLICY_GIT_URL.
+const defaultTenant = "default"
+
 var (
-	policyStores  map[string]*policy.PolicyStore
-	policyEngines map[string]*policy.PolicyEngine
-	policyGraphs  map[string]*graph.Graph
-	policyFiles   map[string]string
+	initOnce sync.Once
+	initErr  error
+	tenants  *TenantRegistry
+	// defaultFile is the default tenant's policy file.
+	defaultFile   string
 	backend       store.Store
 	policyBackend string
 	compiler      policycompiler.Compiler
@@ -43,34 +58,72 @@ var (
 		},
 		[]string{"decision", "reason"},
 	)
-	tracer           trace.Tracer
-	contextProviders contextprovider.Chain
-	identityProvider identity.Provider
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "policy_cache_hit_count",
+		Help: "Number of decisions served from the decision cache",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "policy_cache_miss_count",
+		Help: "Number of decisions evaluated because they were not cached",
+	})
+	decisionCacheSize int
+	delegationDepth   int
+	tracer            trace.Tracer
+	contextProviders  contextprovider.Chain
+	identityProvider  identity.Provider
+	trustedKeys       []ed25519.PublicKey
 )
 
-func init() {
+// Init configures the package from the environment: the store and policy
+// backends, the decision cache, trusted bundle keys and the default tenant.
+// It runs once; SetupRouter calls it if it has not run yet.
+func Init() error {
+	initOnce.Do(func() { initErr = initialize() })
+	return initErr
+}
+
+func initialize() error {
 	if err := godotenv.Load(".env"); err != nil && !os.IsNotExist(err) {
 		log.Printf("warning: could not load .env file: %v", err)
 	}
 
-	policyStores = make(map[string]*policy.PolicyStore)
-	policyEngines = make(map[string]*policy.PolicyEngine)
-	policyGraphs = make(map[string]*graph.Graph)
-	policyFiles = make(map[string]string)
+	tenants = NewTenantRegistry(loadTenant)
 
 	var err error
 	backend, err = store.New()
 	if err != nil {
-		panic("failed to init store: " + err.Error())
+		return fmt.Errorf("failed to init store: %v", err)
+	}
+
+	decisionCacheSize = 10000
+	if v := os.Getenv("DECISION_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid DECISION_CACHE_SIZE: %v", err)
+		}
+		decisionCacheSize = n
+	}
+	if v := os.Getenv("DELEGATION_MAX_DEPTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid DELEGATION_MAX_DEPTH: %v", err)
+		}
+		delegationDepth = n
 	}
 
 	policyBackend = os.Getenv("POLICY_BACKEND")
 	if policyBackend == "" {
 		policyBackend = "file"
 	}
+	if v := os.Getenv("POLICY_TRUSTED_KEYS"); v != "" {
+		if trustedKeys, err = loadTrustedKeys(v); err != nil {
+			return fmt.Errorf("invalid POLICY_TRUSTED_KEYS: %v", err)
+		}
+	}
+	lvl := logger.ParseLevel(os.Getenv("LOG_LEVEL"))
+	auditLogger = logger.New(os.Stdout, lvl)
 
-	defaultTenant := "default"
-	defaultFile := os.Getenv("POLICY_FILE")
+	defaultFile = os.Getenv("POLICY_FILE")
 	if defaultFile == "" {
 		defaultFile = "configs/policies.yaml"
 	}
@@ -83,48 +136,171 @@ func init() {
 		}
 	}
 
-	store := policy.NewPolicyStore()
-	g := graph.New()
-	policyStores[defaultTenant] = store
-	policyGraphs[defaultTenant] = g
-	policyEngines[defaultTenant] = policy.NewPolicyEngine(store, g)
-	policyFiles[defaultTenant] = defaultFile
+	if systemTenant, err = newSystemTenant(); err != nil {
+		return fmt.Errorf("failed to load management policies: %v", err)
+	}
+	globalIssuers = nil
+	for _, did := range strings.Split(os.Getenv("VC_TRUSTED_ISSUERS"), ",") {
+		if did = strings.TrimSpace(did); did != "" {
+			globalIssuers = append(globalIssuers, did)
+		}
+	}
+	platformAdmins = make(map[string]bool)
+	for _, sub := range strings.Split(os.Getenv("PLATFORM_ADMINS"), ",") {
+		if sub = strings.TrimSpace(sub); sub != "" {
+			platformAdmins[sub] = true
+		}
+	}
+
 	def := Tenant{ID: defaultTenant, Name: "default", CreatedAt: time.Now()}
 	if err := backend.SaveTenant(context.Background(), def); err != nil {
-		panic("failed to save default tenant: " + err.Error())
+		return fmt.Errorf("failed to save default tenant: %v", err)
+	}
+	t, err := loadTenant(context.Background(), defaultTenant)
+	if err != nil {
+		return fmt.Errorf("failed to load policies: %v", err)
 	}
+	tenants.Add(t)
 
 	if policyBackend == "db" {
-		if err := loadPoliciesFromDB(context.Background(), defaultTenant); err != nil {
-			panic("failed to load policies from db: " + err.Error())
+		changes, err := backend.Watch(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to watch policy changes: %v", err)
 		}
-		go watchPolicies()
-	} else {
-		if err := store.LoadPolicies(defaultFile); err != nil {
-			panic("Failed to load policies: " + err.Error())
+		go watchPolicies(changes)
+	} else if policyBackend == "git" {
+		interval := 30 * time.Second
+		if v := os.Getenv("POLICY_GIT_POLL_INTERVAL"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid POLICY_GIT_POLL_INTERVAL: %v", err)
+			}
+		}
+		if interval > 0 {
+			go watchGitPolicies(interval)
+		}
+	}
+	tenantRetention = defaultRetention
+	if v := os.Getenv("TENANT_RETENTION"); v != "" {
+		if tenantRetention, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("invalid TENANT_RETENTION: %v", err)
+		}
+	}
+	if tenantRetention > 0 {
+		go purgeDeletedTenants(min(tenantRetention, time.Hour))
+	}
+	if v := os.Getenv("TENANT_IDLE_TIMEOUT"); v != "" {
+		idle, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid TENANT_IDLE_TIMEOUT: %v", err)
+		}
+		if idle > 0 {
+			go evictIdleTenants(idle)
 		}
 	}
 
 	compiler = policycompiler.NewOpenAICompiler(os.Getenv("OPENAI_API_KEY"))
-	lvl := logger.ParseLevel(os.Getenv("LOG_LEVEL"))
-	auditLogger = logger.New(os.Stdout, lvl)
-	prometheus.MustRegister(policyEval)
+	prometheus.MustRegister(policyEval, cacheHits, cacheMisses)
 	tracer = otel.Tracer("authorization-service")
 	contextProviders = contextprovider.Chain{
 		contextprovider.TimeProvider{},
 		contextprovider.GeoIPProvider{},
 		contextprovider.RiskProvider{},
 	}
+	return nil
+}
+
+// newEngine creates a policy engine that resolves resource attributes from the
+// backend store, caches up to DECISION_CACHE_SIZE decisions and follows at
+// most DELEGATION_MAX_DEPTH delegation hops.
+func newEngine(s *policy.PolicyStore, g *graph.Graph) *policy.PolicyEngine {
+	engine := policy.NewPolicyEngine(s, g)
+	engine.SetResourceAttributeProvider(store.NewAttributeProvider(backend))
+	engine.SetDecisionCache(policy.NewDecisionCache(decisionCacheSize))
+	engine.SetMaxDelegationDepth(delegationDepth)
+	return engine
+}
+
+// loadTrustedKeys reads the comma separated list of PEM encoded ed25519
+// public key files used to verify policy bundles.
+func loadTrustedKeys(paths string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, p := range strings.Split(paths, ",") {
+		data, err := os.ReadFile(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		k, err := bundle.ParsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// loadPolicyFile loads a tenant's policies from a file, directory or bundle
+// archive (see readPolicyBundle). The tenant's current policies stay active
+// on any error.
+func loadPolicyFile(ctx context.Context, t *TenantState, file string) error {
+	b, err := readPolicyBundle(ctx, t.ID, file)
+	if err != nil {
+		return err
+	}
+	return t.Store.LoadBundle(b)
+}
+
+// readPolicyBundle assembles the bundle at file, converting legacy policy
+// files for tenantID (see policy.ReadBundle). When trusted keys are
+// configured only archives with a valid detached signature are accepted, and
+// rejected bundles are audited.
+func readPolicyBundle(ctx context.Context, tenantID, file string) (*bundle.Bundle, error) {
+	if len(trustedKeys) == 0 {
+		return policy.ReadBundle(file, tenantID)
+	}
+	var digest string
+	b, err := func() (*bundle.Bundle, error) {
+		if !bundle.IsArchive(file) {
+			return nil, fmt.Errorf("policy bundle %s is not a signed archive", file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		digest = bundle.Digest(data)
+		sigData, err := os.ReadFile(file + bundle.SignatureSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("policy bundle signature: %v", err)
+		}
+		sig, err := bundle.ParseSignature(sigData)
+		if err != nil {
+			return nil, err
+		}
+		if err := bundle.Verify(data, sig, trustedKeys); err != nil {
+			return nil, err
+		}
+		return bundle.ReadArchive(data)
+	}()
+	if err != nil {
+		auditLogger.Log(logger.Entry{
+			Level:         "error",
+			CorrelationID: middleware.CorrelationIDFromContext(ctx),
+			TenantID:      tenantID,
+			Action:        "bundle_reject",
+			Resource:      file,
+			Decision:      "deny",
+			Reason:        err.Error(),
+			Digest:        digest,
+		})
+	}
+	return b, err
 }
 
-// VerifiableCredential represents a W3C Verifiable Credential.
-type VerifiableCredential struct {
-	Context           []string               `json:"@context"`
-	ID                string                 `json:"id"`
-	Type              []string               `json:"type"`
-	Issuer            string                 `json:"issuer"`
-	IssuanceDate      time.Time              `json:"issuanceDate"`
-	CredentialSubject map[string]interface{} `json:"credentialSubject"`
+// invalidateDecisions discards cached decisions of a tenant after changes the
+// engine cannot observe, such as role assignments.
+func invalidateDecisions(tenantID string) {
+	if t, ok := tenants.Loaded(tenantID); ok {
+		t.Engine.InvalidateCache()
+	}
 }
 
 // AuthorizationContext contains metadata for an authorization request.
@@ -137,7 +313,9 @@ type AuthorizationContext struct {
 
 // AuthorizationRequest represents an authorization evaluation request.
 type AuthorizationRequest struct {
-	Credential VerifiableCredential `json:"credential"`
+	// Credential is a W3C Verifiable Credential with an embedded proof, or a
+	// JSON string holding a JWT-VC.
+	Credential json.RawMessage      `json:"credential"`
 	Context    AuthorizationContext `json:"context"`
 }
 
@@ -147,15 +325,111 @@ type AccessRequest struct {
 	Resource   string            `json:"resource"`
 	Action     string            `json:"action"`
 	Conditions map[string]string `json:"conditions"`
+	// ResourceAttributes describes the resource, for example its owner.
+	// Attributes stored for the resource take precedence.
+	ResourceAttributes map[string]interface{} `json:"resourceAttributes"`
+}
+
+// BatchAccessRequest asks for decisions on many resource/action pairs for
+// the authenticated subject.
+type BatchAccessRequest struct {
+	TenantID   string            `json:"tenantID"`
+	Subject    string            `json:"subject"`
+	Items      []policy.Target   `json:"items"`
+	Conditions map[string]string `json:"conditions"`
+}
+
+// BatchAccessResponse holds one decision per requested item, in order.
+type BatchAccessResponse struct {
+	Decisions []policy.Decision `json:"decisions"`
+}
+
+// maxBatchItems bounds the size of a single batch request.
+const maxBatchItems = 1000
+
+// SubjectPermissionsResponse lists what a subject may do in a tenant.
+type SubjectPermissionsResponse struct {
+	Subject     string              `json:"subject"`
+	Permissions []policy.Permission `json:"permissions"`
+}
+
+// ResourceAccessResponse lists the roles and users that may perform an action
+// on a resource.
+type ResourceAccessResponse struct {
+	Resource string          `json:"resource"`
+	Action   string          `json:"action"`
+	Access   []policy.Access `json:"access"`
 }
 
 // SimulationRequest represents a dry-run evaluation with explicit context.
 type SimulationRequest struct {
-	TenantID string            `json:"tenantID"`
-	Subject  string            `json:"subject"`
-	Resource string            `json:"resource"`
-	Action   string            `json:"action"`
-	Context  map[string]string `json:"context"`
+	TenantID           string                 `json:"tenantID"`
+	Subject            string                 `json:"subject"`
+	Resource           string                 `json:"resource"`
+	Action             string                 `json:"action"`
+	Context            map[string]string      `json:"context"`
+	ResourceAttributes map[string]interface{} `json:"resourceAttributes"`
+}
+
+// RelationshipRequest adds or removes an edge of a tenant's relationship
+// graph, such as `user:alice` -> `group:admins`.
+type RelationshipRequest struct {
+	TenantID string `json:"tenantID"`
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+}
+
+// Relationship is a directed edge of the relationship graph.
+type Relationship struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// DelegationRequest creates a delegation letting Delegate act on behalf of
+// Delegator. ExpiresAt is an RFC 3339 timestamp; Actions and Resources are
+// policy patterns limiting the delegation.
+type DelegationRequest struct {
+	TenantID  string   `json:"tenantID"`
+	ID        string   `json:"id"`
+	Delegator string   `json:"delegator"`
+	Delegate  string   `json:"delegate"`
+	Actions   []string `json:"actions"`
+	Resources []string `json:"resources"`
+	ExpiresAt string   `json:"expiresAt"`
+}
+
+// RevokeDelegationRequest identifies a delegation to revoke.
+type RevokeDelegationRequest struct {
+	TenantID string `json:"tenantID"`
+	ID       string `json:"id"`
+}
+
+// TupleRequest writes or deletes a relationship tuple such as
+// `document:123#viewer@group:eng#member`.
+type TupleRequest struct {
+	TenantID string `json:"tenantID"`
+	Tuple    string `json:"tuple"`
+}
+
+// RelationCheckRequest asks whether subject holds relation on object.
+type RelationCheckRequest struct {
+	TenantID string `json:"tenantID"`
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+}
+
+// RelationCheckResponse reports the outcome of a relation check.
+type RelationCheckResponse struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ResourceAttributesRequest stores the attributes of a resource.
+type ResourceAttributesRequest struct {
+	TenantID   string                 `json:"tenantID"`
+	Resource   string                 `json:"resource"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 type CompileRequest struct {
@@ -170,6 +444,27 @@ type TenantRequest struct {
 type CreateTenantRequest struct {
 	TenantID string `json:"tenantID"`
 	Name     string `json:"name"`
+	// PolicyRepo and PolicyBranch name the repository the tenant's policies
+	// are pulled from when POLICY_BACKEND=git.
+	PolicyRepo   string `json:"policyRepo,omitempty"`
+	PolicyBranch string `json:"policyBranch,omitempty"`
+	// Template names a directory under TENANT_TEMPLATE_DIR whose policies
+	// the tenant starts with.
+	Template string `json:"template,omitempty"`
+	// Quota optionally limits the tenant's resources.
+	Quota tenant.Quota `json:"quota"`
+}
+
+// TenantQuotaRequest replaces a tenant's quota.
+type TenantQuotaRequest struct {
+	TenantID string       `json:"tenantID"`
+	Quota    tenant.Quota `json:"quota"`
+}
+
+// TrustedIssuersRequest replaces a tenant's trusted credential issuers.
+type TrustedIssuersRequest struct {
+	TenantID string   `json:"tenantID"`
+	Issuers  []string `json:"issuers"`
 }
 
 type Tenant = tenant.Tenant
@@ -196,50 +491,83 @@ type DeleteUserRequest struct {
 	Username string `json:"username"`
 }
 
-func requireAdmin(w http.ResponseWriter, r *http.Request, tenantID string) (string, bool) {
-	sub, _ := r.Context().Value("subject").(string)
-	tenant, _ := r.Context().Value("tenant").(string)
-	if sub == "" || tenant == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return "", false
-	}
-	if tenantID != "" && tenantID != tenant {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return "", false
-	}
-	if !identityProvider.HasRole(r.Context(), tenant, sub, "TenantAdmin", "PolicyAdmin") {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return "", false
-	}
-	return sub, true
-}
-
 func SetupRouter(p identity.Provider) *mux.Router {
+	if err := Init(); err != nil {
+		panic(err.Error())
+	}
 	identityProvider = p
 	router := mux.NewRouter()
 	router.Use(middleware.TracingMiddleware)
 	router.Use(middleware.CorrelationMiddleware)
 	router.Use(middleware.MetricsMiddleware)
 	router.Use(middleware.JWTMiddleware)
+	router.Use(middleware.RateLimitMiddleware(tenantRequestRate))
 	router.HandleFunc("/authorize", Authorize).Methods("POST")
 	router.HandleFunc("/check-access", CheckAccess).Methods("POST")
+	router.HandleFunc("/check-access/batch", CheckAccessBatch).Methods("POST")
 	router.HandleFunc("/simulate", SimulateAccess).Methods("POST")
+	router.HandleFunc("/query/subject-permissions", QuerySubjectPermissions).Methods("GET")
+	router.HandleFunc("/query/resource-access", QueryResourceAccess).Methods("GET")
 	router.HandleFunc("/reload", ReloadPolicies).Methods("POST")
+	router.HandleFunc("/healthz", Healthz).Methods("GET")
+	router.HandleFunc("/policies/version", GetPolicyVersion).Methods("GET")
+	router.HandleFunc("/policies/webhook", GitWebhook).Methods("POST")
+	router.HandleFunc("/policies/versions", ListPolicyVersions).Methods("GET")
+	router.HandleFunc("/policies/diff", DiffPolicyVersions).Methods("GET")
+	router.HandleFunc("/policies/rollback", RollbackPolicies).Methods("POST")
+	router.HandleFunc("/policies/create", policyItems.create).Methods("POST")
+	router.HandleFunc("/policies/update", policyItems.update).Methods("POST")
+	router.HandleFunc("/policies/delete", policyItems.delete).Methods("POST")
+	router.HandleFunc("/policies/get", policyItems.get).Methods("GET")
+	router.HandleFunc("/policies/list", policyItems.list).Methods("GET")
+	router.HandleFunc("/roles/create", roleItems.create).Methods("POST")
+	router.HandleFunc("/roles/update", roleItems.update).Methods("POST")
+	router.HandleFunc("/roles/delete", roleItems.delete).Methods("POST")
+	router.HandleFunc("/roles/get", roleItems.get).Methods("GET")
+	router.HandleFunc("/roles/list", roleItems.list).Methods("GET")
+	router.HandleFunc("/policy-users/create", userItems.create).Methods("POST")
+	router.HandleFunc("/policy-users/update", userItems.update).Methods("POST")
+	router.HandleFunc("/policy-users/delete", userItems.delete).Methods("POST")
+	router.HandleFunc("/policy-users/get", userItems.get).Methods("GET")
+	router.HandleFunc("/policy-users/list", userItems.list).Methods("GET")
 	router.HandleFunc("/compile", CompileRule).Methods("POST")
 	router.HandleFunc("/validate-policy", ValidatePolicy).Methods("POST")
 	router.HandleFunc("/tenant/create", CreateTenant).Methods("POST")
 	router.HandleFunc("/tenant/delete", DeleteTenant).Methods("POST")
+	router.HandleFunc("/tenant/undelete", UndeleteTenant).Methods("POST")
+	router.HandleFunc("/tenant/suspend", SuspendTenant).Methods("POST")
+	router.HandleFunc("/tenant/resume", ResumeTenant).Methods("POST")
+	router.HandleFunc("/tenant/quota", SetTenantQuota).Methods("POST")
+	router.HandleFunc("/tenant/issuers", SetTrustedIssuers).Methods("POST")
+	router.HandleFunc("/tenant/export", ExportTenant).Methods("GET")
+	router.HandleFunc("/tenant/import", ImportTenant).Methods("POST")
 	router.HandleFunc("/tenant/list", ListTenants).Methods("GET")
 	router.HandleFunc("/user/create", CreateUser).Methods("POST")
 	router.HandleFunc("/user/assign-role", AssignRole).Methods("POST")
 	router.HandleFunc("/user/delete", DeleteUser).Methods("POST")
 	router.HandleFunc("/user/list", ListUsers).Methods("GET")
 	router.HandleFunc("/user/get", GetUser).Methods("GET")
+	router.HandleFunc("/graph/add", AddRelationship).Methods("POST")
+	router.HandleFunc("/graph/remove", RemoveRelationship).Methods("POST")
+	router.HandleFunc("/graph/list", ListRelationships).Methods("GET")
+	router.HandleFunc("/delegations/create", CreateDelegation).Methods("POST")
+	router.HandleFunc("/delegations/revoke", RevokeDelegation).Methods("POST")
+	router.HandleFunc("/delegations/list", ListDelegations).Methods("GET")
+	router.HandleFunc("/tuples/write", WriteTuple).Methods("POST")
+	router.HandleFunc("/tuples/delete", DeleteTuple).Methods("POST")
+	router.HandleFunc("/tuples/list", ListTuples).Methods("GET")
+	router.HandleFunc("/check-relation", CheckRelation).Methods("POST")
+	router.HandleFunc("/resource/attributes", SetResourceAttributes).Methods("POST")
+	router.HandleFunc("/resource/attributes", GetResourceAttributes).Methods("GET")
+	router.HandleFunc("/resource/attributes/delete", DeleteResourceAttributes).Methods("POST")
 	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 	return router
 }
 
 // Authorize evaluates an authorization request using a Verifiable Credential.
+// The credential must be issued by one of the tenant's trusted issuers,
+// carry a valid proof, be within its validity period and not be revoked;
+// otherwise the request is denied with the rejection as reason.
 func Authorize(w http.ResponseWriter, r *http.Request) {
 	ctx, span := tracer.Start(r.Context(), "Authorize")
 	defer span.End()
@@ -260,9 +588,8 @@ func Authorize(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing environment in context", http.StatusBadRequest)
 		return
 	}
-	subj, ok := req.Credential.CredentialSubject["id"].(string)
-	if !ok || subj == "" {
-		http.Error(w, "invalid credential: missing credentialSubject.id", http.StatusBadRequest)
+	if len(req.Credential) == 0 || string(req.Credential) == "null" {
+		http.Error(w, "missing credential", http.StatusBadRequest)
 		return
 	}
 	tenantID, ok := req.Context.Environment["tenantID"]
@@ -270,11 +597,28 @@ func Authorize(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing tenantID in environment", http.StatusBadRequest)
 		return
 	}
-	engine, ok := policyEngines[tenantID]
+	t, ok := tenants.Get(r.Context(), tenantID)
 	if !ok {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
 	}
+	if decision, ok := suspendedDecision(tenantID); ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(decision)
+		return
+	}
+	cred, decision, ok := verifyCredential(ctx, t, req.Credential)
+	if !ok {
+		span.SetAttributes(attribute.String("credential.rejected", decision.Reason))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(decision)
+		return
+	}
+	subj, ok := cred.Subject["id"].(string)
+	if !ok || subj == "" {
+		http.Error(w, "invalid credential: missing credentialSubject.id", http.StatusBadRequest)
+		return
+	}
 	ctxVals := contextProviders.GetContext(r)
 	conds := make(map[string]string)
 	for k, v := range req.Context.Environment {
@@ -283,7 +627,7 @@ func Authorize(w http.ResponseWriter, r *http.Request) {
 	for k, v := range ctxVals {
 		conds[k] = v
 	}
-	for k, v := range req.Credential.CredentialSubject {
+	for k, v := range cred.Subject {
 		if k == "id" {
 			continue
 		}
@@ -297,7 +641,7 @@ func Authorize(w http.ResponseWriter, r *http.Request) {
 	for k, v := range conds {
 		evalSpan.SetAttributes(attribute.String(k, v))
 	}
-	decision := engine.Evaluate(subj, req.Context.Resource, req.Context.Action, conds)
+	decision = t.Engine.Evaluate(subj, req.Context.Resource, req.Context.Action, conds)
 	status := "deny"
 	if decision.Allow {
 		status = "allow"
@@ -330,11 +674,17 @@ func CheckAccess(w http.ResponseWriter, r *http.Request) {
 	}
 	req.TenantID = tenantID
 	req.Subject = subject
-	engine, ok := policyEngines[tenantID]
+	engine, ok := tenants.Engine(r.Context(), tenantID)
 	if !ok {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
 	}
+	if decision, ok := suspendedDecision(tenantID); ok {
+		recordDecision(r, req.TenantID, req.Subject, req.Resource, req.Action, decision)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(decision)
+		return
+	}
 
 	// Gather runtime context and evaluate permissions using the PolicyEngine
 	ctxVals := contextProviders.GetContext(r)
@@ -349,7 +699,13 @@ func CheckAccess(w http.ResponseWriter, r *http.Request) {
 	for k, v := range ctxVals {
 		evalSpan.SetAttributes(attribute.String(k, v))
 	}
-	decision := engine.Evaluate(req.Subject, req.Resource, req.Action, req.Conditions)
+	decision := engine.EvaluateRequest(ctx, policy.Request{
+		Subject:            req.Subject,
+		Resource:           req.Resource,
+		Action:             req.Action,
+		Env:                req.Conditions,
+		ResourceAttributes: req.ResourceAttributes,
+	})
 	status := "deny"
 	if decision.Allow {
 		status = "allow"
@@ -360,10 +716,82 @@ func CheckAccess(w http.ResponseWriter, r *http.Request) {
 	)
 	evalSpan.End()
 
-	// Audit log
-	cid := middleware.CorrelationIDFromContext(r.Context())
+	recordDecision(r, req.TenantID, req.Subject, req.Resource, req.Action, decision)
+
+	// Respond with the authorization decision
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decision)
+}
+
+// CheckAccessBatch evaluates many resource/action pairs for the authenticated
+// subject against one snapshot of the tenant's policies.
+func CheckAccessBatch(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "CheckAccessBatch")
+	defer span.End()
+	var req BatchAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenantID, _ := r.Context().Value("tenant").(string)
+	subject, _ := r.Context().Value("subject").(string)
+	if tenantID == "" || subject == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	req.TenantID = tenantID
+	req.Subject = subject
+	if len(req.Items) == 0 {
+		http.Error(w, "items are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		http.Error(w, "too many items", http.StatusBadRequest)
+		return
+	}
+	engine, ok := tenants.Engine(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+
+	var decisions []policy.Decision
+	if decision, ok := suspendedDecision(tenantID); ok {
+		decisions = make([]policy.Decision, len(req.Items))
+		for i := range decisions {
+			decisions[i] = decision
+		}
+	} else {
+		ctxVals := contextProviders.GetContext(r)
+		if req.Conditions == nil {
+			req.Conditions = make(map[string]string)
+		}
+		req.Conditions["tenantID"] = req.TenantID
+		for k, v := range ctxVals {
+			req.Conditions[k] = v
+		}
+		_, evalSpan := tracer.Start(ctx, "PolicyEvaluation")
+		evalSpan.SetAttributes(attribute.Int("items", len(req.Items)))
+		decisions = engine.EvaluateBatch(ctx, req.Subject, req.Items, req.Conditions)
+		evalSpan.End()
+	}
+
+	for i, item := range req.Items {
+		recordDecision(r, req.TenantID, req.Subject, item.Resource, item.Action, decisions[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BatchAccessResponse{Decisions: decisions})
+}
+
+// recordDecision updates evaluation metrics and writes an audit entry for a
+// single access decision.
+func recordDecision(r *http.Request, tenantID, subject, resource, action string, decision policy.Decision) {
+	status := "deny"
 	reasonLabel := ""
-	if !decision.Allow {
+	if decision.Allow {
+		status = "allow"
+	} else {
 		switch decision.Reason {
 		case "risk", "time":
 			reasonLabel = decision.Reason
@@ -372,26 +800,30 @@ func CheckAccess(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	policyEval.WithLabelValues(status, reasonLabel).Inc()
+	if decisionCacheSize > 0 {
+		if decision.Cached {
+			cacheHits.Inc()
+		} else {
+			cacheMisses.Inc()
+		}
+	}
 	auditLogger.Log(logger.Entry{
 		Level:         "info",
-		CorrelationID: cid,
-		TenantID:      req.TenantID,
-		Subject:       req.Subject,
-		Action:        req.Action,
-		Resource:      req.Resource,
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      tenantID,
+		Subject:       subject,
+		Action:        action,
+		Resource:      resource,
 		Decision:      status,
 		PolicyID:      decision.PolicyID,
 		Reason:        decision.Reason,
+		Commit:        decision.Commit,
 	})
-
-	// Respond with the authorization decision
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(decision)
 }
 
 // SimulateAccess performs a dry-run policy evaluation without audit logging.
 func SimulateAccess(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "SimulateAccess")
+	ctx, span := tracer.Start(r.Context(), "SimulateAccess")
 	defer span.End()
 	var req SimulationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -406,7 +838,7 @@ func SimulateAccess(w http.ResponseWriter, r *http.Request) {
 	}
 	req.TenantID = tenantID
 	req.Subject = subject
-	engine, ok := policyEngines[tenantID]
+	engine, ok := tenants.Engine(r.Context(), tenantID)
 	if !ok {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
@@ -415,11 +847,77 @@ func SimulateAccess(w http.ResponseWriter, r *http.Request) {
 		req.Context = make(map[string]string)
 	}
 	req.Context["tenantID"] = req.TenantID
-	decision := engine.Evaluate(req.Subject, req.Resource, req.Action, req.Context)
+	decision := engine.EvaluateRequest(ctx, policy.Request{
+		Subject:            req.Subject,
+		Resource:           req.Resource,
+		Action:             req.Action,
+		Env:                req.Context,
+		ResourceAttributes: req.ResourceAttributes,
+	})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(decision)
 }
 
+// QuerySubjectPermissions lists the resources and actions a subject may
+// access. Subjects may query themselves; querying another subject requires an
+// administrator.
+func QuerySubjectPermissions(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "QuerySubjectPermissions")
+	defer span.End()
+	tenantID, _ := r.Context().Value("tenant").(string)
+	caller, _ := r.Context().Value("subject").(string)
+	if tenantID == "" || caller == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	subject := r.URL.Query().Get("subject")
+	if subject == "" {
+		subject = caller
+	}
+	if subject != caller {
+		if _, ok := requireAdmin(w, r, tenantID); !ok {
+			return
+		}
+	}
+	engine, ok := tenants.Engine(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	perms, ok := engine.SubjectPermissions(tenantID, subject)
+	if !ok {
+		http.Error(w, "subject not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SubjectPermissionsResponse{Subject: subject, Permissions: perms})
+}
+
+// QueryResourceAccess lists the roles and users that may perform an action on
+// a resource. It requires an administrator.
+func QueryResourceAccess(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "QueryResourceAccess")
+	defer span.End()
+	tenantID, _ := r.Context().Value("tenant").(string)
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	resource := r.URL.Query().Get("resource")
+	action := r.URL.Query().Get("action")
+	if resource == "" || action == "" {
+		http.Error(w, "missing resource or action", http.StatusBadRequest)
+		return
+	}
+	engine, ok := tenants.Engine(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	access := engine.ResourceAccess(tenantID, resource, action)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ResourceAccessResponse{Resource: resource, Action: action, Access: access})
+}
+
 // ReloadPolicies reloads policies from the YAML file.
 func ReloadPolicies(w http.ResponseWriter, r *http.Request) {
 	_, span := tracer.Start(r.Context(), "ReloadPolicies")
@@ -429,22 +927,31 @@ func ReloadPolicies(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if _, ok := policyStores[req.TenantID]; !ok {
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
 	}
+	if err := loadGraph(r.Context(), ts); err != nil {
+		http.Error(w, "failed to reload graph", http.StatusInternalServerError)
+		return
+	}
 	if policyBackend == "db" {
-		if err := loadPoliciesFromDB(r.Context(), req.TenantID); err != nil {
+		if err := loadPoliciesFromDB(r.Context(), ts); err != nil {
 			http.Error(w, "failed to reload policies", http.StatusInternalServerError)
 			return
 		}
-	} else {
-		file, ok := policyFiles[req.TenantID]
-		if !ok {
-			http.Error(w, "tenant not found", http.StatusNotFound)
+	} else if policyBackend == "git" {
+		if _, err := syncGitPolicies(r.Context(), req.TenantID); err != nil {
+			http.Error(w, "failed to reload policies", http.StatusInternalServerError)
 			return
 		}
-		if err := policyStores[req.TenantID].LoadPolicies(file); err != nil {
+	} else {
+		file := ts.File
+		if err := loadPolicyFile(r.Context(), ts, file); err != nil {
 			auditLogger.Log(logger.Entry{
 				Level:         "error",
 				CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
@@ -463,6 +970,8 @@ func ReloadPolicies(w http.ResponseWriter, r *http.Request) {
 			Action:        "reload",
 			Resource:      file,
 			Decision:      "success",
+			Revision:      ts.Store.Revision,
+			Digest:        ts.Store.Digest,
 		})
 	}
 	w.WriteHeader(http.StatusOK)
@@ -478,7 +987,10 @@ func CompileRule(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if _, ok := policyStores[req.TenantID]; !ok {
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if _, ok := tenants.Get(r.Context(), req.TenantID); !ok {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
 	}
@@ -514,7 +1026,10 @@ func ValidatePolicy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if _, ok := policyStores[req.TenantID]; !ok {
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if _, ok := tenants.Get(r.Context(), req.TenantID); !ok {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
 	}
@@ -540,36 +1055,99 @@ func ValidatePolicy(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("policy is valid"))
 }
 
-func loadPoliciesFromDB(ctx context.Context, tenantID string) error {
+// loadPoliciesFromDB replaces a tenant's policies, roles and users with the
+// ones persisted in the backend.
+func loadPoliciesFromDB(ctx context.Context, t *TenantState) error {
+	tenantID := t.ID
+	rev, err := backend.Revision(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	// Versions are read before the items, so that an item is never older
+	// than its recorded version and a conditional write cannot overwrite a
+	// change this replica has not seen.
+	versions, err := backend.ItemVersions(ctx, tenantID)
+	if err != nil {
+		return err
+	}
 	policies, err := backend.LoadPolicies(ctx, tenantID)
 	if err != nil {
 		return err
 	}
-	store, ok := policyStores[tenantID]
-	if !ok {
-		store = policy.NewPolicyStore()
-		policyStores[tenantID] = store
+	roles, err := backend.LoadRoles(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	users, err := backend.LoadPolicyUsers(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if err := t.Store.ReplaceAll(policies, roles, users); err != nil {
+		return err
 	}
-	store.ReplacePolicies(policies)
+	t.setItemVersions(versions)
+	setStoreRevision(tenantID, rev)
 	return nil
 }
 
-func watchPolicies() {
-	ticker := time.NewTicker(30 * time.Second)
-	for range ticker.C {
-		tenants, err := backend.ListTenants(context.Background())
-		if err != nil {
-			continue
-		}
-		for _, t := range tenants {
-			if _, ok := policyStores[t.ID]; ok {
-				loadPoliciesFromDB(context.Background(), t.ID)
-			}
-		}
+// loadGraph replaces a tenant's relationship graph with the edges persisted
+// in the backend.
+func loadGraph(ctx context.Context, t *TenantState) error {
+	edges, err := backend.LoadEdges(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	m := make(map[string][]string)
+	for _, e := range edges {
+		m[e.Src] = append(m[e.Src], e.Dst)
 	}
+	t.Graph.Replace(m)
+	delegations, err := backend.LoadDelegations(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	t.Engine.SetDelegations(delegations)
+	return nil
 }
 
-// CreateTenant registers a new tenant with an empty PolicyStore.
+// watchPolicies reloads a tenant's policies and graph whenever the backend
+// reports a change to them, so that changes made on any replica take effect
+// on every replica. A change without a tenant reloads all of them. Tenants
+// that are not loaded are skipped; they read the latest state when loaded.
+func watchPolicies(changes <-chan store.Change) {
+	for c := range changes {
+		ids := []string{c.TenantID}
+		if c.TenantID == "" {
+			ids = tenants.IDs()
+		}
+		for _, id := range ids {
+			t, ok := tenants.Loaded(id)
+			if !ok {
+				continue
+			}
+			meta, err := backend.LoadTenant(context.Background(), id)
+			if err != nil || meta.State() == tenant.StatusPendingDeletion {
+				tenants.Remove(id)
+				forgetTenant(id)
+				continue
+			}
+			t.SetMeta(meta)
+			if err := loadPoliciesFromDB(context.Background(), t); err != nil {
+				auditLogger.Log(logger.Entry{
+					Level:    "error",
+					TenantID: id,
+					Action:   "reload",
+					Reason:   err.Error(),
+				})
+				continue
+			}
+			loadGraph(context.Background(), t)
+		}
+	}
+}
+
+// CreateTenant registers a new tenant, optionally provisioned from a tenant
+// template, and loads it.
 func CreateTenant(w http.ResponseWriter, r *http.Request) {
 	_, span := tracer.Start(r.Context(), "CreateTenant")
 	defer span.End()
@@ -578,28 +1156,70 @@ func CreateTenant(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if req.TenantID == "" {
+	if req.TenantID == "" || req.TenantID == "." || req.TenantID == ".." {
 		http.Error(w, "tenantID is required", http.StatusBadRequest)
 		return
 	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if reservedTenantID(req.TenantID) {
+		http.Error(w, "tenantID is reserved", http.StatusBadRequest)
+		return
+	}
 	if _, err := backend.LoadTenant(r.Context(), req.TenantID); err == nil {
 		http.Error(w, "tenant already exists", http.StatusConflict)
 		return
 	}
-	store := policy.NewPolicyStore()
-	g := graph.New()
-	policyStores[req.TenantID] = store
-	policyGraphs[req.TenantID] = g
-	policyEngines[req.TenantID] = policy.NewPolicyEngine(store, g)
-	policyFiles[req.TenantID] = ""
-	tenant := Tenant{ID: req.TenantID, Name: req.Name, CreatedAt: time.Now()}
+	if err := req.Quota.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenant := Tenant{
+		ID:           req.TenantID,
+		Name:         req.Name,
+		CreatedAt:    time.Now(),
+		Status:       tenant.StatusActive,
+		Quota:        req.Quota,
+		PolicyRepo:   req.PolicyRepo,
+		PolicyBranch: req.PolicyBranch,
+	}
+	if req.Template != "" {
+		if policyBackend == "git" {
+			http.Error(w, "templates are not supported with the git policy backend", http.StatusBadRequest)
+			return
+		}
+		b, ps, err := loadTemplate(req.Template)
+		if errors.Is(err, errTemplateNotFound) {
+			http.Error(w, "template not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "invalid template: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		tenant.Template, tenant.TemplateVersion = req.Template, b.Revision
+		if policyBackend == "db" {
+			sub, _ := r.Context().Value("subject").(string)
+			err = saveSnapshot(store.WithAuthor(r.Context(), sub), req.TenantID, ps.Snapshot())
+		} else {
+			err = writeTemplateFiles(req.TenantID, b)
+		}
+		if err != nil {
+			http.Error(w, "failed to copy template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	if err := backend.SaveTenant(r.Context(), tenant); err != nil {
 		http.Error(w, "failed to save tenant", http.StatusInternalServerError)
 		return
 	}
-	if policyBackend == "db" {
-		loadPoliciesFromDB(r.Context(), req.TenantID)
+	t, err := loadTenant(r.Context(), req.TenantID)
+	if err != nil {
+		http.Error(w, "failed to load policies: "+err.Error(), http.StatusUnprocessableEntity)
+		return
 	}
+	tenants.Add(t)
 	auditLogger.Log(logger.Entry{
 		Level:         "info",
 		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
@@ -611,7 +1231,9 @@ func CreateTenant(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tenant)
 }
 
-// DeleteTenant removes a tenant and associated policy data.
+// DeleteTenant unloads a tenant and marks it pending deletion. Its policy
+// data is removed once TENANT_RETENTION has passed, until when
+// UndeleteTenant restores it; with a retention of zero it is removed at once.
 func DeleteTenant(w http.ResponseWriter, r *http.Request) {
 	_, span := tracer.Start(r.Context(), "DeleteTenant")
 	defer span.End()
@@ -620,16 +1242,27 @@ func DeleteTenant(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	tenant, err := backend.LoadTenant(r.Context(), req.TenantID)
-	if err != nil {
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	meta, err := backend.LoadTenant(r.Context(), req.TenantID)
+	if err != nil || meta.State() == tenant.StatusPendingDeletion {
 		http.Error(w, "tenant not found", http.StatusNotFound)
 		return
 	}
-	delete(policyStores, req.TenantID)
-	delete(policyGraphs, req.TenantID)
-	delete(policyEngines, req.TenantID)
-	delete(policyFiles, req.TenantID)
-	backend.DeleteTenant(r.Context(), req.TenantID)
+	if tenantRetention > 0 {
+		now := time.Now().UTC()
+		meta.Status, meta.DeletedAt = tenant.StatusPendingDeletion, &now
+		err = backend.SaveTenant(r.Context(), meta)
+	} else {
+		err = backend.DeleteTenant(r.Context(), req.TenantID)
+	}
+	if err != nil {
+		http.Error(w, "failed to delete tenant", http.StatusInternalServerError)
+		return
+	}
+	tenants.Remove(req.TenantID)
+	forgetTenant(req.TenantID)
 	auditLogger.Log(logger.Entry{
 		Level:         "info",
 		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
@@ -638,13 +1271,16 @@ func DeleteTenant(w http.ResponseWriter, r *http.Request) {
 		Decision:      "success",
 	})
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tenant)
+	json.NewEncoder(w).Encode(meta)
 }
 
 // ListTenants returns all registered tenants.
 func ListTenants(w http.ResponseWriter, r *http.Request) {
 	ctx, span := tracer.Start(r.Context(), "ListTenants")
 	defer span.End()
+	if _, ok := requireAdmin(w, r, ""); !ok {
+		return
+	}
 	list, err := backend.ListTenants(ctx)
 	if err != nil {
 		auditLogger.Log(logger.Entry{
@@ -676,11 +1312,20 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
 		return
 	}
+	if ts, ok := tenants.Get(r.Context(), req.TenantID); ok {
+		if max := ts.Meta().Quota.MaxUsers; max > 0 {
+			if users, err := identityProvider.List(r.Context(), req.TenantID); err == nil && len(users) >= max {
+				http.Error(w, "user quota exceeded", http.StatusForbidden)
+				return
+			}
+		}
+	}
 	u, err := identityProvider.Create(r.Context(), req.TenantID, req.Username, req.Roles)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	invalidateDecisions(req.TenantID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
@@ -699,6 +1344,7 @@ func AssignRole(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	invalidateDecisions(req.TenantID)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -716,6 +1362,7 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	invalidateDecisions(req.TenantID)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -757,3 +1404,532 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
+
+// decodeRelationship reads and checks a RelationshipRequest, writing an error
+// response on failure.
+func decodeRelationship(w http.ResponseWriter, r *http.Request) (RelationshipRequest, bool) {
+	var req RelationshipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	if req.TenantID == "" || req.Src == "" || req.Dst == "" {
+		http.Error(w, "missing tenantID, src or dst", http.StatusBadRequest)
+		return req, false
+	}
+	if !strings.Contains(req.Src, ":") || !strings.Contains(req.Dst, ":") {
+		http.Error(w, "src and dst must be typed nodes such as user:alice", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// AddRelationship persists a graph edge and adds it to the tenant's graph.
+func AddRelationship(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRelationship(w, r)
+	if !ok {
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if !withinEdgeQuota(ts, req.Src, req.Dst) {
+		http.Error(w, "edge quota exceeded", http.StatusForbidden)
+		return
+	}
+	if err := backend.SaveEdge(r.Context(), req.TenantID, req.Src, req.Dst); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ts.Graph.AddRelation(req.Src, req.Dst)
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "graph_add",
+		Resource:      req.Src + " -> " + req.Dst,
+		Decision:      "success",
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// RemoveRelationship deletes a graph edge from the backend and the tenant's
+// graph.
+func RemoveRelationship(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRelationship(w, r)
+	if !ok {
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	g, ok := tenants.Graph(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if err := backend.DeleteEdge(r.Context(), req.TenantID, req.Src, req.Dst); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !g.RemoveRelation(req.Src, req.Dst) {
+		http.Error(w, "relationship not found", http.StatusNotFound)
+		return
+	}
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "graph_remove",
+		Resource:      req.Src + " -> " + req.Dst,
+		Decision:      "success",
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListRelationships returns the edges of a tenant's graph sorted by source
+// and destination.
+func ListRelationships(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	g, ok := tenants.Graph(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	out := []Relationship{}
+	for src, targets := range g.List() {
+		for _, dst := range targets {
+			out = append(out, Relationship{Src: src, Dst: dst})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Src != out[j].Src {
+			return out[i].Src < out[j].Src
+		}
+		return out[i].Dst < out[j].Dst
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// requireDelegator authorizes changes to a delegation made by delegator.
+// Users may manage their own delegations; anyone else needs an administrator
+// role.
+func requireDelegator(w http.ResponseWriter, r *http.Request, tenantID, delegator string) (string, bool) {
+	sub, _ := r.Context().Value("subject").(string)
+	tenant, _ := r.Context().Value("tenant").(string)
+	if sub != "" && tenant == tenantID && sub == delegator {
+		return sub, true
+	}
+	return requireAdmin(w, r, tenantID)
+}
+
+// CreateDelegation stores a delegation and applies it to the tenant's
+// engine.
+func CreateDelegation(w http.ResponseWriter, r *http.Request) {
+	var req DelegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	sub, ok := requireDelegator(w, r, req.TenantID, req.Delegator)
+	if !ok {
+		return
+	}
+	engine, ok := tenants.Engine(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	d := policy.Delegation{
+		ID:        req.ID,
+		Delegator: req.Delegator,
+		Delegate:  req.Delegate,
+		Actions:   req.Actions,
+		Resources: req.Resources,
+		CreatedAt: time.Now().UTC(),
+	}
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if req.ExpiresAt != "" {
+		exp, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			http.Error(w, "invalid expiresAt: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !exp.After(d.CreatedAt) {
+			http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+			return
+		}
+		exp = exp.UTC()
+		d.ExpiresAt = &exp
+	}
+	if err := d.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := backend.SaveDelegation(r.Context(), req.TenantID, d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	engine.AddDelegation(d)
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "delegation_create",
+		Resource:      d.Delegator + "->" + d.Delegate,
+		Decision:      "success",
+		PolicyID:      d.ID,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// RevokeDelegation deletes a delegation. Decisions relying on it are no
+// longer served from the cache.
+func RevokeDelegation(w http.ResponseWriter, r *http.Request) {
+	var req RevokeDelegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	// Only the tenant's own users and its administrators may look up its
+	// delegations; which one a user may revoke is checked once it is found.
+	sub, _ := r.Context().Value("subject").(string)
+	caller, _ := r.Context().Value("tenant").(string)
+	member := sub != "" && caller == req.TenantID
+	if !member {
+		var ok bool
+		if sub, ok = requireAdmin(w, r, req.TenantID); !ok {
+			return
+		}
+	}
+	engine, ok := tenants.Engine(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	var found *policy.Delegation
+	for _, d := range engine.Delegations() {
+		if d.ID == req.ID {
+			found = &d
+			break
+		}
+	}
+	if found == nil {
+		http.Error(w, "delegation not found", http.StatusNotFound)
+		return
+	}
+	if member {
+		if sub, ok = requireDelegator(w, r, req.TenantID, found.Delegator); !ok {
+			return
+		}
+	}
+	if err := backend.DeleteDelegation(r.Context(), req.TenantID, req.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	engine.RevokeDelegation(req.ID)
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "delegation_revoke",
+		Resource:      found.Delegator + "->" + found.Delegate,
+		Decision:      "success",
+		PolicyID:      found.ID,
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListDelegations returns the tenant's delegations, including expired ones.
+func ListDelegations(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	engine, ok := tenants.Engine(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(engine.Delegations())
+}
+
+// decodeTuple reads a TupleRequest and parses its tuple, checking it against
+// the tenant's schema. It writes an error response on failure.
+func decodeTuple(w http.ResponseWriter, r *http.Request) (TupleRequest, rebac.Tuple, bool) {
+	var req TupleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return req, rebac.Tuple{}, false
+	}
+	if req.TenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return req, rebac.Tuple{}, false
+	}
+	t, err := rebac.ParseTuple(req.Tuple)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, rebac.Tuple{}, false
+	}
+	return req, t, true
+}
+
+// WriteTuple persists a relationship tuple as a graph edge from the object
+// relation to the subject.
+func WriteTuple(w http.ResponseWriter, r *http.Request) {
+	req, t, ok := decodeTuple(w, r)
+	if !ok {
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if err := ts.Engine.Schema().ValidateTuple(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !withinEdgeQuota(ts, t.ObjectRelation(), t.Subject.String()) {
+		http.Error(w, "edge quota exceeded", http.StatusForbidden)
+		return
+	}
+	if err := backend.SaveEdge(r.Context(), req.TenantID, t.ObjectRelation(), t.Subject.String()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ts.Graph.AddRelation(t.ObjectRelation(), t.Subject.String())
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "tuple_write",
+		Resource:      t.String(),
+		Decision:      "success",
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteTuple removes a relationship tuple.
+func DeleteTuple(w http.ResponseWriter, r *http.Request) {
+	req, t, ok := decodeTuple(w, r)
+	if !ok {
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	g, ok := tenants.Graph(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if err := backend.DeleteEdge(r.Context(), req.TenantID, t.ObjectRelation(), t.Subject.String()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !g.RemoveRelation(t.ObjectRelation(), t.Subject.String()) {
+		http.Error(w, "tuple not found", http.StatusNotFound)
+		return
+	}
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "tuple_delete",
+		Resource:      t.String(),
+		Decision:      "success",
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListTuples returns the tenant's relationship tuples in sorted text form,
+// optionally restricted to one object.
+func ListTuples(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	object := r.URL.Query().Get("object")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	g, ok := tenants.Graph(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	out := []string{}
+	for src, targets := range g.List() {
+		for _, dst := range targets {
+			t, err := rebac.ParseTuple(src + "@" + dst)
+			if err != nil {
+				// Plain relationship edges are not tuples.
+				continue
+			}
+			if object != "" && t.Object.String() != object {
+				continue
+			}
+			out = append(out, t.String())
+		}
+	}
+	sort.Strings(out)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// CheckRelation reports whether a subject holds a relation on an object.
+// Callers may check their own `user:` subject; checking other subjects
+// requires an administrator.
+func CheckRelation(w http.ResponseWriter, r *http.Request) {
+	var req RelationCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenantID, _ := r.Context().Value("tenant").(string)
+	caller, _ := r.Context().Value("subject").(string)
+	if tenantID == "" || caller == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.TenantID == "" {
+		req.TenantID = tenantID
+	}
+	if req.Subject == "" {
+		req.Subject = "user:" + caller
+	}
+	if req.TenantID != tenantID || req.Subject != "user:"+caller {
+		if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+			return
+		}
+	}
+	if req.Object == "" || req.Relation == "" {
+		http.Error(w, "missing object or relation", http.StatusBadRequest)
+		return
+	}
+	engine, ok := tenants.Engine(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	allowed, err := engine.Check(req.Object, req.Relation, req.Subject)
+	resp := RelationCheckResponse{Allowed: allowed}
+	if err != nil {
+		if !errors.Is(err, rebac.ErrMaxDepth) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SetResourceAttributes stores the attributes of a resource, replacing any
+// previous attributes.
+func SetResourceAttributes(w http.ResponseWriter, r *http.Request) {
+	var req ResourceAttributesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TenantID == "" || req.Resource == "" {
+		http.Error(w, "missing tenantID or resource", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if err := backend.SaveResourceAttributes(r.Context(), req.TenantID, req.Resource, req.Attributes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateDecisions(req.TenantID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetResourceAttributes returns the stored attributes of a resource.
+func GetResourceAttributes(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	resource := r.URL.Query().Get("resource")
+	if tenantID == "" || resource == "" {
+		http.Error(w, "missing tenantID or resource", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	attrs, err := backend.LoadResourceAttributes(r.Context(), tenantID, resource)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ResourceAttributesRequest{TenantID: tenantID, Resource: resource, Attributes: attrs})
+}
+
+// DeleteResourceAttributes removes the stored attributes of a resource.
+func DeleteResourceAttributes(w http.ResponseWriter, r *http.Request) {
+	var req ResourceAttributesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TenantID == "" || req.Resource == "" {
+		http.Error(w, "missing tenantID or resource", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if err := backend.DeleteResourceAttributes(r.Context(), req.TenantID, req.Resource); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invalidateDecisions(req.TenantID)
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,12 +1,18 @@
 package api
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/bradtumy/authorization-service/pkg/vc"
 )
 
 func init() {
@@ -14,20 +20,94 @@ func init() {
 	os.Setenv("POLICY_FILE", "../configs/policies.yaml")
 }
 
-func TestAuthorizeValidRequest(t *testing.T) {
-	body := `{"credential":{"@context":["https://www.w3.org/2018/credentials/v1"],"id":"https://example.org/credentials/3732","type":["VerifiableCredential"],"issuer":"https://example.org/issuers/14","issuanceDate":"2023-01-01T19:23:24Z","credentialSubject":{"id":"user1","role":"admin"}},"context":{"action":"read","resource":"file1","environment":{"tenantID":"default"},"consent":"granted"}}`
-	r := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(body))
+const authorizeContext = `{"action":"read","resource":"file1","environment":{"tenantID":"default"},"consent":"granted"}`
+
+// testCredential returns a credential for user1 issued by a new did:key
+// issuer, and the issuer's key and verification method.
+func testCredential() (map[string]interface{}, ed25519.PrivateKey, string) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	did := vc.KeyDID(pub)
+	cred := map[string]interface{}{
+		"@context":          []string{"https://www.w3.org/2018/credentials/v1"},
+		"id":                "https://example.org/credentials/3732",
+		"type":              []string{"VerifiableCredential"},
+		"issuer":            did,
+		"issuanceDate":      "2023-01-01T19:23:24Z",
+		"credentialSubject": map[string]interface{}{"id": "user1", "role": "admin"},
+	}
+	return cred, priv, did + "#" + strings.TrimPrefix(did, "did:key:")
+}
+
+// authorize posts credential to /authorize and returns the decision.
+func authorize(t *testing.T, credential interface{}) map[string]interface{} {
+	t.Helper()
+	data, _ := json.Marshal(credential)
+	body := `{"credential":` + string(data) + `,"context":` + authorizeContext + `}`
 	w := httptest.NewRecorder()
-	Authorize(w, r)
+	Authorize(w, httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(body)))
 	if w.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", w.Code)
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
 	var resp map[string]interface{}
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if allow, ok := resp["allow"].(bool); !ok || !allow {
-		t.Fatalf("expected allow decision")
+	return resp
+}
+
+// trustIssuers sets the default tenant's trusted issuers.
+func trustIssuers(t *testing.T, issuers ...string) {
+	t.Helper()
+	identityProvider = local.New(false)
+	data, _ := json.Marshal(TrustedIssuersRequest{TenantID: defaultTenant, Issuers: issuers})
+	w := httptest.NewRecorder()
+	SetTrustedIssuers(w, platformRequest(http.MethodPost, "/tenant/issuers", strings.NewReader(string(data))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set trusted issuers: got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthorizeValidRequest(t *testing.T) {
+	cred, key, method := testCredential()
+	issuer := cred["issuer"].(string)
+	trustIssuers(t, issuer)
+	defer trustIssuers(t)
+
+	token, err := vc.SignJWT(cred, key, method)
+	if err != nil {
+		t.Fatalf("sign jwt: %v", err)
+	}
+	if resp := authorize(t, token); resp["allow"] != true {
+		t.Fatalf("expected JWT-VC to be allowed, got %v", resp)
+	}
+	if err := vc.AddProof(cred, key, method, time.Now()); err != nil {
+		t.Fatalf("add proof: %v", err)
+	}
+	if resp := authorize(t, cred); resp["allow"] != true {
+		t.Fatalf("expected Data Integrity credential to be allowed, got %v", resp)
+	}
+}
+
+func TestAuthorizeRejectedCredential(t *testing.T) {
+	cred, key, method := testCredential()
+	if resp := authorize(t, cred); resp["allow"] != false || resp["reason"] != vc.ReasonUntrustedIssuer {
+		t.Fatalf("expected untrusted issuer, got %v", resp)
+	}
+	trustIssuers(t, cred["issuer"].(string))
+	defer trustIssuers(t)
+	if resp := authorize(t, cred); resp["allow"] != false || resp["reason"] != vc.ReasonMissingProof {
+		t.Fatalf("expected missing proof, got %v", resp)
+	}
+	cred["expirationDate"] = "2024-01-01T00:00:00Z"
+	if err := vc.AddProof(cred, key, method, time.Now()); err != nil {
+		t.Fatalf("add proof: %v", err)
+	}
+	if resp := authorize(t, cred); resp["allow"] != false || resp["reason"] != vc.ReasonExpired {
+		t.Fatalf("expected expired credential, got %v", resp)
+	}
+	cred["credentialSubject"].(map[string]interface{})["role"] = "viewer"
+	if resp := authorize(t, cred); resp["allow"] != false || resp["reason"] != vc.ReasonInvalidSignature {
+		t.Fatalf("expected invalid signature, got %v", resp)
 	}
 }
 
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+)
+
+const bundleTestPolicy = `roles:
+  - name: reader
+    policies: [p1]
+policies:
+  - id: p1
+    resource: ["files/*"]
+    action: ["read"]
+    effect: allow
+`
+
+func TestLoadPolicyFileRequiresSignature(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.Mkdir(src, 0755)
+	os.WriteFile(filepath.Join(src, "main.yaml"), []byte(bundleTestPolicy), 0644)
+	data, err := bundle.Build(src)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	archive := filepath.Join(dir, "policies.tar.gz")
+	os.WriteFile(archive, data, 0644)
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	_, untrusted, _ := ed25519.GenerateKey(rand.Reader)
+	var audit bytes.Buffer
+	prevKeys, prevLogger := trustedKeys, auditLogger
+	trustedKeys, auditLogger = []ed25519.PublicKey{pub}, logger.New(&audit, logger.LevelDebug)
+	ts := newTenantState("bundle-test", "")
+	tenants.Add(ts)
+	defer func() {
+		trustedKeys, auditLogger = prevKeys, prevLogger
+		tenants.Remove("bundle-test")
+	}()
+
+	writeSig := func(key ed25519.PrivateKey) {
+		sig, _ := json.Marshal(bundle.Sign(data, key))
+		os.WriteFile(archive+bundle.SignatureSuffix, sig, 0644)
+	}
+	ctx := context.Background()
+	if err := loadPolicyFile(ctx, ts, src); err == nil {
+		t.Fatalf("expected unsigned directory to be rejected")
+	}
+	if err := loadPolicyFile(ctx, ts, archive); err == nil {
+		t.Fatalf("expected archive without signature to be rejected")
+	}
+	writeSig(untrusted)
+	if err := loadPolicyFile(ctx, ts, archive); err == nil {
+		t.Fatalf("expected archive signed by an untrusted key to be rejected")
+	}
+	if len(ts.Store.Policies) != 0 {
+		t.Fatalf("expected rejected bundles not to be activated")
+	}
+	if n := strings.Count(audit.String(), `"action":"bundle_reject"`); n != 3 {
+		t.Fatalf("expected 3 audited rejections, got %d: %s", n, audit.String())
+	}
+	if !strings.Contains(audit.String(), bundle.Digest(data)) {
+		t.Fatalf("expected rejection audit to include the digest: %s", audit.String())
+	}
+
+	writeSig(priv)
+	if err := loadPolicyFile(ctx, ts, archive); err != nil {
+		t.Fatalf("load signed bundle: %v", err)
+	}
+	ps := ts.Store
+	if _, ok := ps.Policies["p1"]; !ok || ps.Digest != bundle.Digest(data) {
+		t.Fatalf("expected signed bundle to be active, got digest %q", ps.Digest)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/vc"
+)
+
+var (
+	// credentialVerifier verifies the credentials presented to /authorize.
+	// Its client bounds the did:web and status list fetches made while a
+	// request waits.
+	credentialVerifier = &vc.Verifier{Resolver: &vc.Resolver{Client: &http.Client{Timeout: 5 * time.Second}}}
+	// globalIssuers are the issuers of VC_TRUSTED_ISSUERS, trusted by every
+	// tenant in addition to its own.
+	globalIssuers []string
+)
+
+// trustedIssuers returns the issuers whose credentials tenant t accepts.
+func trustedIssuers(t *TenantState) []string {
+	return append(append([]string(nil), globalIssuers...), t.Meta().TrustedIssuers...)
+}
+
+// verifyCredential verifies a credential presented for tenant t. Rejected
+// credentials yield a deny decision giving the reason.
+func verifyCredential(ctx context.Context, t *TenantState, raw json.RawMessage) (*vc.Credential, policy.Decision, bool) {
+	cred, err := credentialVerifier.Verify(ctx, raw, trustedIssuers(t))
+	if err != nil {
+		reason := vc.ReasonMalformed
+		var rej *vc.Error
+		if errors.As(err, &rej) {
+			reason = rej.Reason
+		}
+		return nil, policy.Decision{Allow: false, Reason: reason}, false
+	}
+	return cred, policy.Decision{}, true
+}
+
+// validIssuer reports whether did names an issuer the verifier can resolve.
+func validIssuer(did string) bool {
+	return strings.HasPrefix(did, "did:key:") || strings.HasPrefix(did, "did:web:")
+}
+
+// SetTrustedIssuers replaces the DIDs whose credentials /authorize accepts
+// for a tenant.
+func SetTrustedIssuers(w http.ResponseWriter, r *http.Request) {
+	var req TrustedIssuersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	for _, did := range req.Issuers {
+		if !validIssuer(did) {
+			http.Error(w, "unsupported issuer "+did+": expected a did:key or did:web DID", http.StatusBadRequest)
+			return
+		}
+	}
+	meta, err := updateTenant(r.Context(), req.TenantID, func(t *Tenant) { t.TrustedIssuers = req.Issuers })
+	writeTenantUpdate(w, r, req.TenantID, "tenant_trusted_issuers", meta, err)
+}
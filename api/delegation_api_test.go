@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+func userRequest(user, method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	ctx := context.WithValue(r.Context(), "subject", user)
+	ctx = context.WithValue(ctx, "tenant", "default")
+	return r.WithContext(ctx)
+}
+
+func TestDelegationEndpoints(t *testing.T) {
+	identityProvider = local.New(false)
+	check := func(resource string) policy.Decision {
+		t.Helper()
+		w := httptest.NewRecorder()
+		CheckAccess(w, userRequest("user2", http.MethodPost, "/check-access",
+			`{"tenantID":"default","subject":"user2","resource":"`+resource+`","action":"write"}`))
+		var dec policy.Decision
+		if err := json.NewDecoder(w.Body).Decode(&dec); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return dec
+	}
+
+	w := httptest.NewRecorder()
+	CreateDelegation(w, userRequest("user2", http.MethodPost, "/delegations/create",
+		`{"tenantID":"default","delegator":"user1","delegate":"user2"}`))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected delegating someone else's access to be forbidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	CreateDelegation(w, userRequest("user1", http.MethodPost, "/delegations/create",
+		`{"tenantID":"default","delegator":"user1","delegate":"user2","actions":["write"],"resources":["reports/*"],"expiresAt":"2999-01-01T00:00:00Z"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var d policy.Delegation
+	if err := json.NewDecoder(w.Body).Decode(&d); err != nil || d.ID == "" {
+		t.Fatalf("decode delegation: %v %#v", err, d)
+	}
+	if stored, _ := backend.LoadDelegations(context.Background(), "default"); len(stored) != 1 {
+		t.Fatalf("expected delegation to be persisted, got %v", stored)
+	}
+
+	dec := check("reports/q1")
+	if !dec.Allow || strings.Join(dec.DelegationChain, ",") != "user2,user1" {
+		t.Fatalf("expected delegated write, got %#v", dec)
+	}
+	if dec := check("payroll/q1"); dec.Allow {
+		t.Fatalf("expected write outside the delegated scope to be denied")
+	}
+
+	// Callers outside the tenant are refused before the delegation is
+	// looked up, so they cannot probe which IDs exist.
+	outsider := userRequest("user1", http.MethodPost, "/delegations/revoke", `{"tenantID":"default","id":"`+d.ID+`"}`)
+	outsider = outsider.WithContext(context.WithValue(outsider.Context(), "tenant", "other"))
+	w = httptest.NewRecorder()
+	RevokeDelegation(w, outsider)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected revocation from another tenant to be forbidden, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	RevokeDelegation(w, userRequest("user2", http.MethodPost, "/delegations/revoke",
+		`{"tenantID":"default","id":"`+d.ID+`"}`))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected the delegate's revocation to be forbidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	RevokeDelegation(w, userRequest("user1", http.MethodPost, "/delegations/revoke",
+		`{"tenantID":"default","id":"`+d.ID+`"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("revoke: expected 200, got %d", w.Code)
+	}
+	if dec := check("reports/q1"); dec.Allow {
+		t.Fatalf("expected revoked delegation to deny, got %#v", dec)
+	}
+	if stored, _ := backend.LoadDelegations(context.Background(), "default"); len(stored) != 0 {
+		t.Fatalf("expected delegation to be deleted, got %v", stored)
+	}
+}
@@ -0,0 +1,817 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/identity"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
+	"github.com/bradtumy/authorization-service/pkg/store"
+	"github.com/bradtumy/authorization-service/pkg/tenant"
+)
+
+// TenantArchiveVersion is the format version of the archives written by
+// ExportTenant. ImportTenant rejects archives of other versions.
+const TenantArchiveVersion = 1
+
+// Conflict handling modes of ImportTenant.
+const (
+	ConflictFail      = "fail"
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+)
+
+// errDryRun aborts PolicyStore.Apply after validation.
+var errDryRun = errors.New("dry run")
+
+// TenantArchive holds what is needed to recreate a tenant in another
+// deployment, whatever the store and policy backends of either side.
+type TenantArchive struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Tenant     Tenant    `json:"tenant"`
+	// Combining and Schema are the combining algorithm and relation schema
+	// the policies are evaluated with.
+	Combining string            `json:"combining,omitempty"`
+	Schema    []rebac.Namespace `json:"schema,omitempty"`
+	Policies  []policy.Policy   `json:"policies"`
+	Roles     []policy.Role     `json:"roles"`
+	// PolicyUsers are the users declared with the tenant's policies; Users
+	// are the users of the identity provider.
+	PolicyUsers        []policy.User       `json:"policyUsers"`
+	Users              []identity.User     `json:"users"`
+	Edges              []ArchiveEdge       `json:"edges"`
+	Delegations        []policy.Delegation `json:"delegations,omitempty"`
+	ResourceAttributes []ArchiveResource   `json:"resourceAttributes,omitempty"`
+}
+
+// ArchiveEdge is a relationship graph edge in a TenantArchive.
+type ArchiveEdge struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// ArchiveResource holds the stored attributes of a resource in a
+// TenantArchive.
+type ArchiveResource struct {
+	Resource   string                 `json:"resource"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// ImportTenantRequest imports an archive into a tenant, which is created if
+// it does not exist.
+type ImportTenantRequest struct {
+	// TenantID is the tenant to import into. It defaults to the archived
+	// tenant's ID.
+	TenantID string `json:"tenantID,omitempty"`
+	// IDMap renames items of the archive. References to renamed items are
+	// renamed too.
+	IDMap ImportIDMap `json:"idMap,omitempty"`
+	// OnConflict decides what happens to archived items that differ from
+	// existing ones: fail (the default), skip or overwrite.
+	OnConflict string        `json:"onConflict,omitempty"`
+	DryRun     bool          `json:"dryRun,omitempty"`
+	Archive    TenantArchive `json:"archive"`
+}
+
+// ImportCounts counts the items of an import by kind.
+type ImportCounts struct {
+	Policies           int `json:"policies"`
+	Roles              int `json:"roles"`
+	PolicyUsers        int `json:"policyUsers"`
+	Users              int `json:"users"`
+	Edges              int `json:"edges"`
+	Delegations        int `json:"delegations"`
+	ResourceAttributes int `json:"resourceAttributes"`
+}
+
+// ImportConflict names an archived item that differs from an existing one.
+type ImportConflict struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// ImportReport describes what an import changed, or would change when it is
+// a dry run. Skipped items are identical to existing ones or were kept
+// because of a conflict.
+type ImportReport struct {
+	TenantID  string           `json:"tenantID"`
+	DryRun    bool             `json:"dryRun"`
+	Created   bool             `json:"created"`
+	Added     ImportCounts     `json:"added"`
+	Updated   ImportCounts     `json:"updated"`
+	Skipped   ImportCounts     `json:"skipped"`
+	Conflicts []ImportConflict `json:"conflicts,omitempty"`
+}
+
+// importPlan is the outcome of merging an archive into a tenant.
+type importPlan struct {
+	report ImportReport
+	// meta is the tenant's record and existing the loaded tenant, unless
+	// the tenant is created.
+	meta     Tenant
+	existing *TenantState
+	// merged is the tenant's resulting policy set and write the items of it
+	// to store.
+	merged policy.Snapshot
+	write  policy.Snapshot
+	// users are created and assign have their roles replaced.
+	users  []identity.User
+	assign []identity.User
+	edges  []ArchiveEdge
+	// delegations and resources are the delegations and resource
+	// attributes to write.
+	delegations map[string]policy.Delegation
+	resources   map[string]ArchiveResource
+	// combining and schema are the archive's settings. settingsConflict
+	// reports an existing tenant whose settings differ; they are never
+	// overwritten.
+	combining        policy.CombiningAlgorithm
+	schema           []rebac.Namespace
+	settingsConflict bool
+	// edgeCount and userCount are the numbers of edges and identity users
+	// after the import.
+	edgeCount int
+	userCount int
+}
+
+// ExportTenant returns an archive of a tenant's metadata, policy settings,
+// policies, roles, users, relationship graph, delegations and stored resource
+// attributes.
+func ExportTenant(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	meta, err := backend.LoadTenant(r.Context(), tenantID)
+	if err != nil || meta.State() == tenant.StatusPendingDeletion {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	a, err := exportTenant(r.Context(), ts, meta)
+	if err != nil {
+		http.Error(w, "failed to export tenant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      tenantID,
+		Action:        "tenant_export",
+		Decision:      "success",
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", url.PathEscape(tenantID)+".json"))
+	json.NewEncoder(w).Encode(a)
+}
+
+func exportTenant(ctx context.Context, t *TenantState, meta Tenant) (TenantArchive, error) {
+	a := TenantArchive{Version: TenantArchiveVersion, ExportedAt: time.Now().UTC(), Tenant: meta}
+	a.Tenant.Status, a.Tenant.DeletedAt = "", nil
+	snap := t.Store.Snapshot()
+	a.Combining, a.Schema = string(t.Store.Algorithm), t.Store.SchemaNamespaces()
+	for _, id := range sortedKeys(snap.Policies) {
+		a.Policies = append(a.Policies, snap.Policies[id])
+	}
+	for _, name := range sortedKeys(snap.Roles) {
+		a.Roles = append(a.Roles, snap.Roles[name])
+	}
+	for _, name := range sortedKeys(snap.Users) {
+		a.PolicyUsers = append(a.PolicyUsers, snap.Users[name])
+	}
+	users, err := identityProvider.List(ctx, t.ID)
+	if err != nil {
+		return TenantArchive{}, err
+	}
+	for _, u := range users {
+		u.TenantID = ""
+		a.Users = append(a.Users, u)
+	}
+	sort.Slice(a.Users, func(i, j int) bool { return a.Users[i].Username < a.Users[j].Username })
+	edges, err := backend.LoadEdges(ctx, t.ID)
+	if err != nil {
+		return TenantArchive{}, err
+	}
+	for _, e := range edges {
+		a.Edges = append(a.Edges, ArchiveEdge{Src: e.Src, Dst: e.Dst})
+	}
+	sort.Slice(a.Edges, func(i, j int) bool {
+		if a.Edges[i].Src != a.Edges[j].Src {
+			return a.Edges[i].Src < a.Edges[j].Src
+		}
+		return a.Edges[i].Dst < a.Edges[j].Dst
+	})
+	delegations, err := backend.LoadDelegations(ctx, t.ID)
+	if err != nil {
+		return TenantArchive{}, err
+	}
+	sort.Slice(delegations, func(i, j int) bool { return delegations[i].ID < delegations[j].ID })
+	if len(delegations) > 0 {
+		a.Delegations = delegations
+	}
+	attrs, err := backend.ListResourceAttributes(ctx, t.ID)
+	if err != nil {
+		return TenantArchive{}, err
+	}
+	for _, resource := range sortedKeys(attrs) {
+		a.ResourceAttributes = append(a.ResourceAttributes, ArchiveResource{Resource: resource, Attributes: attrs[resource]})
+	}
+	return a, nil
+}
+
+// ImportTenant imports an archive written by ExportTenant. Nothing is
+// written when the request is a dry run, when an item conflicts and
+// OnConflict is fail, or when the result is invalid or over quota.
+func ImportTenant(w http.ResponseWriter, r *http.Request) {
+	var req ImportTenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Archive.Version != TenantArchiveVersion {
+		http.Error(w, fmt.Sprintf("unsupported archive version %d", req.Archive.Version), http.StatusBadRequest)
+		return
+	}
+	mode := req.OnConflict
+	if mode == "" {
+		mode = ConflictFail
+	}
+	if mode != ConflictFail && mode != ConflictSkip && mode != ConflictOverwrite {
+		http.Error(w, "onConflict must be fail, skip or overwrite", http.StatusBadRequest)
+		return
+	}
+	if policyBackend == "git" {
+		http.Error(w, "import is not supported with the git policy backend", http.StatusBadRequest)
+		return
+	}
+	if _, err := policy.ParseCombiningAlgorithm(req.Archive.Combining); err != nil {
+		http.Error(w, "invalid archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	a := remapArchive(req.Archive, req.IDMap)
+	tenantID := req.TenantID
+	if tenantID == "" {
+		tenantID = a.Tenant.ID
+	}
+	if tenantID == "" || tenantID == "." || tenantID == ".." {
+		http.Error(w, "tenantID is required", http.StatusBadRequest)
+		return
+	}
+	sub, ok := requireAdmin(w, r, tenantID)
+	if !ok {
+		return
+	}
+	if reservedTenantID(tenantID) {
+		http.Error(w, "tenantID is reserved", http.StatusBadRequest)
+		return
+	}
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	plan, err := planImport(r.Context(), tenantID, a, mode)
+	if errors.Is(err, errTenantDeleted) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read tenant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	plan.report.DryRun = req.DryRun
+	if len(plan.report.Conflicts) > 0 && mode == ConflictFail && !req.DryRun {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(plan.report)
+		return
+	}
+	if plan.settingsConflict && mode == ConflictOverwrite && !req.DryRun {
+		http.Error(w, "the combining algorithm and schema of an existing tenant cannot be overwritten", http.StatusConflict)
+		return
+	}
+	if plan.existing != nil && policyBackend == "file" && plan.existing.File != "" && plan.existing.File != tenantConfigFile(tenantID) {
+		http.Error(w, "the tenant's policies are served from "+plan.existing.File+" and cannot be imported into", http.StatusConflict)
+		return
+	}
+	if plan.existing == nil && policyBackend == "db" && (plan.combining != policy.DefaultCombiningAlgorithm || len(plan.schema) > 0) {
+		http.Error(w, "the db policy backend cannot store a combining algorithm or schema", http.StatusUnprocessableEntity)
+		return
+	}
+	if err := plan.checkQuota(); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	target := policy.NewPolicyStore()
+	if plan.existing != nil {
+		target = plan.existing.Store
+	} else if err := target.Configure(plan.combining, plan.schema); err != nil {
+		http.Error(w, "invalid archive: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := target.Apply(plan.merged, func() error { return errDryRun }); !errors.Is(err, errDryRun) {
+		http.Error(w, "invalid archive: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, d := range plan.delegations {
+		if err := d.Validate(); err != nil {
+			http.Error(w, "invalid archive: delegation "+d.ID+": "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if !req.DryRun {
+		err := plan.apply(store.WithAuthor(r.Context(), sub))
+		if errors.Is(err, policy.ErrStaleSnapshot) {
+			http.Error(w, "policies changed during the import", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to import tenant: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	entry := logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      tenantID,
+		Subject:       sub,
+		Action:        "tenant_import",
+		Resource:      a.Tenant.ID,
+		Decision:      "success",
+	}
+	if req.DryRun {
+		entry.Reason = "dry run"
+	}
+	auditLogger.Log(entry)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan.report)
+}
+
+// planImport merges an archive into the tenant's current state.
+func planImport(ctx context.Context, tenantID string, a TenantArchive, mode string) (*importPlan, error) {
+	p := &importPlan{
+		report: ImportReport{TenantID: tenantID},
+		merged: policy.Snapshot{Policies: map[string]policy.Policy{}, Roles: map[string]policy.Role{}, Users: map[string]policy.User{}},
+		write:  policy.Snapshot{Policies: map[string]policy.Policy{}, Roles: map[string]policy.Role{}, Users: map[string]policy.User{}},
+	}
+	p.combining, _ = policy.ParseCombiningAlgorithm(a.Combining)
+	p.schema = a.Schema
+	existingEdges := map[ArchiveEdge]bool{}
+	existingUsers := map[string]identity.User{}
+	existingDelegations := map[string]policy.Delegation{}
+	existingResources := map[string]ArchiveResource{}
+	meta, err := backend.LoadTenant(ctx, tenantID)
+	if err == nil {
+		if meta.State() == tenant.StatusPendingDeletion {
+			return nil, errTenantDeleted
+		}
+		ts, ok := tenants.Get(ctx, tenantID)
+		if !ok {
+			return nil, errors.New("tenant cannot be loaded")
+		}
+		p.meta, p.existing, p.merged = meta, ts, ts.Store.Snapshot()
+		edges, err := backend.LoadEdges(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			existingEdges[ArchiveEdge{Src: e.Src, Dst: e.Dst}] = true
+		}
+		users, err := identityProvider.List(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			existingUsers[u.Username] = u
+		}
+		delegations, err := backend.LoadDelegations(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range delegations {
+			existingDelegations[d.ID] = d
+		}
+		attrs, err := backend.ListResourceAttributes(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		for resource, v := range attrs {
+			existingResources[resource] = ArchiveResource{Resource: resource, Attributes: v}
+		}
+		if ts.Store.Algorithm != p.combining || !sameSchema(ts.Store.SchemaNamespaces(), p.schema) {
+			p.report.Conflicts = append(p.report.Conflicts, ImportConflict{Kind: "settings", ID: tenantID})
+			p.settingsConflict = true
+		}
+	} else {
+		p.report.Created = true
+		p.meta = a.Tenant
+		p.meta.ID, p.meta.CreatedAt = tenantID, time.Now()
+		p.meta.Status, p.meta.DeletedAt = tenant.StatusActive, nil
+	}
+
+	// Stores return missing role and policy lists as empty or as none;
+	// compare them alike.
+	for i, u := range a.PolicyUsers {
+		if u.Roles == nil {
+			a.PolicyUsers[i].Roles = []string{}
+		}
+	}
+	for name, u := range p.merged.Users {
+		if u.Roles == nil {
+			u.Roles = []string{}
+			p.merged.Users[name] = u
+		}
+	}
+	for i, role := range a.Roles {
+		if role.Policies == nil {
+			a.Roles[i].Policies = []string{}
+		}
+	}
+	for name, role := range p.merged.Roles {
+		if role.Policies == nil {
+			role.Policies = []string{}
+			p.merged.Roles[name] = role
+		}
+	}
+
+	r := &p.report
+	mergeItems(r, mode, "policy", a.Policies, func(x policy.Policy) string { return x.ID },
+		p.merged.Policies, p.write.Policies, &r.Added.Policies, &r.Updated.Policies, &r.Skipped.Policies)
+	mergeItems(r, mode, "role", a.Roles, func(x policy.Role) string { return x.Name },
+		p.merged.Roles, p.write.Roles, &r.Added.Roles, &r.Updated.Roles, &r.Skipped.Roles)
+	mergeItems(r, mode, "policyUser", a.PolicyUsers, func(x policy.User) string { return x.Username },
+		p.merged.Users, p.write.Users, &r.Added.PolicyUsers, &r.Updated.PolicyUsers, &r.Skipped.PolicyUsers)
+	p.delegations = map[string]policy.Delegation{}
+	mergeItems(r, mode, "delegation", a.Delegations, func(x policy.Delegation) string { return x.ID },
+		existingDelegations, p.delegations, &r.Added.Delegations, &r.Updated.Delegations, &r.Skipped.Delegations)
+	p.resources = map[string]ArchiveResource{}
+	mergeItems(r, mode, "resourceAttributes", a.ResourceAttributes, func(x ArchiveResource) string { return x.Resource },
+		existingResources, p.resources, &r.Added.ResourceAttributes, &r.Updated.ResourceAttributes, &r.Skipped.ResourceAttributes)
+
+	for _, u := range a.Users {
+		cur, ok := existingUsers[u.Username]
+		switch {
+		case !ok:
+			existingUsers[u.Username] = u
+			p.users = append(p.users, u)
+			r.Added.Users++
+		case sameRoles(cur.Roles, u.Roles):
+			r.Skipped.Users++
+		default:
+			r.Conflicts = append(r.Conflicts, ImportConflict{Kind: "user", ID: u.Username})
+			if mode == ConflictOverwrite {
+				p.assign = append(p.assign, u)
+				r.Updated.Users++
+			} else {
+				r.Skipped.Users++
+			}
+		}
+	}
+	p.userCount = len(existingUsers)
+	for _, e := range a.Edges {
+		if existingEdges[e] {
+			r.Skipped.Edges++
+			continue
+		}
+		existingEdges[e] = true
+		p.edges = append(p.edges, e)
+		r.Added.Edges++
+	}
+	p.edgeCount = len(existingEdges)
+	return p, nil
+}
+
+// mergeItems merges archived items into current, recording the ones to
+// write and counting them in the report.
+func mergeItems[T any](r *ImportReport, mode, kind string, items []T, key func(T) string, current, write map[string]T, added, updated, skipped *int) {
+	for _, item := range items {
+		k := key(item)
+		cur, ok := current[k]
+		switch {
+		case !ok:
+			current[k], write[k] = item, item
+			*added++
+		case etag(cur) == etag(item):
+			*skipped++
+		default:
+			r.Conflicts = append(r.Conflicts, ImportConflict{Kind: kind, ID: k})
+			if mode == ConflictOverwrite {
+				current[k], write[k] = item, item
+				*updated++
+			} else {
+				*skipped++
+			}
+		}
+	}
+}
+
+// checkQuota reports an error if the import adds items beyond the tenant's
+// quota.
+func (p *importPlan) checkQuota() error {
+	q, added := p.meta.Quota, p.report.Added
+	switch {
+	case q.MaxPolicies > 0 && added.Policies > 0 && len(p.merged.Policies) > q.MaxPolicies:
+		return errors.New("policy quota exceeded")
+	case q.MaxUsers > 0 && added.PolicyUsers > 0 && len(p.merged.Users) > q.MaxUsers,
+		q.MaxUsers > 0 && added.Users > 0 && p.userCount > q.MaxUsers:
+		return errors.New("user quota exceeded")
+	case q.MaxEdges > 0 && added.Edges > 0 && p.edgeCount > q.MaxEdges:
+		return errors.New("edge quota exceeded")
+	}
+	return nil
+}
+
+// apply writes the plan. A tenant created by the import is removed again if
+// it cannot be completed.
+func (p *importPlan) apply(ctx context.Context) error {
+	id := p.report.TenantID
+	if p.existing == nil {
+		err := p.create(ctx)
+		if err != nil {
+			tenants.Remove(id)
+			forgetTenant(id)
+			backend.DeleteTenant(ctx, id)
+		}
+		return err
+	}
+	// The tenant's settings are kept; read them before Apply locks the store.
+	alg, schema := p.existing.Store.Algorithm, p.existing.Store.SchemaNamespaces()
+	commit := func() error {
+		if policyBackend == "file" {
+			if err := writeTenantPolicyFile(id, alg, schema, p.merged); err != nil {
+				return err
+			}
+		}
+		return saveSnapshot(ctx, id, p.write)
+	}
+	if err := p.existing.Store.Apply(p.merged, commit); err != nil {
+		return err
+	}
+	p.existing.setItemVersions(nil)
+	for _, e := range p.edges {
+		if err := backend.SaveEdge(ctx, id, e.Src, e.Dst); err != nil {
+			return err
+		}
+		p.existing.Graph.AddRelation(e.Src, e.Dst)
+	}
+	if err := p.writeExtras(ctx); err != nil {
+		return err
+	}
+	for _, d := range p.delegations {
+		p.existing.Engine.AddDelegation(d)
+	}
+	if err := p.writeUsers(ctx); err != nil {
+		return err
+	}
+	invalidateDecisions(id)
+	if policyBackend == "file" && p.existing.File == "" {
+		// The tenant had no policy file; serve it from the one written.
+		t, err := loadTenant(ctx, id)
+		if err != nil {
+			return err
+		}
+		tenants.Add(t)
+	}
+	return nil
+}
+
+func (p *importPlan) create(ctx context.Context) error {
+	id := p.report.TenantID
+	if err := backend.SaveTenant(ctx, p.meta); err != nil {
+		return err
+	}
+	if policyBackend == "file" {
+		if err := writeTenantPolicyFile(id, p.combining, p.schema, p.merged); err != nil {
+			return err
+		}
+	}
+	if err := saveSnapshot(ctx, id, p.write); err != nil {
+		return err
+	}
+	for _, e := range p.edges {
+		if err := backend.SaveEdge(ctx, id, e.Src, e.Dst); err != nil {
+			return err
+		}
+	}
+	if err := p.writeExtras(ctx); err != nil {
+		return err
+	}
+	if err := p.writeUsers(ctx); err != nil {
+		return err
+	}
+	t, err := loadTenant(ctx, id)
+	if err != nil {
+		return err
+	}
+	tenants.Add(t)
+	return nil
+}
+
+// writeExtras stores the plan's delegations and resource attributes.
+func (p *importPlan) writeExtras(ctx context.Context) error {
+	id := p.report.TenantID
+	for _, d := range p.delegations {
+		if err := backend.SaveDelegation(ctx, id, d); err != nil {
+			return err
+		}
+	}
+	for _, res := range p.resources {
+		if err := backend.SaveResourceAttributes(ctx, id, res.Resource, res.Attributes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *importPlan) writeUsers(ctx context.Context) error {
+	id := p.report.TenantID
+	for _, u := range p.users {
+		if _, err := identityProvider.Create(ctx, id, u.Username, u.Roles); err != nil {
+			return err
+		}
+	}
+	for _, u := range p.assign {
+		if err := identityProvider.AssignRoles(ctx, id, u.Username, u.Roles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportIDMap renames archived items, one map from old to new name per
+// kind, so that a policy, a role and a user sharing a name are renamed
+// independently.
+type ImportIDMap struct {
+	Policies map[string]string `json:"policies,omitempty"`
+	Roles    map[string]string `json:"roles,omitempty"`
+	// Users renames both policy users and identity provider users.
+	Users map[string]string `json:"users,omitempty"`
+	// Nodes renames graph nodes named whole. A renamed user or role also
+	// renames the graph node user:<name> or group:<name>.
+	Nodes map[string]string `json:"nodes,omitempty"`
+}
+
+func (m ImportIDMap) empty() bool {
+	return len(m.Policies) == 0 && len(m.Roles) == 0 && len(m.Users) == 0 && len(m.Nodes) == 0
+}
+
+// rename returns the new name of s in m, or s.
+func rename(m map[string]string, s string) string {
+	if v, ok := m[s]; ok {
+		return v
+	}
+	return s
+}
+
+// renameAll renames every name of list found in m.
+func renameAll(m map[string]string, list []string) []string {
+	if list == nil {
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = rename(m, s)
+	}
+	return out
+}
+
+// remapArchive renames the policy IDs, role names, usernames and graph
+// nodes of an archive found in m, including references to them. Graph nodes
+// not named in m.Nodes are renamed by their ID: user:<name> as a user and
+// group:<name> as a role, keeping any #relation suffix.
+func remapArchive(a TenantArchive, m ImportIDMap) TenantArchive {
+	if m.empty() {
+		return a
+	}
+	node := func(s string) string {
+		if v, ok := m.Nodes[s]; ok {
+			return v
+		}
+		for prefix, names := range map[string]map[string]string{"user:": m.Users, "group:": m.Roles} {
+			if rest, ok := strings.CutPrefix(s, prefix); ok {
+				name, rel, hasRel := strings.Cut(rest, "#")
+				if hasRel {
+					return prefix + rename(names, name) + "#" + rel
+				}
+				return prefix + rename(names, name)
+			}
+		}
+		return s
+	}
+	out := a
+	out.Policies = make([]policy.Policy, len(a.Policies))
+	for i, p := range a.Policies {
+		p.ID = rename(m.Policies, p.ID)
+		if p.Subjects != nil {
+			subjects := make([]policy.Subject, len(p.Subjects))
+			for j, s := range p.Subjects {
+				subjects[j] = policy.Subject{Role: rename(m.Roles, s.Role)}
+			}
+			p.Subjects = subjects
+		}
+		out.Policies[i] = p
+	}
+	out.Roles = make([]policy.Role, len(a.Roles))
+	for i, r := range a.Roles {
+		out.Roles[i] = policy.Role{Name: rename(m.Roles, r.Name), Policies: renameAll(m.Policies, r.Policies), Inherits: renameAll(m.Roles, r.Inherits)}
+	}
+	out.PolicyUsers = make([]policy.User, len(a.PolicyUsers))
+	for i, u := range a.PolicyUsers {
+		out.PolicyUsers[i] = policy.User{Username: rename(m.Users, u.Username), Roles: renameAll(m.Roles, u.Roles)}
+	}
+	out.Users = make([]identity.User, len(a.Users))
+	for i, u := range a.Users {
+		out.Users[i] = identity.User{Username: rename(m.Users, u.Username), Roles: renameAll(m.Roles, u.Roles)}
+	}
+	out.Edges = make([]ArchiveEdge, len(a.Edges))
+	for i, e := range a.Edges {
+		out.Edges[i] = ArchiveEdge{Src: node(e.Src), Dst: node(e.Dst)}
+	}
+	if a.Delegations != nil {
+		out.Delegations = make([]policy.Delegation, len(a.Delegations))
+		for i, d := range a.Delegations {
+			d.Delegator, d.Delegate = rename(m.Users, d.Delegator), rename(m.Users, d.Delegate)
+			out.Delegations[i] = d
+		}
+	}
+	return out
+}
+
+// writeTenantPolicyFile writes snap as the policy file a file-backed tenant
+// is served from.
+func writeTenantPolicyFile(tenantID string, alg policy.CombiningAlgorithm, schema []rebac.Namespace, snap policy.Snapshot) error {
+	doc := policy.Document{Schema: schema}
+	if alg != policy.DefaultCombiningAlgorithm {
+		doc.Combining = string(alg)
+	}
+	for _, name := range sortedKeys(snap.Roles) {
+		doc.Roles = append(doc.Roles, snap.Roles[name])
+	}
+	for _, name := range sortedKeys(snap.Users) {
+		doc.Users = append(doc.Users, snap.Users[name])
+	}
+	for _, id := range sortedKeys(snap.Policies) {
+		doc.Policies = append(doc.Policies, snap.Policies[id])
+	}
+	data, err := doc.Marshal()
+	if err != nil {
+		return err
+	}
+	file := tenantConfigFile(tenantID)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// sameSchema reports whether a and b declare the same namespaces.
+func sameSchema(a, b []rebac.Namespace) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	return etag(a) == etag(b)
+}
+
+// sameRoles reports whether a and b hold the same roles in any order.
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/tenant"
+)
+
+func TestTenantExportImport(t *testing.T) {
+	t.Setenv("TENANT_CONFIG_DIR", t.TempDir())
+	const src, dst = "exportSource", "exportTarget"
+	ctx := context.Background()
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(ctx, src, "export-admin", []string{"PolicyAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	CreateTenant(httptest.NewRecorder(), platformRequest(http.MethodPost, "/tenant/create",
+		strings.NewReader(fmt.Sprintf(`{"tenantID":"%s","quota":{"maxUsers":10}}`, src))))
+	for _, id := range []string{src, dst} {
+		defer backend.DeleteTenant(ctx, id)
+		defer tenants.Remove(id)
+	}
+	request := func(handler http.HandlerFunc, target, body string) {
+		t.Helper()
+		r := userRequest("export-admin", http.MethodPost, target, body)
+		r = r.WithContext(context.WithValue(r.Context(), "tenant", src))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code >= 300 {
+			t.Fatalf("%s: got %d: %s", target, w.Code, w.Body.String())
+		}
+	}
+	request(policyItems.create, "/policies/create", `{"tenantID":"exportSource","policy":{"id":"read-docs","resource":["docs/*"],"action":["read"],"effect":"allow"}}`)
+	request(roleItems.create, "/roles/create", `{"tenantID":"exportSource","role":{"name":"reader","policies":["read-docs"]}}`)
+	request(userItems.create, "/policy-users/create", `{"tenantID":"exportSource","user":{"username":"alice","roles":["reader"]}}`)
+	request(AddRelationship, "/graph/add", `{"tenantID":"exportSource","src":"user:alice","dst":"group:eng"}`)
+	request(policyItems.create, "/policies/create", `{"tenantID":"exportSource","policy":{"id":"write-docs","resource":["docs/*"],"action":["write"],"effect":"allow"}}`)
+	request(roleItems.create, "/roles/create", `{"tenantID":"exportSource","role":{"name":"editor","policies":["write-docs"]}}`)
+	request(userItems.create, "/policy-users/create", `{"tenantID":"exportSource","user":{"username":"carol"}}`)
+	request(AddRelationship, "/graph/add", `{"tenantID":"exportSource","src":"user:carol","dst":"group:editor"}`)
+	if err := backend.SaveDelegation(ctx, src, policy.Delegation{ID: "d1", Delegator: "alice", Delegate: "carol", Actions: []string{"read"}}); err != nil {
+		t.Fatalf("save delegation: %v", err)
+	}
+	if err := backend.SaveResourceAttributes(ctx, src, "docs/a", map[string]interface{}{"classification": "internal"}); err != nil {
+		t.Fatalf("save attributes: %v", err)
+	}
+	defer backend.DeleteResourceAttributes(ctx, src, "docs/a")
+	defer backend.DeleteResourceAttributes(ctx, dst, "docs/a")
+
+	w := httptest.NewRecorder()
+	ExportTenant(w, platformRequest(http.MethodGet, "/tenant/export?tenantID="+src, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var archive TenantArchive
+	if err := json.NewDecoder(w.Body).Decode(&archive); err != nil {
+		t.Fatalf("decode archive: %v", err)
+	}
+	if archive.Version != TenantArchiveVersion || archive.Tenant.Quota.MaxUsers != 10 || len(archive.Policies) != 2 ||
+		len(archive.Roles) != 2 || len(archive.PolicyUsers) != 2 || len(archive.Users) != 1 || len(archive.Edges) != 2 ||
+		archive.Combining != string(policy.DenyOverrides) || len(archive.Delegations) != 1 || len(archive.ResourceAttributes) != 1 {
+		t.Fatalf("unexpected archive %#v", archive)
+	}
+
+	importTenant := func(body ImportTenantRequest) (int, ImportReport) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		ImportTenant(w, platformRequest(http.MethodPost, "/tenant/import", strings.NewReader(string(data))))
+		var report ImportReport
+		json.NewDecoder(w.Body).Decode(&report)
+		return w.Code, report
+	}
+	req := ImportTenantRequest{TenantID: dst, IDMap: ImportIDMap{Users: map[string]string{"alice": "bob", "carol": "carl"}, Roles: map[string]string{"editor": "writer"}}, DryRun: true, Archive: archive}
+	code, report := importTenant(req)
+	if code != http.StatusOK || !report.Created || report.Added.Policies != 2 || report.Added.Edges != 2 ||
+		report.Added.Delegations != 1 || report.Added.ResourceAttributes != 1 {
+		t.Fatalf("dry run: unexpected report %d %#v", code, report)
+	}
+	if _, err := backend.LoadTenant(ctx, dst); err == nil {
+		t.Fatalf("expected dry run to leave the target tenant missing")
+	}
+
+	req.DryRun = false
+	if code, _ := importTenant(req); code != http.StatusOK {
+		t.Fatalf("import: expected status 200, got %d", code)
+	}
+	engine, ok := tenants.Engine(ctx, dst)
+	if !ok {
+		t.Fatalf("expected imported tenant to be loaded")
+	}
+	if dec := engine.Evaluate("bob", "docs/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected remapped user to be allowed, got %#v", dec)
+	}
+	if g, _ := tenants.Graph(ctx, dst); !g.HasRelation("user:bob", "group:eng") {
+		t.Fatalf("expected remapped edge to be imported")
+	}
+	// carol held editor through the graph; both were renamed.
+	if dec := engine.Evaluate("carl", "docs/a", "write", nil); !dec.Allow || dec.Role != "writer" {
+		t.Fatalf("expected renamed user to hold the renamed role through the graph, got %#v", dec)
+	}
+	if dec := engine.Evaluate("carl", "docs/a", "read", nil); !dec.Allow || dec.Delegator != "bob" {
+		t.Fatalf("expected remapped delegation to apply, got %#v", dec)
+	}
+	if attrs, _ := backend.LoadResourceAttributes(ctx, dst, "docs/a"); attrs["classification"] != "internal" {
+		t.Fatalf("expected resource attributes to be imported, got %v", attrs)
+	}
+	if meta, err := backend.LoadTenant(ctx, dst); err != nil || meta.Quota.MaxUsers != 10 {
+		t.Fatalf("unexpected imported tenant %#v (%v)", meta, err)
+	}
+
+	code, report = importTenant(req)
+	if code != http.StatusOK || report.Created || report.Skipped.Policies != 2 || len(report.Conflicts) != 0 {
+		t.Fatalf("reimport: unexpected report %d %#v", code, report)
+	}
+
+	settings := req
+	settings.Archive.Combining = string(policy.PermitOverrides)
+	if code, report := importTenant(settings); code != http.StatusConflict || len(report.Conflicts) != 1 || report.Conflicts[0].Kind != "settings" {
+		t.Fatalf("settings: unexpected report %d %#v", code, report)
+	}
+
+	archive.Policies[0].Effect = "deny"
+	req.Archive = archive
+	code, report = importTenant(req)
+	if code != http.StatusConflict || len(report.Conflicts) != 1 || report.Conflicts[0].ID != "read-docs" {
+		t.Fatalf("conflict: unexpected report %d %#v", code, report)
+	}
+	req.OnConflict = ConflictSkip
+	if code, report = importTenant(req); code != http.StatusOK || report.Skipped.Policies != 2 {
+		t.Fatalf("skip: unexpected report %d %#v", code, report)
+	}
+	if dec := engine.Evaluate("bob", "docs/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected skipped conflict to keep the existing policy")
+	}
+	req.OnConflict = ConflictOverwrite
+	if code, report = importTenant(req); code != http.StatusOK || report.Updated.Policies != 1 {
+		t.Fatalf("overwrite: unexpected report %d %#v", code, report)
+	}
+	if dec := engine.Evaluate("bob", "docs/a", "read", nil); dec.Allow {
+		t.Fatalf("expected overwritten policy to deny bob")
+	}
+	// The tenant's policy file is rewritten, so a reload keeps the import.
+	file := policy.NewPolicyStore()
+	if err := file.LoadPolicies(tenantConfigFile(dst)); err != nil {
+		t.Fatalf("load policy file: %v", err)
+	}
+	if p, ok := file.GetPolicy("read-docs"); !ok || p.Effect != "deny" {
+		t.Fatalf("expected overwritten policy in the tenant's policy file, got %#v", p)
+	}
+
+	// Tenants served from another file cannot be imported into.
+	const served = "exportServed"
+	if err := backend.SaveTenant(ctx, tenant.Tenant{ID: served}); err != nil {
+		t.Fatalf("save tenant: %v", err)
+	}
+	defer backend.DeleteTenant(ctx, served)
+	tenants.Add(newTenantState(served, filepath.Join(t.TempDir(), "policies.yaml")))
+	defer tenants.Remove(served)
+	req.TenantID = served
+	if code, _ := importTenant(req); code != http.StatusConflict {
+		t.Fatalf("expected import into a tenant served from another file to return 409, got %d", code)
+	}
+}
+
+func TestRemapArchiveByKind(t *testing.T) {
+	a := TenantArchive{
+		Policies:    []policy.Policy{{ID: "editor", Subjects: []policy.Subject{{Role: "editor"}}}},
+		Roles:       []policy.Role{{Name: "editor", Policies: []string{"editor"}}},
+		PolicyUsers: []policy.User{{Username: "editor", Roles: []string{"editor"}}},
+		Edges:       []ArchiveEdge{{Src: "user:editor", Dst: "group:editor"}},
+	}
+	out := remapArchive(a, ImportIDMap{Roles: map[string]string{"editor": "writer"}})
+	if p := out.Policies[0]; p.ID != "editor" || p.Subjects[0].Role != "writer" {
+		t.Fatalf("expected only the subject role to be renamed, got %#v", p)
+	}
+	if r := out.Roles[0]; r.Name != "writer" || r.Policies[0] != "editor" {
+		t.Fatalf("expected only the role name to be renamed, got %#v", r)
+	}
+	if u := out.PolicyUsers[0]; u.Username != "editor" || u.Roles[0] != "writer" {
+		t.Fatalf("expected only the user's role to be renamed, got %#v", u)
+	}
+	if e := out.Edges[0]; e.Src != "user:editor" || e.Dst != "group:writer" {
+		t.Fatalf("expected only the group node to be renamed, got %#v", e)
+	}
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/policystore"
+)
+
+// gitSource tracks the repository a tenant's policies are pulled from when
+// POLICY_BACKEND=git.
+type gitSource struct {
+	// mu serializes syncs of the tenant's repository.
+	mu     sync.Mutex
+	tenant *TenantState
+	repo   *policystore.GitStore
+	// rejected is the last commit that failed to load. It is not retried
+	// until the branch moves on.
+	rejected string
+}
+
+// gitTimeout bounds each clone or fetch of a tenant repository.
+const gitTimeout = 2 * time.Minute
+
+var (
+	// gitMu guards gitSources; syncs lock their source instead.
+	gitMu      sync.Mutex
+	gitSources = make(map[string]*gitSource)
+	// gitCloneOptions configures tenant clones. Tests allow file URLs.
+	gitCloneOptions policystore.CloneOptions
+)
+
+// PolicyVersion reports the policy revision active for a tenant.
+type PolicyVersion struct {
+	TenantID string `json:"tenantID"`
+	Commit   string `json:"commit,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	// StoreRevision is the backend revision loaded when policies are served
+	// from the database.
+	StoreRevision int64 `json:"storeRevision,omitempty"`
+}
+
+// gitWorkDir returns the directory holding the local clones, from
+// POLICY_GIT_WORKDIR.
+func gitWorkDir() string {
+	if dir := os.Getenv("POLICY_GIT_WORKDIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "authz-policies")
+}
+
+// cloneTenantRepo clones a tenant's policy repository into the work
+// directory, replacing an earlier clone, and loads its head revision.
+func cloneTenantRepo(ctx context.Context, t *TenantState, repoURL, branch string) error {
+	dir := filepath.Join(gitWorkDir(), url.PathEscape(t.ID))
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	cloneCtx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	repo, err := policystore.CloneRepo(cloneCtx, repoURL, branch, dir, gitCloneOptions)
+	if err != nil {
+		return err
+	}
+	if err := loadGitPolicies(ctx, t, repo); err != nil {
+		return err
+	}
+	gitMu.Lock()
+	gitSources[t.ID] = &gitSource{tenant: t, repo: repo}
+	gitMu.Unlock()
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(ctx),
+		TenantID:      t.ID,
+		Action:        "reload",
+		Resource:      repoURL,
+		Decision:      "success",
+		Revision:      t.Store.Revision,
+		Commit:        repo.CommitSHA(),
+	})
+	return nil
+}
+
+// loadGitPolicies loads the bundle checked out in repo, under
+// POLICY_GIT_PATH, stamped with its commit.
+func loadGitPolicies(ctx context.Context, t *TenantState, repo *policystore.GitStore) error {
+	b, err := readPolicyBundle(ctx, t.ID, filepath.Join(repo.Path(), os.Getenv("POLICY_GIT_PATH")))
+	if err != nil {
+		return err
+	}
+	b.Commit = repo.CommitSHA()
+	return t.Store.LoadBundle(b)
+}
+
+// syncGitPolicies pulls a tenant's repository and activates the new head if
+// it differs from the active commit. A revision that fails to load is rolled
+// back: the working tree returns to the active commit, the previous policies
+// stay in effect and the failure is audited. It reports whether a new
+// revision was activated. Syncs of different tenants run concurrently and
+// each fetch is bounded by gitTimeout.
+func syncGitPolicies(ctx context.Context, tenantID string) (bool, error) {
+	gitMu.Lock()
+	src, ok := gitSources[tenantID]
+	gitMu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("tenant %s has no policy repository", tenantID)
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	active := src.repo.CommitSHA()
+	fetchCtx, cancel := context.WithTimeout(ctx, gitTimeout)
+	err := src.repo.PullLatest(fetchCtx)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	head := src.repo.CommitSHA()
+	if head == active || head == src.rejected {
+		if head != active {
+			return false, src.repo.ResetTo(active)
+		}
+		return false, nil
+	}
+	if err := loadGitPolicies(ctx, src.tenant, src.repo); err != nil {
+		src.rejected = head
+		auditLogger.Log(logger.Entry{
+			Level:         "error",
+			CorrelationID: middleware.CorrelationIDFromContext(ctx),
+			TenantID:      tenantID,
+			Action:        "policy_rollback",
+			Decision:      "deny",
+			Reason:        err.Error(),
+			Commit:        head,
+		})
+		if rerr := src.repo.ResetTo(active); rerr != nil {
+			return false, rerr
+		}
+		return false, err
+	}
+	src.rejected = ""
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(ctx),
+		TenantID:      tenantID,
+		Action:        "reload",
+		Decision:      "success",
+		Revision:      src.tenant.Store.Revision,
+		Commit:        head,
+	})
+	return true, nil
+}
+
+// watchGitPolicies pulls every tenant repository at the interval set by
+// POLICY_GIT_POLL_INTERVAL, each tenant in its own goroutine.
+func watchGitPolicies(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		gitMu.Lock()
+		ids := make([]string, 0, len(gitSources))
+		for id := range gitSources {
+			ids = append(ids, id)
+		}
+		gitMu.Unlock()
+		var wg sync.WaitGroup
+		for _, id := range ids {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				syncGitPolicies(context.Background(), id)
+			}(id)
+		}
+		wg.Wait()
+	}
+}
+
+// GitWebhook pulls a tenant's policy repository on demand, typically from a
+// push hook, instead of waiting for the next poll.
+func GitWebhook(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "GitWebhook")
+	defer span.End()
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if policyBackend != "git" {
+		http.Error(w, "policy backend is not git", http.StatusConflict)
+		return
+	}
+	t, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if _, err := syncGitPolicies(r.Context(), req.TenantID); err != nil {
+		http.Error(w, "failed to update policies: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policyVersion(t))
+}
+
+// GetPolicyVersion returns the commit, manifest revision and archive digest
+// of the caller's tenant's active policies.
+func GetPolicyVersion(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := r.Context().Value("tenant").(string)
+	tenantID := r.URL.Query().Get("tenantID")
+	if tenantID == "" {
+		tenantID = tenant
+	}
+	if tenantID != tenant {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	t, ok := tenants.Get(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policyVersion(t))
+}
+
+func policyVersion(t *TenantState) PolicyVersion {
+	ps := t.Store
+	return PolicyVersion{TenantID: t.ID, Commit: ps.Commit, Revision: ps.Revision, Digest: ps.Digest, StoreRevision: storeRevision(t.ID)}
+}
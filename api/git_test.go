@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/pkg/policystore"
+)
+
+const gitTestPolicy = `users:
+  - username: git-user
+    roles: [reader]
+roles:
+  - name: reader
+    policies: [p1]
+policies:
+  - id: p1
+    resource: ["files/*"]
+    action: ["%s"]
+    effect: allow
+`
+
+// commitPolicy writes main.yaml to the repository at dir and commits it,
+// returning the new commit SHA.
+func commitPolicy(t *testing.T, dir, data string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "main.yaml"), []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, args := range [][]string{
+		{"add", "main.yaml"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "update"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("rev-parse: %v", err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitPolicySource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	origin := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", origin).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	first := commitPolicy(t, origin, strings.Replace(gitTestPolicy, "%s", "read", 1))
+
+	t.Setenv("POLICY_GIT_WORKDIR", t.TempDir())
+	var audit bytes.Buffer
+	prevBackend, prevLogger := policyBackend, auditLogger
+	policyBackend, auditLogger = "git", logger.New(&audit, logger.LevelDebug)
+	const tenantID = "git-test"
+	ts := newTenantState(tenantID, "")
+	tenants.Add(ts)
+	defer func() {
+		policyBackend, auditLogger = prevBackend, prevLogger
+		tenants.Remove(tenantID)
+		forgetTenant(tenantID)
+	}()
+
+	ctx := context.Background()
+	if err := cloneTenantRepo(ctx, ts, "file://"+origin, "main"); err == nil {
+		t.Fatalf("expected file URLs to be refused")
+	}
+	gitCloneOptions = policystore.CloneOptions{Schemes: []string{"file"}}
+	defer func() { gitCloneOptions = policystore.CloneOptions{} }()
+	if err := cloneTenantRepo(ctx, ts, "file://"+origin, "main"); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	dec := ts.Engine.Evaluate("git-user", "files/a", "read", nil)
+	if !dec.Allow || dec.Commit != first {
+		t.Fatalf("expected allow stamped with %s, got %#v", first, dec)
+	}
+
+	second := commitPolicy(t, origin, strings.Replace(gitTestPolicy, "%s", "write", 1))
+	if changed, err := syncGitPolicies(ctx, tenantID); err != nil || !changed {
+		t.Fatalf("sync: changed=%v err=%v", changed, err)
+	}
+	dec = ts.Engine.Evaluate("git-user", "files/a", "write", nil)
+	if !dec.Allow || dec.Commit != second {
+		t.Fatalf("expected allow stamped with %s, got %#v", second, dec)
+	}
+
+	bad := commitPolicy(t, origin, "policies: [{id: p1, effect: maybe}]\n")
+	if _, err := syncGitPolicies(ctx, tenantID); err == nil {
+		t.Fatalf("expected invalid revision to be rejected")
+	}
+	if ts.Store.Commit != second || gitSources[tenantID].repo.CommitSHA() != second {
+		t.Fatalf("expected rollback to %s, store at %s", second, ts.Store.Commit)
+	}
+	if !strings.Contains(audit.String(), `"action":"policy_rollback"`) || !strings.Contains(audit.String(), bad) {
+		t.Fatalf("expected audited rollback of %s: %s", bad, audit.String())
+	}
+	if changed, err := syncGitPolicies(ctx, tenantID); err != nil || changed {
+		t.Fatalf("expected rejected revision not to be retried: changed=%v err=%v", changed, err)
+	}
+
+	third := commitPolicy(t, origin, strings.Replace(gitTestPolicy, "%s", "delete", 1))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/policies/version", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "tenant", tenantID))
+	if _, err := syncGitPolicies(ctx, tenantID); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	GetPolicyVersion(w, r)
+	var v PolicyVersion
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil || v.Commit != third {
+		t.Fatalf("expected version %s, got %#v (%v)", third, v, err)
+	}
+}
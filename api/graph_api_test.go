@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+)
+
+func adminRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	ctx := context.WithValue(r.Context(), "subject", "graph-admin")
+	ctx = context.WithValue(ctx, "tenant", "default")
+	return r.WithContext(ctx)
+}
+
+// defaultGraph returns the default tenant's relationship graph.
+func defaultGraph(t *testing.T) *graph.Graph {
+	ts, ok := tenants.Loaded(defaultTenant)
+	if !ok {
+		t.Fatalf("default tenant is not loaded")
+	}
+	return ts.Graph
+}
+
+func TestRelationshipEndpoints(t *testing.T) {
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(context.Background(), "default", "graph-admin", []string{"TenantAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	AddRelationship(w, adminRequest(http.MethodPost, "/graph/add", `{"tenantID":"default","src":"user:graph-user","dst":"group:admin"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("add: expected 200, got %d", w.Code)
+	}
+	edges, err := backend.LoadEdges(context.Background(), "default")
+	if err != nil || len(edges) != 1 {
+		t.Fatalf("expected edge to be persisted, got %v %v", edges, err)
+	}
+
+	// Reloading rebuilds the graph from the backend.
+	defaultGraph(t).Replace(nil)
+	w = httptest.NewRecorder()
+	ReloadPolicies(w, adminRequest(http.MethodPost, "/reload", `{"tenantID":"default"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("reload: expected 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ListRelationships(w, adminRequest(http.MethodGet, "/graph/list?tenantID=default", ""))
+	var rels []Relationship
+	if err := json.NewDecoder(w.Body).Decode(&rels); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(rels) != 1 || rels[0].Src != "user:graph-user" || rels[0].Dst != "group:admin" {
+		t.Fatalf("unexpected relationships %#v", rels)
+	}
+
+	w = httptest.NewRecorder()
+	AddRelationship(w, adminRequest(http.MethodPost, "/graph/add", `{"tenantID":"default","src":"alice","dst":"group:admin"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected untyped node to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	RemoveRelationship(w, adminRequest(http.MethodPost, "/graph/remove", `{"tenantID":"default","src":"user:graph-user","dst":"group:admin"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("remove: expected 200, got %d", w.Code)
+	}
+	if edges, _ := backend.LoadEdges(context.Background(), "default"); len(edges) != 0 {
+		t.Fatalf("expected edge to be deleted, got %v", edges)
+	}
+	if len(defaultGraph(t).List()) != 0 {
+		t.Fatalf("expected graph to be empty")
+	}
+}
+
+func TestTupleEndpoints(t *testing.T) {
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(context.Background(), "default", "graph-admin", []string{"TenantAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	defer defaultGraph(t).Replace(nil)
+
+	for _, tuple := range []string{"document:1#viewer@group:eng#member", "group:eng#member@user:tuple-user"} {
+		w := httptest.NewRecorder()
+		WriteTuple(w, adminRequest(http.MethodPost, "/tuples/write", `{"tenantID":"default","tuple":"`+tuple+`"}`))
+		if w.Code != http.StatusOK {
+			t.Fatalf("write %s: expected 200, got %d", tuple, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	WriteTuple(w, adminRequest(http.MethodPost, "/tuples/write", `{"tenantID":"default","tuple":"document:1@user:x"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected malformed tuple to be rejected, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	ListTuples(w, adminRequest(http.MethodGet, "/tuples/list?tenantID=default&object=document:1", ""))
+	var tuples []string
+	if err := json.NewDecoder(w.Body).Decode(&tuples); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(tuples) != 1 || tuples[0] != "document:1#viewer@group:eng#member" {
+		t.Fatalf("unexpected tuples %v", tuples)
+	}
+
+	check := func(body string) RelationCheckResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		CheckRelation(w, adminRequest(http.MethodPost, "/check-relation", body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("check: expected 200, got %d", w.Code)
+		}
+		var resp RelationCheckResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+	if resp := check(`{"object":"document:1","relation":"viewer","subject":"user:tuple-user"}`); !resp.Allowed {
+		t.Fatalf("expected nested viewer to be allowed")
+	}
+
+	w = httptest.NewRecorder()
+	DeleteTuple(w, adminRequest(http.MethodPost, "/tuples/delete", `{"tenantID":"default","tuple":"group:eng#member@user:tuple-user"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", w.Code)
+	}
+	if resp := check(`{"object":"document:1","relation":"viewer","subject":"user:tuple-user"}`); resp.Allowed {
+		t.Fatalf("expected viewer to be revoked")
+	}
+	backend.DeleteEdge(context.Background(), "default", "document:1#viewer", "group:eng#member")
+}
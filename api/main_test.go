@@ -0,0 +1,15 @@
+package api
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("PLATFORM_ADMINS", platformAdmin)
+	if err := Init(); err != nil {
+		log.Fatalf("init: %v", err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+// SystemTenant is the reserved tenant holding the management authorization
+// model. Its users administer every tenant; it cannot be created, deleted
+// or imported into.
+const SystemTenant = "_system"
+
+// Management roles. Users of SystemTenant hold them for the whole platform;
+// users of any other tenant only within it, where platform-admin grants no
+// more than tenant-admin.
+const (
+	RolePlatformAdmin = "platform-admin"
+	RoleTenantAdmin   = "tenant-admin"
+	RolePolicyAuthor  = "policy-author"
+	RoleAuditor       = "auditor"
+)
+
+// Scopes of a management request, passed to the system tenant's policies as
+// the scope context value.
+const (
+	scopePlatform = "platform"
+	scopeTenant   = "tenant"
+)
+
+var (
+	// systemTenant evaluates management requests.
+	systemTenant *TenantState
+	// platformAdmins are the subjects of PLATFORM_ADMINS, platform admins
+	// of the system tenant.
+	platformAdmins map[string]bool
+)
+
+// permission is the action a management route needs on a kind of resource
+// of the target tenant.
+type permission struct {
+	resource string
+	action   string
+}
+
+// managementRoutes lists the permission of every management route, by
+// method and path.
+var managementRoutes = map[string]permission{
+	"POST /tenant/create":   {"tenants", "write"},
+	"POST /tenant/delete":   {"tenants", "write"},
+	"POST /tenant/undelete": {"tenants", "write"},
+	"POST /tenant/suspend":  {"tenants", "write"},
+	"POST /tenant/resume":   {"tenants", "write"},
+	"POST /tenant/quota":    {"tenants", "write"},
+	"POST /tenant/import":   {"tenants", "write"},
+	"POST /tenant/issuers":  {"users", "write"},
+	"GET /tenant/export":    {"tenants", "read"},
+	"GET /tenant/list":      {"tenants", "read"},
+
+	"POST /reload":                     {"policies", "write"},
+	"POST /compile":                    {"policies", "write"},
+	"POST /validate-policy":            {"policies", "read"},
+	"POST /policies/webhook":           {"policies", "write"},
+	"POST /policies/rollback":          {"policies", "write"},
+	"GET /policies/versions":           {"policies", "read"},
+	"GET /policies/diff":               {"policies", "read"},
+	"POST /policies/create":            {"policies", "write"},
+	"POST /policies/update":            {"policies", "write"},
+	"POST /policies/delete":            {"policies", "write"},
+	"GET /policies/get":                {"policies", "read"},
+	"GET /policies/list":               {"policies", "read"},
+	"POST /roles/create":               {"policies", "write"},
+	"POST /roles/update":               {"policies", "write"},
+	"POST /roles/delete":               {"policies", "write"},
+	"GET /roles/get":                   {"policies", "read"},
+	"GET /roles/list":                  {"policies", "read"},
+	"POST /policy-users/create":        {"policies", "write"},
+	"POST /policy-users/update":        {"policies", "write"},
+	"POST /policy-users/delete":        {"policies", "write"},
+	"GET /policy-users/get":            {"policies", "read"},
+	"GET /policy-users/list":           {"policies", "read"},
+	"POST /user/create":                {"users", "write"},
+	"POST /user/assign-role":           {"users", "write"},
+	"POST /user/delete":                {"users", "write"},
+	"GET /user/list":                   {"users", "read"},
+	"GET /user/get":                    {"users", "read"},
+	"POST /graph/add":                  {"relationships", "write"},
+	"POST /graph/remove":               {"relationships", "write"},
+	"GET /graph/list":                  {"relationships", "read"},
+	"POST /delegations/create":         {"relationships", "write"},
+	"POST /delegations/revoke":         {"relationships", "write"},
+	"GET /delegations/list":            {"relationships", "read"},
+	"POST /tuples/write":               {"relationships", "write"},
+	"POST /tuples/delete":              {"relationships", "write"},
+	"GET /tuples/list":                 {"relationships", "read"},
+	"POST /resource/attributes":        {"relationships", "write"},
+	"GET /resource/attributes":         {"relationships", "read"},
+	"POST /resource/attributes/delete": {"relationships", "write"},
+	"GET /query/subject-permissions":   {"access", "read"},
+	"GET /query/resource-access":       {"access", "read"},
+	"POST /check-relation":             {"access", "read"},
+}
+
+// systemPolicies and systemRoles are the management authorization model
+// evaluated in the system tenant. TenantAdmin and PolicyAdmin are the
+// administrator roles used before the model existed.
+var (
+	tenantResources = []string{"policies", "users", "relationships", "access"}
+	systemPolicies  = []policy.Policy{
+		{ID: "platform-write", Resource: []string{"tenants"}, Action: []string{"write"}, Effect: "allow", Conditions: map[string]string{"scope": scopePlatform}},
+		{ID: "platform-read", Resource: []string{"tenants"}, Action: []string{"read"}, Effect: "allow", Conditions: map[string]string{"scope": scopePlatform}},
+		{ID: "tenant-manage", Resource: tenantResources, Action: []string{"read", "write"}, Effect: "allow"},
+		{ID: "tenant-read", Resource: tenantResources, Action: []string{"read"}, Effect: "allow"},
+		{ID: "policy-write", Resource: []string{"policies"}, Action: []string{"read", "write"}, Effect: "allow"},
+	}
+	systemRoles = []policy.Role{
+		{Name: RolePlatformAdmin, Policies: []string{"platform-write", "platform-read"}, Inherits: []string{RoleTenantAdmin}},
+		{Name: RoleTenantAdmin, Policies: []string{"tenant-manage"}},
+		{Name: RolePolicyAuthor, Policies: []string{"policy-write"}},
+		{Name: RoleAuditor, Policies: []string{"platform-read", "tenant-read"}},
+		{Name: "TenantAdmin", Inherits: []string{RoleTenantAdmin}},
+		{Name: "PolicyAdmin", Inherits: []string{RoleTenantAdmin}},
+	}
+)
+
+// newSystemTenant loads the management authorization model into a tenant of
+// its own, which is not part of the registry.
+func newSystemTenant() (*TenantState, error) {
+	t := newTenantState(SystemTenant, "")
+	if err := t.Store.ReplaceAll(systemPolicies, systemRoles, nil); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// requireAdmin authorizes the caller for the management route being served,
+// on tenantID or, when it is empty, the caller's own tenant. Denials are
+// audited and answered with 401 or 403.
+func requireAdmin(w http.ResponseWriter, r *http.Request, tenantID string) (string, bool) {
+	sub, _ := r.Context().Value("subject").(string)
+	caller, _ := r.Context().Value("tenant").(string)
+	if sub == "" || caller == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	if tenantID == "" {
+		tenantID = caller
+	}
+	route := r.Method + " " + r.URL.Path
+	dec := authorizeManagement(r.Context(), caller, sub, tenantID, route)
+	if !dec.Allow {
+		auditLogger.Log(logger.Entry{
+			Level:         "warn",
+			CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+			TenantID:      tenantID,
+			Subject:       sub,
+			Action:        "manage",
+			Resource:      route,
+			Decision:      "deny",
+			PolicyID:      dec.PolicyID,
+			Reason:        dec.Reason,
+		})
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", false
+	}
+	return sub, true
+}
+
+// authorizeManagement decides whether sub, authenticated for the caller
+// tenant, may use a management route on tenantID. Users of other tenants
+// than the system tenant may only manage their own.
+func authorizeManagement(ctx context.Context, caller, sub, tenantID, route string) policy.Decision {
+	perm, ok := managementRoutes[route]
+	if !ok {
+		return policy.Decision{Reason: "not a management route"}
+	}
+	scope := scopeTenant
+	if caller == SystemTenant {
+		scope = scopePlatform
+	} else if tenantID != caller {
+		return policy.Decision{Reason: "cross-tenant request"}
+	}
+	resource := perm.resource
+	if tenantID == SystemTenant {
+		// The system tenant's users are the platform's administrators.
+		resource = "tenants"
+	}
+	roles := []string{}
+	if u, err := identityProvider.Get(ctx, caller, sub); err == nil {
+		roles = append(roles, u.Roles...)
+	}
+	if scope == scopePlatform && platformAdmins[sub] {
+		roles = append(roles, RolePlatformAdmin)
+	}
+	return systemTenant.Engine.EvaluateRequest(ctx, policy.Request{
+		Subject:  sub,
+		Resource: resource,
+		Action:   perm.action,
+		Env:      map[string]string{"tenantID": SystemTenant, "scope": scope},
+		Roles:    roles,
+	})
+}
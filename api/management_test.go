@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/gorilla/mux"
+)
+
+// platformAdmin is listed in PLATFORM_ADMINS by TestMain.
+const platformAdmin = "root"
+
+// platformRequest returns a request made by a platform admin of the system
+// tenant.
+func platformRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	ctx := context.WithValue(r.Context(), "subject", platformAdmin)
+	ctx = context.WithValue(ctx, "tenant", SystemTenant)
+	return r.WithContext(ctx)
+}
+
+func TestManagementAuthorization(t *testing.T) {
+	const id = "mgmt-test"
+	ctx := context.Background()
+	idp := local.New(false)
+	identityProvider = idp
+	for user, role := range map[string]string{
+		"admin":   RoleTenantAdmin,
+		"author":  RolePolicyAuthor,
+		"auditor": RoleAuditor,
+		"rogue":   RolePlatformAdmin,
+	} {
+		if _, err := idp.Create(ctx, id, user, []string{role}); err != nil {
+			t.Fatalf("create %s: %v", user, err)
+		}
+	}
+	if _, err := idp.Create(ctx, SystemTenant, "ops", []string{RoleAuditor}); err != nil {
+		t.Fatalf("create ops: %v", err)
+	}
+
+	cases := []struct {
+		caller, sub, target, route string
+		allow                      bool
+	}{
+		{id, "admin", id, "POST /user/create", true},
+		{id, "admin", id, "POST /policies/create", true},
+		{id, "admin", id, "POST /tenant/delete", false},
+		{id, "admin", "other", "GET /policies/list", false},
+		{id, "author", id, "POST /roles/update", true},
+		{id, "author", id, "POST /user/assign-role", false},
+		{id, "auditor", id, "GET /graph/list", true},
+		{id, "auditor", id, "POST /graph/add", false},
+		{id, "auditor", id, "GET /tenant/list", false},
+		// Platform roles held in an ordinary tenant stay within it.
+		{id, "rogue", id, "POST /policies/update", true},
+		{id, "rogue", "other", "POST /tenant/create", false},
+		{SystemTenant, "ops", "other", "GET /tenant/list", true},
+		{SystemTenant, "ops", "other", "GET /policies/get", true},
+		{SystemTenant, "ops", "other", "POST /tenant/suspend", false},
+		{SystemTenant, "ops", SystemTenant, "POST /user/create", false},
+		{SystemTenant, platformAdmin, "other", "POST /tenant/suspend", true},
+		{SystemTenant, platformAdmin, SystemTenant, "POST /user/create", true},
+		{SystemTenant, platformAdmin, "other", "POST /check-access", false},
+	}
+	for _, tc := range cases {
+		dec := authorizeManagement(ctx, tc.caller, tc.sub, tc.target, tc.route)
+		if dec.Allow != tc.allow {
+			t.Errorf("%s/%s %s on %s: expected allow=%v, got %#v", tc.caller, tc.sub, tc.route, tc.target, tc.allow, dec)
+		}
+	}
+
+	var audit bytes.Buffer
+	prevLogger := auditLogger
+	auditLogger = logger.New(&audit, logger.LevelDebug)
+	defer func() { auditLogger = prevLogger }()
+	r := userRequest("auditor", http.MethodPost, "/reload", `{"tenantID":"mgmt-test"}`)
+	w := httptest.NewRecorder()
+	ReloadPolicies(w, r.WithContext(context.WithValue(r.Context(), "tenant", id)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected auditor reload to be forbidden, got %d", w.Code)
+	}
+	if !strings.Contains(audit.String(), `"action":"manage"`) || !strings.Contains(audit.String(), `"decision":"deny"`) {
+		t.Fatalf("expected denial to be audited, got %s", audit.String())
+	}
+}
+
+func TestManagementRoutes(t *testing.T) {
+	dataRoutes := map[string]bool{
+		"POST /authorize":          true,
+		"POST /check-access":       true,
+		"POST /check-access/batch": true,
+		"POST /simulate":           true,
+		"GET /healthz":             true,
+		"GET /policies/version":    true,
+		"GET /metrics":             true,
+	}
+	router := SetupRouter(identityProvider)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, m := range methods {
+			key := m + " " + path
+			if _, ok := managementRoutes[key]; !ok && !dataRoutes[key] {
+				t.Errorf("route %s has no management permission", key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+}
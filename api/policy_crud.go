@@ -0,0 +1,366 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/store"
+	"github.com/bradtumy/authorization-service/pkg/tenant"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// policyMu serializes changes made through the policy, role and user
+// endpoints within this process. Across replicas, each write is made
+// conditional on the backend version of the item the ETag was checked
+// against.
+var policyMu sync.Mutex
+
+// PolicyStoreRequest carries the item of a create, update or delete request
+// on /policies, /roles or /policy-users. Only the field matching the
+// endpoint is read; deletes name the item by ID.
+type PolicyStoreRequest struct {
+	TenantID string         `json:"tenantID"`
+	ID       string         `json:"id,omitempty"`
+	Policy   *policy.Policy `json:"policy,omitempty"`
+	Role     *policy.Role   `json:"role,omitempty"`
+	User     *policy.User   `json:"user,omitempty"`
+}
+
+// ListResponse is a page of items. NextOffset is set when more items follow.
+type ListResponse[T any] struct {
+	Items      []T `json:"items"`
+	Total      int `json:"total"`
+	NextOffset int `json:"nextOffset,omitempty"`
+}
+
+// storeItem describes one kind of item held in a tenant's PolicyStore.
+type storeItem[T any] struct {
+	kind  string
+	key   func(T) string
+	items func(*policy.Snapshot) map[string]T
+	from  func(PolicyStoreRequest) *T
+	// save and remove persist a change in the backend.
+	save   func(ctx context.Context, tenantID string, item T) error
+	remove func(ctx context.Context, tenantID, key string) error
+	// limit returns the tenant's quota for the kind, if it has one.
+	limit func(tenant.Quota) int
+}
+
+var (
+	policyItems = storeItem[policy.Policy]{
+		kind:  "policy",
+		key:   func(p policy.Policy) string { return p.ID },
+		items: func(s *policy.Snapshot) map[string]policy.Policy { return s.Policies },
+		from:  func(r PolicyStoreRequest) *policy.Policy { return r.Policy },
+		save: func(ctx context.Context, tenantID string, p policy.Policy) error {
+			return backend.SavePolicy(ctx, tenantID, p)
+		},
+		remove: func(ctx context.Context, tenantID, id string) error {
+			return backend.DeletePolicy(ctx, tenantID, id)
+		},
+		limit: func(q tenant.Quota) int { return q.MaxPolicies },
+	}
+	roleItems = storeItem[policy.Role]{
+		kind:  "role",
+		key:   func(r policy.Role) string { return r.Name },
+		items: func(s *policy.Snapshot) map[string]policy.Role { return s.Roles },
+		from:  func(r PolicyStoreRequest) *policy.Role { return r.Role },
+		save: func(ctx context.Context, tenantID string, r policy.Role) error {
+			return backend.SaveRole(ctx, tenantID, r)
+		},
+		remove: func(ctx context.Context, tenantID, name string) error {
+			return backend.DeleteRole(ctx, tenantID, name)
+		},
+	}
+	userItems = storeItem[policy.User]{
+		kind:  "user",
+		key:   func(u policy.User) string { return u.Username },
+		items: func(s *policy.Snapshot) map[string]policy.User { return s.Users },
+		from:  func(r PolicyStoreRequest) *policy.User { return r.User },
+		save: func(ctx context.Context, tenantID string, u policy.User) error {
+			return backend.SavePolicyUser(ctx, tenantID, u)
+		},
+		remove: func(ctx context.Context, tenantID, username string) error {
+			return backend.DeletePolicyUser(ctx, tenantID, username)
+		},
+		limit: func(q tenant.Quota) int { return q.MaxUsers },
+	}
+)
+
+// etag returns a strong entity tag for the JSON form of v.
+func etag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func (it storeItem[T]) create(w http.ResponseWriter, r *http.Request) {
+	it.write(w, r, "create")
+}
+
+func (it storeItem[T]) update(w http.ResponseWriter, r *http.Request) {
+	it.write(w, r, "update")
+}
+
+// write creates or updates an item. Updates must send the item's current
+// ETag in If-Match.
+func (it storeItem[T]) write(w http.ResponseWriter, r *http.Request, op string) {
+	var req PolicyStoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	ps := ts.Store
+	item := it.from(req)
+	if item == nil || it.key(*item) == "" {
+		http.Error(w, it.kind+" is required", http.StatusBadRequest)
+		return
+	}
+	key := it.key(*item)
+	match := r.Header.Get("If-Match")
+	if op == "update" && match == "" {
+		http.Error(w, "If-Match header is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	snap := ps.Snapshot()
+	items := it.items(&snap)
+	cur, exists := items[key]
+	switch {
+	case op == "create" && exists:
+		http.Error(w, it.kind+" already exists", http.StatusConflict)
+		return
+	case op == "update" && !exists:
+		http.Error(w, it.kind+" not found", http.StatusNotFound)
+		return
+	case op == "update" && match != "*" && match != etag(cur):
+		http.Error(w, it.kind+" was modified", http.StatusPreconditionFailed)
+		return
+	case op == "create" && it.full(ts, len(items)):
+		http.Error(w, it.kind+" quota exceeded", http.StatusForbidden)
+		return
+	}
+	version, err := ts.itemVersion(r.Context(), store.ItemKey(it.kind, key))
+	if err != nil {
+		http.Error(w, "failed to load "+it.kind, http.StatusInternalServerError)
+		return
+	}
+	items[key] = *item
+	var commitErr error
+	commit := func() error {
+		ctx := store.IfVersion(store.WithAuthor(r.Context(), sub), version)
+		commitErr = it.save(ctx, req.TenantID, *item)
+		return commitErr
+	}
+	if err := ps.Apply(snap, commit); err != nil {
+		it.applyFailed(w, r, ts, err, commitErr, "invalid "+it.kind)
+		return
+	}
+	ts.setItemVersion(store.ItemKey(it.kind, key), version+1)
+	it.audit(r, req.TenantID, sub, op, key)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(*item))
+	if op == "create" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(item)
+}
+
+// full reports whether a tenant holding n items of the kind has reached its
+// quota.
+func (it storeItem[T]) full(t *TenantState, n int) bool {
+	if it.limit == nil {
+		return false
+	}
+	max := it.limit(t.Meta().Quota)
+	return max > 0 && n >= max
+}
+
+// delete removes an item. If-Match is optional.
+func (it storeItem[T]) delete(w http.ResponseWriter, r *http.Request) {
+	var req PolicyStoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	ps := ts.Store
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	snap := ps.Snapshot()
+	items := it.items(&snap)
+	cur, exists := items[req.ID]
+	if !exists {
+		http.Error(w, it.kind+" not found", http.StatusNotFound)
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && match != "*" && match != etag(cur) {
+		http.Error(w, it.kind+" was modified", http.StatusPreconditionFailed)
+		return
+	}
+	version, err := ts.itemVersion(r.Context(), store.ItemKey(it.kind, req.ID))
+	if err != nil {
+		http.Error(w, "failed to load "+it.kind, http.StatusInternalServerError)
+		return
+	}
+	delete(items, req.ID)
+	var commitErr error
+	commit := func() error {
+		ctx := store.IfVersion(store.WithAuthor(r.Context(), sub), version)
+		commitErr = it.remove(ctx, req.TenantID, req.ID)
+		return commitErr
+	}
+	if err := ps.Apply(snap, commit); err != nil {
+		it.applyFailed(w, r, ts, err, commitErr, "cannot delete "+it.kind)
+		return
+	}
+	ts.setItemVersion(store.ItemKey(it.kind, req.ID), version+1)
+	it.audit(r, req.TenantID, sub, "delete", req.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cur)
+}
+
+// applyFailed reports an error returned by PolicyStore.Apply. A change that
+// lost a race with another change, made on this replica or another, fails
+// the precondition: 412 if the request sent If-Match, 409 otherwise.
+// Backend errors return 500 and validation errors 422 with the given
+// prefix.
+func (it storeItem[T]) applyFailed(w http.ResponseWriter, r *http.Request, ts *TenantState, err, commitErr error, invalid string) {
+	switch {
+	case errors.Is(err, policy.ErrStaleSnapshot), errors.Is(commitErr, store.ErrConflict):
+		// The versions are read again on the next change.
+		ts.setItemVersions(nil)
+		status := http.StatusConflict
+		if r.Header.Get("If-Match") != "" {
+			status = http.StatusPreconditionFailed
+		}
+		http.Error(w, it.kind+" was modified", status)
+	case commitErr != nil:
+		http.Error(w, "failed to save "+it.kind, http.StatusInternalServerError)
+	default:
+		http.Error(w, invalid+": "+err.Error(), http.StatusUnprocessableEntity)
+	}
+}
+
+// get returns one item with its ETag.
+func (it storeItem[T]) get(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	ps, ok := tenants.Store(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	snap := ps.Snapshot()
+	item, ok := it.items(&snap)[r.URL.Query().Get("id")]
+	if !ok {
+		http.Error(w, it.kind+" not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(item))
+	json.NewEncoder(w).Encode(item)
+}
+
+// list returns a page of items sorted by key, selected with `offset` and
+// `limit`.
+func (it storeItem[T]) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tenantID := q.Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	ps, ok := tenants.Store(r.Context(), tenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	offset, limit := 0, defaultPageSize
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	snap := ps.Snapshot()
+	items := it.items(&snap)
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := ListResponse[T]{Items: []T{}, Total: len(keys)}
+	for i := offset; i < len(keys) && i < offset+limit; i++ {
+		out.Items = append(out.Items, items[keys[i]])
+	}
+	if offset+limit < len(keys) {
+		out.NextOffset = offset + limit
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func (it storeItem[T]) audit(r *http.Request, tenantID, sub, op, key string) {
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      tenantID,
+		Subject:       sub,
+		Action:        it.kind + "_" + op,
+		Resource:      key,
+		Decision:      "success",
+	})
+}
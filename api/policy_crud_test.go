@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/store"
+)
+
+func TestPolicyCRUDEndpoints(t *testing.T) {
+	const tenantID = "crud-test"
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(context.Background(), tenantID, "editor", []string{"PolicyAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	ts := newTenantState(tenantID, "")
+	ps, engine := ts.Store, ts.Engine
+	tenants.Add(ts)
+	defer func() {
+		tenants.Remove(tenantID)
+		backend.DeleteTenant(context.Background(), tenantID)
+	}()
+	request := func(method, target, body, ifMatch string) *http.Request {
+		r := userRequest("editor", method, target, body)
+		r = r.WithContext(context.WithValue(r.Context(), "tenant", tenantID))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		return r
+	}
+
+	const p1 = `{"tenantID":"crud-test","policy":{"id":"p1","resource":["files/*"],"action":["read"],"effect":"allow"}}`
+	w := httptest.NewRecorder()
+	policyItems.create(w, request(http.MethodPost, "/policies/create", p1, ""))
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") == "" {
+		t.Fatalf("create: expected 201 with ETag, got %d: %s", w.Code, w.Body.String())
+	}
+	tag := w.Header().Get("ETag")
+	w = httptest.NewRecorder()
+	policyItems.create(w, request(http.MethodPost, "/policies/create", p1, ""))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate create to return 409, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	policyItems.create(w, request(http.MethodPost, "/policies/create", `{"tenantID":"crud-test","policy":{"id":"bad","resource":["x"],"action":["read"],"effect":"maybe"}}`, ""))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected invalid policy to return 422, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	roleItems.create(w, request(http.MethodPost, "/roles/create", `{"tenantID":"crud-test","role":{"name":"reader","policies":["p1"]}}`, ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create role: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	userItems.create(httptest.NewRecorder(), request(http.MethodPost, "/policy-users/create", `{"tenantID":"crud-test","user":{"username":"bob","roles":["reader"]}}`, ""))
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected created policy to allow bob, got %#v", dec)
+	}
+	if roles, err := backend.LoadRoles(context.Background(), tenantID); err != nil || len(roles) != 1 || roles[0].Policies[0] != "p1" {
+		t.Fatalf("unexpected stored roles %#v (%v)", roles, err)
+	}
+
+	const p1Deny = `{"tenantID":"crud-test","policy":{"id":"p1","resource":["files/*"],"action":["read"],"effect":"deny"}}`
+	w = httptest.NewRecorder()
+	policyItems.update(w, request(http.MethodPost, "/policies/update", p1Deny, ""))
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected update without If-Match to return 428, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	policyItems.update(w, request(http.MethodPost, "/policies/update", p1Deny, `"stale"`))
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected stale ETag to return 412, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	policyItems.update(w, request(http.MethodPost, "/policies/update", p1Deny, tag))
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == tag {
+		t.Fatalf("update: expected 200 with new ETag, got %d: %s", w.Code, w.Body.String())
+	}
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); dec.Allow {
+		t.Fatalf("expected updated policy to deny bob")
+	}
+
+	w = httptest.NewRecorder()
+	policyItems.get(w, request(http.MethodGet, "/policies/get?tenantID=crud-test&id=p1", "", ""))
+	var got policy.Policy
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.Effect != "deny" || w.Header().Get("ETag") != etag(got) {
+		t.Fatalf("unexpected policy %#v (%v)", got, err)
+	}
+
+	policyItems.create(httptest.NewRecorder(), request(http.MethodPost, "/policies/create", `{"tenantID":"crud-test","policy":{"id":"p2","resource":["docs/*"],"action":["read"],"effect":"allow"}}`, ""))
+	w = httptest.NewRecorder()
+	policyItems.list(w, request(http.MethodGet, "/policies/list?tenantID=crud-test&limit=1", "", ""))
+	var page ListResponse[policy.Policy]
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil || page.Total != 2 || len(page.Items) != 1 || page.Items[0].ID != "p1" || page.NextOffset != 1 {
+		t.Fatalf("unexpected page %#v (%v)", page, err)
+	}
+
+	w = httptest.NewRecorder()
+	policyItems.delete(w, request(http.MethodPost, "/policies/delete", `{"tenantID":"crud-test","id":"p2"}`, ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := ps.GetPolicy("p2"); ok {
+		t.Fatalf("expected p2 to be removed")
+	}
+	stored, err := backend.LoadPolicies(context.Background(), tenantID)
+	if err != nil || len(stored) != 1 || stored[0].Effect != "deny" {
+		t.Fatalf("unexpected stored policies %#v (%v)", stored, err)
+	}
+	versions, err := backend.PolicyVersions(context.Background(), tenantID)
+	if err != nil || len(versions) != 4 || versions[3].Author != "editor" {
+		t.Fatalf("unexpected versions %#v (%v)", versions, err)
+	}
+
+	w = httptest.NewRecorder()
+	policyItems.list(w, userRequest("editor", http.MethodGet, "/policies/list?tenantID="+tenantID, ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected other tenant to be forbidden, got %d", w.Code)
+	}
+}
+
+// failingStore is a backend whose policy writes fail.
+type failingStore struct{ store.Store }
+
+func (failingStore) SavePolicy(ctx context.Context, tenantID string, p policy.Policy) error {
+	return errors.New("database unavailable")
+}
+
+func TestPolicyCRUDConflictsAcrossReplicas(t *testing.T) {
+	const tenantID = "crud-replica-test"
+	ctx := context.Background()
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(ctx, tenantID, "editor", []string{"PolicyAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	ts := newTenantState(tenantID, "")
+	tenants.Add(ts)
+	defer func() {
+		tenants.Remove(tenantID)
+		backend.DeleteTenant(ctx, tenantID)
+	}()
+	request := func(target, body, ifMatch string) *http.Request {
+		r := userRequest("editor", http.MethodPost, target, body)
+		r = r.WithContext(context.WithValue(r.Context(), "tenant", tenantID))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	policyItems.create(w, request("/policies/create", `{"tenantID":"crud-replica-test","policy":{"id":"p1","resource":["files/*"],"action":["read"],"effect":"allow"}}`, ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	tag := w.Header().Get("ETag")
+
+	// Another replica changes p1 before this one reloads it.
+	if err := backend.SavePolicy(ctx, tenantID, policy.Policy{ID: "p1", Resource: []string{"files/*"}, Action: []string{"write"}, Effect: "allow"}); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+	const p1Deny = `{"tenantID":"crud-replica-test","policy":{"id":"p1","resource":["files/*"],"action":["read"],"effect":"deny"}}`
+	w = httptest.NewRecorder()
+	policyItems.update(w, request("/policies/update", p1Deny, tag))
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected update of a changed policy to return 412, got %d: %s", w.Code, w.Body.String())
+	}
+	if stored, _ := backend.LoadPolicies(ctx, tenantID); len(stored) != 1 || stored[0].Action[0] != "write" {
+		t.Fatalf("expected the other replica's change to be kept, got %#v", stored)
+	}
+
+	if err := loadPoliciesFromDB(ctx, ts); err != nil {
+		t.Fatalf("load policies: %v", err)
+	}
+	p1, _ := ts.Store.GetPolicy("p1")
+	w = httptest.NewRecorder()
+	policyItems.update(w, request("/policies/update", p1Deny, etag(p1)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("update after reload: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	prev := backend
+	backend = failingStore{prev}
+	defer func() { backend = prev }()
+	w = httptest.NewRecorder()
+	policyItems.create(w, request("/policies/create", `{"tenantID":"crud-replica-test","policy":{"id":"p2","resource":["docs/*"],"action":["read"],"effect":"allow"}}`, ""))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected backend failure to return 500, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := ts.Store.GetPolicy("p2"); ok {
+		t.Fatalf("expected failed create to leave the policies unchanged")
+	}
+}
+
+func TestLoadPoliciesFromDBIncludesRoles(t *testing.T) {
+	const tenantID = "db-roles-test"
+	ctx := context.Background()
+	ts := newTenantState(tenantID, "")
+	engine := ts.Engine
+	tenants.Add(ts)
+	defer func() {
+		tenants.Remove(tenantID)
+		backend.DeleteTenant(ctx, tenantID)
+	}()
+	if err := backend.SavePolicy(ctx, tenantID, policy.Policy{ID: "read-files", Effect: "allow", Action: []string{"read"}, Resource: []string{"files/*"}}); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+	if err := backend.SaveRole(ctx, tenantID, policy.Role{Name: "reader", Policies: []string{"read-files"}}); err != nil {
+		t.Fatalf("save role: %v", err)
+	}
+	if err := backend.SavePolicyUser(ctx, tenantID, policy.User{Username: "carol", Roles: []string{"reader"}}); err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+	if err := loadPoliciesFromDB(ctx, ts); err != nil {
+		t.Fatalf("load policies: %v", err)
+	}
+	if dec := engine.Evaluate("carol", "files/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected stored role to allow carol, got %#v", dec)
+	}
+	if err := backend.DeletePolicyUser(ctx, tenantID, "carol"); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if err := loadPoliciesFromDB(ctx, ts); err != nil {
+		t.Fatalf("reload policies: %v", err)
+	}
+	if dec := engine.Evaluate("carol", "files/a", "read", nil); dec.Allow {
+		t.Fatalf("expected deleted user to be denied")
+	}
+}
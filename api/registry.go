@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+// TenantState is a tenant's loaded policies, relationship graph and engine.
+type TenantState struct {
+	ID     string
+	Store  *policy.PolicyStore
+	Graph  *graph.Graph
+	Engine *policy.PolicyEngine
+	// File is the policy file loaded when POLICY_BACKEND=file, if any.
+	File string
+	// lastUsed is the time of the last lookup in Unix nanoseconds.
+	lastUsed atomic.Int64
+	// meta is the tenant's stored record, holding its status and quota.
+	mu   sync.RWMutex
+	meta Tenant
+	// versions holds the backend versions of the items in Store, keyed by
+	// store.ItemKey, or nil until they are next read from the backend.
+	versions map[string]int64
+}
+
+// newTenantState returns an empty tenant whose engine resolves resource
+// attributes from the backend.
+func newTenantState(id, file string) *TenantState {
+	s, g := policy.NewPolicyStore(), graph.New()
+	t := &TenantState{ID: id, Store: s, Graph: g, Engine: newEngine(s, g), File: file, meta: Tenant{ID: id}}
+	t.touch()
+	return t
+}
+
+// Meta returns the tenant's stored record.
+func (t *TenantState) Meta() Tenant {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.meta
+}
+
+// SetMeta replaces the tenant's stored record after it changed.
+func (t *TenantState) SetMeta(meta Tenant) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.meta = meta
+}
+
+// itemVersion returns the backend version of the item in Store with the
+// given key, reading the versions from the backend if they are not known.
+func (t *TenantState) itemVersion(ctx context.Context, key string) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.versions == nil {
+		versions, err := backend.ItemVersions(ctx, t.ID)
+		if err != nil {
+			return 0, err
+		}
+		t.versions = versions
+	}
+	return t.versions[key], nil
+}
+
+// setItemVersion records the version of an item after Store was changed.
+func (t *TenantState) setItemVersion(key string, version int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.versions != nil {
+		t.versions[key] = version
+	}
+}
+
+// setItemVersions replaces the versions of the items in Store. nil makes
+// the next lookup read them from the backend.
+func (t *TenantState) setItemVersions(versions map[string]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.versions = versions
+}
+
+func (t *TenantState) touch() {
+	t.lastUsed.Store(time.Now().UnixNano())
+}
+
+// TenantRegistry holds the tenants loaded by this process. Tenants that are
+// not loaded yet, for example because they were created on another replica,
+// are loaded on first use; tenants that stay idle can be evicted and are
+// loaded again when next requested. It is safe for concurrent use.
+type TenantRegistry struct {
+	mu      sync.RWMutex
+	tenants map[string]*TenantState
+	// loading holds the loads in flight, so that concurrent lookups of a
+	// tenant share one load while other tenants load independently.
+	loading map[string]*tenantLoad
+	// missing records until when a tenant that failed to load is reported
+	// missing without asking load again.
+	missing map[string]time.Time
+	load    func(ctx context.Context, id string) (*TenantState, error)
+}
+
+// tenantLoad is a load in flight. done is closed once t and ok are set.
+type tenantLoad struct {
+	done chan struct{}
+	t    *TenantState
+	ok   bool
+	// removed is set when the tenant is removed during the load, whose
+	// result is then discarded.
+	removed bool
+}
+
+const (
+	// missingTTL is how long a failed load is remembered.
+	missingTTL = 5 * time.Second
+	// maxMissing bounds the failed loads remembered at once.
+	maxMissing = 10000
+)
+
+// NewTenantRegistry returns an empty registry that loads missing tenants
+// with load. load may be nil, in which case only added tenants are found.
+func NewTenantRegistry(load func(ctx context.Context, id string) (*TenantState, error)) *TenantRegistry {
+	return &TenantRegistry{
+		tenants: make(map[string]*TenantState),
+		loading: make(map[string]*tenantLoad),
+		missing: make(map[string]time.Time),
+		load:    load,
+	}
+}
+
+// Get returns a tenant, loading it on first use. It reports false if the
+// tenant does not exist or cannot be loaded; such lookups are answered from
+// memory for missingTTL.
+func (r *TenantRegistry) Get(ctx context.Context, id string) (*TenantState, bool) {
+	if t, ok := r.Loaded(id); ok {
+		t.touch()
+		return t, true
+	}
+	if r.load == nil || id == "" {
+		return nil, false
+	}
+	r.mu.Lock()
+	if t, ok := r.tenants[id]; ok {
+		r.mu.Unlock()
+		t.touch()
+		return t, true
+	}
+	if until, ok := r.missing[id]; ok && time.Now().Before(until) {
+		r.mu.Unlock()
+		return nil, false
+	}
+	if l, ok := r.loading[id]; ok {
+		r.mu.Unlock()
+		select {
+		case <-l.done:
+			return l.t, l.ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	l := &tenantLoad{done: make(chan struct{})}
+	r.loading[id] = l
+	r.mu.Unlock()
+
+	t, err := r.load(ctx, id)
+
+	r.mu.Lock()
+	delete(r.loading, id)
+	switch {
+	case l.removed:
+		// The tenant was deleted while it loaded.
+	case err != nil:
+		if ctx.Err() == nil {
+			r.rememberMissing(id)
+		}
+	default:
+		t.touch()
+		r.tenants[id] = t
+		l.t, l.ok = t, true
+	}
+	r.mu.Unlock()
+	close(l.done)
+	return l.t, l.ok
+}
+
+// rememberMissing records a failed load of id. r.mu must be held.
+func (r *TenantRegistry) rememberMissing(id string) {
+	now := time.Now()
+	if len(r.missing) >= maxMissing {
+		for k, until := range r.missing {
+			if !now.Before(until) {
+				delete(r.missing, k)
+			}
+		}
+		if len(r.missing) >= maxMissing {
+			r.missing = make(map[string]time.Time)
+		}
+	}
+	r.missing[id] = now.Add(missingTTL)
+}
+
+// Loaded returns a tenant only if it is already loaded. Unlike Get, it does
+// not count as a use of the tenant.
+func (r *TenantRegistry) Loaded(id string) (*TenantState, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tenants[id]
+	return t, ok
+}
+
+// Engine returns a tenant's policy engine, loading the tenant on first use.
+func (r *TenantRegistry) Engine(ctx context.Context, id string) (*policy.PolicyEngine, bool) {
+	t, ok := r.Get(ctx, id)
+	if !ok {
+		return nil, false
+	}
+	return t.Engine, true
+}
+
+// Graph returns a tenant's relationship graph, loading the tenant on first
+// use.
+func (r *TenantRegistry) Graph(ctx context.Context, id string) (*graph.Graph, bool) {
+	t, ok := r.Get(ctx, id)
+	if !ok {
+		return nil, false
+	}
+	return t.Graph, true
+}
+
+// Store returns a tenant's policy store, loading the tenant on first use.
+func (r *TenantRegistry) Store(ctx context.Context, id string) (*policy.PolicyStore, bool) {
+	t, ok := r.Get(ctx, id)
+	if !ok {
+		return nil, false
+	}
+	return t.Store, true
+}
+
+// Add registers a loaded tenant, replacing any tenant with the same ID.
+func (r *TenantRegistry) Add(t *TenantState) {
+	t.touch()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants[t.ID] = t
+	delete(r.missing, t.ID)
+}
+
+// Remove unloads a tenant and reports whether it was loaded. A load of the
+// tenant in flight does not add it back.
+func (r *TenantRegistry) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.tenants[id]
+	delete(r.tenants, id)
+	if l, loading := r.loading[id]; loading {
+		l.removed = true
+	}
+	return ok
+}
+
+// IDs returns the IDs of the loaded tenants, sorted.
+func (r *TenantRegistry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// EvictIdle unloads the tenants not looked up for at least idle and returns
+// their IDs.
+func (r *TenantRegistry) EvictIdle(idle time.Duration) []string {
+	cutoff := time.Now().Add(-idle).UnixNano()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var evicted []string
+	for id, t := range r.tenants {
+		if t.lastUsed.Load() <= cutoff {
+			delete(r.tenants, id)
+			evicted = append(evicted, id)
+		}
+	}
+	sort.Strings(evicted)
+	return evicted
+}
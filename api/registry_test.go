@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTenantRegistryLoadsOnce(t *testing.T) {
+	var loads atomic.Int32
+	r := NewTenantRegistry(func(ctx context.Context, id string) (*TenantState, error) {
+		if id == "missing" {
+			return nil, errors.New("tenant not found")
+		}
+		loads.Add(1)
+		return &TenantState{ID: id}, nil
+	})
+	if _, ok := r.Loaded("a"); ok {
+		t.Fatalf("expected tenant not to be loaded before use")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := r.Get(context.Background(), "a"); !ok {
+				t.Errorf("expected tenant to load")
+			}
+		}()
+	}
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected one load, got %d", n)
+	}
+	if _, ok := r.Get(context.Background(), "missing"); ok {
+		t.Fatalf("expected missing tenant not to be found")
+	}
+	if ids := r.IDs(); len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("expected only loaded tenant to be listed, got %v", ids)
+	}
+	if !r.Remove("a") || r.Remove("a") {
+		t.Fatalf("expected remove to report whether the tenant was loaded")
+	}
+}
+
+func TestTenantRegistryEvictIdle(t *testing.T) {
+	r := NewTenantRegistry(nil)
+	r.Add(&TenantState{ID: "idle"})
+	r.Add(&TenantState{ID: "busy"})
+	time.Sleep(20 * time.Millisecond)
+	r.Get(context.Background(), "busy")
+	if evicted := r.EvictIdle(10 * time.Millisecond); len(evicted) != 1 || evicted[0] != "idle" {
+		t.Fatalf("expected idle tenant to be evicted, got %v", evicted)
+	}
+	if _, ok := r.Get(context.Background(), "idle"); ok {
+		t.Fatalf("expected evicted tenant to be unloaded")
+	}
+	if _, ok := r.Loaded("busy"); !ok {
+		t.Fatalf("expected used tenant to stay loaded")
+	}
+}
+
+func TestTenantRegistryLoadsTenantsIndependently(t *testing.T) {
+	var misses atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := NewTenantRegistry(func(ctx context.Context, id string) (*TenantState, error) {
+		switch id {
+		case "slow":
+			close(started)
+			<-release
+		case "missing":
+			misses.Add(1)
+			return nil, errors.New("tenant not found")
+		}
+		return &TenantState{ID: id}, nil
+	})
+
+	loaded := make(chan bool)
+	go func() {
+		_, ok := r.Get(context.Background(), "slow")
+		loaded <- ok
+	}()
+	<-started
+	// Another tenant loads while the slow one is in flight.
+	if _, ok := r.Get(context.Background(), "fast"); !ok {
+		t.Fatalf("expected fast tenant to load")
+	}
+	// A tenant removed while it loads is not added back.
+	r.Remove("slow")
+	close(release)
+	if <-loaded {
+		t.Fatalf("expected removed tenant not to be returned")
+	}
+	if _, ok := r.Loaded("slow"); ok {
+		t.Fatalf("expected removed tenant not to be added")
+	}
+
+	for i := 0; i < 3; i++ {
+		r.Get(context.Background(), "missing")
+	}
+	if n := misses.Load(); n != 1 {
+		t.Fatalf("expected a failed load to be remembered, got %d loads", n)
+	}
+	r.Add(&TenantState{ID: "missing"})
+	if _, ok := r.Get(context.Background(), "missing"); !ok {
+		t.Fatalf("expected added tenant to be found")
+	}
+}
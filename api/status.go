@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+var (
+	revMu sync.Mutex
+	// storeRevisions holds the backend revision each tenant's policies were
+	// last loaded at when POLICY_BACKEND=db.
+	storeRevisions = make(map[string]int64)
+)
+
+// HealthStatus reports that the service is up and which policy revision
+// each tenant is serving.
+type HealthStatus struct {
+	Status        string          `json:"status"`
+	PolicyBackend string          `json:"policyBackend"`
+	Tenants       []PolicyVersion `json:"tenants"`
+}
+
+func setStoreRevision(tenantID string, rev int64) {
+	revMu.Lock()
+	defer revMu.Unlock()
+	storeRevisions[tenantID] = rev
+}
+
+func storeRevision(tenantID string) int64 {
+	revMu.Lock()
+	defer revMu.Unlock()
+	return storeRevisions[tenantID]
+}
+
+// Healthz returns the service status with the active policy version of
+// every loaded tenant. Replicas serving the same revision of a tenant hold
+// the same policies.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	status := HealthStatus{Status: "ok", PolicyBackend: policyBackend, Tenants: []PolicyVersion{}}
+	for _, id := range tenants.IDs() {
+		if t, ok := tenants.Loaded(id); ok {
+			status.Tenants = append(status.Tenants, policyVersion(t))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+func TestWatchPoliciesReloadsChangedTenant(t *testing.T) {
+	const tenantID = "watch-test"
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := newTenantState(tenantID, "")
+	engine := ts.Engine
+	tenants.Add(ts)
+	if err := backend.SaveTenant(ctx, Tenant{ID: tenantID, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save tenant: %v", err)
+	}
+	changes, err := backend.Watch(ctx)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		watchPolicies(changes)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		tenants.Remove(tenantID)
+		backend.DeleteTenant(context.Background(), tenantID)
+	}()
+
+	if err := backend.SavePolicy(ctx, tenantID, policy.Policy{ID: "read-files", Effect: "allow", Action: []string{"read"}, Resource: []string{"files/*"}}); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+	if err := backend.SaveRole(ctx, tenantID, policy.Role{Name: "reader", Policies: []string{"read-files"}}); err != nil {
+		t.Fatalf("save role: %v", err)
+	}
+	if err := backend.SavePolicyUser(ctx, tenantID, policy.User{Username: "dave", Roles: []string{"reader"}}); err != nil {
+		t.Fatalf("save user: %v", err)
+	}
+	rev, _ := backend.Revision(ctx, tenantID)
+	deadline := time.Now().Add(5 * time.Second)
+	for storeRevision(tenantID) != rev {
+		if time.Now().After(deadline) {
+			t.Fatalf("tenant was not reloaded at revision %d", rev)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if dec := engine.Evaluate("dave", "files/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected reloaded policies to allow dave, got %#v", dec)
+	}
+
+	w := httptest.NewRecorder()
+	Healthz(w, adminRequest(http.MethodGet, "/healthz", ""))
+	var status HealthStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil || status.Status != "ok" {
+		t.Fatalf("unexpected status %#v (%v)", status, err)
+	}
+	for _, v := range status.Tenants {
+		if v.TenantID == tenantID {
+			if v.StoreRevision != rev {
+				t.Fatalf("expected store revision %d, got %d", rev, v.StoreRevision)
+			}
+			return
+		}
+	}
+	t.Fatalf("tenant missing from status %#v", status)
+}
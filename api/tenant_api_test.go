@@ -1,18 +1,25 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/tenant"
 )
 
 func TestCreateTenant(t *testing.T) {
 	id := "tenantCreate"
 	body := fmt.Sprintf(`{"tenantID":"%s","name":"%s"}`, id, id)
-	r := httptest.NewRequest(http.MethodPost, "/tenant/create", strings.NewReader(body))
+	r := platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(body))
 	w := httptest.NewRecorder()
 	CreateTenant(w, r)
 	if w.Code != http.StatusOK {
@@ -25,12 +32,12 @@ func TestCreateTenant(t *testing.T) {
 	if tenant.ID != id {
 		t.Fatalf("expected id %s, got %s", id, tenant.ID)
 	}
-	if store, ok := policyStores[id]; !ok || len(store.Policies) != 0 {
+	if ts, ok := tenants.Loaded(id); !ok || len(ts.Store.Policies) != 0 {
 		t.Fatalf("expected empty policy store for tenant")
 	}
 	// cleanup
 	delBody := fmt.Sprintf(`{"tenantID":"%s"}`, id)
-	dr := httptest.NewRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody))
+	dr := platformRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody))
 	dw := httptest.NewRecorder()
 	DeleteTenant(dw, dr)
 }
@@ -40,14 +47,14 @@ func TestListTenants(t *testing.T) {
 	id2 := "tenantList2"
 	for _, id := range []string{id1, id2} {
 		body := fmt.Sprintf(`{"tenantID":"%s","name":"%s"}`, id, id)
-		r := httptest.NewRequest(http.MethodPost, "/tenant/create", strings.NewReader(body))
+		r := platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(body))
 		w := httptest.NewRecorder()
 		CreateTenant(w, r)
 		if w.Code != http.StatusOK {
 			t.Fatalf("create tenant %s failed", id)
 		}
 	}
-	r := httptest.NewRequest(http.MethodGet, "/tenant/list", nil)
+	r := platformRequest(http.MethodGet, "/tenant/list", nil)
 	w := httptest.NewRecorder()
 	ListTenants(w, r)
 	if w.Code != http.StatusOK {
@@ -72,7 +79,7 @@ func TestListTenants(t *testing.T) {
 	// cleanup
 	for _, id := range []string{id1, id2} {
 		delBody := fmt.Sprintf(`{"tenantID":"%s"}`, id)
-		dr := httptest.NewRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody))
+		dr := platformRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody))
 		dw := httptest.NewRecorder()
 		DeleteTenant(dw, dr)
 	}
@@ -81,20 +88,214 @@ func TestListTenants(t *testing.T) {
 func TestDeleteTenant(t *testing.T) {
 	id := "tenantDelete"
 	createBody := fmt.Sprintf(`{"tenantID":"%s","name":"%s"}`, id, id)
-	cr := httptest.NewRequest(http.MethodPost, "/tenant/create", strings.NewReader(createBody))
+	cr := platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(createBody))
 	cw := httptest.NewRecorder()
 	CreateTenant(cw, cr)
 	if cw.Code != http.StatusOK {
 		t.Fatalf("create tenant failed")
 	}
 	delBody := fmt.Sprintf(`{"tenantID":"%s"}`, id)
-	r := httptest.NewRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody))
+	r := platformRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody))
 	w := httptest.NewRecorder()
 	DeleteTenant(w, r)
 	if w.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", w.Code)
 	}
+	if meta, err := backend.LoadTenant(r.Context(), id); err != nil || meta.Status != tenant.StatusPendingDeletion || meta.DeletedAt == nil {
+		t.Fatalf("expected tenant to be pending deletion, got %#v %v", meta, err)
+	}
+	if _, ok := tenants.Get(r.Context(), id); ok {
+		t.Fatalf("expected deleted tenant not to be served")
+	}
+
+	uw := httptest.NewRecorder()
+	UndeleteTenant(uw, platformRequest(http.MethodPost, "/tenant/undelete", strings.NewReader(delBody)))
+	if uw.Code != http.StatusOK {
+		t.Fatalf("undelete: expected status 200, got %d", uw.Code)
+	}
+	if _, ok := tenants.Loaded(id); !ok {
+		t.Fatalf("expected restored tenant to be loaded")
+	}
+	uw = httptest.NewRecorder()
+	UndeleteTenant(uw, platformRequest(http.MethodPost, "/tenant/undelete", strings.NewReader(delBody)))
+	if uw.Code != http.StatusConflict {
+		t.Fatalf("undelete active tenant: expected status 409, got %d", uw.Code)
+	}
+
+	DeleteTenant(httptest.NewRecorder(), platformRequest(http.MethodPost, "/tenant/delete", strings.NewReader(delBody)))
+	if purged := purgeExpiredTenants(r.Context(), time.Now()); len(purged) != 0 {
+		t.Fatalf("expected tenant to be kept during the retention window, purged %v", purged)
+	}
+	purgeExpiredTenants(r.Context(), time.Now().Add(tenantRetention))
 	if _, err := backend.LoadTenant(r.Context(), id); err == nil {
-		t.Fatalf("tenant not deleted")
+		t.Fatalf("tenant not deleted after the retention window")
+	}
+}
+
+func TestSuspendTenant(t *testing.T) {
+	id := "tenantSuspend"
+	CreateTenant(httptest.NewRecorder(), platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(fmt.Sprintf(`{"tenantID":"%s"}`, id))))
+	defer backend.DeleteTenant(context.Background(), id)
+	defer tenants.Remove(id)
+	check := func() policy.Decision {
+		r := httptest.NewRequest(http.MethodPost, "/check-access", strings.NewReader(`{"resource":"file1","action":"read"}`))
+		ctx := context.WithValue(r.Context(), "subject", "alice")
+		ctx = context.WithValue(ctx, "tenant", id)
+		w := httptest.NewRecorder()
+		CheckAccess(w, r.WithContext(ctx))
+		var dec policy.Decision
+		json.NewDecoder(w.Body).Decode(&dec)
+		return dec
+	}
+	if dec := check(); dec.Reason == ReasonTenantSuspended {
+		t.Fatalf("expected active tenant to be evaluated, got %#v", dec)
+	}
+	body := fmt.Sprintf(`{"tenantID":"%s"}`, id)
+	w := httptest.NewRecorder()
+	SuspendTenant(w, platformRequest(http.MethodPost, "/tenant/suspend", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("suspend: expected status 200, got %d", w.Code)
+	}
+	if dec := check(); dec.Allow || dec.Reason != ReasonTenantSuspended {
+		t.Fatalf("expected suspended tenant to be denied, got %#v", dec)
+	}
+	// A suspended tenant stays suspended when it is loaded again.
+	tenants.Remove(id)
+	if dec := check(); dec.Reason != ReasonTenantSuspended {
+		t.Fatalf("expected reloaded tenant to stay suspended, got %#v", dec)
+	}
+	w = httptest.NewRecorder()
+	ResumeTenant(w, platformRequest(http.MethodPost, "/tenant/resume", strings.NewReader(body)))
+	if dec := check(); w.Code != http.StatusOK || dec.Reason == ReasonTenantSuspended {
+		t.Fatalf("expected resumed tenant to be evaluated, got %d %#v", w.Code, dec)
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	const id = "tenantQuota"
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(context.Background(), id, "quota-admin", []string{"PolicyAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	CreateTenant(httptest.NewRecorder(), platformRequest(http.MethodPost, "/tenant/create",
+		strings.NewReader(fmt.Sprintf(`{"tenantID":"%s","quota":{"maxPolicies":1,"maxEdges":1}}`, id))))
+	defer backend.DeleteTenant(context.Background(), id)
+	defer tenants.Remove(id)
+	request := func(target, body string) int {
+		r := userRequest("quota-admin", http.MethodPost, target, body)
+		r = r.WithContext(context.WithValue(r.Context(), "tenant", id))
+		w := httptest.NewRecorder()
+		switch target {
+		case "/policies/create":
+			policyItems.create(w, r)
+		case "/graph/add":
+			AddRelationship(w, r)
+		}
+		return w.Code
+	}
+	policyBody := func(pid string) string {
+		return fmt.Sprintf(`{"tenantID":"%s","policy":{"id":"%s","resource":["*"],"action":["read"],"effect":"allow"}}`, id, pid)
+	}
+	if code := request("/policies/create", policyBody("p1")); code != http.StatusCreated {
+		t.Fatalf("expected first policy to be created, got %d", code)
+	}
+	if code := request("/policies/create", policyBody("p2")); code != http.StatusForbidden {
+		t.Fatalf("expected policy over quota to be refused, got %d", code)
+	}
+	edge := fmt.Sprintf(`{"tenantID":"%s","src":"user:a","dst":"group:b"}`, id)
+	if code := request("/graph/add", edge); code != http.StatusOK {
+		t.Fatalf("expected first edge to be added, got %d", code)
+	}
+	if code := request("/graph/add", edge); code != http.StatusOK {
+		t.Fatalf("expected existing edge to be accepted, got %d", code)
+	}
+	if code := request("/graph/add", fmt.Sprintf(`{"tenantID":"%s","src":"user:a","dst":"group:c"}`, id)); code != http.StatusForbidden {
+		t.Fatalf("expected edge over quota to be refused, got %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	SetTenantQuota(w, platformRequest(http.MethodPost, "/tenant/quota", strings.NewReader(fmt.Sprintf(`{"tenantID":"%s","quota":{"maxPolicies":2}}`, id))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set quota: expected status 200, got %d", w.Code)
+	}
+	if code := request("/policies/create", policyBody("p2")); code != http.StatusCreated {
+		t.Fatalf("expected raised quota to take effect, got %d", code)
+	}
+	w = httptest.NewRecorder()
+	SetTenantQuota(w, platformRequest(http.MethodPost, "/tenant/quota", strings.NewReader(fmt.Sprintf(`{"tenantID":"%s","quota":{"maxUsers":-1}}`, id))))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected negative quota to be rejected, got %d", w.Code)
+	}
+}
+
+func TestCreateTenantFromTemplate(t *testing.T) {
+	t.Setenv("TENANT_CONFIG_DIR", t.TempDir())
+	t.Setenv("TENANT_TEMPLATE_DIR", "../configs/templates")
+	for _, mode := range []string{"file", "db"} {
+		prevBackend := policyBackend
+		policyBackend = mode
+		id := "tenantTemplate-" + mode
+		body := fmt.Sprintf(`{"tenantID":"%s","name":"%s","template":"saas-basic"}`, id, id)
+		w := httptest.NewRecorder()
+		CreateTenant(w, platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", mode, w.Code, w.Body.String())
+		}
+		var tenant Tenant
+		json.NewDecoder(w.Body).Decode(&tenant)
+		if tenant.Template != "saas-basic" || tenant.TemplateVersion != "1.0.0" {
+			t.Fatalf("%s: expected template version to be recorded, got %#v", mode, tenant)
+		}
+		ts, ok := tenants.Loaded(id)
+		if !ok {
+			t.Fatalf("%s: expected tenant to be loaded", mode)
+		}
+		if _, ok := ts.Store.GetPolicy("admin-read"); !ok {
+			t.Fatalf("%s: expected template policies to be active", mode)
+		}
+		if mode == "file" && ts.File != filepath.Join(tenantConfigDir(), id, TemplatePolicyFile) {
+			t.Fatalf("expected tenant to be served from its copy of the template, got %q", ts.File)
+		}
+		if mode == "db" {
+			if policies, _ := backend.LoadPolicies(context.Background(), id); len(policies) != 3 {
+				t.Fatalf("expected template policies to be stored, got %d", len(policies))
+			}
+		}
+
+		// A reloaded tenant serves the same policies.
+		tenants.Remove(id)
+		if ts, ok := tenants.Get(context.Background(), id); !ok {
+			t.Fatalf("%s: expected tenant to be loaded again", mode)
+		} else if _, ok := ts.Store.GetPolicy("member-read"); !ok {
+			t.Fatalf("%s: expected reloaded tenant to keep template policies", mode)
+		}
+
+		dw := httptest.NewRecorder()
+		DeleteTenant(dw, platformRequest(http.MethodPost, "/tenant/delete", strings.NewReader(fmt.Sprintf(`{"tenantID":"%s"}`, id))))
+		policyBackend = prevBackend
+	}
+
+	// The template directory cannot double as a tenant's configuration.
+	t.Setenv("TENANT_TEMPLATE_DIR", "")
+	w := httptest.NewRecorder()
+	CreateTenant(w, platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(`{"tenantID":"templates"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected tenantID templates to be reserved, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	ImportTenant(w, platformRequest(http.MethodPost, "/tenant/import", strings.NewReader(`{"tenantID":"templates","archive":{"tenant":{"id":"acme"}}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected import into tenantID templates to be rejected, got %d", w.Code)
+	}
+	t.Setenv("TENANT_TEMPLATE_DIR", "../configs/templates")
+
+	w = httptest.NewRecorder()
+	CreateTenant(w, platformRequest(http.MethodPost, "/tenant/create", strings.NewReader(`{"tenantID":"tenantTemplate-missing","template":"missing"}`)))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown template to be rejected with 404, got %d", w.Code)
+	}
+	if _, err := backend.LoadTenant(context.Background(), "tenantTemplate-missing"); err == nil {
+		t.Fatalf("expected tenant with unknown template not to be saved")
 	}
 }
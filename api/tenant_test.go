@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/bradtumy/authorization-service/pkg/graph"
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
 	"github.com/bradtumy/authorization-service/pkg/policy"
 )
 
@@ -93,14 +94,10 @@ policies:
 	}
 	gA := graph.New()
 	gB := graph.New()
-	policyStores["tenantA"] = storeA
-	policyGraphs["tenantA"] = gA
-	policyEngines["tenantA"] = policy.NewPolicyEngine(storeA, gA)
-	policyFiles["tenantA"] = fileA.Name()
-	policyStores["tenantB"] = storeB
-	policyGraphs["tenantB"] = gB
-	policyEngines["tenantB"] = policy.NewPolicyEngine(storeB, gB)
-	policyFiles["tenantB"] = fileB.Name()
+	tenants.Add(&TenantState{ID: "tenantA", Store: storeA, Graph: gA, Engine: policy.NewPolicyEngine(storeA, gA), File: fileA.Name()})
+	tenants.Add(&TenantState{ID: "tenantB", Store: storeB, Graph: gB, Engine: policy.NewPolicyEngine(storeB, gB), File: fileB.Name()})
+	defer tenants.Remove("tenantA")
+	defer tenants.Remove("tenantB")
 
 	reqA := `{"tenantID":"tenantA","subject":"alice","resource":"file1","action":"read","conditions":{}}`
 	wA := httptest.NewRecorder()
@@ -136,3 +133,124 @@ policies:
 		t.Fatalf("expected 404 for unknown tenant, got %d", wC.Code)
 	}
 }
+
+func TestCheckAccessBatch(t *testing.T) {
+	reqBody := `{"items":[{"resource":"file1","action":"read"},{"resource":"file1","action":"delete"},{"resource":"file2","action":"write"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/check-access/batch", strings.NewReader(reqBody))
+	ctx := context.WithValue(r.Context(), "subject", "user1")
+	ctx = context.WithValue(ctx, "tenant", "default")
+	w := httptest.NewRecorder()
+	CheckAccessBatch(w, r.WithContext(ctx))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp BatchAccessResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Decisions) != 3 {
+		t.Fatalf("expected 3 decisions, got %d", len(resp.Decisions))
+	}
+	if !resp.Decisions[0].Allow || resp.Decisions[1].Allow || !resp.Decisions[2].Allow {
+		t.Fatalf("unexpected decisions %#v", resp.Decisions)
+	}
+}
+
+func TestQuerySubjectPermissions(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/query/subject-permissions", nil)
+	ctx := context.WithValue(r.Context(), "subject", "user1")
+	ctx = context.WithValue(ctx, "tenant", "default")
+	w := httptest.NewRecorder()
+	QuerySubjectPermissions(w, r.WithContext(ctx))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp SubjectPermissionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Subject != "user1" || len(resp.Permissions) != 2 {
+		t.Fatalf("unexpected response %#v", resp)
+	}
+}
+
+func TestQueryResourceAccess(t *testing.T) {
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(context.Background(), "default", "admin", []string{"TenantAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/query/subject-permissions?subject=user2", nil)
+	ctx := context.WithValue(r.Context(), "subject", "user1")
+	ctx = context.WithValue(ctx, "tenant", "default")
+	w := httptest.NewRecorder()
+	QuerySubjectPermissions(w, r.WithContext(ctx))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for another subject, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/query/resource-access?resource=file1&action=write", nil)
+	ctx = context.WithValue(r.Context(), "subject", "admin")
+	ctx = context.WithValue(ctx, "tenant", "default")
+	w = httptest.NewRecorder()
+	QueryResourceAccess(w, r.WithContext(ctx))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp ResourceAccessResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	found := false
+	for _, a := range resp.Access {
+		if a.Kind == "user" && a.Name == "user1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected user1 to have write access, got %#v", resp.Access)
+	}
+}
+
+func TestCheckAccessResourceOwner(t *testing.T) {
+	for owner, allow := range map[string]bool{"user2": true, "user1": false} {
+		reqBody := `{"resource":"file2","action":"edit","resourceAttributes":{"owner":"` + owner + `"}}`
+		r := httptest.NewRequest(http.MethodPost, "/check-access", strings.NewReader(reqBody))
+		ctx := context.WithValue(r.Context(), "subject", "user2")
+		ctx = context.WithValue(ctx, "tenant", "default")
+		w := httptest.NewRecorder()
+		CheckAccess(w, r.WithContext(ctx))
+		var dec policy.Decision
+		if err := json.NewDecoder(w.Body).Decode(&dec); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if dec.Allow != allow {
+			t.Fatalf("owner %s: expected allow=%v, got %v", owner, allow, dec)
+		}
+	}
+}
+
+func TestCheckAccessDecisionCache(t *testing.T) {
+	check := func() policy.Decision {
+		reqBody := `{"resource":"cache-file","action":"read"}`
+		r := httptest.NewRequest(http.MethodPost, "/check-access", strings.NewReader(reqBody))
+		ctx := context.WithValue(r.Context(), "subject", "user1")
+		ctx = context.WithValue(ctx, "tenant", "default")
+		w := httptest.NewRecorder()
+		CheckAccess(w, r.WithContext(ctx))
+		var dec policy.Decision
+		if err := json.NewDecoder(w.Body).Decode(&dec); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return dec
+	}
+	check()
+	if dec := check(); !dec.Allow || !dec.Cached {
+		t.Fatalf("expected cached allow, got %v", dec)
+	}
+	invalidateDecisions("default")
+	if dec := check(); dec.Cached {
+		t.Fatalf("expected fresh decision after invalidation, got %v", dec)
+	}
+}
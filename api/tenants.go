@@ -0,0 +1,407 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/tenant"
+)
+
+// TemplatePolicyFile is the entry point a tenant template must contain. A
+// file-backed tenant created from a template serves its copy of this file.
+const TemplatePolicyFile = "policies.yaml"
+
+// ReasonTenantSuspended is the reason given for access checks denied
+// because the tenant is suspended.
+const ReasonTenantSuspended = "tenant suspended"
+
+// defaultRetention is how long deleted tenants can be restored when
+// TENANT_RETENTION is not set.
+const defaultRetention = 30 * 24 * time.Hour
+
+var (
+	errTemplateNotFound = errors.New("template not found")
+	errTenantNotFound   = errors.New("tenant not found")
+	errTenantDeleted    = errors.New("tenant is pending deletion")
+)
+
+// tenantRetention is how long a deleted tenant can be restored before its
+// data is removed. Zero removes it at once.
+var tenantRetention = defaultRetention
+
+// tenantConfigDir returns the directory holding per-tenant configuration,
+// from TENANT_CONFIG_DIR.
+func tenantConfigDir() string {
+	if dir := os.Getenv("TENANT_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	return "configs"
+}
+
+// templateDir returns the directory holding tenant templates, from
+// TENANT_TEMPLATE_DIR.
+func templateDir() string {
+	if dir := os.Getenv("TENANT_TEMPLATE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(tenantConfigDir(), "templates")
+}
+
+// tenantPolicyFile returns the policy file a tenant is served from when
+// POLICY_BACKEND=file: POLICY_FILE for the default tenant and
+// `<TENANT_CONFIG_DIR>/<tenantID>/policies.yaml` for the others, if it
+// exists.
+func tenantPolicyFile(tenantID string) string {
+	if tenantID == defaultTenant {
+		return defaultFile
+	}
+	file := tenantConfigFile(tenantID)
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+	return file
+}
+
+// tenantConfigFile returns `<TENANT_CONFIG_DIR>/<tenantID>/policies.yaml`,
+// where the policies of tenants created from a template or by an import are
+// written when POLICY_BACKEND=file.
+func tenantConfigFile(tenantID string) string {
+	return filepath.Join(tenantConfigDir(), url.PathEscape(tenantID), TemplatePolicyFile)
+}
+
+// reservedTenantID reports whether id cannot name a tenant: the system
+// tenant, or an ID whose configuration directory is the template directory,
+// `templates` by default.
+func reservedTenantID(id string) bool {
+	if id == SystemTenant {
+		return true
+	}
+	dir := filepath.Join(tenantConfigDir(), url.PathEscape(id))
+	return filepath.Clean(dir) == filepath.Clean(templateDir())
+}
+
+// loadTenant loads a stored tenant's relationship graph, delegations and
+// policies from the configured policy backend. Failures are audited.
+func loadTenant(ctx context.Context, tenantID string) (*TenantState, error) {
+	meta, err := backend.LoadTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if meta.State() == tenant.StatusPendingDeletion {
+		return nil, errTenantDeleted
+	}
+	t := newTenantState(tenantID, tenantPolicyFile(tenantID))
+	t.SetMeta(meta)
+	err = loadGraph(ctx, t)
+	if err == nil {
+		switch policyBackend {
+		case "db":
+			err = loadPoliciesFromDB(ctx, t)
+		case "git":
+			repo, branch := meta.PolicyRepo, meta.PolicyBranch
+			if tenantID == defaultTenant && repo == "" {
+				repo, branch = os.Getenv("POLICY_GIT_URL"), os.Getenv("POLICY_GIT_BRANCH")
+			}
+			if repo != "" {
+				err = cloneTenantRepo(ctx, t, repo, branch)
+			}
+		default:
+			if t.File != "" {
+				err = loadPolicyFile(ctx, t, t.File)
+			}
+		}
+	}
+	if err != nil {
+		auditLogger.Log(logger.Entry{
+			Level:    "error",
+			TenantID: tenantID,
+			Action:   "tenant_load",
+			Reason:   err.Error(),
+		})
+		return nil, err
+	}
+	return t, nil
+}
+
+// forgetTenant discards the state kept for a tenant outside the registry.
+func forgetTenant(tenantID string) {
+	gitMu.Lock()
+	delete(gitSources, tenantID)
+	gitMu.Unlock()
+	revMu.Lock()
+	delete(storeRevisions, tenantID)
+	revMu.Unlock()
+}
+
+// evictIdleTenants unloads the tenants not used for TENANT_IDLE_TIMEOUT. They
+// are loaded again on their next request.
+func evictIdleTenants(idle time.Duration) {
+	ticker := time.NewTicker(idle / 2)
+	for range ticker.C {
+		for _, id := range tenants.EvictIdle(idle) {
+			forgetTenant(id)
+			auditLogger.Log(logger.Entry{
+				Level:    "info",
+				TenantID: id,
+				Action:   "tenant_evict",
+				Decision: "success",
+			})
+		}
+	}
+}
+
+// loadTemplate reads and validates the tenant template called name, a
+// policy bundle under TENANT_TEMPLATE_DIR whose manifest revision is the
+// template version. Its manifest should list TemplatePolicyFile as the only
+// entry point, which file-backed tenants are served from. The returned store
+// holds the template's policies.
+func loadTemplate(name string) (*bundle.Bundle, *policy.PolicyStore, error) {
+	if name == "." || name == ".." || name != filepath.Base(name) {
+		return nil, nil, errTemplateNotFound
+	}
+	dir := filepath.Join(templateDir(), name)
+	if _, err := os.Stat(filepath.Join(dir, TemplatePolicyFile)); err != nil {
+		return nil, nil, errTemplateNotFound
+	}
+	b, err := bundle.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	ps := policy.NewPolicyStore()
+	if err := ps.LoadBundle(b); err != nil {
+		return nil, nil, err
+	}
+	return b, ps, nil
+}
+
+// writeTemplateFiles copies a template's files into the tenant's
+// configuration directory.
+func writeTemplateFiles(tenantID string, b *bundle.Bundle) error {
+	dir := filepath.Join(tenantConfigDir(), url.PathEscape(tenantID))
+	for _, f := range b.Files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSnapshot writes the policies, roles and users of snap to the backend.
+func saveSnapshot(ctx context.Context, tenantID string, snap policy.Snapshot) error {
+	for _, p := range snap.Policies {
+		if err := backend.SavePolicy(ctx, tenantID, p); err != nil {
+			return err
+		}
+	}
+	for _, r := range snap.Roles {
+		if err := backend.SaveRole(ctx, tenantID, r); err != nil {
+			return err
+		}
+	}
+	for _, u := range snap.Users {
+		if err := backend.SavePolicyUser(ctx, tenantID, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeDeletedTenants removes the data of deleted tenants whose retention
+// window has ended, checking at the given interval.
+func purgeDeletedTenants(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		purgeExpiredTenants(context.Background(), time.Now())
+	}
+}
+
+// purgeExpiredTenants removes the tenants deleted more than tenantRetention
+// before now and returns their IDs.
+func purgeExpiredTenants(ctx context.Context, now time.Time) []string {
+	list, err := backend.ListTenants(ctx)
+	if err != nil {
+		return nil
+	}
+	var purged []string
+	for _, t := range list {
+		if t.State() != tenant.StatusPendingDeletion || t.DeletedAt == nil || now.Sub(*t.DeletedAt) < tenantRetention {
+			continue
+		}
+		entry := logger.Entry{Level: "info", TenantID: t.ID, Action: "tenant_purge", Decision: "success"}
+		if err := backend.DeleteTenant(ctx, t.ID); err != nil {
+			entry.Level, entry.Decision, entry.Reason = "error", "", err.Error()
+		} else {
+			purged = append(purged, t.ID)
+		}
+		auditLogger.Log(entry)
+	}
+	return purged
+}
+
+// suspendedDecision returns the denial served for a loaded tenant that is
+// suspended.
+func suspendedDecision(tenantID string) (policy.Decision, bool) {
+	t, ok := tenants.Loaded(tenantID)
+	if !ok || t.Meta().State() != tenant.StatusSuspended {
+		return policy.Decision{}, false
+	}
+	return policy.Decision{Allow: false, Reason: ReasonTenantSuspended}, true
+}
+
+// tenantRequestRate returns the requests per second allowed for a tenant's
+// tokens, or zero if unlimited.
+func tenantRequestRate(r *http.Request, tenantID string) float64 {
+	t, ok := tenants.Get(r.Context(), tenantID)
+	if !ok {
+		return 0
+	}
+	return t.Meta().Quota.RequestsPerSecond
+}
+
+// withinEdgeQuota reports whether the edge from src to dst may be added to
+// a tenant's graph. Existing edges are always accepted.
+func withinEdgeQuota(t *TenantState, src, dst string) bool {
+	n := t.Meta().Quota.MaxEdges
+	return n <= 0 || t.Graph.HasRelation(src, dst) || t.Graph.Len() < n
+}
+
+// updateTenant applies change to a stored tenant that is not pending
+// deletion and saves it. A loaded tenant sees the change at once.
+func updateTenant(ctx context.Context, tenantID string, change func(*Tenant)) (Tenant, error) {
+	meta, err := backend.LoadTenant(ctx, tenantID)
+	if err != nil {
+		return Tenant{}, errTenantNotFound
+	}
+	if meta.State() == tenant.StatusPendingDeletion {
+		return Tenant{}, errTenantDeleted
+	}
+	change(&meta)
+	if err := backend.SaveTenant(ctx, meta); err != nil {
+		return Tenant{}, err
+	}
+	if t, ok := tenants.Loaded(tenantID); ok {
+		t.SetMeta(meta)
+	}
+	return meta, nil
+}
+
+// SuspendTenant denies every access check for a tenant until it is resumed.
+// Its policies and data are kept.
+func SuspendTenant(w http.ResponseWriter, r *http.Request) {
+	setTenantStatus(w, r, "tenant_suspend", tenant.StatusSuspended)
+}
+
+// ResumeTenant serves a suspended tenant again.
+func ResumeTenant(w http.ResponseWriter, r *http.Request) {
+	setTenantStatus(w, r, "tenant_resume", tenant.StatusActive)
+}
+
+func setTenantStatus(w http.ResponseWriter, r *http.Request, action string, status tenant.Status) {
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	meta, err := updateTenant(r.Context(), req.TenantID, func(t *Tenant) { t.Status = status })
+	writeTenantUpdate(w, r, req.TenantID, action, meta, err)
+}
+
+// SetTenantQuota replaces a tenant's quota. Zero values are unlimited.
+// Lowering a quota does not remove existing items; only new ones are
+// refused.
+func SetTenantQuota(w http.ResponseWriter, r *http.Request) {
+	var req TenantQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	if err := req.Quota.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	meta, err := updateTenant(r.Context(), req.TenantID, func(t *Tenant) { t.Quota = req.Quota })
+	writeTenantUpdate(w, r, req.TenantID, "tenant_quota", meta, err)
+}
+
+// writeTenantUpdate audits and writes the result of updateTenant.
+func writeTenantUpdate(w http.ResponseWriter, r *http.Request, tenantID, action string, meta Tenant, err error) {
+	switch {
+	case errors.Is(err, errTenantNotFound), errors.Is(err, errTenantDeleted):
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "failed to save tenant", http.StatusInternalServerError)
+		return
+	}
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      tenantID,
+		Action:        action,
+		Decision:      "success",
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meta)
+}
+
+// UndeleteTenant restores a tenant that is pending deletion and loads it
+// again.
+func UndeleteTenant(w http.ResponseWriter, r *http.Request) {
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, req.TenantID); !ok {
+		return
+	}
+	meta, err := backend.LoadTenant(r.Context(), req.TenantID)
+	if err != nil {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if meta.State() != tenant.StatusPendingDeletion {
+		http.Error(w, "tenant is not pending deletion", http.StatusConflict)
+		return
+	}
+	meta.Status, meta.DeletedAt = tenant.StatusActive, nil
+	if err := backend.SaveTenant(r.Context(), meta); err != nil {
+		http.Error(w, "failed to save tenant", http.StatusInternalServerError)
+		return
+	}
+	t, err := loadTenant(r.Context(), req.TenantID)
+	if err != nil {
+		http.Error(w, "failed to load policies: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	tenants.Add(t)
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Action:        "tenant_undelete",
+		Decision:      "success",
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meta)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bradtumy/authorization-service/internal/logger"
+	"github.com/bradtumy/authorization-service/internal/middleware"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/store"
+)
+
+// PolicyVersionSummary describes one recorded policy version and what
+// changed from the version before it.
+type PolicyVersionSummary struct {
+	Version   int                  `json:"version"`
+	Author    string               `json:"author,omitempty"`
+	CreatedAt time.Time            `json:"createdAt"`
+	Message   string               `json:"message,omitempty"`
+	Changes   []store.PolicyChange `json:"changes"`
+}
+
+// PolicyDiffResponse lists the changes between two policy versions.
+type PolicyDiffResponse struct {
+	From    int                  `json:"from"`
+	To      int                  `json:"to"`
+	Changes []store.PolicyChange `json:"changes"`
+}
+
+type RollbackPoliciesRequest struct {
+	TenantID string `json:"tenantID"`
+	Version  int    `json:"version"`
+}
+
+// ListPolicyVersions returns a tenant's stored policy versions, oldest first,
+// with the policies each one added, removed or modified.
+func ListPolicyVersions(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	versions, err := backend.PolicyVersions(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "failed to load policy versions", http.StatusInternalServerError)
+		return
+	}
+	out := make([]PolicyVersionSummary, len(versions))
+	for i, v := range versions {
+		var prev []policy.Policy
+		if i > 0 {
+			prev = versions[i-1].Policies
+		}
+		changes := store.DiffPolicies(prev, v.Policies)
+		for j := range changes {
+			changes[j].Before, changes[j].After = nil, nil
+		}
+		out[i] = PolicyVersionSummary{Version: v.Version, Author: v.Author, CreatedAt: v.CreatedAt, Message: v.Message, Changes: changes}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// DiffPolicyVersions compares the policy sets of two versions. `to` defaults
+// to the latest version.
+func DiffPolicyVersions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tenantID := q.Get("tenantID")
+	if tenantID == "" {
+		http.Error(w, "missing tenantID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := requireAdmin(w, r, tenantID); !ok {
+		return
+	}
+	from, err := strconv.Atoi(q.Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from version", http.StatusBadRequest)
+		return
+	}
+	versions, err := backend.PolicyVersions(r.Context(), tenantID)
+	if err != nil {
+		http.Error(w, "failed to load policy versions", http.StatusInternalServerError)
+		return
+	}
+	to := len(versions)
+	if v := q.Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid to version", http.StatusBadRequest)
+			return
+		}
+	}
+	if from < 1 || from > len(versions) || to < 1 || to > len(versions) {
+		http.Error(w, store.ErrVersionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	changes := store.DiffPolicies(versions[from-1].Policies, versions[to-1].Policies)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PolicyDiffResponse{From: from, To: to, Changes: changes})
+}
+
+// RollbackPolicies restores the stored policies of a previous version,
+// recording the restore as a new version. The restored policies are
+// validated together with the tenant's roles and users and activated only
+// if the backend accepts the restore.
+func RollbackPolicies(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "RollbackPolicies")
+	defer span.End()
+	var req RollbackPoliciesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, ok := requireAdmin(w, r, req.TenantID)
+	if !ok {
+		return
+	}
+	ts, ok := tenants.Get(r.Context(), req.TenantID)
+	if !ok {
+		http.Error(w, "tenant not found", http.StatusNotFound)
+		return
+	}
+	target, err := backend.LoadPolicyVersion(r.Context(), req.TenantID, req.Version)
+	if errors.Is(err, store.ErrVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load policy version", http.StatusInternalServerError)
+		return
+	}
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	snap := ts.Store.Snapshot()
+	snap.Policies = make(map[string]policy.Policy, len(target.Policies))
+	for _, p := range target.Policies {
+		snap.Policies[p.ID] = p
+	}
+	var v store.PolicyVersion
+	var commitErr error
+	commit := func() error {
+		v, commitErr = backend.RollbackPolicies(store.WithAuthor(r.Context(), sub), req.TenantID, req.Version)
+		return commitErr
+	}
+	if err := ts.Store.Apply(snap, commit); err != nil {
+		if errors.Is(err, policy.ErrStaleSnapshot) {
+			http.Error(w, "policies changed during the rollback", http.StatusConflict)
+			return
+		}
+		if commitErr != nil {
+			http.Error(w, "failed to roll back policies", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "invalid policies: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	ts.setItemVersions(nil)
+	auditLogger.Log(logger.Entry{
+		Level:         "info",
+		CorrelationID: middleware.CorrelationIDFromContext(r.Context()),
+		TenantID:      req.TenantID,
+		Subject:       sub,
+		Action:        "policy_rollback",
+		Decision:      "success",
+		Reason:        v.Message,
+		Revision:      strconv.Itoa(v.Version),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/identity/local"
+	"github.com/bradtumy/authorization-service/pkg/policy"
+	"github.com/bradtumy/authorization-service/pkg/store"
+)
+
+func TestPolicyVersionEndpoints(t *testing.T) {
+	const tenantID = "versions-test"
+	idp := local.New(false)
+	identityProvider = idp
+	if _, err := idp.Create(context.Background(), tenantID, "auditor", []string{"PolicyAdmin"}); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	prevBackend := policyBackend
+	policyBackend = "db"
+	ts := newTenantState(tenantID, "")
+	ps := ts.Store
+	tenants.Add(ts)
+	defer func() {
+		policyBackend = prevBackend
+		tenants.Remove(tenantID)
+		backend.DeleteTenant(context.Background(), tenantID)
+	}()
+	request := func(method, target, body string) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		ctx := context.WithValue(r.Context(), "subject", "auditor")
+		ctx = context.WithValue(ctx, "tenant", tenantID)
+		return r.WithContext(ctx)
+	}
+
+	ctx := store.WithAuthor(context.Background(), "alice")
+	for _, p := range []policy.Policy{
+		{ID: "p1", Effect: "allow", Action: []string{"read"}, Resource: []string{"files/*"}},
+		{ID: "p1", Effect: "deny", Action: []string{"read"}, Resource: []string{"files/*"}},
+		{ID: "p2", Effect: "allow", Action: []string{"write"}, Resource: []string{"files/*"}},
+	} {
+		if err := backend.SavePolicy(ctx, tenantID, p); err != nil {
+			t.Fatalf("save policy: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ListPolicyVersions(w, request(http.MethodGet, "/policies/versions?tenantID="+tenantID, ""))
+	var versions []PolicyVersionSummary
+	if err := json.NewDecoder(w.Body).Decode(&versions); err != nil || len(versions) != 3 {
+		t.Fatalf("expected 3 versions, got %v (%v)", versions, err)
+	}
+	if v := versions[1]; v.Author != "alice" || len(v.Changes) != 1 || v.Changes[0].Type != "modified" {
+		t.Fatalf("unexpected version %#v", v)
+	}
+
+	w = httptest.NewRecorder()
+	DiffPolicyVersions(w, request(http.MethodGet, "/policies/diff?tenantID="+tenantID+"&from=1", ""))
+	var diff PolicyDiffResponse
+	if err := json.NewDecoder(w.Body).Decode(&diff); err != nil || diff.To != 3 || len(diff.Changes) != 2 {
+		t.Fatalf("unexpected diff %#v (%v)", diff, err)
+	}
+	if c := diff.Changes[0]; c.PolicyID != "p1" || c.Before.Effect != "allow" || c.After.Effect != "deny" {
+		t.Fatalf("unexpected change %#v", c)
+	}
+
+	w = httptest.NewRecorder()
+	RollbackPolicies(w, request(http.MethodPost, "/policies/rollback", `{"tenantID":"versions-test","version":7}`))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown version to return 404, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	RollbackPolicies(w, request(http.MethodPost, "/policies/rollback", `{"tenantID":"versions-test","version":1}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("rollback: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var v store.PolicyVersion
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil || v.Version != 4 || v.Author != "auditor" {
+		t.Fatalf("unexpected rollback version %#v (%v)", v, err)
+	}
+	if p, ok := ps.GetPolicy("p1"); !ok || p.Effect != "allow" {
+		t.Fatalf("expected rolled back policy to be active, got %#v", p)
+	}
+	if _, ok := ps.GetPolicy("p2"); ok {
+		t.Fatalf("expected p2 to be removed by the rollback")
+	}
+
+	if err := backend.SavePolicy(ctx, tenantID, policy.Policy{ID: "p1", Effect: "maybe"}); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+	w = httptest.NewRecorder()
+	RollbackPolicies(w, request(http.MethodPost, "/policies/rollback", `{"tenantID":"versions-test","version":5}`))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected invalid version to return 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if all, _ := backend.PolicyVersions(context.Background(), tenantID); len(all) != 5 {
+		t.Fatalf("expected rejected rollback not to be recorded, got %d versions", len(all))
+	}
+	if p, _ := ps.GetPolicy("p1"); p.Effect != "allow" {
+		t.Fatalf("expected rejected rollback to leave policies active, got %#v", p)
+	}
+
+	w = httptest.NewRecorder()
+	ListPolicyVersions(w, userRequest("auditor", http.MethodGet, "/policies/versions?tenantID="+tenantID, ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected other tenant to be forbidden, got %d", w.Code)
+	}
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/bradtumy/authorization-service/pkg/policy"
 	"github.com/bradtumy/authorization-service/pkg/validator"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
 )
 
 func main() {
@@ -35,7 +40,7 @@ func main() {
 	case "tenant":
 		handleTenant(args[1:], *addr, *token)
 	case "policy":
-		handlePolicy(args[1:])
+		handlePolicy(args[1:], *addr, *token)
 	case "check-access":
 		handleCheckAccess(args[1:], *addr, *token)
 	case "simulate":
@@ -53,17 +58,21 @@ func usage() {
 
 func handleTenant(args []string, addr, token string) {
 	if len(args) < 1 {
-		fmt.Println("usage: authzctl tenant <create|delete> <id>")
+		fmt.Println("usage: authzctl tenant <create|delete|undelete|suspend|resume|export|import|issuers> <id>")
 		os.Exit(1)
 	}
 	client := &http.Client{}
 	switch args[0] {
 	case "create":
-		if len(args) < 2 {
-			fmt.Println("usage: authzctl tenant create <id>")
+		fs := flag.NewFlagSet("tenant create", flag.ExitOnError)
+		template := fs.String("template", "", "template the tenant's policies are copied from")
+		fs.Parse(args[1:])
+		if fs.NArg() < 1 {
+			fmt.Println("usage: authzctl tenant create [--template NAME] <id>")
 			os.Exit(1)
 		}
-		data, _ := json.Marshal(map[string]string{"tenantID": args[1], "name": args[1]})
+		id := fs.Arg(0)
+		data, _ := json.Marshal(map[string]string{"tenantID": id, "name": id, "template": *template})
 		req, _ := http.NewRequest(http.MethodPost, addr+"/tenant/create", bytes.NewReader(data))
 		req.Header.Set("Content-Type", "application/json")
 		if token != "" {
@@ -80,13 +89,119 @@ func handleTenant(args []string, addr, token string) {
 		if resp.StatusCode >= 300 {
 			os.Exit(1)
 		}
-	case "delete":
+	case "delete", "undelete", "suspend", "resume":
 		if len(args) < 2 {
-			fmt.Println("usage: authzctl tenant delete <id>")
+			fmt.Printf("usage: authzctl tenant %s <id>\n", args[0])
 			os.Exit(1)
 		}
 		data, _ := json.Marshal(map[string]string{"tenantID": args[1]})
-		req, _ := http.NewRequest(http.MethodPost, addr+"/tenant/delete", bytes.NewReader(data))
+		req, _ := http.NewRequest(http.MethodPost, addr+"/tenant/"+args[0], bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Println("request error:", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Println(string(body))
+		if resp.StatusCode >= 300 {
+			os.Exit(1)
+		}
+	case "export":
+		fs := flag.NewFlagSet("tenant export", flag.ExitOnError)
+		out := fs.String("out", "", "file the archive is written to (default stdout)")
+		fs.Parse(args[1:])
+		if fs.NArg() < 1 {
+			fmt.Println("usage: authzctl tenant export [--out FILE] <id>")
+			os.Exit(1)
+		}
+		req, _ := http.NewRequest(http.MethodGet, addr+"/tenant/export?tenantID="+url.QueryEscape(fs.Arg(0)), nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Println("request error:", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode >= 300 || *out == "" {
+			fmt.Println(string(body))
+		}
+		if resp.StatusCode >= 300 {
+			os.Exit(1)
+		}
+		if *out != "" {
+			if err := os.WriteFile(*out, body, 0644); err != nil {
+				fmt.Println("write error:", err)
+				os.Exit(1)
+			}
+		}
+	case "import":
+		fs := flag.NewFlagSet("tenant import", flag.ExitOnError)
+		tenantID := fs.String("tenant", "", "tenant to import into (default the archived tenant)")
+		onConflict := fs.String("on-conflict", "fail", "what to do with conflicting items: fail, skip or overwrite")
+		dryRun := fs.Bool("dry-run", false, "report the changes without making them")
+		idMap := map[string]map[string]string{}
+		kinds := map[string]string{"policy": "policies", "role": "roles", "user": "users", "node": "nodes"}
+		fs.Func("map", "rename an item in the archive, as KIND:OLD=NEW with KIND policy, role, user or node (repeatable)", func(v string) error {
+			kind, rename, _ := strings.Cut(v, ":")
+			from, to, ok := strings.Cut(rename, "=")
+			key, known := kinds[kind]
+			if !known || !ok || from == "" || to == "" {
+				return fmt.Errorf("expected KIND:OLD=NEW with KIND policy, role, user or node")
+			}
+			if idMap[key] == nil {
+				idMap[key] = map[string]string{}
+			}
+			idMap[key][from] = to
+			return nil
+		})
+		fs.Parse(args[1:])
+		if fs.NArg() < 1 {
+			fmt.Println("usage: authzctl tenant import [--tenant ID] [--on-conflict MODE] [--dry-run] [--map KIND:OLD=NEW]... <file>")
+			os.Exit(1)
+		}
+		archive, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Println("read error:", err)
+			os.Exit(1)
+		}
+		data, _ := json.Marshal(map[string]any{
+			"tenantID":   *tenantID,
+			"idMap":      idMap,
+			"onConflict": *onConflict,
+			"dryRun":     *dryRun,
+			"archive":    json.RawMessage(archive),
+		})
+		req, _ := http.NewRequest(http.MethodPost, addr+"/tenant/import", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Println("request error:", err)
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Println(string(body))
+		if resp.StatusCode >= 300 {
+			os.Exit(1)
+		}
+	case "issuers":
+		if len(args) < 2 {
+			fmt.Println("usage: authzctl tenant issuers <id> [did]...")
+			os.Exit(1)
+		}
+		data, _ := json.Marshal(map[string]any{"tenantID": args[1], "issuers": append([]string{}, args[2:]...)})
+		req, _ := http.NewRequest(http.MethodPost, addr+"/tenant/issuers", bytes.NewReader(data))
 		req.Header.Set("Content-Type", "application/json")
 		if token != "" {
 			req.Header.Set("Authorization", "Bearer "+token)
@@ -103,21 +218,134 @@ func handleTenant(args []string, addr, token string) {
 			os.Exit(1)
 		}
 	default:
-		fmt.Println("usage: authzctl tenant <create|delete> <id>")
+		fmt.Println("usage: authzctl tenant <create|delete|undelete|suspend|resume|export|import|issuers> <id>")
 		os.Exit(1)
 	}
 }
 
-func handlePolicy(args []string) {
-	if len(args) < 2 || args[0] != "validate" {
-		fmt.Println("usage: authzctl policy validate <file>")
+func handlePolicy(args []string, addr, token string) {
+	if len(args) < 1 {
+		policyUsage()
+	}
+	switch args[0] {
+	case "validate":
+		if len(args) < 2 {
+			policyUsage()
+		}
+		if err := validator.ValidatePolicyFile(args[1]); err != nil {
+			fmt.Println("invalid policy:", err)
+			os.Exit(1)
+		}
+		fmt.Println("policy is valid")
+	case "create", "update":
+		fs := flag.NewFlagSet("policy "+args[0], flag.ExitOnError)
+		tenant := fs.String("tenant", "", "tenant ID")
+		etag := fs.String("etag", "", "ETag the update must match (defaults to the current one)")
+		fs.Parse(args[1:])
+		if *tenant == "" || fs.NArg() < 1 {
+			policyUsage()
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Println("read policy:", err)
+			os.Exit(1)
+		}
+		var p policy.Policy
+		if err := yaml.Unmarshal(data, &p); err != nil {
+			fmt.Println("invalid policy:", err)
+			os.Exit(1)
+		}
+		payload, _ := json.Marshal(map[string]any{"tenantID": *tenant, "policy": p})
+		headers := map[string]string{}
+		if args[0] == "update" {
+			match := *etag
+			if match == "" {
+				resp, _ := policyRequest(http.MethodGet, addr+"/policies/get?"+policyQuery(*tenant, p.ID), token, nil, nil)
+				match = resp.Header.Get("ETag")
+			}
+			headers["If-Match"] = match
+		}
+		resp, body := policyRequest(http.MethodPost, addr+"/policies/"+args[0], token, payload, headers)
+		printPolicyResponse(resp, body)
+	case "get", "delete":
+		fs := flag.NewFlagSet("policy "+args[0], flag.ExitOnError)
+		tenant := fs.String("tenant", "", "tenant ID")
+		fs.Parse(args[1:])
+		if *tenant == "" || fs.NArg() < 1 {
+			policyUsage()
+		}
+		var resp *http.Response
+		var body []byte
+		if args[0] == "get" {
+			resp, body = policyRequest(http.MethodGet, addr+"/policies/get?"+policyQuery(*tenant, fs.Arg(0)), token, nil, nil)
+		} else {
+			payload, _ := json.Marshal(map[string]string{"tenantID": *tenant, "id": fs.Arg(0)})
+			resp, body = policyRequest(http.MethodPost, addr+"/policies/delete", token, payload, nil)
+		}
+		printPolicyResponse(resp, body)
+	case "list":
+		fs := flag.NewFlagSet("policy list", flag.ExitOnError)
+		tenant := fs.String("tenant", "", "tenant ID")
+		offset := fs.Int("offset", 0, "index of the first policy to list")
+		limit := fs.Int("limit", 100, "maximum number of policies to list")
+		fs.Parse(args[1:])
+		if *tenant == "" {
+			policyUsage()
+		}
+		q := url.Values{"tenantID": {*tenant}, "offset": {strconv.Itoa(*offset)}, "limit": {strconv.Itoa(*limit)}}
+		resp, body := policyRequest(http.MethodGet, addr+"/policies/list?"+q.Encode(), token, nil, nil)
+		printPolicyResponse(resp, body)
+	default:
+		policyUsage()
+	}
+}
+
+func policyUsage() {
+	fmt.Println("usage: authzctl policy validate <file>")
+	fmt.Println("       authzctl policy create --tenant TENANT <file>")
+	fmt.Println("       authzctl policy update --tenant TENANT [--etag ETAG] <file>")
+	fmt.Println("       authzctl policy get --tenant TENANT <id>")
+	fmt.Println("       authzctl policy delete --tenant TENANT <id>")
+	fmt.Println("       authzctl policy list --tenant TENANT [--offset N] [--limit N]")
+	os.Exit(1)
+}
+
+func policyQuery(tenant, id string) string {
+	return url.Values{"tenantID": {tenant}, "id": {id}}.Encode()
+}
+
+// policyRequest sends a request to the policy endpoints and returns the
+// response with its body read.
+func policyRequest(method, target, token string, payload []byte, headers map[string]string) (*http.Response, []byte) {
+	req, _ := http.NewRequest(method, target, bytes.NewReader(payload))
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Println("request error:", err)
 		os.Exit(1)
 	}
-	if err := validator.ValidatePolicyFile(args[1]); err != nil {
-		fmt.Println("invalid policy:", err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func printPolicyResponse(resp *http.Response, body []byte) {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		fmt.Println("ETag:", etag)
+	}
+	fmt.Println(strings.TrimSpace(string(body)))
+	if resp.StatusCode >= 300 {
 		os.Exit(1)
 	}
-	fmt.Println("policy is valid")
 }
 
 func handleCheckAccess(args []string, addr, token string) {
@@ -126,9 +354,15 @@ func handleCheckAccess(args []string, addr, token string) {
 	subject := fs.String("subject", "", "subject performing the action")
 	resource := fs.String("resource", "", "resource being accessed")
 	action := fs.String("action", "", "action to check")
+	batch := fs.String("batch", "", "JSONL file with one {\"resource\",\"action\"} object per line")
 	fs.Parse(args)
+	if *batch != "" {
+		handleCheckAccessBatch(*batch, *tenant, *subject, addr, token)
+		return
+	}
 	if *tenant == "" || *subject == "" || *resource == "" || *action == "" {
 		fmt.Println("usage: authzctl check-access --tenant TENANT --subject SUBJECT --resource RESOURCE --action ACTION")
+		fmt.Println("       authzctl check-access --tenant TENANT --subject SUBJECT --batch FILE.jsonl")
 		os.Exit(1)
 	}
 	payload, _ := json.Marshal(map[string]any{
@@ -166,6 +400,87 @@ func handleCheckAccess(args []string, addr, token string) {
 	}
 }
 
+func handleCheckAccessBatch(file, tenant, subject, addr, token string) {
+	if tenant == "" || subject == "" {
+		fmt.Println("usage: authzctl check-access --tenant TENANT --subject SUBJECT --batch FILE.jsonl")
+		os.Exit(1)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Println("read batch:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	type item struct {
+		Resource string `json:"resource"`
+		Action   string `json:"action"`
+	}
+	var items []item
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var it item
+		if err := json.Unmarshal([]byte(text), &it); err != nil {
+			fmt.Printf("invalid batch line %d: %v\n", line, err)
+			os.Exit(1)
+		}
+		items = append(items, it)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Println("read batch:", err)
+		os.Exit(1)
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"tenantID":   tenant,
+		"subject":    subject,
+		"items":      items,
+		"conditions": map[string]any{},
+	})
+	req, _ := http.NewRequest(http.MethodPost, addr+"/check-access/batch", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Println("request error:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+	var result struct {
+		Decisions []json.RawMessage `json:"decisions"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		fmt.Println(string(body))
+		os.Exit(1)
+	}
+	// Print one decision per line to mirror the input file.
+	allowAll := true
+	for _, raw := range result.Decisions {
+		fmt.Println(string(raw))
+		var dec struct {
+			Allow bool `json:"allow"`
+		}
+		if json.Unmarshal(raw, &dec) != nil || !dec.Allow {
+			allowAll = false
+		}
+	}
+	if !allowAll {
+		os.Exit(1)
+	}
+}
+
 func handleSimulate(args []string, addr, token string) {
 	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
 	tenant := fs.String("tenant", "", "tenant ID")
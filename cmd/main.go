@@ -55,6 +55,9 @@ func main() {
 	}
 
 	user.SetProvider(idProvider)
+	if err := api.Init(); err != nil {
+		log.Fatalf("failed to initialize: %v", err)
+	}
 	router := api.SetupRouter(idProvider)
 	log.Println("Starting server on :", port)
 	log.Fatal(http.ListenAndServe(":"+port, router))
@@ -2,13 +2,21 @@ package main
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/bradtumy/authorization-service/pkg/graph"
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+	"github.com/bradtumy/authorization-service/pkg/policy"
 	"github.com/bradtumy/authorization-service/pkg/policycompiler"
 	"github.com/bradtumy/authorization-service/pkg/validator"
 )
@@ -34,7 +42,7 @@ func main() {
 		fmt.Println(yaml)
 	case "validate":
 		if len(os.Args) < 3 {
-			fmt.Println("usage: policyctl validate <file.yaml>")
+			fmt.Println("usage: policyctl validate <file.yaml|bundle-dir>")
 			os.Exit(1)
 		}
 		if err := validator.ValidatePolicyFile(os.Args[2]); err != nil {
@@ -46,8 +54,12 @@ func main() {
 		handleTenant(os.Args[2:])
 	case "graph":
 		handleGraph(os.Args[2:])
+	case "bundle":
+		handleBundle(os.Args[2:])
+	case "migrate":
+		handleMigrate(os.Args[2:])
 	default:
-		fmt.Println("usage: policyctl <compile|validate|tenant|graph> ...")
+		fmt.Println("usage: policyctl <compile|validate|tenant|graph|bundle|migrate> ...")
 		os.Exit(1)
 	}
 }
@@ -123,61 +135,343 @@ func handleTenant(args []string) {
 
 func handleGraph(args []string) {
 	if len(args) < 1 {
-		fmt.Println("usage: policyctl graph <add|list|delegate> ...")
+		fmt.Println("usage: policyctl graph <add|remove|list|delegate|revoke|delegations> [--tenant id] ...")
 		os.Exit(1)
 	}
-	file := "graph.json"
-	g := loadGraphFile(file)
+	fs := flag.NewFlagSet("graph "+args[0], flag.ExitOnError)
+	tenantID := fs.String("tenant", "default", "tenant ID")
+	expires := fs.String("expires", "", "delegation expiry as a duration (24h) or RFC 3339 time")
+	actions := fs.String("actions", "", "comma-separated actions the delegation covers")
+	resources := fs.String("resources", "", "comma-separated resources the delegation covers")
+	fs.Parse(args[1:])
+	rest := fs.Args()
 	switch args[0] {
-	case "add":
-		if len(args) < 3 {
-			fmt.Println("usage: policyctl graph add <src> <dst>")
+	case "add", "remove":
+		if len(rest) < 2 {
+			fmt.Printf("usage: policyctl graph %s [--tenant id] <src> <dst>\n", args[0])
 			os.Exit(1)
 		}
-		g.AddRelation(args[1], args[2])
-		saveGraphFile(file, g)
-		fmt.Println("relationship added")
+		body := map[string]string{"tenantID": *tenantID, "src": rest[0], "dst": rest[1]}
+		serverRequest(http.MethodPost, "/graph/"+args[0], body)
+		if args[0] == "add" {
+			fmt.Println("relationship added")
+		} else {
+			fmt.Println("relationship removed")
+		}
 	case "delegate":
-		if len(args) < 3 {
-			fmt.Println("usage: policyctl graph delegate <delegator> <delegatee>")
+		if len(rest) < 2 {
+			fmt.Println("usage: policyctl graph delegate [--tenant id] [--expires 24h|RFC3339] [--actions a,b] [--resources r1,r2] <delegator> <delegatee>")
+			os.Exit(1)
+		}
+		body := map[string]interface{}{
+			"tenantID":  *tenantID,
+			"delegator": rest[0],
+			"delegate":  rest[1],
+			"actions":   splitList(*actions),
+			"resources": splitList(*resources),
+		}
+		if *expires != "" {
+			exp, err := parseExpiry(*expires, time.Now())
+			if err != nil {
+				fmt.Println("invalid --expires:", err)
+				os.Exit(1)
+			}
+			body["expiresAt"] = exp.Format(time.RFC3339)
+		}
+		data := serverRequest(http.MethodPost, "/delegations/create", body)
+		var d struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(data, &d)
+		fmt.Println("delegation added:", d.ID)
+	case "revoke":
+		if len(rest) < 1 {
+			fmt.Println("usage: policyctl graph revoke [--tenant id] <delegation-id>")
 			os.Exit(1)
 		}
-		g.AddRelation("user:"+args[1], "user:"+args[2])
-		saveGraphFile(file, g)
-		fmt.Println("delegation added")
+		serverRequest(http.MethodPost, "/delegations/revoke", map[string]string{"tenantID": *tenantID, "id": rest[0]})
+		fmt.Println("delegation revoked")
+	case "delegations":
+		data := serverRequest(http.MethodGet, "/delegations/list?tenantID="+url.QueryEscape(*tenantID), nil)
+		var list []struct {
+			ID        string   `json:"id"`
+			Delegator string   `json:"delegator"`
+			Delegate  string   `json:"delegate"`
+			Actions   []string `json:"actions"`
+			Resources []string `json:"resources"`
+			ExpiresAt string   `json:"expiresAt"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil {
+			fmt.Println("invalid response:", err)
+			os.Exit(1)
+		}
+		for _, d := range list {
+			fmt.Printf("%s: %s acts for %s actions=%s resources=%s expires=%s\n", d.ID, d.Delegate, d.Delegator,
+				strings.Join(d.Actions, ","), strings.Join(d.Resources, ","), d.ExpiresAt)
+		}
 	case "list":
-		rels := g.List()
-		for src, targets := range rels {
-			for _, dst := range targets {
-				fmt.Printf("%s -> %s\n", src, dst)
+		data := serverRequest(http.MethodGet, "/graph/list?tenantID="+url.QueryEscape(*tenantID), nil)
+		var rels []struct {
+			Src string `json:"src"`
+			Dst string `json:"dst"`
+		}
+		if err := json.Unmarshal(data, &rels); err != nil {
+			fmt.Println("invalid response:", err)
+			os.Exit(1)
+		}
+		for _, r := range rels {
+			fmt.Printf("%s -> %s\n", r.Src, r.Dst)
+		}
+	default:
+		fmt.Println("usage: policyctl graph <add|remove|list|delegate|revoke|delegations> [--tenant id] ...")
+		os.Exit(1)
+	}
+}
+
+func handleBundle(args []string) {
+	if len(args) < 1 {
+		fmt.Println("usage: policyctl bundle <build|keygen|sign|verify> ...")
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("bundle "+args[0], flag.ExitOnError)
+	keyFile := fs.String("key", "", "PEM encoded ed25519 private key")
+	pubFiles := fs.String("pub", "", "comma-separated PEM encoded ed25519 public keys")
+	fs.Parse(args[1:])
+	rest := fs.Args()
+	switch args[0] {
+	case "build":
+		if len(rest) < 2 {
+			fmt.Println("usage: policyctl bundle build <bundle-dir> <out.tar.gz>")
+			os.Exit(1)
+		}
+		if err := validator.ValidatePolicyFile(rest[0]); err != nil {
+			fmt.Println("invalid policy:", err)
+			os.Exit(1)
+		}
+		data, err := bundle.Build(rest[0])
+		if err != nil {
+			fmt.Println("build error:", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(rest[1], data, 0644); err != nil {
+			fmt.Println("write error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("bundle built:", bundle.Digest(data))
+	case "keygen":
+		if len(rest) < 1 {
+			fmt.Println("usage: policyctl bundle keygen <name>")
+			os.Exit(1)
+		}
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			fmt.Println("keygen error:", err)
+			os.Exit(1)
+		}
+		privPEM, _ := bundle.MarshalPrivateKey(priv)
+		pubPEM, _ := bundle.MarshalPublicKey(pub)
+		if err := os.WriteFile(rest[0]+".key", privPEM, 0600); err != nil {
+			fmt.Println("write error:", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(rest[0]+".pub", pubPEM, 0644); err != nil {
+			fmt.Println("write error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("key pair written:", bundle.KeyID(pub))
+	case "sign":
+		if len(rest) < 1 || *keyFile == "" {
+			fmt.Println("usage: policyctl bundle sign --key <private.pem> <bundle.tar.gz>")
+			os.Exit(1)
+		}
+		keyData, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Println("read error:", err)
+			os.Exit(1)
+		}
+		key, err := bundle.ParsePrivateKey(keyData)
+		if err != nil {
+			fmt.Println("invalid key:", err)
+			os.Exit(1)
+		}
+		data, err := os.ReadFile(rest[0])
+		if err != nil {
+			fmt.Println("read error:", err)
+			os.Exit(1)
+		}
+		sig, _ := json.MarshalIndent(bundle.Sign(data, key), "", "  ")
+		if err := os.WriteFile(rest[0]+bundle.SignatureSuffix, sig, 0644); err != nil {
+			fmt.Println("write error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("bundle signed:", rest[0]+bundle.SignatureSuffix)
+	case "verify":
+		if len(rest) < 1 || *pubFiles == "" {
+			fmt.Println("usage: policyctl bundle verify --pub <key.pem[,key.pem]> <bundle.tar.gz>")
+			os.Exit(1)
+		}
+		var keys []ed25519.PublicKey
+		for _, f := range splitList(*pubFiles) {
+			keyData, err := os.ReadFile(f)
+			if err != nil {
+				fmt.Println("read error:", err)
+				os.Exit(1)
+			}
+			k, err := bundle.ParsePublicKey(keyData)
+			if err != nil {
+				fmt.Println("invalid key:", err)
+				os.Exit(1)
 			}
+			keys = append(keys, k)
+		}
+		data, err := os.ReadFile(rest[0])
+		if err != nil {
+			fmt.Println("read error:", err)
+			os.Exit(1)
+		}
+		sigData, err := os.ReadFile(rest[0] + bundle.SignatureSuffix)
+		if err != nil {
+			fmt.Println("read error:", err)
+			os.Exit(1)
+		}
+		sig, err := bundle.ParseSignature(sigData)
+		if err == nil {
+			err = bundle.Verify(data, sig, keys)
+		}
+		if err != nil {
+			fmt.Println("verification failed:", err)
+			os.Exit(1)
 		}
+		if _, err := bundle.ReadArchive(data); err != nil {
+			fmt.Println("invalid bundle:", err)
+			os.Exit(1)
+		}
+		fmt.Println("bundle verified:", sig.Digest)
 	default:
-		fmt.Println("usage: policyctl graph <add|list|delegate> ...")
+		fmt.Println("usage: policyctl bundle <build|keygen|sign|verify> ...")
 		os.Exit(1)
 	}
 }
 
-func loadGraphFile(path string) *graph.Graph {
-	g := graph.New()
-	data, err := os.ReadFile(path)
+// serverRequest sends a request to the authorization service configured by
+// POLICYCTL_ADDR and POLICYCTL_TOKEN and returns the response body. It exits
+// on transport errors and non-2xx responses.
+// handleMigrate rewrites a legacy permission file or rule list into the
+// policy schema. Without --tenant, every tenant of a permission file is
+// written to <out>/<tenant>/policies.yaml, out defaulting to configs.
+func handleMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	tenantID := fs.String("tenant", "", "tenant of a permission file to migrate")
+	out := fs.String("out", "", "output file, or directory when migrating every tenant")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Println("usage: policyctl migrate [--tenant id] [--out path] <legacy-file|rules-dir>")
+		os.Exit(1)
+	}
+	src := fs.Arg(0)
+	var tenants []string
+	if data, err := os.ReadFile(src); err == nil && *tenantID == "" && policy.DetectFormat(data) == policy.FormatPermissions {
+		if tenants, err = policy.PermissionTenants(data); err != nil {
+			fmt.Println("read error:", err)
+			os.Exit(1)
+		}
+	}
+	if tenants == nil {
+		writeMigrated(src, *tenantID, *out)
+		return
+	}
+	dir := *out
+	if dir == "" {
+		dir = "configs"
+	}
+	for _, t := range tenants {
+		writeMigrated(src, t, filepath.Join(dir, url.PathEscape(t), "policies.yaml"))
+	}
+}
+
+// writeMigrated converts the tenant's policies of the legacy file at src and
+// writes them to out, or stdout when out is empty.
+func writeMigrated(src, tenantID, out string) {
+	doc, ok, err := policy.ConvertLegacy(src, tenantID)
 	if err != nil {
-		return g
+		fmt.Println("migrate error:", err)
+		os.Exit(1)
 	}
-	var m map[string][]string
-	if err := json.Unmarshal(data, &m); err != nil {
-		return g
+	if !ok {
+		fmt.Println(src, "is already in the policy schema")
+		return
 	}
-	for src, targets := range m {
-		for _, dst := range targets {
-			g.AddRelation(src, dst)
+	data, err := doc.Marshal()
+	if err != nil {
+		fmt.Println("migrate error:", err)
+		os.Exit(1)
+	}
+	if err := validator.ValidatePolicyData(data); err != nil {
+		fmt.Println("invalid policy:", err)
+		os.Exit(1)
+	}
+	if out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		fmt.Println("write error:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		fmt.Println("write error:", err)
+		os.Exit(1)
+	}
+	fmt.Println("migrated policies written:", out)
+}
+
+func serverRequest(method, path string, payload any) []byte {
+	base := os.Getenv("POLICYCTL_ADDR")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	var body io.Reader
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		body = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, base+path, body)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := os.Getenv("POLICYCTL_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Println("request error:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		fmt.Printf("request failed: %s: %s\n", resp.Status, bytes.TrimSpace(data))
+		os.Exit(1)
+	}
+	return data
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
 		}
 	}
-	return g
+	return out
 }
 
-func saveGraphFile(path string, g *graph.Graph) {
-	m := g.List()
-	data, _ := json.MarshalIndent(m, "", "  ")
-	os.WriteFile(path, data, 0644)
+// parseExpiry reads --expires as either a duration from now or an RFC 3339
+// timestamp.
+func parseExpiry(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	return time.Parse(time.RFC3339, s)
 }
@@ -0,0 +1,62 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/bradtumy/authorization-service/internal/identity"
+	"github.com/bradtumy/authorization-service/internal/policy"
+	engine "github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+// EngineSource returns the policy engine of a tenant, and false when the
+// tenant is unknown.
+type EngineSource func(tenant string) (*engine.PolicyEngine, bool)
+
+// PolicyAuthorizer implements Authorizer with the policy engine of the
+// principal's tenant, so that callers share the service's policies instead
+// of a separate permission file.
+type PolicyAuthorizer struct {
+	engines EngineSource
+}
+
+// NewPolicyAuthorizer creates an authorizer evaluating against the engines
+// returned by engines.
+func NewPolicyAuthorizer(engines EngineSource) *PolicyAuthorizer {
+	return &PolicyAuthorizer{engines: engines}
+}
+
+// IsAllowed evaluates the permission's action on its resource (see
+// engine.SplitPermission) for the principal's roles, to which the engine adds
+// engine.AllUsersRole.
+// The principal's attributes and the resource map are passed as context
+// values, the resource map taking precedence. Principals without a tenant
+// belong to "default"; unknown tenants are denied.
+func (a *PolicyAuthorizer) IsAllowed(ctx context.Context, p identity.Principal, perm policy.Permission, resource map[string]string) (bool, error) {
+	tenant := p.Tenant
+	if tenant == "" {
+		tenant = "default"
+	}
+	pe, ok := a.engines(tenant)
+	if !ok {
+		return false, nil
+	}
+	env := make(map[string]string, len(p.Attrs)+len(resource)+1)
+	for k, v := range p.Attrs {
+		env[k] = v
+	}
+	for k, v := range resource {
+		env[k] = v
+	}
+	env["tenantID"] = tenant
+	res, action := engine.SplitPermission(string(perm))
+	dec := pe.EvaluateRequest(ctx, engine.Request{
+		Subject:  p.Subject,
+		Resource: res,
+		Action:   action,
+		Env:      env,
+		Roles:    append([]string{}, p.Roles...),
+	})
+	return dec.Allow, nil
+}
+
+var _ Authorizer = (*PolicyAuthorizer)(nil)
@@ -0,0 +1,57 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/internal/identity"
+	"github.com/bradtumy/authorization-service/internal/policy"
+	engine "github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+func newPolicyAuthorizer(t *testing.T) Authorizer {
+	path := writePolicy(t, testPolicy)
+	engines := make(map[string]*engine.PolicyEngine)
+	for _, tenant := range []string{"default", "acme"} {
+		store := engine.NewPolicyStore()
+		b, err := engine.ReadBundle(path, tenant)
+		if err != nil {
+			t.Fatalf("read %s: %v", tenant, err)
+		}
+		if err := store.LoadBundle(b); err != nil {
+			t.Fatalf("load %s: %v", tenant, err)
+		}
+		engines[tenant] = engine.NewPolicyEngine(store, nil)
+	}
+	return NewPolicyAuthorizer(func(tenant string) (*engine.PolicyEngine, bool) {
+		pe, ok := engines[tenant]
+		return pe, ok
+	})
+}
+
+func TestPolicyAuthorizer(t *testing.T) {
+	a := newPolicyAuthorizer(t)
+	ctx := context.Background()
+	cases := []struct {
+		p     identity.Principal
+		perm  string
+		allow bool
+	}{
+		{identity.Principal{Subject: "a", Roles: []string{"admin"}}, "user:create", true},
+		{identity.Principal{Subject: "v", Roles: []string{"viewer"}}, "user:create", false},
+		{identity.Principal{Subject: "v", Roles: []string{"viewer", "admin"}}, "policy:read", true},
+		{identity.Principal{Subject: "a", Tenant: "acme", Roles: []string{"admin"}}, "user:list", true},
+		{identity.Principal{Subject: "a", Tenant: "acme", Roles: []string{"admin"}}, "user:create", false},
+		{identity.Principal{Subject: "b", Roles: []string{"bogus"}}, "user:list", false},
+		{identity.Principal{Subject: "a", Tenant: "unknown", Roles: []string{"admin"}}, "user:list", false},
+	}
+	for _, tc := range cases {
+		ok, err := a.IsAllowed(ctx, tc.p, policy.Permission(tc.perm), nil)
+		if err != nil {
+			t.Fatalf("%v %s: unexpected error: %v", tc.p, tc.perm, err)
+		}
+		if ok != tc.allow {
+			t.Errorf("%v %s: expected allow=%v", tc.p, tc.perm, tc.allow)
+		}
+	}
+}
@@ -15,6 +15,9 @@ import (
 )
 
 // RBAC implements role-based access control Authorizer.
+//
+// Deprecated: use PolicyAuthorizer, which evaluates the policy engine's
+// policies. policyctl migrate converts permission files for it.
 type RBAC struct {
 	store policy.PolicyStore
 	ttl   time.Duration
@@ -1,3 +1,7 @@
+// Package evaluator evaluates verifiable credentials against ordered rules.
+//
+// Deprecated: the policy engine (package pkg/policy) loads rule files
+// directly; policyctl migrate rewrites them into its schema.
 package evaluator
 
 import (
@@ -19,6 +19,12 @@ type Entry struct {
 	Decision      string    `json:"decision,omitempty"`
 	PolicyID      string    `json:"policy_id,omitempty"`
 	Reason        string    `json:"reason,omitempty"`
+	// Revision identifies the policy revision involved, if known.
+	Revision string `json:"revision,omitempty"`
+	// Digest identifies the policy bundle archive involved, if any.
+	Digest string `json:"digest,omitempty"`
+	// Commit is the source control revision of the policies involved.
+	Commit string `json:"commit,omitempty"`
 }
 
 type Level int
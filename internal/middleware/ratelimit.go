@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket refilled at rate tokens per second, holding at
+// most one second's worth of tokens.
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and removes a token, reporting whether one was
+// available.
+func (b *bucket) take(now time.Time, rate float64) bool {
+	burst := math.Max(1, math.Ceil(rate))
+	if b.rate != rate {
+		b.rate, b.tokens = rate, burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimitMiddleware rejects requests with 429 once the tenant in the
+// request context exceeds limit(r, tenantID) requests per second. A limit of
+// zero or less is unlimited; requests without a tenant are not limited.
+func RateLimitMiddleware(limit func(r *http.Request, tenantID string) float64) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	buckets := make(map[string]*bucket)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, _ := r.Context().Value("tenant").(string)
+			if tenant == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rate := limit(r, tenant)
+			if rate <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			mu.Lock()
+			b, ok := buckets[tenant]
+			if !ok {
+				b = &bucket{}
+				buckets[tenant] = b
+			}
+			allowed := b.take(time.Now(), rate)
+			mu.Unlock()
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/rate))))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
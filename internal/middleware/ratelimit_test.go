@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limits := map[string]float64{"limited": 2}
+	h := RateLimitMiddleware(func(r *http.Request, tenantID string) float64 {
+		return limits[tenantID]
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(tenant string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), "tenant", tenant))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	for i := 0; i < 2; i++ {
+		if code := do("limited"); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	if code := do("limited"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected burst above the limit to be rejected, got %d", code)
+	}
+	for i := 0; i < 5; i++ {
+		if code := do("unlimited"); code != http.StatusOK {
+			t.Fatalf("expected tenant without a limit to be served, got %d", code)
+		}
+	}
+}
@@ -9,6 +9,9 @@ import (
 )
 
 // FileStore loads policy definitions from a YAML file.
+//
+// Deprecated: the policy engine (package pkg/policy) loads permission files
+// directly; policyctl migrate rewrites them into its schema.
 type FileStore struct {
 	mu       sync.RWMutex
 	policies map[string]map[string][]Permission // tenant -> role -> perms
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS resources (
+    tenant_id TEXT,
+    resource_id TEXT,
+    attributes TEXT,
+    PRIMARY KEY (tenant_id, resource_id)
+);
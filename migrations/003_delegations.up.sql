@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS delegations (
+    tenant_id TEXT,
+    delegation_id TEXT,
+    delegation TEXT,
+    PRIMARY KEY (tenant_id, delegation_id)
+);
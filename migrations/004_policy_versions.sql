@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS policy_versions (
+    tenant_id TEXT,
+    version INTEGER,
+    author TEXT,
+    created_at INTEGER,
+    message TEXT,
+    policies TEXT,
+    PRIMARY KEY (tenant_id, version)
+);
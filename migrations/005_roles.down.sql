@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS policy_users;
+DROP TABLE IF EXISTS role_policies;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS roles (
+    tenant_id TEXT,
+    name TEXT,
+    inherits TEXT,
+    PRIMARY KEY (tenant_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS role_policies (
+    tenant_id TEXT,
+    role TEXT,
+    policy_id TEXT,
+    PRIMARY KEY (tenant_id, role, policy_id)
+);
+
+CREATE TABLE IF NOT EXISTS policy_users (
+    tenant_id TEXT,
+    username TEXT,
+    roles TEXT,
+    PRIMARY KEY (tenant_id, username)
+);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS policy_revisions (
+    tenant_id TEXT PRIMARY KEY,
+    revision INTEGER
+);
@@ -0,0 +1,4 @@
+ALTER TABLE tenants DROP COLUMN policy_branch;
+ALTER TABLE tenants DROP COLUMN policy_repo;
+ALTER TABLE tenants DROP COLUMN template_version;
+ALTER TABLE tenants DROP COLUMN template;
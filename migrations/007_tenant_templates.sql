@@ -0,0 +1,4 @@
+ALTER TABLE tenants ADD COLUMN template TEXT DEFAULT '';
+ALTER TABLE tenants ADD COLUMN template_version TEXT DEFAULT '';
+ALTER TABLE tenants ADD COLUMN policy_repo TEXT DEFAULT '';
+ALTER TABLE tenants ADD COLUMN policy_branch TEXT DEFAULT '';
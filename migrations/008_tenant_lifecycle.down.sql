@@ -0,0 +1,3 @@
+ALTER TABLE tenants DROP COLUMN quota;
+ALTER TABLE tenants DROP COLUMN deleted_at;
+ALTER TABLE tenants DROP COLUMN status;
@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN status TEXT DEFAULT 'active';
+ALTER TABLE tenants ADD COLUMN deleted_at INTEGER;
+ALTER TABLE tenants ADD COLUMN quota TEXT DEFAULT '{}';
@@ -0,0 +1 @@
+ALTER TABLE tenants ADD COLUMN trusted_issuers TEXT DEFAULT '[]';
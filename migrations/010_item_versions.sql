@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS item_versions (
+    tenant_id TEXT,
+    kind TEXT,
+    item_id TEXT,
+    version INTEGER,
+    PRIMARY KEY (tenant_id, kind, item_id)
+);
@@ -0,0 +1,145 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxArchiveSize bounds the uncompressed size of a bundle archive.
+const maxArchiveSize = 32 << 20
+
+// IsArchive reports whether name looks like a bundle archive.
+func IsArchive(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// Digest returns the SHA-256 digest of data as `sha256:<hex>`.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Build packs the manifest and YAML files under dir into a gzipped tar
+// archive. The output only depends on file paths and contents, so building
+// the same tree twice yields the same digest. The bundle is assembled first
+// so that broken imports are caught before packing.
+func Build(dir string) ([]byte, error) {
+	if _, err := Load(dir); err != nil {
+		return nil, err
+	}
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && (d.Name() == ManifestFile || isPolicyFile(d.Name())) {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		hdr := &tar.Header{Name: rel, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadArchive assembles a bundle from a gzipped tar archive produced by
+// Build. Only regular files are read; entries escaping the archive root are
+// rejected.
+func ReadArchive(data []byte) (*Bundle, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("bundle archive: %v", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(io.LimitReader(gz, maxArchiveSize))
+	files := make(archiveSource)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("bundle archive: entry %s is outside the bundle", hdr.Name)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("bundle archive: %v", err)
+		}
+		files[name] = b
+	}
+	b, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	b.Digest = Digest(data)
+	return b, nil
+}
+
+// archiveSource serves bundle files read from an archive.
+type archiveSource map[string][]byte
+
+func (a archiveSource) readFile(rel string) ([]byte, error) {
+	data, ok := a[rel]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: rel, Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+func (a archiveSource) rootFiles() ([]string, error) {
+	var out []string
+	for name := range a {
+		if !strings.Contains(name, "/") && isPolicyFile(name) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"manifest.yaml":     "revision: r7\nfiles: [main.yaml]\n",
+		"main.yaml":         "import: [teams/hr.yaml]\n",
+		"teams/hr.yaml":     "namespace: hr\n",
+		"teams/notes.txt":   "ignored",
+		"unreferenced.yaml": "policies: []\n",
+	})
+	data, err := Build(dir)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	again, err := Build(dir)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Fatalf("expected builds of the same tree to be identical")
+	}
+	b, err := ReadArchive(data)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if b.Revision != "r7" || b.Digest != Digest(data) {
+		t.Fatalf("unexpected revision %q or digest %q", b.Revision, b.Digest)
+	}
+	if len(b.Files) != 2 || b.Files[0].Path != "teams/hr.yaml" || b.Files[0].Namespace != "hr" || b.Files[1].Path != "main.yaml" {
+		t.Fatalf("unexpected files %#v", b.Files)
+	}
+}
+
+func TestArchiveRejectsEscapingEntries(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	data := []byte("policies: []\n")
+	tw.WriteHeader(&tar.Header{Name: "../evil.yaml", Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+	tw.Write(data)
+	tw.Close()
+	gz.Close()
+	if _, err := ReadArchive(buf.Bytes()); err == nil {
+		t.Fatalf("expected entry outside the bundle to be rejected")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.yaml": "policies: []\n"})
+	data, err := Build(dir)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	sig := Sign(data, priv)
+	if sig.KeyID != KeyID(pub) {
+		t.Fatalf("expected key id %s, got %s", KeyID(pub), sig.KeyID)
+	}
+	if err := Verify(data, sig, []ed25519.PublicKey{other, pub}); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := Verify(data, sig, []ed25519.PublicKey{other}); !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("expected untrusted key error, got %v", err)
+	}
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 0xff
+	if err := Verify(tampered, sig, []ed25519.PublicKey{pub}); err == nil {
+		t.Fatalf("expected tampered archive to fail verification")
+	}
+	forged := sig
+	forged.Digest = Digest(tampered)
+	if err := Verify(tampered, forged, []ed25519.PublicKey{pub}); !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("expected forged digest to fail verification, got %v", err)
+	}
+}
+
+func TestKeyPEMRoundTrip(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	pubPEM, err := MarshalPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	privPEM, err := MarshalPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshal private key: %v", err)
+	}
+	gotPub, err := ParsePublicKey(pubPEM)
+	if err != nil || !gotPub.Equal(pub) {
+		t.Fatalf("public key round trip failed: %v", err)
+	}
+	gotPriv, err := ParsePrivateKey(privPEM)
+	if err != nil || !gotPriv.Equal(priv) {
+		t.Fatalf("private key round trip failed: %v", err)
+	}
+	if _, err := ParsePublicKey(privPEM); err == nil {
+		t.Fatalf("expected private key to be rejected as public key")
+	}
+}
@@ -0,0 +1,205 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestFile is the name of the manifest at the root of a bundle directory.
+const ManifestFile = "manifest.yaml"
+
+// Manifest describes a bundle directory.
+//
+//	revision: "2024-06-01.1"
+//	files: ["main.yaml"]
+//
+// Files lists the entry points relative to the bundle root. When empty every
+// `.yaml` and `.yml` file in the root directory except the manifest is an
+// entry point.
+type Manifest struct {
+	Revision string   `yaml:"revision"`
+	Files    []string `yaml:"files"`
+}
+
+// File is one policy document of a bundle.
+type File struct {
+	// Path is the file's path relative to the bundle root, using forward
+	// slashes.
+	Path string
+	// Namespace qualifies the IDs of the policies declared in the file.
+	Namespace string
+	Data      []byte
+}
+
+// Bundle is a tenant's policy set assembled from one or more files.
+type Bundle struct {
+	Revision string
+	// Digest identifies the archive the bundle was read from, if any.
+	Digest string
+	// Commit is the source control revision the bundle was read from, if
+	// known. It is set by the caller.
+	Commit string
+	// Files are ordered so that every file follows the files it imports.
+	Files []File
+}
+
+// header holds the fields of a policy document that control assembly.
+type header struct {
+	Namespace string   `yaml:"namespace"`
+	Import    []string `yaml:"import"`
+}
+
+// Load assembles the bundle at name. A directory is read through its
+// manifest, or all of its top-level YAML files when it has none; a `.tar.gz`
+// or `.tgz` archive is read the same way (see ReadArchive); any other file is
+// a bundle on its own. Imports are resolved relative to the importing file
+// and must stay within the bundle root. Each file is read once even if
+// imported several times.
+func Load(name string) (*Bundle, error) {
+	if IsArchive(name) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return ReadArchive(data)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return load(dirSource(name))
+	}
+	l := &loader{src: dirSource(filepath.Dir(name)), seen: make(map[string]struct{})}
+	if err := l.load(filepath.Base(name), nil); err != nil {
+		return nil, err
+	}
+	return &l.bundle, nil
+}
+
+// load assembles a bundle rooted at src.
+func load(src source) (*Bundle, error) {
+	l := &loader{src: src, seen: make(map[string]struct{})}
+	m, err := readManifest(src)
+	if err != nil {
+		return nil, err
+	}
+	l.bundle.Revision = m.Revision
+	entries := m.Files
+	if len(entries) == 0 {
+		if entries, err = src.rootFiles(); err != nil {
+			return nil, err
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("bundle contains no policy files")
+	}
+	for _, e := range entries {
+		if err := l.load(e, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &l.bundle, nil
+}
+
+func readManifest(src source) (Manifest, error) {
+	var m Manifest
+	data, err := src.readFile(ManifestFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %v", ManifestFile, err)
+	}
+	return m, nil
+}
+
+// isPolicyFile reports whether name is a YAML file other than the manifest.
+func isPolicyFile(name string) bool {
+	ext := path.Ext(name)
+	return name != ManifestFile && (ext == ".yaml" || ext == ".yml")
+}
+
+// source provides the files of a bundle by slash-separated path relative to
+// the bundle root.
+type source interface {
+	readFile(rel string) ([]byte, error)
+	// rootFiles lists the policy files at the top level, sorted.
+	rootFiles() ([]string, error)
+}
+
+// dirSource reads a bundle from a directory.
+type dirSource string
+
+func (d dirSource) readFile(rel string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), filepath.FromSlash(rel)))
+}
+
+func (d dirSource) rootFiles() ([]string, error) {
+	list, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range list {
+		if !e.IsDir() && isPolicyFile(e.Name()) {
+			out = append(out, e.Name())
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+type loader struct {
+	src    source
+	seen   map[string]struct{}
+	bundle Bundle
+}
+
+// load reads the file at rel, relative to the bundle root, after the files it
+// imports. stack holds the files being imported to report cycles.
+func (l *loader) load(rel string, stack []string) error {
+	rel = path.Clean(filepath.ToSlash(rel))
+	if path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("import %s is outside the bundle", rel)
+	}
+	for _, s := range stack {
+		if s == rel {
+			return fmt.Errorf("import cycle: %s -> %s", strings.Join(stack, " -> "), rel)
+		}
+	}
+	if _, ok := l.seen[rel]; ok {
+		return nil
+	}
+	data, err := l.src.readFile(rel)
+	if err != nil {
+		return err
+	}
+	var h header
+	if err := yaml.Unmarshal(data, &h); err != nil {
+		return fmt.Errorf("%s: %v", rel, err)
+	}
+	if strings.ContainsAny(h.Namespace, ". /") {
+		return fmt.Errorf("%s: invalid namespace %q", rel, h.Namespace)
+	}
+	stack = append(stack, rel)
+	for _, imp := range h.Import {
+		if err := l.load(path.Join(path.Dir(rel), imp), stack); err != nil {
+			return err
+		}
+	}
+	l.seen[rel] = struct{}{}
+	l.bundle.Files = append(l.bundle.Files, File{Path: rel, Namespace: h.Namespace, Data: data})
+	return nil
+}
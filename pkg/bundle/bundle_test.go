@@ -0,0 +1,78 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"manifest.yaml":      "revision: r42\nfiles: [main.yaml]\n",
+		"main.yaml":          "import: [teams/finance.yaml, teams/hr.yaml]\n",
+		"teams/finance.yaml": "namespace: finance\nimport: [common.yaml]\n",
+		"teams/hr.yaml":      "namespace: hr\nimport: [common.yaml]\n",
+		"teams/common.yaml":  "roles: []\n",
+		"unreferenced.yaml":  "policies: []\n",
+	})
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if b.Revision != "r42" {
+		t.Fatalf("expected revision r42, got %q", b.Revision)
+	}
+	var paths []string
+	for _, f := range b.Files {
+		paths = append(paths, f.Path)
+	}
+	if got := strings.Join(paths, ","); got != "teams/common.yaml,teams/finance.yaml,teams/hr.yaml,main.yaml" {
+		t.Fatalf("unexpected file order %s", got)
+	}
+	if b.Files[1].Namespace != "finance" {
+		t.Fatalf("expected finance namespace, got %q", b.Files[1].Namespace)
+	}
+}
+
+func TestLoadWithoutManifest(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"b.yaml": "", "a.yml": "", "notes.txt": ""})
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(b.Files) != 2 || b.Files[0].Path != "a.yml" || b.Revision != "" {
+		t.Fatalf("unexpected bundle %#v", b)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"cycle":     {"a.yaml": "import: [b.yaml]\n", "b.yaml": "import: [a.yaml]\n"},
+		"outside":   {"a.yaml": "import: [../secret.yaml]\n"},
+		"missing":   {"a.yaml": "import: [nope.yaml]\n"},
+		"namespace": {"a.yaml": "namespace: a.b\n"},
+		"manifest":  {"manifest.yaml": "revision: 1\nunknown: x\n", "a.yaml": ""},
+	}
+	for name, files := range cases {
+		dir := writeFiles(t, files)
+		if _, err := Load(dir); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// SignatureSuffix is appended to an archive's path to name its detached
+// signature.
+const SignatureSuffix = ".sig"
+
+// Signature is a detached ed25519 signature over an archive's digest. It is
+// stored as JSON next to the archive.
+type Signature struct {
+	KeyID     string `json:"keyid"`
+	Digest    string `json:"digest"`
+	Signature string `json:"signature"`
+}
+
+// ErrUntrusted is returned when a signature does not verify with any trusted
+// key.
+var ErrUntrusted = errors.New("bundle signature does not match a trusted key")
+
+// KeyID returns a short identifier for a public key.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign signs the digest of archive with key.
+func Sign(archive []byte, key ed25519.PrivateKey) Signature {
+	digest := Digest(archive)
+	return Signature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Digest:    digest,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(digest))),
+	}
+}
+
+// Verify checks that sig covers archive and was made by one of keys.
+func Verify(archive []byte, sig Signature, keys []ed25519.PublicKey) error {
+	digest := Digest(archive)
+	if sig.Digest != digest {
+		return fmt.Errorf("bundle digest %s does not match signed digest %s", digest, sig.Digest)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	for _, k := range keys {
+		if ed25519.Verify(k, []byte(digest), raw) {
+			return nil
+		}
+	}
+	return ErrUntrusted
+}
+
+// ParseSignature decodes a detached signature file.
+func ParseSignature(data []byte) (Signature, error) {
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return sig, fmt.Errorf("invalid signature file: %v", err)
+	}
+	return sig, nil
+}
+
+// MarshalPrivateKey encodes key as a PKCS #8 PEM block.
+func MarshalPrivateKey(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKey encodes key as a PKIX PEM block.
+func MarshalPublicKey(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey decodes an ed25519 private key from PEM.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an ed25519 private key")
+	}
+	return k, nil
+}
+
+// ParsePublicKey decodes an ed25519 public key from PEM.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("not an ed25519 public key")
+	}
+	return k, nil
+}
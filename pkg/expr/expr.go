@@ -0,0 +1,264 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Env resolves attribute references such as `context.risk`, `subject.id` or
+// `resource.owner` during evaluation. Unknown references resolve to null.
+type Env interface {
+	Lookup(path string) (Value, bool)
+}
+
+// MapEnv is an Env backed by a map of reference paths to values.
+type MapEnv map[string]Value
+
+// Lookup implements Env.
+func (m MapEnv) Lookup(path string) (Value, bool) {
+	v, ok := m[path]
+	return v, ok
+}
+
+// Program is a compiled boolean expression. Programs are immutable and safe
+// for concurrent use.
+type Program struct {
+	src  string
+	root node
+	refs []string
+}
+
+// Compile parses src into a Program. The grammar supports string, number,
+// boolean and list literals, the operators `== != < <= > >= in ! && ||`,
+// parentheses, attribute references rooted at context, subject, resource or
+// action, and the functions listed in functions.
+func Compile(src string) (*Program, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("position %d: unexpected %q", t.pos, t.text)
+	}
+	return &Program{src: src, root: root, refs: p.refs}, nil
+}
+
+// MustCompile is like Compile but panics on error.
+func MustCompile(src string) *Program {
+	p, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source of the program.
+func (p *Program) String() string { return p.src }
+
+// References returns the attribute paths read by the program in source order.
+func (p *Program) References() []string {
+	return append([]string(nil), p.refs...)
+}
+
+// ContextKeys returns the context keys read by the program, without the
+// `context.` prefix.
+func (p *Program) ContextKeys() []string {
+	var keys []string
+	for _, r := range p.refs {
+		if strings.HasPrefix(r, "context.") {
+			keys = append(keys, strings.TrimPrefix(r, "context."))
+		}
+	}
+	return keys
+}
+
+// Eval evaluates the program and reports whether it holds. Type errors, such
+// as ordering a list, are returned as errors.
+func (p *Program) Eval(env Env) (bool, error) {
+	v, err := p.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.truth()
+	if !ok {
+		return false, fmt.Errorf("expression %q does not evaluate to a boolean", p.src)
+	}
+	return b, nil
+}
+
+func (n literal) eval(Env) (Value, error) { return n.val, nil }
+
+func (n reference) eval(env Env) (Value, error) {
+	if env == nil {
+		return Value{}, nil
+	}
+	v, _ := env.Lookup(n.path)
+	return v, nil
+}
+
+func (n listNode) eval(env Env) (Value, error) {
+	out := Value{Kind: List, List: make([]Value, 0, len(n.items))}
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return Value{}, err
+		}
+		out.List = append(out.List, v)
+	}
+	return out, nil
+}
+
+func (n unary) eval(env Env) (Value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	b, ok := v.truth()
+	if !ok {
+		return Value{}, fmt.Errorf("operand of ! is not a boolean")
+	}
+	return BoolValue(!b), nil
+}
+
+func (n binary) eval(env Env) (Value, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	switch n.op {
+	case "&&", "||":
+		lb, ok := left.truth()
+		if !ok {
+			return Value{}, fmt.Errorf("operand of %s is not a boolean", n.op)
+		}
+		if n.op == "&&" && !lb || n.op == "||" && lb {
+			return BoolValue(lb), nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return Value{}, err
+		}
+		rb, ok := right.truth()
+		if !ok {
+			return Value{}, fmt.Errorf("operand of %s is not a boolean", n.op)
+		}
+		return BoolValue(rb), nil
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return Value{}, err
+	}
+	switch n.op {
+	case "==":
+		return BoolValue(equal(left, right)), nil
+	case "!=":
+		return BoolValue(!equal(left, right)), nil
+	case "in":
+		return BoolValue(contains(right, left)), nil
+	}
+	// Ordering against a missing attribute is simply false.
+	if left.Kind == Null || right.Kind == Null {
+		return BoolValue(false), nil
+	}
+	c, ok := compare(left, right)
+	if !ok {
+		return Value{}, fmt.Errorf("cannot compare %s with %s", left, right)
+	}
+	switch n.op {
+	case "<":
+		return BoolValue(c < 0), nil
+	case "<=":
+		return BoolValue(c <= 0), nil
+	case ">":
+		return BoolValue(c > 0), nil
+	default:
+		return BoolValue(c >= 0), nil
+	}
+}
+
+func (n call) eval(env Env) (Value, error) {
+	args := make([]Value, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return Value{}, err
+		}
+		args[i] = v
+	}
+	return n.fn.impl(args)
+}
+
+// contains reports whether item is an element of list. A string container is
+// treated as a comma separated list so that context values can carry sets.
+func contains(container, item Value) bool {
+	switch container.Kind {
+	case List:
+		for _, v := range container.List {
+			if equal(v, item) {
+				return true
+			}
+		}
+	case String:
+		for _, part := range strings.Split(container.Str, ",") {
+			if equal(StringValue(strings.TrimSpace(part)), item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type function struct {
+	arity int
+	impl  func(args []Value) (Value, error)
+}
+
+func stringFunc(f func(a, b string) bool) function {
+	return function{arity: 2, impl: func(args []Value) (Value, error) {
+		if args[0].Kind == Null || args[1].Kind == Null {
+			return BoolValue(false), nil
+		}
+		return BoolValue(f(args[0].String(), args[1].String())), nil
+	}}
+}
+
+// functions are the built-in functions available to expressions.
+var functions = map[string]function{
+	"startsWith": stringFunc(strings.HasPrefix),
+	"endsWith":   stringFunc(strings.HasSuffix),
+	"contains": {arity: 2, impl: func(args []Value) (Value, error) {
+		if args[0].Kind == List {
+			return BoolValue(contains(args[0], args[1])), nil
+		}
+		if args[0].Kind == Null || args[1].Kind == Null {
+			return BoolValue(false), nil
+		}
+		return BoolValue(strings.Contains(args[0].String(), args[1].String())), nil
+	}},
+	"lower": {arity: 1, impl: func(args []Value) (Value, error) {
+		if args[0].Kind == Null {
+			return args[0], nil
+		}
+		return StringValue(strings.ToLower(args[0].String())), nil
+	}},
+	"upper": {arity: 1, impl: func(args []Value) (Value, error) {
+		if args[0].Kind == Null {
+			return args[0], nil
+		}
+		return StringValue(strings.ToUpper(args[0].String())), nil
+	}},
+	"len": {arity: 1, impl: func(args []Value) (Value, error) {
+		switch args[0].Kind {
+		case List:
+			return NumberValue(float64(len(args[0].List))), nil
+		case Null:
+			return NumberValue(0), nil
+		}
+		return NumberValue(float64(len(args[0].String()))), nil
+	}},
+}
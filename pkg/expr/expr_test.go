@@ -0,0 +1,106 @@
+package expr
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	env := MapEnv{
+		"context.risk":   StringValue("low"),
+		"context.amount": StringValue("250"),
+		"context.region": StringValue("eu"),
+		"subject.id":     StringValue("alice"),
+		"subject.roles":  StringList([]string{"editor", "viewer"}),
+		"resource.owner": StringValue("alice"),
+		"resource.id":    StringValue("files/reports/q1"),
+		"action":         StringValue("read"),
+	}
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`context.risk == "low"`, true},
+		{`context.risk != "low"`, false},
+		{`context.risk < "medium"`, true},
+		{`context.risk <= "low"`, true},
+		{`context.amount >= 100`, true},
+		{`context.amount > 1000`, false},
+		{`context.amount<=250`, true},
+		{`context.risk == "low" && context.amount < 300`, true},
+		{`context.risk == "high" || context.region in ["eu", "us"]`, true},
+		{`!(context.region == "us")`, true},
+		{`resource.owner == subject.id`, true},
+		{`"editor" in subject.roles`, true},
+		{`"admin" in subject.roles`, false},
+		{`startsWith(resource.id, "files/reports/")`, true},
+		{`endsWith(resource.id, "q2")`, false},
+		{`contains(subject.roles, "viewer")`, true},
+		{`lower("ABC") == "abc"`, true},
+		{`len(subject.roles) == 2`, true},
+		{`context.missing == null`, true},
+		{`context.missing > 5`, false},
+		{`action == "read"`, true},
+	}
+	for _, c := range cases {
+		p, err := Compile(c.src)
+		if err != nil {
+			t.Fatalf("compile %q: %v", c.src, err)
+		}
+		got, err := p.Eval(env)
+		if err != nil {
+			t.Fatalf("eval %q: %v", c.src, err)
+		}
+		if got != c.want {
+			t.Errorf("%q = %v, want %v", c.src, got, c.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	invalid := []string{
+		``,
+		`context.risk ==`,
+		`context.risk == "low`,
+		`(context.risk == "low"`,
+		`unknown.key == "x"`,
+		`context == "x"`,
+		`nosuchfn(context.risk)`,
+		`startsWith(context.risk)`,
+		`context.a < context.b < context.c`,
+		`context.risk = "low"`,
+	}
+	for _, src := range invalid {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("expected compile error for %q", src)
+		}
+	}
+}
+
+func TestContextKeys(t *testing.T) {
+	p := MustCompile(`context.risk < "medium" && resource.owner == subject.id || context.time == "x"`)
+	keys := p.ContextKeys()
+	if len(keys) != 2 || keys[0] != "risk" || keys[1] != "time" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestEvalTypeError(t *testing.T) {
+	p := MustCompile(`subject.roles > 1`)
+	if _, err := p.Eval(MapEnv{"subject.roles": StringList([]string{"a"})}); err == nil {
+		t.Fatalf("expected type error comparing a list")
+	}
+}
+
+func TestValueOf(t *testing.T) {
+	env := MapEnv{
+		"resource.level": ValueOf(float64(3)),
+		"resource.tags":  ValueOf([]interface{}{"pii", "eu"}),
+		"resource.draft": ValueOf(true),
+	}
+	p := MustCompile(`resource.level > 2 && "pii" in resource.tags && resource.draft`)
+	ok, err := p.Eval(env)
+	if err != nil || !ok {
+		t.Fatalf("expected true, got %v %v", ok, err)
+	}
+	if v := ValueOf(map[string]interface{}{}); v.Kind != Null {
+		t.Fatalf("expected null for map, got %v", v)
+	}
+}
@@ -0,0 +1,248 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// node is an element of the expression syntax tree.
+type node interface {
+	eval(env Env) (Value, error)
+}
+
+type literal struct{ val Value }
+
+type reference struct{ path string }
+
+type listNode struct{ items []node }
+
+type unary struct {
+	op      string
+	operand node
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+type call struct {
+	name string
+	fn   function
+	args []node
+}
+
+// roots lists the identifiers that may start an attribute reference.
+var roots = map[string]bool{
+	"context":  true,
+	"subject":  true,
+	"resource": true,
+	"action":   true,
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	refs []string
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	t := p.next()
+	if t.kind != kind {
+		return fmt.Errorf("position %d: expected %q, found %q", t.pos, text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) isOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind == tokOp || t.kind == tokIdent && t.text == "in" {
+		for _, op := range ops {
+			if t.text == op {
+				return op, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseOr := and ( "||" and )*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp("||"); !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "||", left: left, right: right}
+	}
+}
+
+// parseAnd := unary ( "&&" unary )*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp("&&"); !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "&&", left: left, right: right}
+	}
+}
+
+// parseUnary := "!" unary | comparison
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.isOp("!"); ok {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "!", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison := primary ( op primary )?
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.isOp("==", "!=", "<", "<=", ">", ">=", "in")
+	if !ok {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, chained := p.isOp("==", "!=", "<", "<=", ">", ">=", "in"); chained {
+		return nil, fmt.Errorf("position %d: comparisons cannot be chained", p.peek().pos)
+	}
+	return binary{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{StringValue(t.text)}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("position %d: invalid number %q", t.pos, t.text)
+		}
+		return literal{NumberValue(f)}, nil
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokLBracket:
+		var items []node
+		if p.peek().kind != tokRBracket {
+			for {
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.peek().kind != tokComma {
+					break
+				}
+				p.next()
+			}
+		}
+		if err := p.expect(tokRBracket, "]"); err != nil {
+			return nil, err
+		}
+		return listNode{items: items}, nil
+	case tokIdent:
+		return p.parseIdent(t)
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("position %d: unexpected %q", t.pos, t.text)
+}
+
+func (p *parser) parseIdent(t token) (node, error) {
+	switch t.text {
+	case "true":
+		return literal{BoolValue(true)}, nil
+	case "false":
+		return literal{BoolValue(false)}, nil
+	case "null":
+		return literal{Value{}}, nil
+	}
+	if p.peek().kind == tokLParen {
+		fn, ok := functions[t.text]
+		if !ok {
+			return nil, fmt.Errorf("position %d: unknown function %q", t.pos, t.text)
+		}
+		p.next()
+		var args []node
+		if p.peek().kind != tokRParen {
+			for {
+				arg, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if p.peek().kind != tokComma {
+					break
+				}
+				p.next()
+			}
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		if len(args) != fn.arity {
+			return nil, fmt.Errorf("position %d: %s expects %d arguments, got %d", t.pos, t.text, fn.arity, len(args))
+		}
+		return call{name: t.text, fn: fn, args: args}, nil
+	}
+	root := t.text
+	if i := strings.IndexByte(root, '.'); i >= 0 {
+		root = root[:i]
+	}
+	if !roots[root] {
+		return nil, fmt.Errorf("position %d: unknown identifier %q", t.pos, t.text)
+	}
+	if root != "action" && !strings.Contains(t.text, ".") || strings.HasSuffix(t.text, ".") || strings.Contains(t.text, "..") {
+		return nil, fmt.Errorf("position %d: invalid reference %q", t.pos, t.text)
+	}
+	p.refs = append(p.refs, t.text)
+	return reference{path: t.text}, nil
+}
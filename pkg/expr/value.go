@@ -0,0 +1,182 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kind identifies the type of a Value.
+type Kind int
+
+const (
+	Null Kind = iota
+	String
+	Number
+	Bool
+	List
+)
+
+// Value is a typed value produced while evaluating an expression.
+type Value struct {
+	Kind Kind
+	Str  string
+	Num  float64
+	Bool bool
+	List []Value
+}
+
+// StringValue wraps a string.
+func StringValue(s string) Value { return Value{Kind: String, Str: s} }
+
+// NumberValue wraps a number.
+func NumberValue(f float64) Value { return Value{Kind: Number, Num: f} }
+
+// BoolValue wraps a boolean.
+func BoolValue(b bool) Value { return Value{Kind: Bool, Bool: b} }
+
+// StringList wraps a list of strings.
+func StringList(items []string) Value {
+	list := make([]Value, len(items))
+	for i, s := range items {
+		list[i] = StringValue(s)
+	}
+	return Value{Kind: List, List: list}
+}
+
+// ValueOf converts a decoded JSON or YAML value into a Value. Maps and other
+// unsupported types convert to null.
+func ValueOf(v interface{}) Value {
+	switch t := v.(type) {
+	case Value:
+		return t
+	case string:
+		return StringValue(t)
+	case bool:
+		return BoolValue(t)
+	case float64:
+		return NumberValue(t)
+	case float32:
+		return NumberValue(float64(t))
+	case int:
+		return NumberValue(float64(t))
+	case int64:
+		return NumberValue(float64(t))
+	case []string:
+		return StringList(t)
+	case []interface{}:
+		list := make([]Value, len(t))
+		for i, item := range t {
+			list[i] = ValueOf(item)
+		}
+		return Value{Kind: List, List: list}
+	}
+	return Value{}
+}
+
+// String renders the value for messages and string functions.
+func (v Value) String() string {
+	switch v.Kind {
+	case String:
+		return v.Str
+	case Number:
+		return strconv.FormatFloat(v.Num, 'f', -1, 64)
+	case Bool:
+		return strconv.FormatBool(v.Bool)
+	case List:
+		parts := make([]string, len(v.List))
+		for i, item := range v.List {
+			parts[i] = item.String()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return "null"
+}
+
+// number converts v to a number. Strings holding a numeric literal convert
+// successfully so that context values, which are always strings, compare
+// numerically.
+func (v Value) number() (float64, bool) {
+	switch v.Kind {
+	case Number:
+		return v.Num, true
+	case String:
+		f, err := strconv.ParseFloat(v.Str, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// truth converts v to a boolean. Strings "true" and "false" are accepted.
+func (v Value) truth() (bool, bool) {
+	switch v.Kind {
+	case Bool:
+		return v.Bool, true
+	case String:
+		b, err := strconv.ParseBool(v.Str)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// levels gives an ordering to well-known qualitative values such as risk.
+var levels = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+func equal(a, b Value) bool {
+	if a.Kind == Null || b.Kind == Null {
+		return a.Kind == b.Kind
+	}
+	if a.Kind == List || b.Kind == List {
+		if a.Kind != b.Kind || len(a.List) != len(b.List) {
+			return false
+		}
+		for i := range a.List {
+			if !equal(a.List[i], b.List[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if a.Kind == Bool || b.Kind == Bool {
+		x, ok1 := a.truth()
+		y, ok2 := b.truth()
+		return ok1 && ok2 && x == y
+	}
+	if a.Kind == Number || b.Kind == Number {
+		x, ok1 := a.number()
+		y, ok2 := b.number()
+		return ok1 && ok2 && x == y
+	}
+	return a.Str == b.Str
+}
+
+// compare orders a and b, returning -1, 0 or 1. Numbers compare numerically,
+// known levels (low < medium < high) by rank and other strings lexically.
+func compare(a, b Value) (int, bool) {
+	if a.Kind == Null || b.Kind == Null || a.Kind == List || b.Kind == List || a.Kind == Bool || b.Kind == Bool {
+		return 0, false
+	}
+	if x, ok := a.number(); ok {
+		if y, ok := b.number(); ok {
+			return cmp(x, y), true
+		}
+	}
+	if a.Kind == Number || b.Kind == Number {
+		return 0, false
+	}
+	if x, ok := levels[strings.ToLower(a.Str)]; ok {
+		if y, ok := levels[strings.ToLower(b.Str)]; ok {
+			return cmp(float64(x), float64(y)), true
+		}
+	}
+	return strings.Compare(a.Str, b.Str), true
+}
+
+func cmp(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
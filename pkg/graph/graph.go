@@ -1,16 +1,26 @@
 package graph
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // Graph stores directed relationships between entities like users, groups, and resources.
 type Graph struct {
 	mu    sync.RWMutex
 	edges map[string]map[string]struct{}
+	// reverse indexes edges by destination for ancestor lookups.
+	reverse map[string]map[string]struct{}
+	// version increases whenever an edge is added or removed.
+	version uint64
 }
 
 // New creates a new in-memory graph.
 func New() *Graph {
-	return &Graph{edges: make(map[string]map[string]struct{})}
+	return &Graph{
+		edges:   make(map[string]map[string]struct{}),
+		reverse: make(map[string]map[string]struct{}),
+	}
 }
 
 // AddRelation adds a directed edge from src to dst.
@@ -20,10 +30,88 @@ func (g *Graph) AddRelation(src, dst string) {
 	if g.edges[src] == nil {
 		g.edges[src] = make(map[string]struct{})
 	}
+	if _, ok := g.edges[src][dst]; !ok {
+		g.version++
+	}
 	g.edges[src][dst] = struct{}{}
+	if g.reverse[dst] == nil {
+		g.reverse[dst] = make(map[string]struct{})
+	}
+	g.reverse[dst][src] = struct{}{}
+}
+
+// RemoveRelation deletes the directed edge from src to dst. It reports whether
+// the edge existed.
+func (g *Graph) RemoveRelation(src, dst string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.edges[src][dst]; !ok {
+		return false
+	}
+	delete(g.edges[src], dst)
+	if len(g.edges[src]) == 0 {
+		delete(g.edges, src)
+	}
+	delete(g.reverse[dst], src)
+	if len(g.reverse[dst]) == 0 {
+		delete(g.reverse, dst)
+	}
+	g.version++
+	return true
+}
+
+// Replace atomically swaps all edges for the given source to targets mapping,
+// in the format returned by List.
+func (g *Graph) Replace(edges map[string][]string) {
+	fwd := make(map[string]map[string]struct{})
+	rev := make(map[string]map[string]struct{})
+	for src, targets := range edges {
+		for _, dst := range targets {
+			if fwd[src] == nil {
+				fwd[src] = make(map[string]struct{})
+			}
+			fwd[src][dst] = struct{}{}
+			if rev[dst] == nil {
+				rev[dst] = make(map[string]struct{})
+			}
+			rev[dst][src] = struct{}{}
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.edges = fwd
+	g.reverse = rev
+	g.version++
+}
+
+// HasRelation reports whether the directed edge from src to dst exists.
+func (g *Graph) HasRelation(src, dst string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, ok := g.edges[src][dst]
+	return ok
+}
+
+// Len returns the number of edges in the graph.
+func (g *Graph) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	n := 0
+	for _, targets := range g.edges {
+		n += len(targets)
+	}
+	return n
 }
 
-// Targets returns the direct targets for a source node.
+// Version returns a counter that changes whenever the graph's edges change,
+// so callers can detect stale derived data.
+func (g *Graph) Version() uint64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.version
+}
+
+// Targets returns the direct targets for a source node in sorted order.
 func (g *Graph) Targets(src string) []string {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -32,6 +120,31 @@ func (g *Graph) Targets(src string) []string {
 	for t := range m {
 		out = append(out, t)
 	}
+	sort.Strings(out)
+	return out
+}
+
+// Ancestors returns every node with a path to dst, excluding dst itself, in
+// sorted order.
+func (g *Graph) Ancestors(dst string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	visited := map[string]struct{}{dst: {}}
+	queue := []string{dst}
+	var out []string
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for src := range g.reverse[n] {
+			if _, ok := visited[src]; ok {
+				continue
+			}
+			visited[src] = struct{}{}
+			out = append(out, src)
+			queue = append(queue, src)
+		}
+	}
+	sort.Strings(out)
 	return out
 }
 
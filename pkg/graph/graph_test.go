@@ -17,3 +17,63 @@ func TestGraphRelationships(t *testing.T) {
 		t.Fatalf("expected path from user to resource")
 	}
 }
+
+func TestGraphAncestors(t *testing.T) {
+	g := New()
+	g.AddRelation("group:all", "group:teamA")
+	g.AddRelation("group:teamA", "resource:file1")
+	g.AddRelation("group:teamB", "resource:file2")
+
+	got := g.Ancestors("resource:file1")
+	if len(got) != 2 || got[0] != "group:all" || got[1] != "group:teamA" {
+		t.Fatalf("unexpected ancestors %v", got)
+	}
+}
+
+func TestGraphVersion(t *testing.T) {
+	g := New()
+	v0 := g.Version()
+	g.AddRelation("user:alice", "group:admins")
+	v1 := g.Version()
+	if v1 == v0 {
+		t.Fatalf("expected version to change after adding an edge")
+	}
+	g.AddRelation("user:alice", "group:admins")
+	if g.Version() != v1 {
+		t.Fatalf("expected version to stay the same for a duplicate edge")
+	}
+}
+
+func TestGraphRemoveAndReplace(t *testing.T) {
+	g := New()
+	g.AddRelation("user:alice", "group:admins")
+	g.AddRelation("group:admins", "resource:file1")
+	v := g.Version()
+	if !g.RemoveRelation("user:alice", "group:admins") {
+		t.Fatalf("expected edge to be removed")
+	}
+	if g.RemoveRelation("user:alice", "group:admins") {
+		t.Fatalf("expected missing edge to report false")
+	}
+	if g.HasPath("user:alice", "resource:file1") || g.Version() == v {
+		t.Fatalf("expected path to be gone and version to change")
+	}
+
+	g.Replace(map[string][]string{"user:bob": {"group:admins"}})
+	if len(g.List()) != 1 || g.HasPath("user:bob", "resource:file1") {
+		t.Fatalf("unexpected edges after replace: %v", g.List())
+	}
+	if got := g.Ancestors("group:admins"); len(got) != 1 || got[0] != "user:bob" {
+		t.Fatalf("expected reverse index to be rebuilt, got %v", got)
+	}
+}
+
+func TestGraphLen(t *testing.T) {
+	g := New()
+	g.AddRelation("user:alice", "group:admins")
+	g.AddRelation("user:alice", "group:admins")
+	g.AddRelation("user:alice", "group:eng")
+	if g.Len() != 2 || !g.HasRelation("user:alice", "group:eng") || g.HasRelation("group:eng", "user:alice") {
+		t.Fatalf("expected 2 directed edges, got %d", g.Len())
+	}
+}
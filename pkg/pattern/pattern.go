@@ -0,0 +1,126 @@
+package pattern
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Separators used when matching policy resources and actions.
+const (
+	ResourceSeparator = '/'
+	ActionSeparator   = ':'
+)
+
+// IsLiteral reports whether p contains no wildcard characters.
+func IsLiteral(p string) bool {
+	return !strings.ContainsAny(p, "*?")
+}
+
+// Validate checks that p is a well formed pattern. A lone `*` matches any
+// value. Otherwise `*` and `?` match within a single segment and `**` must
+// occupy a whole segment, where it matches zero or more segments.
+func Validate(p string, sep byte) error {
+	if p == "" {
+		return fmt.Errorf("pattern is empty")
+	}
+	if strings.TrimSpace(p) != p {
+		return fmt.Errorf("pattern %q has leading or trailing whitespace", p)
+	}
+	if p == "*" {
+		return nil
+	}
+	for _, seg := range strings.Split(p, string(sep)) {
+		if seg == "**" {
+			continue
+		}
+		if strings.Contains(seg, "**") {
+			return fmt.Errorf("pattern %q: ** must be a whole segment", p)
+		}
+	}
+	return nil
+}
+
+// Match reports whether value matches pattern p using sep as the segment
+// separator. Malformed patterns never match.
+func Match(p, value string, sep byte) bool {
+	if p == "*" || p == value {
+		return true
+	}
+	if IsLiteral(p) {
+		return false
+	}
+	if Validate(p, sep) != nil {
+		return false
+	}
+	return matchSegments(strings.Split(p, string(sep)), strings.Split(value, string(sep)))
+}
+
+// MatchResource matches a resource against a policy resource pattern.
+func MatchResource(p, resource string) bool {
+	return Match(p, resource, ResourceSeparator)
+}
+
+// MatchAction matches an action against a policy action pattern.
+func MatchAction(p, action string) bool {
+	return Match(p, action, ActionSeparator)
+}
+
+// LiteralPrefix returns the leading segments of p that contain no wildcards,
+// joined by sep. It returns an empty string when the first segment is a
+// wildcard.
+func LiteralPrefix(p string, sep byte) string {
+	segs := strings.Split(p, string(sep))
+	n := 0
+	for n < len(segs) && IsLiteral(segs[n]) {
+		n++
+	}
+	return strings.Join(segs[:n], string(sep))
+}
+
+func matchSegments(pat, val []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(val); i++ {
+				if matchSegments(rest, val[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(val) == 0 || !matchSegment(pat[0], val[0]) {
+			return false
+		}
+		pat, val = pat[1:], val[1:]
+	}
+	return len(val) == 0
+}
+
+// matchSegment matches a single segment supporting `*` (any run of
+// characters) and `?` (exactly one character).
+func matchSegment(p, s string) bool {
+	px, sx := 0, 0
+	starPx, starSx := -1, 0
+	for sx < len(s) {
+		switch {
+		case px < len(p) && (p[px] == '?' || p[px] == s[sx]):
+			px++
+			sx++
+		case px < len(p) && p[px] == '*':
+			starPx, starSx = px, sx
+			px++
+		case starPx >= 0:
+			starSx++
+			px, sx = starPx+1, starSx
+		default:
+			return false
+		}
+	}
+	for px < len(p) && p[px] == '*' {
+		px++
+	}
+	return px == len(p)
+}
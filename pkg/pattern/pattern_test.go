@@ -0,0 +1,70 @@
+package pattern
+
+import "testing"
+
+func TestMatchResource(t *testing.T) {
+	cases := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "files/reports/q1", true},
+		{"file1", "file1", true},
+		{"file1", "file2", false},
+		{"files/reports/*", "files/reports/q1", true},
+		{"files/reports/*", "files/reports/2024/q1", false},
+		{"files/reports/*", "files/reports", false},
+		{"files/**", "files/reports/2024/q1", true},
+		{"files/**", "files", true},
+		{"files/**/q1", "files/reports/2024/q1", true},
+		{"files/**/q1", "files/q1", true},
+		{"files/**/q1", "files/reports/q2", false},
+		{"files/report-*", "files/report-2024", true},
+		{"files/report-?", "files/report-1", true},
+		{"files/report-?", "files/report-10", false},
+		{"files/*/q1", "other/x/q1", false},
+	}
+	for _, c := range cases {
+		if got := MatchResource(c.pattern, c.value); got != c.want {
+			t.Errorf("MatchResource(%q, %q) = %v, want %v", c.pattern, c.value, got, c.want)
+		}
+	}
+}
+
+func TestMatchAction(t *testing.T) {
+	if !MatchAction("document:*", "document:read") {
+		t.Fatalf("expected document:* to match document:read")
+	}
+	if MatchAction("document:*", "folder:read") {
+		t.Fatalf("expected document:* not to match folder:read")
+	}
+	if MatchAction("document:*", "document:share:external") {
+		t.Fatalf("expected document:* not to cross segments")
+	}
+	if !MatchAction("document:**", "document:share:external") {
+		t.Fatalf("expected document:** to match nested actions")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{"*", "file1", "files/**", "files/*/q1", "files/report-?"}
+	for _, p := range valid {
+		if err := Validate(p, ResourceSeparator); err != nil {
+			t.Errorf("expected %q to be valid: %v", p, err)
+		}
+	}
+	invalid := []string{"", " files/*", "files/a**", "files/***"}
+	for _, p := range invalid {
+		if err := Validate(p, ResourceSeparator); err == nil {
+			t.Errorf("expected %q to be invalid", p)
+		}
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	if got := LiteralPrefix("files/reports/*", ResourceSeparator); got != "files/reports" {
+		t.Fatalf("unexpected prefix %q", got)
+	}
+	if got := LiteralPrefix("*", ResourceSeparator); got != "" {
+		t.Fatalf("unexpected prefix %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/bradtumy/authorization-service/pkg/expr"
+)
+
+// ResourceAttributeProvider supplies the attributes of a resource, such as its
+// owner or classification, so that conditions can reference them as
+// `resource.<name>`. Providers return an empty map for unknown resources.
+type ResourceAttributeProvider interface {
+	ResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error)
+}
+
+// resourceAttrs resolves resource attributes for a single request. The
+// provider is only consulted when a condition references an attribute.
+// Attributes from the provider take precedence over those supplied by the
+// caller, so a caller cannot claim ownership of a resource the provider knows.
+// Once the provider has failed no attribute is found, so the caller's values
+// cannot stand in for the stored ones.
+type resourceAttrs struct {
+	ctx      context.Context
+	provider ResourceAttributeProvider
+	tenantID string
+	resource string
+	supplied map[string]interface{}
+
+	loaded bool
+	stored map[string]interface{}
+	err    error
+}
+
+func (r *resourceAttrs) lookup(name string) (expr.Value, bool) {
+	if r == nil {
+		return expr.Value{}, false
+	}
+	if r.provider != nil && !r.loaded {
+		r.loaded = true
+		r.stored, r.err = r.provider.ResourceAttributes(r.ctx, r.tenantID, r.resource)
+	}
+	if r.err != nil {
+		return expr.Value{}, false
+	}
+	if v, ok := r.stored[name]; ok {
+		return expr.ValueOf(v), true
+	}
+	if v, ok := r.supplied[name]; ok {
+		return expr.ValueOf(v), true
+	}
+	return expr.Value{}, false
+}
+
+// failed reports whether the provider returned an error.
+func (r *resourceAttrs) failed() bool {
+	return r != nil && r.err != nil
+}
+
+// consulted reports whether the provider was asked for the attributes.
+// Stored attributes can change on another replica without notice, so
+// decisions that read them are not cached.
+func (r *resourceAttrs) consulted() bool {
+	return r != nil && r.provider != nil && r.loaded
+}
@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// DecisionCache is a size-bounded LRU cache of policy decisions. Entries are
+// tied to the policy index and graph version they were computed against, so
+// reloading policies or changing graph edges invalidates them without an
+// explicit flush. Changes the engine cannot observe, such as role assignments
+// in the identity provider or stored resource attributes, must be reported
+// through Invalidate.
+type DecisionCache struct {
+	mu    sync.Mutex
+	size  int
+	gen   uint64
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	key          string
+	dec          Decision
+	idx          *policyIndex
+	graphVersion uint64
+	gen          uint64
+}
+
+// NewDecisionCache returns a cache holding at most size decisions. A size of
+// zero or less returns nil, which disables caching.
+func NewDecisionCache(size int) *DecisionCache {
+	if size <= 0 {
+		return nil
+	}
+	return &DecisionCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Invalidate discards every cached decision, including those being computed
+// concurrently.
+func (c *DecisionCache) Invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Len returns the number of cached decisions.
+func (c *DecisionCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *DecisionCache) Stats() (hits, misses uint64) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// generation returns the current invalidation generation.
+func (c *DecisionCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *DecisionCache) get(key string, idx *policyIndex, graphVersion uint64) (Decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if e.idx == idx && e.graphVersion == graphVersion && e.gen == c.gen {
+			c.ll.MoveToFront(el)
+			c.hits++
+			return e.dec, true
+		}
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+	c.misses++
+	return Decision{}, false
+}
+
+func (c *DecisionCache) put(key string, dec Decision, idx *policyIndex, graphVersion, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		// Invalidated while the decision was being computed.
+		return
+	}
+	e := &cacheEntry{key: key, dec: dec, idx: idx, graphVersion: graphVersion, gen: gen}
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(e)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheKey builds the cache key for a request. Only the context keys read by
+// some policy are included, along with caller-supplied resource attributes
+// when policies read them. It reports false when the decision depends on the
+// clock or on roles supplied with the request and therefore cannot be cached.
+func cacheKey(idx *policyIndex, req Request) (string, bool) {
+	if req.Roles != nil {
+		return "", false
+	}
+	var b strings.Builder
+	for _, s := range []string{req.Env["tenantID"], req.Subject, req.Resource, req.Action} {
+		b.WriteString(s)
+		b.WriteByte(0)
+	}
+	for _, k := range idx.contextKeys {
+		v, ok := req.Env[k]
+		if k == "time" && !validClock(v) {
+			// The time condition falls back to the current time.
+			return "", false
+		}
+		b.WriteString(k)
+		if ok {
+			b.WriteByte('=')
+			b.WriteString(v)
+		}
+		b.WriteByte(0)
+	}
+	if idx.readsAttributes && len(req.ResourceAttributes) > 0 {
+		attrs, err := json.Marshal(req.ResourceAttributes)
+		if err != nil {
+			return "", false
+		}
+		b.Write(attrs)
+	}
+	return b.String(), true
+}
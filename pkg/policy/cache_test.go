@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+)
+
+// newCachedEngine caches up to size decisions for a store in which alice, a
+// viewer, reads files and writes them at low risk. bob holds no roles.
+func newCachedEngine(size int) (*PolicyEngine, *PolicyStore, *graph.Graph) {
+	store := testStore(
+		map[string][]string{"viewer": {"read", "write-low-risk"}},
+		map[string][]string{"alice": {"viewer"}, "bob": nil},
+		Policy{ID: "read", Resource: []string{"files/*"}, Action: []string{"read"}, Effect: "allow"},
+		Policy{ID: "write-low-risk", Resource: []string{"files/*"}, Action: []string{"write"}, Effect: "allow", When: []string{`context.risk == "low"`}},
+	)
+	g := graph.New()
+	engine := NewPolicyEngine(store, g)
+	engine.SetDecisionCache(NewDecisionCache(size))
+	return engine, store, g
+}
+
+func TestDecisionCacheHit(t *testing.T) {
+	engine, _, _ := newCachedEngine(10)
+	env := map[string]string{"risk": "low", "ip": "10.0.0.1"}
+	if dec := engine.Evaluate("alice", "files/a", "write", env); !dec.Allow || dec.Cached {
+		t.Fatalf("expected uncached allow, got %v", dec)
+	}
+	// Keys no policy reads do not affect the cache key.
+	env = map[string]string{"risk": "low", "ip": "10.0.0.2"}
+	dec := engine.Evaluate("alice", "files/a", "write", env)
+	if !dec.Allow || !dec.Cached {
+		t.Fatalf("expected cached allow, got %v", dec)
+	}
+	if dec.Context["ip"] != "10.0.0.2" {
+		t.Fatalf("expected context of the current request, got %v", dec.Context)
+	}
+	if dec := engine.Evaluate("alice", "files/a", "write", map[string]string{"risk": "high"}); dec.Allow || dec.Cached {
+		t.Fatalf("expected uncached deny for a different risk, got %v", dec)
+	}
+	hits, misses := engine.DecisionCache().Stats()
+	if hits != 1 || misses != 2 {
+		t.Fatalf("expected 1 hit and 2 misses, got %d/%d", hits, misses)
+	}
+}
+
+func TestDecisionCacheInvalidation(t *testing.T) {
+	engine, store, g := newCachedEngine(10)
+	engine.Evaluate("bob", "files/a", "read", nil)
+
+	// Graph edges change bob's roles.
+	g.AddRelation("user:bob", "group:viewer")
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); !dec.Allow || dec.Cached {
+		t.Fatalf("expected fresh allow after graph change, got %v", dec)
+	}
+
+	// Reloading policies swaps the index.
+	delete(store.Policies, "read")
+	if err := store.Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); dec.Allow || dec.Cached {
+		t.Fatalf("expected fresh deny after reload, got %v", dec)
+	}
+
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); !dec.Cached {
+		t.Fatalf("expected cached decision")
+	}
+	engine.InvalidateCache()
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); dec.Cached {
+		t.Fatalf("expected fresh decision after invalidation")
+	}
+}
+
+func TestDecisionCacheEviction(t *testing.T) {
+	engine, _, _ := newCachedEngine(2)
+	engine.Evaluate("alice", "files/a", "read", nil)
+	engine.Evaluate("alice", "files/b", "read", nil)
+	engine.Evaluate("alice", "files/a", "read", nil)
+	engine.Evaluate("alice", "files/c", "read", nil)
+	if n := engine.DecisionCache().Len(); n != 2 {
+		t.Fatalf("expected 2 entries, got %d", n)
+	}
+	if dec := engine.Evaluate("alice", "files/b", "read", nil); dec.Cached {
+		t.Fatalf("expected least recently used entry to be evicted")
+	}
+	if dec := engine.Evaluate("alice", "files/c", "read", nil); !dec.Cached {
+		t.Fatalf("expected recent entry to be cached")
+	}
+}
+
+func TestDecisionCacheSkipsClock(t *testing.T) {
+	engine, store, _ := newCachedEngine(10)
+	p := store.Policies["read"]
+	p.Conditions = map[string]string{"time": "business-hours"}
+	store.Policies["read"] = p
+	if err := store.Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	engine.Evaluate("alice", "files/a", "read", nil)
+	if dec := engine.Evaluate("alice", "files/a", "read", nil); dec.Cached {
+		t.Fatalf("expected clock-dependent decision not to be cached")
+	}
+	engine.Evaluate("alice", "files/a", "read", map[string]string{"time": "soon"})
+	if dec := engine.Evaluate("alice", "files/a", "read", map[string]string{"time": "soon"}); dec.Cached {
+		t.Fatalf("expected decision with a malformed time not to be cached")
+	}
+	engine.Evaluate("alice", "files/a", "read", map[string]string{"time": "10:00"})
+	if dec := engine.Evaluate("alice", "files/a", "read", map[string]string{"time": "10:00"}); !dec.Cached {
+		t.Fatalf("expected decision with explicit time to be cached")
+	}
+}
+
+func TestDecisionCacheSkipsStoredAttributes(t *testing.T) {
+	engine := NewPolicyEngine(newOwnerStore(), graph.New())
+	engine.SetDecisionCache(NewDecisionCache(10))
+	attrs := staticAttributes{"files/a": {"owner": "alice"}}
+	engine.SetResourceAttributeProvider(attrs)
+	engine.Evaluate("alice", "files/a", "edit", nil)
+	// Another replica may change the owner without invalidating this cache.
+	attrs["files/a"]["owner"] = "bob"
+	if dec := engine.Evaluate("alice", "files/a", "edit", nil); dec.Allow || dec.Cached {
+		t.Fatalf("expected fresh deny after the stored owner changed, got %v", dec)
+	}
+}
@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CombiningAlgorithm determines how the effects of multiple applicable
+// policies are merged into a single decision.
+type CombiningAlgorithm string
+
+const (
+	// DenyOverrides denies access if any applicable policy denies it.
+	DenyOverrides CombiningAlgorithm = "deny-overrides"
+	// PermitOverrides allows access if any applicable policy allows it.
+	PermitOverrides CombiningAlgorithm = "permit-overrides"
+	// FirstApplicable uses the effect of the applicable policy with the
+	// highest priority. Ties are broken by policy ID.
+	FirstApplicable CombiningAlgorithm = "first-applicable"
+)
+
+// DefaultCombiningAlgorithm is used when a tenant does not declare one.
+const DefaultCombiningAlgorithm = DenyOverrides
+
+// ParseCombiningAlgorithm converts a string into a CombiningAlgorithm. An
+// empty string yields the default algorithm.
+func ParseCombiningAlgorithm(s string) (CombiningAlgorithm, error) {
+	switch CombiningAlgorithm(s) {
+	case "":
+		return DefaultCombiningAlgorithm, nil
+	case DenyOverrides, PermitOverrides, FirstApplicable:
+		return CombiningAlgorithm(s), nil
+	}
+	return "", fmt.Errorf("unknown combining algorithm %q", s)
+}
+
+// applicable is a policy whose target and conditions matched a request.
+type applicable struct {
+	policy    Policy
+	delegator string
+	chain     []string
+	grant     roleGrant
+}
+
+// sortApplicable orders policies by descending priority and then by ID so
+// that combining is independent of map and slice iteration order.
+func sortApplicable(list []applicable) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].policy.Priority != list[j].policy.Priority {
+			return list[i].policy.Priority > list[j].policy.Priority
+		}
+		return list[i].policy.ID < list[j].policy.ID
+	})
+}
+
+// combine merges the applicable policies into a decision using alg. The list
+// must be non-empty and sorted with sortApplicable.
+func combine(alg CombiningAlgorithm, list []applicable) Decision {
+	var allows, denies []applicable
+	for _, a := range list {
+		if a.policy.Effect == "allow" {
+			allows = append(allows, a)
+		} else {
+			denies = append(denies, a)
+		}
+	}
+
+	var winners []applicable
+	switch alg {
+	case PermitOverrides:
+		if len(allows) > 0 {
+			winners = allows
+		} else {
+			winners = denies
+		}
+	case FirstApplicable:
+		winners = list[:1]
+	default:
+		alg = DenyOverrides
+		if len(denies) > 0 {
+			winners = denies
+		} else {
+			winners = allows
+		}
+	}
+
+	first := winners[0]
+	dec := Decision{
+		PolicyID:        first.policy.ID,
+		Delegator:       first.delegator,
+		DelegationChain: first.chain,
+		Algorithm:       string(alg),
+		Role:            first.grant.role,
+		InheritedVia:    first.grant.via,
+	}
+	for _, a := range list {
+		dec.PolicyIDs = append(dec.PolicyIDs, a.policy.ID)
+	}
+	for _, w := range winners {
+		dec.DecidingPolicyIDs = append(dec.DecidingPolicyIDs, w.policy.ID)
+	}
+	if first.policy.Effect == "allow" {
+		dec.Allow = true
+		dec.Reason = "allowed by policy"
+	} else {
+		dec.Reason = "denied by policy"
+	}
+	return dec
+}
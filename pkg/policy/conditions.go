@@ -1,18 +1,20 @@
 package policy
 
 import (
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/expr"
 )
 
 // now is a variable for mocking current time in tests.
 var now = time.Now
 
 // evaluateConditions checks whether all policy conditions are satisfied using
-// the provided environment values. It returns false along with the offending
-// condition key when a condition fails.
-func evaluateConditions(policyConds map[string]string, env map[string]string) (bool, string) {
+// the provided environment values. Keys prefixed with `resource.` are compared
+// against resource attributes resolved through attrs. It returns false along
+// with the offending condition key when a condition fails.
+func evaluateConditions(policyConds map[string]string, env map[string]string, attrs expr.Env) (bool, string) {
 	if len(policyConds) == 0 {
 		return true, ""
 	}
@@ -22,6 +24,11 @@ func evaluateConditions(policyConds map[string]string, env map[string]string) (b
 		case "time":
 			res = evaluateTimeCondition(expected, env)
 		default:
+			if strings.HasPrefix(key, "resource.") && attrs != nil {
+				v, ok := attrs.Lookup(key)
+				res = ok && v.Kind != expr.Null && v.String() == expected
+				break
+			}
 			if v, ok := env[key]; ok {
 				res = v == expected
 			} else {
@@ -35,136 +42,85 @@ func evaluateConditions(policyConds map[string]string, env map[string]string) (b
 	return true, ""
 }
 
-// evaluateWhen evaluates a list of boolean expressions against the environment.
-// Supported operators are ==, <, and >. Expressions must reference context
-// values using the form `context.key`. It returns false and the context key if
-// any expression fails.
-func evaluateWhen(exprs []string, env map[string]string) (bool, string) {
-	if len(exprs) == 0 {
-		return true, ""
-	}
-	for _, expr := range exprs {
-		if !evaluateExpression(expr, env) {
-			key := extractContextKey(expr)
-			return false, key
+// evaluateWhen evaluates compiled `when` expressions against the request
+// attributes. It returns false and the attribute that explains the failure:
+// the first context key read by the failing expression (for example `risk`),
+// otherwise its first reference.
+func evaluateWhen(progs []*expr.Program, env expr.Env) (bool, string) {
+	for _, p := range progs {
+		if ok, err := p.Eval(env); err != nil || !ok {
+			return false, failureKey(p)
 		}
 	}
 	return true, ""
 }
 
-// extractContextKey attempts to parse the context key from an expression like
-// `context.key < "value"`.
-func extractContextKey(expr string) string {
-	expr = strings.TrimSpace(expr)
-	var parts []string
-	if strings.Contains(expr, "==") {
-		parts = strings.SplitN(expr, "==", 2)
-	} else if strings.Contains(expr, ">") {
-		parts = strings.SplitN(expr, ">", 2)
-	} else if strings.Contains(expr, "<") {
-		parts = strings.SplitN(expr, "<", 2)
-	} else {
-		return ""
+func failureKey(p *expr.Program) string {
+	if keys := p.ContextKeys(); len(keys) > 0 {
+		return keys[0]
 	}
-	left := strings.TrimSpace(parts[0])
-	if strings.HasPrefix(left, "context.") {
-		return strings.TrimPrefix(left, "context.")
+	if refs := p.References(); len(refs) > 0 {
+		return refs[0]
 	}
-	return ""
+	return "condition"
 }
 
-// evaluateExpression parses and evaluates a single expression.
-func evaluateExpression(expr string, env map[string]string) bool {
-	expr = strings.TrimSpace(expr)
-	var op string
-	var parts []string
-	if strings.Contains(expr, "==") {
-		op = "=="
-		parts = strings.SplitN(expr, "==", 2)
-	} else if strings.Contains(expr, ">") {
-		op = ">"
-		parts = strings.SplitN(expr, ">", 2)
-	} else if strings.Contains(expr, "<") {
-		op = "<"
-		parts = strings.SplitN(expr, "<", 2)
-	} else {
-		return false
-	}
-	if len(parts) != 2 {
-		return false
-	}
-	left := strings.TrimSpace(parts[0])
-	right := strings.TrimSpace(parts[1])
-	right = strings.Trim(right, "'\"")
-	if !strings.HasPrefix(left, "context.") {
-		return false
-	}
-	key := strings.TrimPrefix(left, "context.")
-	val, ok := env[key]
-	if !ok {
-		return false
-	}
-	switch op {
-	case "==":
-		return val == right
-	case "<", ">":
-		return compareValues(val, right, op)
-	}
-	return false
+// evalEnv exposes request attributes to `when` expressions: `context.*` for
+// environment values, `subject.id` and `subject.roles`, `resource.id`,
+// `resource.<attribute>` and `action`.
+type evalEnv struct {
+	context  map[string]string
+	subject  string
+	roles    []string
+	resource string
+	action   string
+	attrs    *resourceAttrs
 }
 
-// compareValues compares two values using the provided operator. It attempts
-// numeric comparison, then known risk level ordering, and finally falls back to
-// lexical string comparison.
-func compareValues(left, right, op string) bool {
-	if lf, err := strconv.ParseFloat(left, 64); err == nil {
-		if rf, err := strconv.ParseFloat(right, 64); err == nil {
-			switch op {
-			case "<":
-				return lf < rf
-			case ">":
-				return lf > rf
-			}
-		}
-	}
-	order := map[string]int{"low": 1, "medium": 2, "high": 3}
-	if lv, ok := order[strings.ToLower(left)]; ok {
-		if rv, ok := order[strings.ToLower(right)]; ok {
-			switch op {
-			case "<":
-				return lv < rv
-			case ">":
-				return lv > rv
-			}
+// Lookup implements expr.Env.
+func (e evalEnv) Lookup(path string) (expr.Value, bool) {
+	switch path {
+	case "subject.id":
+		return expr.StringValue(e.subject), true
+	case "subject.roles":
+		return expr.StringList(e.roles), true
+	case "resource.id":
+		return expr.StringValue(e.resource), true
+	case "action":
+		return expr.StringValue(e.action), true
+	}
+	if strings.HasPrefix(path, "context.") {
+		if v, ok := e.context[strings.TrimPrefix(path, "context.")]; ok {
+			return expr.StringValue(v), true
 		}
 	}
-	switch op {
-	case "<":
-		return left < right
-	case ">":
-		return left > right
+	if strings.HasPrefix(path, "resource.") {
+		return e.attrs.lookup(strings.TrimPrefix(path, "resource."))
 	}
-	return false
+	return expr.Value{}, false
+}
+
+// clockLayout is the format of the "time" context value.
+const clockLayout = "15:04"
+
+// validClock reports whether ts is a time of day in clockLayout. Other
+// values are ignored in favour of the current time.
+func validClock(ts string) bool {
+	_, err := time.Parse(clockLayout, ts)
+	return err == nil
 }
 
 // evaluateTimeCondition evaluates the "time" condition. The expected value
 // "business-hours" means the time must be between 9:00 and 17:00.
 // The current time is taken from env["time"] in HH:MM format, or time.Now() if
-// not provided.
+// it is missing or malformed.
 func evaluateTimeCondition(expected string, env map[string]string) bool {
 	if expected != "business-hours" {
 		return false
 	}
-	var t time.Time
-	if env != nil {
-		if ts, ok := env["time"]; ok {
-			if parsed, err := time.Parse("15:04", ts); err == nil {
-				t = parsed
-			}
-		}
-	}
-	if t.IsZero() {
-		t = now()
+	t := now()
+	if ts := env["time"]; validClock(ts) {
+		t, _ = time.Parse(clockLayout, ts)
 	}
 	hour := t.Hour()
 	return hour >= 9 && hour < 17
@@ -2,11 +2,26 @@ package policy
 
 // Decision represents the outcome of a policy evaluation.
 type Decision struct {
-        Allow       bool              `json:"allow"`
-        PolicyID    string            `json:"policy_id,omitempty"`
-        Reason      string            `json:"reason"`
-        Context     map[string]string `json:"context,omitempty"`
-        Delegator   string            `json:"delegator,omitempty"`
-        Remediation []string          `json:"remediation,omitempty"`
-       Commit      string            `json:"commit,omitempty"`
+	Allow    bool   `json:"allow"`
+	PolicyID string `json:"policy_id,omitempty"`
+	// PolicyIDs lists every applicable policy, including those overridden
+	// by the combining algorithm. DecidingPolicyIDs are the ones whose
+	// effect is the outcome.
+	PolicyIDs         []string          `json:"policy_ids,omitempty"`
+	DecidingPolicyIDs []string          `json:"deciding_policy_ids,omitempty"`
+	Algorithm         string            `json:"algorithm,omitempty"`
+	Reason            string            `json:"reason"`
+	Context           map[string]string `json:"context,omitempty"`
+	Delegator         string            `json:"delegator,omitempty"`
+	// DelegationChain lists the users from the subject to Delegator when
+	// access was obtained through delegation.
+	DelegationChain []string `json:"delegation_chain,omitempty"`
+	Remediation     []string `json:"remediation,omitempty"`
+	Commit          string   `json:"commit,omitempty"`
+	// Cached reports whether the decision was served from the decision cache.
+	Cached bool `json:"cached,omitempty"`
+	// Role is the role whose policy decided the request. InheritedVia names
+	// the directly assigned role it was inherited through, if any.
+	Role         string `json:"role,omitempty"`
+	InheritedVia string `json:"inherited_via,omitempty"`
 }
@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+)
+
+// newDelegationEngine evaluates a store in which mary, an admin, reads and
+// writes files, and alice, bob, carol and dave hold no roles.
+func newDelegationEngine() *PolicyEngine {
+	store := testStore(
+		map[string][]string{"admin": {"p1"}},
+		map[string][]string{"mary": {"admin"}, "alice": nil, "bob": nil, "carol": nil, "dave": nil},
+		Policy{ID: "p1", Resource: []string{"files/**"}, Action: []string{"read", "write"}, Effect: "allow"},
+	)
+	return NewPolicyEngine(store, graph.New())
+}
+
+func TestDelegationScopeAndChain(t *testing.T) {
+	engine := newDelegationEngine()
+	engine.SetDelegations([]Delegation{
+		{ID: "d1", Delegator: "mary", Delegate: "bob", Actions: []string{"read"}},
+		{ID: "d2", Delegator: "bob", Delegate: "alice", Resources: []string{"files/reports/**"}},
+	})
+
+	dec := engine.Evaluate("alice", "files/reports/q1", "read", nil)
+	if !dec.Allow || dec.Delegator != "mary" {
+		t.Fatalf("expected delegated allow via mary, got %#v", dec)
+	}
+	if chain := strings.Join(dec.DelegationChain, ","); chain != "alice,bob,mary" {
+		t.Fatalf("expected chain alice,bob,mary, got %s", chain)
+	}
+	if dec := engine.Evaluate("alice", "files/reports/q1", "write", nil); dec.Allow {
+		t.Fatalf("expected action outside the scope to be denied")
+	}
+	if dec := engine.Evaluate("alice", "files/other", "read", nil); dec.Allow {
+		t.Fatalf("expected resource outside the scope to be denied")
+	}
+
+	if !engine.RevokeDelegation("d1") {
+		t.Fatalf("expected d1 to be revoked")
+	}
+	if dec := engine.Evaluate("alice", "files/reports/q1", "read", nil); dec.Allow {
+		t.Fatalf("expected revoked delegation to deny, got %#v", dec)
+	}
+	if engine.RevokeDelegation("d1") {
+		t.Fatalf("expected second revocation to report false")
+	}
+}
+
+func TestDelegationExpiry(t *testing.T) {
+	engine := newDelegationEngine()
+	engine.SetDecisionCache(NewDecisionCache(10))
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	expires := now.Add(time.Hour)
+	engine.AddDelegation(Delegation{ID: "d1", Delegator: "mary", Delegate: "bob", ExpiresAt: &expires})
+
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected unexpired delegation to allow, got %#v", dec)
+	}
+	now = expires
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); dec.Allow || dec.Cached {
+		t.Fatalf("expected expired delegation to deny without the cache, got %#v", dec)
+	}
+}
+
+func TestDelegationMaxDepth(t *testing.T) {
+	engine := newDelegationEngine()
+	engine.SetDelegations([]Delegation{
+		{ID: "d1", Delegator: "mary", Delegate: "carol"},
+		{ID: "d2", Delegator: "carol", Delegate: "bob"},
+		{ID: "d3", Delegator: "bob", Delegate: "alice"},
+		{ID: "d4", Delegator: "alice", Delegate: "dave"},
+	})
+	if dec := engine.Evaluate("alice", "files/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected three hops to be followed, got %#v", dec)
+	}
+	if dec := engine.Evaluate("dave", "files/a", "read", nil); dec.Allow {
+		t.Fatalf("expected four hops to exceed the default depth, got %#v", dec)
+	}
+	engine.SetMaxDelegationDepth(4)
+	if dec := engine.Evaluate("dave", "files/a", "read", nil); !dec.Allow || len(dec.DelegationChain) != 5 {
+		t.Fatalf("expected raised depth to allow, got %#v", dec)
+	}
+}
+
+func TestDelegationValidate(t *testing.T) {
+	bad := []Delegation{
+		{Delegator: "a", Delegate: "b"},
+		{ID: "d", Delegate: "b"},
+		{ID: "d", Delegator: "a", Delegate: "a"},
+		{ID: "d", Delegator: "a", Delegate: "b", Resources: []string{"files/a**"}},
+	}
+	for i, d := range bad {
+		if err := d.Validate(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestDelegationReevaluatesPoliciesForDelegator(t *testing.T) {
+	store := testStore(
+		map[string][]string{"reader": {"senior-read"}, "senior": nil},
+		map[string][]string{"mary": {"reader", "senior"}, "bob": {"reader"}},
+		Policy{ID: "senior-read", Resource: []string{"files/**"}, Action: []string{"read"}, Effect: "allow", When: []string{`"senior" in subject.roles`}},
+	)
+	engine := NewPolicyEngine(store, graph.New())
+	if dec := engine.Evaluate("bob", "files/a", "read", nil); dec.Allow {
+		t.Fatalf("expected bob alone to be denied, got %#v", dec)
+	}
+	engine.SetDelegations([]Delegation{{ID: "d1", Delegator: "mary", Delegate: "bob"}})
+	// senior-read fails for bob but holds for mary.
+	dec := engine.Evaluate("bob", "files/a", "read", nil)
+	if !dec.Allow || dec.Delegator != "mary" || len(dec.PolicyIDs) != 1 {
+		t.Fatalf("expected read delegated through the policy bob failed, got %#v", dec)
+	}
+}
@@ -0,0 +1,270 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bradtumy/authorization-service/pkg/expr"
+	"github.com/bradtumy/authorization-service/pkg/pattern"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
+	"github.com/bradtumy/authorization-service/pkg/validator"
+)
+
+// compiledPolicy is a policy with its `when` expressions parsed.
+type compiledPolicy struct {
+	Policy
+	when []*expr.Program
+	// err records a `when` expression that failed to compile. Such policies
+	// never apply.
+	err error
+	// readsAttributes reports whether the policy's conditions reference
+	// resource attributes.
+	readsAttributes bool
+}
+
+// bucketKey groups candidate policies by literal action and by the first
+// literal segment of the resource pattern. Empty strings hold patterns that
+// begin with a wildcard.
+type bucketKey struct {
+	action   string
+	resource string
+}
+
+// policyIndex is an immutable, pre-compiled view of a PolicyStore. Evaluation
+// only reads from the index, so a store can swap in a new one atomically while
+// requests are in flight.
+type policyIndex struct {
+	users     map[string]User
+	roles     map[string]Role
+	policies  map[string]*compiledPolicy
+	algorithm CombiningAlgorithm
+	// byRole maps a role to the policies it grants, bucketed by action and
+	// resource prefix. Subject scoping is applied at build time.
+	byRole map[string]map[bucketKey][]*compiledPolicy
+	// rolePolicies lists the policies of each role in declaration order for
+	// full scans.
+	rolePolicies map[string][]*compiledPolicy
+	// inherited maps each role to the roles it inherits, nearest first.
+	inherited map[string][]string
+	// contextKeys lists, sorted, the request context keys read by any
+	// policy. Decisions only depend on these keys, so they form part of the
+	// decision cache key.
+	contextKeys []string
+	// readsAttributes reports whether any policy reads resource attributes.
+	readsAttributes bool
+	// relationBuckets holds policies that require a relation and are not
+	// restricted to roles, bucketed like byRole. They are candidates for
+	// every subject.
+	relationBuckets map[bucketKey][]*compiledPolicy
+	schema          *rebac.Schema
+	// commit is the source revision the index was built from.
+	commit string
+}
+
+// buildIndex compiles the given definitions. It always returns an index; the
+// error reports the first `when` expression that failed to compile or an
+// invalid role hierarchy, in which case inheritance is ignored.
+func buildIndex(policies map[string]Policy, roles map[string]Role, users map[string]User, alg CombiningAlgorithm, schema *rebac.Schema) (*policyIndex, error) {
+	idx := &policyIndex{
+		users:           make(map[string]User, len(users)),
+		roles:           make(map[string]Role, len(roles)),
+		policies:        make(map[string]*compiledPolicy, len(policies)),
+		algorithm:       alg,
+		byRole:          make(map[string]map[bucketKey][]*compiledPolicy, len(roles)),
+		rolePolicies:    make(map[string][]*compiledPolicy, len(roles)),
+		relationBuckets: make(map[bucketKey][]*compiledPolicy),
+		schema:          schema,
+	}
+	for k, v := range users {
+		idx.users[k] = v
+	}
+	programs := make(map[string]*expr.Program)
+	keys := make(map[string]struct{})
+	var firstErr error
+	for id, p := range policies {
+		cp := &compiledPolicy{Policy: p}
+		for k := range p.Conditions {
+			if strings.HasPrefix(k, "resource.") {
+				cp.readsAttributes = true
+			} else {
+				keys[k] = struct{}{}
+			}
+		}
+		for _, w := range p.When {
+			prog, ok := programs[w]
+			if !ok {
+				var err error
+				if prog, err = validator.CompileWhen(w); err != nil {
+					cp.err = fmt.Errorf("policy %s: invalid when expression %q: %v", p.ID, w, err)
+					if firstErr == nil {
+						firstErr = cp.err
+					}
+					break
+				}
+				programs[w] = prog
+			}
+			cp.when = append(cp.when, prog)
+			for _, k := range prog.ContextKeys() {
+				keys[k] = struct{}{}
+			}
+			for _, r := range prog.References() {
+				if strings.HasPrefix(r, "resource.") && r != "resource.id" {
+					cp.readsAttributes = true
+				}
+			}
+		}
+		idx.policies[id] = cp
+		if cp.readsAttributes {
+			idx.readsAttributes = true
+		}
+		if p.Relation != "" && len(p.Subjects) == 0 {
+			addToBuckets(idx.relationBuckets, cp)
+		}
+	}
+	for k := range keys {
+		idx.contextKeys = append(idx.contextKeys, k)
+	}
+	sort.Strings(idx.contextKeys)
+	parents := make(map[string][]string, len(roles))
+	for name, role := range roles {
+		parents[name] = role.Inherits
+	}
+	inherited, err := validator.RoleHierarchy(parents)
+	if err != nil {
+		inherited = nil
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	idx.inherited = inherited
+	for name, role := range roles {
+		idx.roles[name] = role
+		held := append([]string{name}, inherited[name]...)
+		buckets := make(map[bucketKey][]*compiledPolicy)
+		for _, id := range role.Policies {
+			cp, ok := idx.policies[id]
+			if !ok || !cp.appliesToRoles(held) {
+				continue
+			}
+			idx.rolePolicies[name] = append(idx.rolePolicies[name], cp)
+			addToBuckets(buckets, cp)
+		}
+		idx.byRole[name] = buckets
+	}
+	return idx, firstErr
+}
+
+// addToBuckets files cp under each of its action and resource prefix keys.
+func addToBuckets(buckets map[bucketKey][]*compiledPolicy, cp *compiledPolicy) {
+	for _, a := range cp.Action {
+		ak := ""
+		if pattern.IsLiteral(a) {
+			ak = a
+		}
+		for _, r := range cp.Resource {
+			k := bucketKey{action: ak, resource: firstLiteralSegment(r)}
+			buckets[k] = append(buckets[k], cp)
+		}
+	}
+}
+
+// appliesToRoles reports whether the policy's subject scoping admits a holder
+// of roles.
+func (p Policy) appliesToRoles(roles []string) bool {
+	if len(p.Subjects) == 0 {
+		return true
+	}
+	for _, s := range p.Subjects {
+		for _, r := range roles {
+			if s.Role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// roleGrant is a role held by a subject. Via names the directly held role
+// it was inherited through and is empty for direct roles.
+type roleGrant struct {
+	role string
+	via  string
+}
+
+// expandRoles returns the directly held roles followed by the roles they
+// inherit, each once. Direct roles take precedence over inherited ones.
+func (idx *policyIndex) expandRoles(roles []string) []roleGrant {
+	seen := make(map[string]struct{}, len(roles))
+	out := make([]roleGrant, 0, len(roles))
+	for _, r := range roles {
+		if _, ok := seen[r]; !ok {
+			seen[r] = struct{}{}
+			out = append(out, roleGrant{role: r})
+		}
+	}
+	for _, r := range roles {
+		for _, a := range idx.inherited[r] {
+			if _, ok := seen[a]; !ok {
+				seen[a] = struct{}{}
+				out = append(out, roleGrant{role: a, via: r})
+			}
+		}
+	}
+	return out
+}
+
+// roleNames returns the role names of grants.
+func roleNames(grants []roleGrant) []string {
+	out := make([]string, len(grants))
+	for i, g := range grants {
+		out[i] = g.role
+	}
+	return out
+}
+
+// firstLiteralSegment returns the first segment of a resource or resource
+// pattern, or an empty string if it contains a wildcard.
+func firstLiteralSegment(r string) string {
+	seg := r
+	if i := strings.IndexByte(r, pattern.ResourceSeparator); i >= 0 {
+		seg = r[:i]
+	}
+	if !pattern.IsLiteral(seg) {
+		return ""
+	}
+	return seg
+}
+
+// candidates returns the policies of role that may match action on resource
+// or on one of the resource groups containing it. The result can contain
+// duplicates and false positives; callers must still match the target.
+func (idx *policyIndex) candidates(role, resource, action string, groups []string) []*compiledPolicy {
+	return lookupBuckets(idx.byRole[role], resource, action, groups)
+}
+
+// relationCandidates returns the role-independent policies that require a
+// relation and may match action on resource.
+func (idx *policyIndex) relationCandidates(resource, action string, groups []string) []*compiledPolicy {
+	return lookupBuckets(idx.relationBuckets, resource, action, groups)
+}
+
+func lookupBuckets(buckets map[bucketKey][]*compiledPolicy, resource, action string, groups []string) []*compiledPolicy {
+	if len(buckets) == 0 {
+		return nil
+	}
+	prefixes := []string{"", firstLiteralSegment(resource)}
+	for _, g := range groups {
+		prefixes = append(prefixes, firstLiteralSegment(g))
+	}
+	var out []*compiledPolicy
+	for _, ak := range [2]string{action, ""} {
+		for _, rk := range prefixes {
+			out = append(out, buckets[bucketKey{action: ak, resource: rk}]...)
+		}
+		if action == "" {
+			break
+		}
+	}
+	return out
+}
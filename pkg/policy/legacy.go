@@ -0,0 +1,261 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
+)
+
+// Format identifies the schema of a policy file.
+type Format string
+
+const (
+	// FormatNative files declare roles, users and policies as read by
+	// PolicyStore.
+	FormatNative Format = "native"
+	// FormatPermissions files list the permissions of each role of each
+	// tenant, as read by the retired RBAC authorizer:
+	//
+	//	tenants:
+	//	  acme:
+	//	    roles:
+	//	      admin:
+	//	        permissions: ["user:list", "user:create"]
+	FormatPermissions Format = "permissions"
+	// FormatRules files are a list of rules evaluated in order against the
+	// roles and attributes of a verifiable credential, as read by the
+	// retired rule evaluator.
+	FormatRules Format = "rules"
+)
+
+// AllUsersRole is held by every subject: the engine adds it to the roles of
+// each known subject, and to Request.Roles, when the tenant defines it.
+// Converted rules that name no roles are bound to it.
+const AllUsersRole = "all-users"
+
+// Document is a policy file in the native schema.
+type Document struct {
+	Combining string            `yaml:"combining,omitempty"`
+	Schema    []rebac.Namespace `yaml:"schema,omitempty"`
+	Roles     []Role            `yaml:"roles,omitempty"`
+	Users     []User            `yaml:"users,omitempty"`
+	Policies  []Policy          `yaml:"policies"`
+}
+
+// Marshal encodes the document as YAML.
+func (d Document) Marshal() ([]byte, error) {
+	return yaml.Marshal(&d)
+}
+
+// LegacyRule is a rule of a FormatRules file.
+type LegacyRule struct {
+	ID          string            `yaml:"id"`
+	Description string            `yaml:"description,omitempty"`
+	Roles       []string          `yaml:"roles,omitempty"`
+	Actions     []string          `yaml:"actions"`
+	Resources   []string          `yaml:"resources"`
+	Conditions  map[string]string `yaml:"conditions,omitempty"`
+	Effect      string            `yaml:"effect"`
+	Advice      string            `yaml:"advice,omitempty"`
+}
+
+// legacyPermissions is the layout of a FormatPermissions file.
+type legacyPermissions struct {
+	Tenants map[string]struct {
+		Roles map[string]struct {
+			Permissions []string `yaml:"permissions"`
+		} `yaml:"roles"`
+	} `yaml:"tenants"`
+}
+
+// DetectFormat reports the schema of a policy file. Files that are neither
+// a list of rules nor a map holding only tenants are native.
+func DetectFormat(data []byte) Format {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return FormatNative
+	}
+	switch v := doc.(type) {
+	case []interface{}:
+		return FormatRules
+	case map[interface{}]interface{}:
+		if _, ok := v["tenants"]; ok && len(v) == 1 {
+			return FormatPermissions
+		}
+	}
+	return FormatNative
+}
+
+// SplitPermission returns the resource and action of a legacy permission.
+// "user:list" is action list on resource user; a permission without a colon
+// is an action on any resource.
+func SplitPermission(perm string) (resource, action string) {
+	if r, a, ok := strings.Cut(perm, ":"); ok {
+		return r, a
+	}
+	return "*", perm
+}
+
+// PermissionTenants returns the tenants of a FormatPermissions file, sorted.
+func PermissionTenants(data []byte) ([]string, error) {
+	var f legacyPermissions
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	tenants := make([]string, 0, len(f.Tenants))
+	for t := range f.Tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// ConvertPermissions converts the roles of one tenant of a FormatPermissions
+// file. Each permission becomes an allow policy named after it, bound to the
+// roles that held it. A tenant missing from the file has no policies.
+//
+// Resources and actions become glob patterns, which match more widely than
+// the exact comparison and whole-value `*` of the retired authorizer: `*` or
+// `?` inside a value, and `**` segments, now act as wildcards.
+func ConvertPermissions(data []byte, tenant string) (Document, error) {
+	var f legacyPermissions
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return Document{}, err
+	}
+	var doc Document
+	seen := make(map[string]bool)
+	roles := f.Tenants[tenant].Roles
+	for _, name := range sortedKeys(roles) {
+		role := Role{Name: name, Policies: []string{}}
+		for _, perm := range roles[name].Permissions {
+			role.Policies = append(role.Policies, perm)
+			if seen[perm] {
+				continue
+			}
+			seen[perm] = true
+			resource, action := SplitPermission(perm)
+			doc.Policies = append(doc.Policies, Policy{ID: perm, Resource: []string{resource}, Action: []string{action}, Effect: "allow"})
+		}
+		doc.Roles = append(doc.Roles, role)
+	}
+	sort.Slice(doc.Policies, func(i, j int) bool { return doc.Policies[i].ID < doc.Policies[j].ID })
+	return doc, nil
+}
+
+// ParseRules reads a FormatRules file.
+func ParseRules(data []byte) ([]LegacyRule, error) {
+	var rules []LegacyRule
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ConvertRules converts rules into policies combined first-applicable, with
+// priorities preserving the rules' order. Each rule is bound to the roles
+// it names, or to AllUsersRole when it names none; the credential
+// attributes rules matched are read from the request context. Advice is
+// kept as the policy's description when it has none. As with
+// ConvertPermissions, resources and actions become glob patterns.
+func ConvertRules(rules []LegacyRule) Document {
+	doc := Document{Combining: string(FirstApplicable)}
+	bindings := make(map[string][]string)
+	for i, r := range rules {
+		p := Policy{
+			ID:          r.ID,
+			Description: r.Description,
+			Resource:    r.Resources,
+			Action:      r.Actions,
+			Effect:      r.Effect,
+			Conditions:  r.Conditions,
+			Priority:    len(rules) - i,
+		}
+		if p.Description == "" {
+			p.Description = r.Advice
+		}
+		roles := r.Roles
+		if len(roles) == 0 {
+			roles = []string{AllUsersRole}
+		}
+		for _, role := range roles {
+			bindings[role] = append(bindings[role], r.ID)
+		}
+		doc.Policies = append(doc.Policies, p)
+	}
+	for _, name := range sortedKeys(bindings) {
+		doc.Roles = append(doc.Roles, Role{Name: name, Policies: bindings[name]})
+	}
+	return doc
+}
+
+// ReadBundle assembles the bundle at name like bundle.Load, converting
+// legacy files into the native schema first: a FormatPermissions file
+// yields the roles of tenant, or of "default" when tenant is empty, and a
+// FormatRules file or a directory of them yields their rules in file name
+// order.
+func ReadBundle(name, tenant string) (*bundle.Bundle, error) {
+	doc, ok, err := ConvertLegacy(name, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return bundle.Load(name)
+	}
+	data, err := doc.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &bundle.Bundle{Files: []bundle.File{{Path: filepath.Base(name), Data: data}}}, nil
+}
+
+// ConvertLegacy converts the legacy file or rules directory at name as
+// ReadBundle does. It reports false for native files, bundles and archives.
+func ConvertLegacy(name, tenant string) (Document, bool, error) {
+	info, err := os.Stat(name)
+	if err != nil || bundle.IsArchive(name) {
+		return Document{}, false, nil
+	}
+	files := []string{name}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(name, "*.yaml")); err != nil || len(files) == 0 {
+			return Document{}, false, err
+		}
+	}
+	var rules []LegacyRule
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return Document{}, false, err
+		}
+		switch DetectFormat(data) {
+		case FormatPermissions:
+			if info.IsDir() {
+				return Document{}, false, nil
+			}
+			if tenant == "" {
+				tenant = "default"
+			}
+			doc, err := ConvertPermissions(data, tenant)
+			if err != nil {
+				return Document{}, false, fmt.Errorf("%s: %v", f, err)
+			}
+			return doc, true, nil
+		case FormatRules:
+			rs, err := ParseRules(data)
+			if err != nil {
+				return Document{}, false, fmt.Errorf("%s: %v", f, err)
+			}
+			rules = append(rules, rs...)
+		default:
+			return Document{}, false, nil
+		}
+	}
+	return ConvertRules(rules), true, nil
+}
@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLegacyRules(t *testing.T) {
+	ps := NewPolicyStore()
+	if err := ps.LoadPolicies(filepath.Join("..", "..", "policies")); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	pe := NewPolicyEngine(ps, nil)
+	cases := []struct {
+		roles            []string
+		env              map[string]string
+		resource, action string
+		allow            bool
+		policy           string
+	}{
+		{[]string{"admin"}, nil, "doc", "read", true, "admin-read"},
+		{[]string{"viewer"}, nil, "doc", "read", false, "admin-read-deny"},
+		{nil, map[string]string{"department": "sales"}, "report", "view", true, "sales-view"},
+		{nil, map[string]string{"department": "engineering"}, "report", "view", false, "sales-view-deny"},
+	}
+	for _, tc := range cases {
+		dec := pe.EvaluateRequest(context.Background(), Request{
+			Subject:  "alice",
+			Resource: tc.resource,
+			Action:   tc.action,
+			Env:      tc.env,
+			Roles:    append([]string{}, tc.roles...),
+		})
+		if dec.Allow != tc.allow || dec.PolicyID != tc.policy {
+			t.Errorf("%v %s %s: expected allow=%v by %s, got %#v", tc.roles, tc.action, tc.resource, tc.allow, tc.policy, dec)
+		}
+	}
+
+	// Subjects resolved by the engine, as for /check-access, hold
+	// AllUsersRole too.
+	ps.Users["bob"] = User{Username: "bob"}
+	ps.Rebuild()
+	if dec := pe.Evaluate("bob", "report", "view", map[string]string{"department": "sales"}); !dec.Allow || dec.PolicyID != "sales-view" {
+		t.Fatalf("expected roleless rule to apply to a known user, got %#v", dec)
+	}
+}
+
+func TestLegacyPermissions(t *testing.T) {
+	data := []byte(`tenants:
+  default:
+    roles:
+      admin:
+        permissions: ["user:list", "user:create"]
+      viewer:
+        permissions: ["user:list"]
+  acme:
+    roles:
+      admin:
+        permissions: ["user:list"]
+`)
+	if f := DetectFormat(data); f != FormatPermissions {
+		t.Fatalf("expected permissions format, got %s", f)
+	}
+	tenants, err := PermissionTenants(data)
+	if err != nil || len(tenants) != 2 || tenants[0] != "acme" {
+		t.Fatalf("unexpected tenants %v (%v)", tenants, err)
+	}
+	path := filepath.Join(t.TempDir(), "rbac.yaml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	ps := NewPolicyStore()
+	if err := ps.LoadPolicies(path); err != nil {
+		t.Fatalf("load permissions: %v", err)
+	}
+	if len(ps.Policies) != 2 {
+		t.Fatalf("expected one policy per permission, got %d", len(ps.Policies))
+	}
+	pe := NewPolicyEngine(ps, nil)
+	for _, tc := range []struct {
+		role, action string
+		allow        bool
+	}{
+		{"admin", "create", true},
+		{"viewer", "list", true},
+		{"viewer", "create", false},
+	} {
+		dec := pe.EvaluateRequest(context.Background(), Request{Subject: "bob", Resource: "user", Action: tc.action, Roles: []string{tc.role}})
+		if dec.Allow != tc.allow {
+			t.Errorf("%s %s: expected allow=%v, got %#v", tc.role, tc.action, tc.allow, dec)
+		}
+	}
+
+	doc, err := ConvertPermissions(data, "acme")
+	if err != nil || len(doc.Roles) != 1 || len(doc.Policies) != 1 {
+		t.Fatalf("unexpected acme document %#v (%v)", doc, err)
+	}
+	if f := DetectFormat([]byte("policies: []\n")); f != FormatNative {
+		t.Fatalf("expected native format, got %s", f)
+	}
+}
@@ -1,29 +1,38 @@
 package policy
 
-// Role represents a user role.
+// Role represents a user role. A role holds its own policies and those of
+// every role it inherits, directly or transitively.
 type Role struct {
-	Name     string   `yaml:"name"`
-	Policies []string `yaml:"policies"`
+	Name     string   `yaml:"name" json:"name"`
+	Policies []string `yaml:"policies" json:"policies"`
+	Inherits []string `yaml:"inherits,omitempty" json:"inherits,omitempty"`
 }
 
 // User represents a user and their assigned roles.
 type User struct {
-	Username string   `yaml:"username"`
-	Roles    []string `yaml:"roles"`
+	Username string   `yaml:"username" json:"username"`
+	Roles    []string `yaml:"roles" json:"roles"`
 }
 
 type Subject struct {
-	Role string `yaml:"role"`
+	Role string `yaml:"role" json:"role"`
 }
 
 // Policy represents an authorization policy.
 type Policy struct {
-	ID          string            `yaml:"id"`
-	Description string            `yaml:"description"`
-	Subjects    []Subject         `yaml:"subjects"`
-	Resource    []string          `yaml:"resource"`
-	Action      []string          `yaml:"action"`
-	Effect      string            `yaml:"effect"`
-	Conditions  map[string]string `yaml:"conditions"`
-	When        []string          `yaml:"when"`
+	ID          string            `yaml:"id" json:"id"`
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Subjects    []Subject         `yaml:"subjects,omitempty" json:"subjects,omitempty"`
+	Resource    []string          `yaml:"resource" json:"resource"`
+	Action      []string          `yaml:"action" json:"action"`
+	Effect      string            `yaml:"effect" json:"effect"`
+	Conditions  map[string]string `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+	When        []string          `yaml:"when,omitempty" json:"when,omitempty"`
+	// Priority orders policies for the first-applicable combining
+	// algorithm. Higher values are considered first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+	// Relation, when set, requires the subject to hold this relation on the
+	// resource (see package rebac). Such policies apply without a role
+	// binding unless Subjects restricts them to roles.
+	Relation string `yaml:"relation,omitempty" json:"relation,omitempty"`
 }
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+)
+
+// generateStore builds a store with n policies spread over 10 roles. Each
+// policy targets its own document folder and one of a few actions, and every
+// tenth policy carries a `when` condition.
+func generateStore(n int) *PolicyStore {
+	store := NewPolicyStore()
+	actions := []string{"read", "write", "delete", "document:*"}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("p%d", i)
+		p := Policy{
+			ID:       id,
+			Resource: []string{fmt.Sprintf("docs%d/**", i)},
+			Action:   []string{actions[i%len(actions)]},
+			Effect:   "allow",
+		}
+		if i%10 == 0 {
+			p.When = []string{`context.risk < "high"`}
+		}
+		if i%7 == 0 {
+			p.Effect = "deny"
+		}
+		store.Policies[id] = p
+		role := fmt.Sprintf("role%d", i%10)
+		r := store.Roles[role]
+		r.Name = role
+		r.Policies = append(r.Policies, id)
+		store.Roles[role] = r
+	}
+	store.Users["alice"] = User{Username: "alice", Roles: []string{"role0", "role3", "role7"}}
+	store.Rebuild()
+	return store
+}
+
+func TestIndexMatchesFullScan(t *testing.T) {
+	store := generateStore(500)
+	indexed := NewPolicyEngine(store, graph.New())
+	scan := NewPolicyEngine(store, graph.New())
+	scan.fullScan = true
+	env := map[string]string{"risk": "low"}
+	for i := 0; i < 500; i += 3 {
+		for _, action := range []string{"read", "write", "delete", "document:read"} {
+			res := fmt.Sprintf("docs%d/report", i)
+			a := indexed.Evaluate("alice", res, action, env)
+			b := scan.Evaluate("alice", res, action, env)
+			if a.Allow != b.Allow || a.PolicyID != b.PolicyID || a.Reason != b.Reason {
+				t.Fatalf("%s %s: indexed %#v, scan %#v", res, action, a, b)
+			}
+		}
+	}
+}
+
+func benchmarkEvaluate(b *testing.B, n int, fullScan bool) {
+	store := generateStore(n)
+	engine := NewPolicyEngine(store, graph.New())
+	engine.fullScan = fullScan
+	env := map[string]string{"risk": "low"}
+	resource := fmt.Sprintf("docs%d/report", n-20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.Evaluate("alice", resource, "read", env)
+	}
+}
+
+func BenchmarkEvaluateIndexed1k(b *testing.B)  { benchmarkEvaluate(b, 1000, false) }
+func BenchmarkEvaluateScan1k(b *testing.B)     { benchmarkEvaluate(b, 1000, true) }
+func BenchmarkEvaluateIndexed10k(b *testing.B) { benchmarkEvaluate(b, 10000, false) }
+func BenchmarkEvaluateScan10k(b *testing.B)    { benchmarkEvaluate(b, 10000, true) }
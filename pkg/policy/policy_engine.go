@@ -1,163 +1,418 @@
 package policy
 
 import (
+	"context"
 	"strings"
+	"time"
 
 	"github.com/bradtumy/authorization-service/pkg/graph"
+	"github.com/bradtumy/authorization-service/pkg/pattern"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
 	"github.com/bradtumy/authorization-service/pkg/remediation"
 	authuser "github.com/bradtumy/authorization-service/pkg/user"
 )
 
 // PolicyEngine evaluates policies to determine access decisions.
 //
-// The engine performs simple matching on resource and action attributes. Policies
-// may optionally scope themselves to specific roles via the `Subjects` field.
-// Evaluation stops at the first matching policy and returns a structured
-// decision describing the result.
+// The engine matches resource and action attributes against the patterns
+// declared by each policy (see package pattern). Policies may optionally scope
+// themselves to specific roles via the `Subjects` field.
+// All policies that apply to a request are collected and merged with the
+// tenant's combining algorithm (deny-overrides unless configured otherwise), so
+// the outcome does not depend on role or map iteration order. Candidate
+// policies are looked up in the store's pre-compiled index, so evaluation cost
+// depends on the policies relevant to a request rather than the policy count.
 type PolicyEngine struct {
 	store *PolicyStore
 	graph *graph.Graph
+	// fullScan disables index lookups and walks every policy of each role.
+	// It exists to benchmark the index against a linear scan.
+	fullScan bool
+	// attributes resolves resource attributes referenced by conditions.
+	attributes ResourceAttributeProvider
+	// cache, when set, memoizes decisions per policy index and graph version.
+	cache *DecisionCache
+	// delegations holds the delegation records followed during evaluation.
+	delegations delegations
+	// now returns the current time and is replaced in tests.
+	now func() time.Time
 }
 
 // NewPolicyEngine creates a new PolicyEngine instance.
 func NewPolicyEngine(store *PolicyStore, g *graph.Graph) *PolicyEngine {
-	return &PolicyEngine{store: store, graph: g}
+	return &PolicyEngine{store: store, graph: g, now: time.Now}
+}
+
+// SetResourceAttributeProvider configures where the engine looks up resource
+// attributes referenced by conditions. A nil provider leaves only the
+// attributes supplied with each request.
+func (pe *PolicyEngine) SetResourceAttributeProvider(p ResourceAttributeProvider) {
+	pe.attributes = p
+}
+
+// SetDecisionCache configures a decision cache in front of evaluation. A nil
+// cache disables caching.
+func (pe *PolicyEngine) SetDecisionCache(c *DecisionCache) {
+	pe.cache = c
+}
+
+// DecisionCache returns the engine's decision cache, or nil if caching is
+// disabled.
+func (pe *PolicyEngine) DecisionCache() *DecisionCache {
+	return pe.cache
+}
+
+// InvalidateCache discards cached decisions. Call it after changes the engine
+// cannot observe, such as role assignments in the identity provider or updates
+// to stored resource attributes.
+func (pe *PolicyEngine) InvalidateCache() {
+	pe.cache.Invalidate()
+}
+
+// Target identifies a resource and action pair to evaluate.
+type Target struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	// ResourceAttributes optionally describes the resource, for example its
+	// owner or classification.
+	ResourceAttributes map[string]interface{} `json:"resourceAttributes,omitempty"`
+}
+
+// Request is a single access request.
+type Request struct {
+	Subject  string
+	Resource string
+	Action   string
+	Env      map[string]string
+	// ResourceAttributes are attributes supplied by the caller. Attributes
+	// known to the engine's ResourceAttributeProvider take precedence.
+	ResourceAttributes map[string]interface{}
+	// Roles, when not nil, are the subject's roles. The subject is then not
+	// looked up and the decision is not cached.
+	Roles []string
 }
 
 // Evaluate determines whether the given subject is allowed to perform the
 // specified action on the resource. It returns a Decision describing the
 // outcome and does not log sensitive data.
 func (pe *PolicyEngine) Evaluate(subject, resource, action string, env map[string]string) Decision {
-	ctx := map[string]string{
-		"subject":  subject,
-		"resource": resource,
-		"action":   action,
-	}
-	for k, v := range env {
-		ctx[k] = v
-	}
+	return pe.EvaluateRequest(context.Background(), Request{Subject: subject, Resource: resource, Action: action, Env: env})
+}
 
-	addRemediation := func(dec Decision) Decision {
-		if !dec.Allow {
-			dec.Remediation = remediation.Suggest(dec.Context)
-		}
-		return dec
+// EvaluateRequest is like Evaluate but also accepts resource attributes. ctx
+// is passed to the ResourceAttributeProvider.
+func (pe *PolicyEngine) EvaluateRequest(ctx context.Context, req Request) Decision {
+	return pe.evaluate(ctx, pe.store.snapshot(), req)
+}
+
+// EvaluateBatch evaluates several targets for one subject against a single
+// snapshot of the policies, so a concurrent reload cannot produce a mix of
+// old and new decisions. Decisions are returned in the order of targets. ctx
+// is passed to the ResourceAttributeProvider.
+func (pe *PolicyEngine) EvaluateBatch(ctx context.Context, subject string, targets []Target, env map[string]string) []Decision {
+	idx := pe.store.snapshot()
+	out := make([]Decision, len(targets))
+	for i, t := range targets {
+		out[i] = pe.evaluate(ctx, idx, Request{
+			Subject:            subject,
+			Resource:           t.Resource,
+			Action:             t.Action,
+			Env:                env,
+			ResourceAttributes: t.ResourceAttributes,
+		})
 	}
+	return out
+}
 
-	// Collect candidate subjects including delegation chain.
-	subjects := []string{subject}
-	if pe.graph != nil {
-		queue := []string{subject}
-		visited := map[string]struct{}{subject: struct{}{}}
-		for len(queue) > 0 {
-			s := queue[0]
-			queue = queue[1:]
-			for _, t := range pe.graph.Targets("user:" + s) {
-				if strings.HasPrefix(t, "user:") {
-					u := strings.TrimPrefix(t, "user:")
-					if _, ok := visited[u]; !ok {
-						visited[u] = struct{}{}
-						subjects = append(subjects, u)
-						queue = append(queue, u)
-					}
-				}
+// evaluate decides the request, consulting the decision cache if one is
+// configured, and attaches the request context and remediation hints.
+func (pe *PolicyEngine) evaluate(reqCtx context.Context, idx *policyIndex, req Request) Decision {
+	var dec Decision
+	key, cacheable := "", false
+	if pe.cache != nil {
+		key, cacheable = cacheKey(idx, req)
+	}
+	if cacheable {
+		var graphVersion uint64
+		if pe.graph != nil {
+			graphVersion = pe.graph.Version()
+		}
+		gen := pe.cache.generation()
+		if cached, ok := pe.cache.get(key, idx, graphVersion); ok {
+			dec = cached
+			dec.PolicyIDs = append([]string(nil), cached.PolicyIDs...)
+			dec.DecidingPolicyIDs = append([]string(nil), cached.DecidingPolicyIDs...)
+			dec.Cached = true
+		} else {
+			var transient bool
+			dec, transient = pe.decide(reqCtx, idx, req)
+			if !transient {
+				pe.cache.put(key, dec, idx, graphVersion, gen)
 			}
 		}
+	} else {
+		dec, _ = pe.decide(reqCtx, idx, req)
 	}
 
+	dec.Commit = idx.commit
+	dec.Context = map[string]string{
+		"subject":  req.Subject,
+		"resource": req.Resource,
+		"action":   req.Action,
+	}
+	for k, v := range req.Env {
+		dec.Context[k] = v
+	}
+	if !dec.Allow {
+		dec.Remediation = remediation.Suggest(dec.Context)
+	}
+	return dec
+}
+
+// decide evaluates the request against idx. The returned decision carries no
+// request context or remediation. transient reports a decision that read
+// stored resource attributes, which may change on another replica, or that
+// rests on a delegation that may expire. Such decisions must not be cached.
+func (pe *PolicyEngine) decide(reqCtx context.Context, idx *policyIndex, req Request) (dec Decision, transient bool) {
+	subject, resource, action, env := req.Subject, req.Resource, req.Action, req.Env
+
+	links := pe.delegationChain(subject, resource, action, pe.now())
+	groups := pe.resourceGroups(resource)
 	tenantID := env["tenantID"]
-	for idx, subj := range subjects {
-		user, exists := pe.store.Users[subj]
-		if !exists && tenantID != "" {
-			if u, err := authuser.Get(tenantID, subj); err == nil {
-				user = User{Username: u.Username, Roles: u.Roles}
-				exists = true
-			}
+	attrs := &resourceAttrs{
+		ctx:      reqCtx,
+		provider: pe.attributes,
+		tenantID: tenantID,
+		resource: resource,
+		supplied: req.ResourceAttributes,
+	}
+	var matched []applicable
+	var failed *Decision
+	// A policy rejected for one link may still apply to the next, so policies
+	// are skipped per link, and across links only once they have applied.
+	applied := make(map[string]struct{})
+	for i, link := range links {
+		subj := link.user
+		if link.bounded {
+			// Delegations may expire, so the decision must not be cached.
+			transient = true
+		}
+		roles, exists := pe.subjectRoles(idx, tenantID, subj)
+		if i == 0 && req.Roles != nil {
+			roles, exists = idx.withAllUsers(req.Roles), true
 		}
-		if !exists {
-			if idx == 0 {
-				return addRemediation(Decision{Allow: false, Reason: "user not found", Context: ctx})
+		if !exists && len(idx.relationBuckets) == 0 {
+			if i == 0 {
+				return Decision{Allow: false, Reason: "user not found"}, false
 			}
 			continue
 		}
+		delegator, chain := "", []string(nil)
+		if subj != subject {
+			delegator, chain = subj, link.chain
+		}
+		grants := idx.expandRoles(roles)
+		vars := evalEnv{context: env, subject: subject, roles: roleNames(grants), resource: resource, action: action, attrs: attrs}
 
-		// Gather roles from user definition and graph-based group memberships.
-		roles := append([]string{}, user.Roles...)
-		if pe.graph != nil {
-			for _, target := range pe.graph.Targets("user:" + subj) {
-				if strings.HasPrefix(target, "group:") {
-					roles = append(roles, strings.TrimPrefix(target, "group:"))
-				}
+		var cands []candidate
+		for _, g := range grants {
+			policies := idx.rolePolicies[g.role]
+			if !pe.fullScan {
+				policies = idx.candidates(g.role, resource, action, groups)
+			}
+			for _, p := range policies {
+				cands = append(cands, candidate{compiledPolicy: p, grant: g})
 			}
 		}
-
-		for _, roleName := range roles {
-			role, exists := pe.store.Roles[roleName]
-			if !exists {
+		for _, p := range idx.relationCandidates(resource, action, groups) {
+			cands = append(cands, candidate{compiledPolicy: p})
+		}
+		seen := make(map[string]struct{})
+		for _, policy := range cands {
+			if _, ok := seen[policy.ID]; ok {
+				continue
+			}
+			if _, ok := applied[policy.ID]; ok {
+				continue
+			}
+			if !matchTarget(policy.Policy, resource, action, groups) {
 				continue
 			}
+			seen[policy.ID] = struct{}{}
 
-			for _, policyID := range role.Policies {
-				policy, exists := pe.store.Policies[policyID]
-				if !exists {
-					continue
-				}
-				// Ensure the policy applies to the current role
-				if len(policy.Subjects) > 0 {
-					allowed := false
-					for _, subjRole := range policy.Subjects {
-						if subjRole.Role == roleName {
-							allowed = true
-							break
-						}
-					}
-					if !allowed {
-						continue
-					}
+			ok, reason := pe.holdsRelation(idx, policy.Relation, resource, subj)
+			related := ok
+			if ok {
+				ok, reason = evaluateConditions(policy.Conditions, env, vars)
+			}
+			if ok && policy.err != nil {
+				ok, reason = false, "invalid condition"
+			}
+			if ok {
+				ok, reason = evaluateWhen(policy.when, vars)
+			}
+			if related && policy.readsAttributes && attrs.failed() {
+				// The outcome of a policy that reads attributes cannot be
+				// trusted without them, whatever its effect.
+				return Decision{Allow: false, PolicyID: policy.ID, Reason: "resource attributes unavailable", Delegator: delegator, DelegationChain: chain}, true
+			}
+			if !ok {
+				// Remember the first unmet condition so a request that
+				// matches no applicable policy can explain why.
+				if failed == nil {
+					failed = &Decision{Allow: false, PolicyID: policy.ID, Reason: reason, Delegator: delegator, DelegationChain: chain}
 				}
+				continue
+			}
+			matched = append(matched, applicable{policy: policy.Policy, delegator: delegator, chain: chain, grant: policy.grant})
+			applied[policy.ID] = struct{}{}
+		}
+	}
 
-				for _, polResource := range policy.Resource {
-					matchResource := polResource == "*" || polResource == resource
-					if !matchResource && pe.graph != nil {
-						if pe.graph.HasPath("group:"+polResource, "resource:"+resource) {
-							matchResource = true
-						}
-					}
-					for _, polAction := range policy.Action {
-						if matchResource && (polAction == "*" || polAction == action) {
-							if ok, reason := evaluateConditions(policy.Conditions, env); !ok {
-								dec := Decision{Allow: false, PolicyID: policy.ID, Reason: reason, Context: ctx}
-								if subj != subject {
-									dec.Delegator = subj
-								}
-								return addRemediation(dec)
-							}
-							if ok, reason := evaluateWhen(policy.When, env); !ok {
-								dec := Decision{Allow: false, PolicyID: policy.ID, Reason: reason, Context: ctx}
-								if subj != subject {
-									dec.Delegator = subj
-								}
-								return addRemediation(dec)
-							}
-							dec := Decision{PolicyID: policy.ID, Context: ctx}
-							if subj != subject {
-								dec.Delegator = subj
-							}
-							switch policy.Effect {
-							case "allow":
-								dec.Allow = true
-								dec.Reason = "allowed by policy"
-							case "deny":
-								dec.Allow = false
-								dec.Reason = "denied by policy"
-							}
-							return addRemediation(dec)
-						}
-					}
-				}
+	if len(matched) > 0 {
+		sortApplicable(matched)
+		return combine(idx.algorithm, matched), transient || attrs.consulted()
+	}
+	if failed != nil {
+		return *failed, transient || attrs.consulted()
+	}
+	return Decision{Allow: false, Reason: "no matching policy"}, transient || attrs.consulted()
+}
+
+// candidate is a policy that may apply to a request together with the role
+// that grants it, which is empty for policies granted by a relation alone.
+type candidate struct {
+	*compiledPolicy
+	grant roleGrant
+}
+
+// holdsRelation reports whether user holds relation on the resource, read as
+// a `type:id` object. An empty relation always holds. On failure it returns
+// the decision reason.
+func (pe *PolicyEngine) holdsRelation(idx *policyIndex, relation, resource, user string) (bool, string) {
+	if relation == "" {
+		return true, ""
+	}
+	obj, err := rebac.ParseObject(resource)
+	if err != nil {
+		return false, "missing relation " + relation
+	}
+	ok, err := pe.checker(idx).Check(obj, relation, rebac.Subject{Object: rebac.Object{Type: "user", ID: user}})
+	if err != nil {
+		return false, "relation check depth exceeded"
+	}
+	if !ok {
+		return false, "missing relation " + relation
+	}
+	return true, ""
+}
+
+// checker returns a relationship checker over the engine's graph.
+func (pe *PolicyEngine) checker(idx *policyIndex) *rebac.Checker {
+	c := &rebac.Checker{Schema: idx.schema}
+	if pe.graph != nil {
+		c.Tuples = pe.graph
+	}
+	return c
+}
+
+// Check reports whether subject holds relation on object, using the tenant's
+// schema and the relationship tuples stored in the graph. object is written
+// `type:id` and subject `type:id` or `type:id#relation`.
+func (pe *PolicyEngine) Check(object, relation, subject string) (bool, error) {
+	obj, err := rebac.ParseObject(object)
+	if err != nil {
+		return false, err
+	}
+	sub, err := rebac.ParseSubject(subject)
+	if err != nil {
+		return false, err
+	}
+	return pe.checker(pe.store.snapshot()).Check(obj, relation, sub)
+}
+
+// Schema returns the tenant's relation schema, which may be nil.
+func (pe *PolicyEngine) Schema() *rebac.Schema {
+	return pe.store.snapshot().schema
+}
+
+// subjectRoles returns the roles of a user from the policy file or the
+// identity provider, plus graph-based group memberships. It reports false if
+// the user is unknown.
+func (pe *PolicyEngine) subjectRoles(idx *policyIndex, tenantID, subject string) ([]string, bool) {
+	user, exists := idx.users[subject]
+	if !exists && tenantID != "" {
+		if u, err := authuser.Get(tenantID, subject); err == nil {
+			user = User{Username: u.Username, Roles: u.Roles}
+			exists = true
+		}
+	}
+	if !exists {
+		return nil, false
+	}
+	roles := append([]string{}, user.Roles...)
+	if pe.graph != nil {
+		for _, target := range pe.graph.Targets("user:" + subject) {
+			if strings.HasPrefix(target, "group:") {
+				roles = append(roles, strings.TrimPrefix(target, "group:"))
 			}
 		}
 	}
+	return idx.withAllUsers(roles), true
+}
+
+// withAllUsers adds AllUsersRole to roles when the tenant defines it, since
+// every subject holds it.
+func (idx *policyIndex) withAllUsers(roles []string) []string {
+	if _, ok := idx.roles[AllUsersRole]; !ok {
+		return roles
+	}
+	for _, r := range roles {
+		if r == AllUsersRole {
+			return roles
+		}
+	}
+	return append(append([]string(nil), roles...), AllUsersRole)
+}
+
+// resourceGroups returns the graph groups that contain the resource, directly
+// or transitively, without the `group:` prefix.
+func (pe *PolicyEngine) resourceGroups(resource string) []string {
+	if pe.graph == nil {
+		return nil
+	}
+	var groups []string
+	for _, n := range pe.graph.Ancestors("resource:" + resource) {
+		if strings.HasPrefix(n, "group:") {
+			groups = append(groups, strings.TrimPrefix(n, "group:"))
+		}
+	}
+	return groups
+}
 
-	return addRemediation(Decision{Allow: false, Reason: "no matching policy", Context: ctx})
+// matchTarget reports whether the policy's resource and action patterns cover
+// the request. Resource entries may also name a graph group containing the
+// resource.
+func matchTarget(policy Policy, resource, action string, groups []string) bool {
+	matchAction := false
+	for _, polAction := range policy.Action {
+		if pattern.MatchAction(polAction, action) {
+			matchAction = true
+			break
+		}
+	}
+	if !matchAction {
+		return false
+	}
+	for _, polResource := range policy.Resource {
+		if pattern.MatchResource(polResource, resource) {
+			return true
+		}
+		for _, g := range groups {
+			if g == polResource {
+				return true
+			}
+		}
+	}
+	return false
 }
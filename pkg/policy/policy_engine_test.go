@@ -1,6 +1,8 @@
 package policy
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/bradtumy/authorization-service/pkg/graph"
@@ -284,3 +286,229 @@ func TestEvaluateDelegationChainInvalid(t *testing.T) {
 		t.Fatalf("unexpected delegator %q for failed delegation", dec.Delegator)
 	}
 }
+
+func TestEvaluateGlobPatterns(t *testing.T) {
+	store := NewPolicyStore()
+	store.Roles["analyst"] = Role{Name: "analyst", Policies: []string{"p1"}}
+	store.Users["alice"] = User{Username: "alice", Roles: []string{"analyst"}}
+	store.Policies["p1"] = Policy{
+		ID:       "p1",
+		Subjects: []Subject{{Role: "analyst"}},
+		Resource: []string{"files/reports/**"},
+		Action:   []string{"document:*"},
+		Effect:   "allow",
+	}
+
+	engine := NewPolicyEngine(store, graph.New())
+	if dec := engine.Evaluate("alice", "files/reports/2024/q1", "document:read", nil); !dec.Allow {
+		t.Fatalf("expected glob policy to allow access, got %#v", dec)
+	}
+	if dec := engine.Evaluate("alice", "files/private/q1", "document:read", nil); dec.Allow {
+		t.Fatalf("expected resource outside pattern to be denied")
+	}
+	if dec := engine.Evaluate("alice", "files/reports/q1", "folder:read", nil); dec.Allow {
+		t.Fatalf("expected action outside pattern to be denied")
+	}
+}
+
+// testStore returns a store holding policies, granting each role the listed
+// policy IDs and each user the listed roles.
+func testStore(roles, users map[string][]string, policies ...Policy) *PolicyStore {
+	store := NewPolicyStore()
+	for name, ids := range roles {
+		store.Roles[name] = Role{Name: name, Policies: ids}
+	}
+	for name, assigned := range users {
+		store.Users[name] = User{Username: name, Roles: assigned}
+	}
+	for _, p := range policies {
+		store.Policies[p.ID] = p
+	}
+	return store
+}
+
+// newCombiningStore grants alice both an allow and a deny of reading file1.
+func newCombiningStore(alg CombiningAlgorithm) *PolicyStore {
+	store := testStore(
+		map[string][]string{"reader": {"allow-read"}, "restricted": {"deny-read"}},
+		map[string][]string{"alice": {"reader", "restricted"}},
+		Policy{ID: "allow-read", Resource: []string{"file1"}, Action: []string{"read"}, Effect: "allow", Priority: 10},
+		Policy{ID: "deny-read", Resource: []string{"file1"}, Action: []string{"read"}, Effect: "deny", Priority: 1},
+	)
+	store.Algorithm = alg
+	return store
+}
+
+func TestEvaluateDenyOverrides(t *testing.T) {
+	engine := NewPolicyEngine(newCombiningStore(DenyOverrides), graph.New())
+	for i := 0; i < 20; i++ {
+		dec := engine.Evaluate("alice", "file1", "read", nil)
+		if dec.Allow || dec.PolicyID != "deny-read" {
+			t.Fatalf("expected deny-read to override, got %#v", dec)
+		}
+		if dec.Algorithm != string(DenyOverrides) {
+			t.Fatalf("unexpected algorithm %q", dec.Algorithm)
+		}
+	}
+}
+
+func TestEvaluatePermitOverrides(t *testing.T) {
+	engine := NewPolicyEngine(newCombiningStore(PermitOverrides), graph.New())
+	dec := engine.Evaluate("alice", "file1", "read", nil)
+	if !dec.Allow || dec.PolicyID != "allow-read" {
+		t.Fatalf("expected allow-read to override, got %#v", dec)
+	}
+	if len(dec.DecidingPolicyIDs) != 1 || dec.DecidingPolicyIDs[0] != "allow-read" {
+		t.Fatalf("unexpected deciding policies %v", dec.DecidingPolicyIDs)
+	}
+	if len(dec.PolicyIDs) != 2 {
+		t.Fatalf("expected the overridden deny to be listed, got %v", dec.PolicyIDs)
+	}
+}
+
+func TestEvaluateFirstApplicablePriority(t *testing.T) {
+	store := newCombiningStore(FirstApplicable)
+	engine := NewPolicyEngine(store, graph.New())
+	if dec := engine.Evaluate("alice", "file1", "read", nil); !dec.Allow {
+		t.Fatalf("expected higher priority allow to win, got %#v", dec)
+	}
+	p := store.Policies["deny-read"]
+	p.Priority = 20
+	store.Policies["deny-read"] = p
+	store.Rebuild()
+	if dec := engine.Evaluate("alice", "file1", "read", nil); dec.Allow {
+		t.Fatalf("expected higher priority deny to win, got %#v", dec)
+	}
+}
+
+func TestEvaluateWhenExpressions(t *testing.T) {
+	store := NewPolicyStore()
+	store.Roles["editor"] = Role{Name: "editor", Policies: []string{"p1"}}
+	store.Users["alice"] = User{Username: "alice", Roles: []string{"editor"}}
+	store.Policies["p1"] = Policy{
+		ID:       "p1",
+		Resource: []string{"files/**"},
+		Action:   []string{"edit"},
+		Effect:   "allow",
+		When: []string{
+			`(context.amount <= 100 || "approver" in subject.roles) && context.region != "cn"`,
+			`startsWith(resource.id, "files/")`,
+		},
+	}
+	engine := NewPolicyEngine(store, graph.New())
+	if dec := engine.Evaluate("alice", "files/a", "edit", map[string]string{"amount": "100", "region": "eu"}); !dec.Allow {
+		t.Fatalf("expected allow, got %#v", dec)
+	}
+	dec := engine.Evaluate("alice", "files/a", "edit", map[string]string{"amount": "101", "region": "eu"})
+	if dec.Allow || dec.Reason != "amount" {
+		t.Fatalf("expected deny on amount, got %#v", dec)
+	}
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	store := NewPolicyStore()
+	store.Roles["admin"] = Role{Name: "admin", Policies: []string{"p1"}}
+	store.Users["alice"] = User{Username: "alice", Roles: []string{"admin"}}
+	store.Policies["p1"] = Policy{
+		ID:       "p1",
+		Resource: []string{"files/*"},
+		Action:   []string{"read"},
+		Effect:   "allow",
+	}
+	engine := NewPolicyEngine(store, graph.New())
+	decs := engine.EvaluateBatch(context.Background(), "alice", []Target{
+		{Resource: "files/a", Action: "read"},
+		{Resource: "files/b", Action: "write"},
+		{Resource: "other/c", Action: "read"},
+	}, nil)
+	if len(decs) != 3 {
+		t.Fatalf("expected 3 decisions, got %d", len(decs))
+	}
+	if !decs[0].Allow || decs[1].Allow || decs[2].Allow {
+		t.Fatalf("unexpected decisions %#v", decs)
+	}
+	if decs[1].Context["action"] != "write" {
+		t.Fatalf("expected decisions in request order")
+	}
+}
+
+type staticAttributes map[string]map[string]interface{}
+
+func (s staticAttributes) ResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error) {
+	if attrs, ok := s[resource]; ok {
+		return attrs, nil
+	}
+	return nil, errors.New("attribute lookup failed")
+}
+
+// newOwnerStore lets alice, an editor, edit the files alice owns and read
+// public files.
+func newOwnerStore() *PolicyStore {
+	return testStore(
+		map[string][]string{"editor": {"edit-own", "read-public"}},
+		map[string][]string{"alice": {"editor"}},
+		Policy{ID: "edit-own", Resource: []string{"files/*"}, Action: []string{"edit"}, Effect: "allow", When: []string{"resource.owner == subject.id"}},
+		Policy{ID: "read-public", Resource: []string{"files/*"}, Action: []string{"read"}, Effect: "allow", Conditions: map[string]string{"resource.classification": "public"}},
+	)
+}
+
+func TestEvaluateResourceAttributes(t *testing.T) {
+	engine := NewPolicyEngine(newOwnerStore(), graph.New())
+	req := Request{Subject: "alice", Resource: "files/a", Action: "edit", ResourceAttributes: map[string]interface{}{"owner": "alice"}}
+	if dec := engine.EvaluateRequest(context.Background(), req); !dec.Allow {
+		t.Fatalf("expected owner to edit, got %v", dec)
+	}
+	req.ResourceAttributes["owner"] = "bob"
+	dec := engine.EvaluateRequest(context.Background(), req)
+	if dec.Allow || dec.Reason != "resource.owner" {
+		t.Fatalf("expected deny on owner mismatch, got %v", dec)
+	}
+	req = Request{Subject: "alice", Resource: "files/a", Action: "read", ResourceAttributes: map[string]interface{}{"classification": "public"}}
+	if dec := engine.EvaluateRequest(context.Background(), req); !dec.Allow {
+		t.Fatalf("expected public read, got %v", dec)
+	}
+	if dec := engine.Evaluate("alice", "files/a", "read", nil); dec.Allow {
+		t.Fatalf("expected deny without attributes")
+	}
+}
+
+func TestEvaluateResourceAttributeProvider(t *testing.T) {
+	engine := NewPolicyEngine(newOwnerStore(), graph.New())
+	engine.SetResourceAttributeProvider(staticAttributes{"files/a": {"owner": "bob"}})
+
+	// Stored attributes override those supplied by the caller.
+	req := Request{Subject: "alice", Resource: "files/a", Action: "edit", ResourceAttributes: map[string]interface{}{"owner": "alice"}}
+	if dec := engine.EvaluateRequest(context.Background(), req); dec.Allow {
+		t.Fatalf("expected provider owner to win, got %v", dec)
+	}
+
+	dec := engine.Evaluate("alice", "files/missing", "edit", nil)
+	if dec.Allow || dec.Reason != "resource attributes unavailable" {
+		t.Fatalf("expected provider failure to deny, got %v", dec)
+	}
+	// A failed lookup does not fall back to the caller's attributes.
+	req.Resource = "files/missing"
+	dec = engine.EvaluateRequest(context.Background(), req)
+	if dec.Allow || dec.Reason != "resource attributes unavailable" {
+		t.Fatalf("expected provider failure to deny a claimed owner, got %v", dec)
+	}
+}
+
+func TestEvaluateResourceAttributeProviderFailsClosed(t *testing.T) {
+	store := testStore(
+		map[string][]string{"reader": {"read-all", "deny-secret"}},
+		map[string][]string{"alice": {"reader"}},
+		Policy{ID: "read-all", Resource: []string{"files/*"}, Action: []string{"read"}, Effect: "allow"},
+		Policy{ID: "deny-secret", Resource: []string{"files/*"}, Action: []string{"read"}, Effect: "deny", When: []string{`resource.classification == "secret"`}},
+	)
+	engine := NewPolicyEngine(store, graph.New())
+	engine.SetResourceAttributeProvider(staticAttributes{"files/a": {"classification": "public"}})
+	if dec := engine.Evaluate("alice", "files/a", "read", nil); !dec.Allow {
+		t.Fatalf("expected public read, got %v", dec)
+	}
+	// The deny cannot be ruled out, so the allow must not win.
+	dec := engine.Evaluate("alice", "files/missing", "read", nil)
+	if dec.Allow || dec.PolicyID != "deny-secret" || dec.Reason != "resource attributes unavailable" {
+		t.Fatalf("expected provider failure to deny, got %v", dec)
+	}
+}
@@ -1,7 +1,9 @@
 package policy
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/bradtumy/authorization-service/pkg/graph"
@@ -80,3 +82,76 @@ policies:
 		t.Fatalf("expected allow decision after reload")
 	}
 }
+
+// Test that a bundle directory loads as a whole or not at all.
+func TestPolicyBundleReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("manifest.yaml", "revision: \"1\"\nfiles: [main.yaml]\n")
+	write("main.yaml", `import: [finance.yaml]
+roles:
+  - name: accountant
+    policies: ["finance.read"]
+users:
+  - username: alice
+    roles: ["accountant"]
+`)
+	write("finance.yaml", `namespace: finance
+policies:
+  - id: read
+    resource: ["ledger/**"]
+    action: ["read"]
+    effect: allow
+`)
+	store := NewPolicyStore()
+	if err := store.LoadPolicies(dir); err != nil {
+		t.Fatalf("load bundle: %v", err)
+	}
+	engine := NewPolicyEngine(store, graph.New())
+	if dec := engine.Evaluate("alice", "ledger/2024", "read", nil); !dec.Allow || dec.PolicyID != "finance.read" {
+		t.Fatalf("expected namespaced policy to allow, got %#v", dec)
+	}
+	if store.Revision != "1" {
+		t.Fatalf("expected revision 1, got %q", store.Revision)
+	}
+
+	// A broken file anywhere in the bundle leaves the previous set in place.
+	write("manifest.yaml", "revision: \"2\"\nfiles: [main.yaml]\n")
+	write("finance.yaml", "namespace: finance\npolicies:\n  - id: read\n    effect: allow\n")
+	if err := store.LoadPolicies(dir); err == nil {
+		t.Fatalf("expected invalid bundle to be rejected")
+	}
+	if dec := engine.Evaluate("alice", "ledger/2024", "read", nil); !dec.Allow || store.Revision != "1" {
+		t.Fatalf("expected previous bundle to remain active, got %#v revision %q", dec, store.Revision)
+	}
+}
+
+// Test that a change prepared before a reload cannot undo it.
+func TestApplyRejectsStaleSnapshot(t *testing.T) {
+	ps := NewPolicyStore()
+	snap := ps.Snapshot()
+	snap.Policies["p1"] = Policy{ID: "p1", Resource: []string{"files/*"}, Action: []string{"read"}, Effect: "allow"}
+	if err := ps.ReplaceAll([]Policy{{ID: "p2", Resource: []string{"docs/*"}, Action: []string{"read"}, Effect: "allow"}}, nil, nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	committed := false
+	if err := ps.Apply(snap, func() error { committed = true; return nil }); !errors.Is(err, ErrStaleSnapshot) || committed {
+		t.Fatalf("expected stale snapshot to be rejected before commit, got %v (committed %v)", err, committed)
+	}
+	if _, ok := ps.GetPolicy("p2"); !ok {
+		t.Fatalf("expected reloaded policies to stay in effect")
+	}
+	snap = ps.Snapshot()
+	delete(snap.Policies, "p2")
+	if err := ps.Apply(snap, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := ps.ReplaceAll([]Policy{{ID: "p3", Effect: "maybe"}}, nil, nil); err == nil {
+		t.Fatalf("expected invalid policies to be rejected")
+	}
+}
@@ -1,52 +1,102 @@
 package policy
 
 import (
-	"io/ioutil"
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
 	"github.com/bradtumy/authorization-service/pkg/validator"
 )
 
 // PolicyStore represents a store for policies, roles, and users.
+//
+// Evaluation reads from an immutable index built from the maps below. The
+// loaders rebuild and swap the index atomically; code that edits the maps
+// directly must call Rebuild afterwards.
 type PolicyStore struct {
 	Policies map[string]Policy
 	Roles    map[string]Role
 	Users    map[string]User
-	mu       sync.RWMutex
+	// Algorithm combines the effects of multiple applicable policies.
+	Algorithm CombiningAlgorithm
+	// Schema declares the relations used by relationship tuples and by
+	// policies that require a relation. It may be nil.
+	Schema *rebac.Schema
+	// Revision is the revision declared by the loaded bundle's manifest.
+	Revision string
+	// Digest identifies the signed archive the policies were loaded from.
+	Digest string
+	// Commit is the source control revision the policies were loaded from.
+	// It is reported on every decision.
+	Commit string
+	mu     sync.RWMutex
+	index  atomic.Pointer[policyIndex]
+	// schemaSource holds the namespaces Schema was compiled from, for
+	// validating changes made through Apply.
+	schemaSource []rebac.Namespace
+	// gen counts the changes swapped into the maps. Apply rejects snapshots
+	// taken before the latest one.
+	gen uint64
 }
 
+// ErrStaleSnapshot is returned by Apply when the store changed after the
+// snapshot was taken.
+var ErrStaleSnapshot = errors.New("policies changed since the snapshot was taken")
+
 // NewPolicyStore creates a new PolicyStore instance.
 func NewPolicyStore() *PolicyStore {
 	return &PolicyStore{
-		Policies: make(map[string]Policy),
-		Roles:    make(map[string]Role),
-		Users:    make(map[string]User),
+		Policies:  make(map[string]Policy),
+		Roles:     make(map[string]Role),
+		Users:     make(map[string]User),
+		Algorithm: DefaultCombiningAlgorithm,
 	}
 }
 
-// LoadPolicies loads policies, roles, and users from the specified file.
-// The configuration is validated before being swapped into the store.
+// LoadPolicies loads policies, roles, and users from the specified file or
+// bundle directory, following imports (see package bundle). Legacy files are
+// converted first (see ReadBundle). The whole bundle is validated before
+// being swapped into the store; on error the store is left unchanged.
 func (ps *PolicyStore) LoadPolicies(filePath string) error {
-	data, err := ioutil.ReadFile(filePath)
+	b, err := ReadBundle(filePath, "")
 	if err != nil {
 		return err
 	}
+	return ps.LoadBundle(b)
+}
 
-	if err = validator.ValidatePolicyData(data); err != nil {
+// LoadBundle validates an assembled bundle and swaps it into the store. On
+// error the store is left unchanged.
+func (ps *PolicyStore) LoadBundle(b *bundle.Bundle) error {
+	data, err := validator.ValidateBundle(b)
+	if err != nil {
 		return err
 	}
 
 	var config struct {
-		Roles    []Role   `yaml:"roles"`
-		Users    []User   `yaml:"users"`
-		Policies []Policy `yaml:"policies"`
+		Combining string            `yaml:"combining"`
+		Schema    []rebac.Namespace `yaml:"schema"`
+		Roles     []Role            `yaml:"roles"`
+		Users     []User            `yaml:"users"`
+		Policies  []Policy          `yaml:"policies"`
 	}
 
 	if err = yaml.UnmarshalStrict(data, &config); err != nil {
 		return err
 	}
+	alg, err := ParseCombiningAlgorithm(config.Combining)
+	if err != nil {
+		return err
+	}
+	schema, err := rebac.Compile(config.Schema)
+	if err != nil {
+		return err
+	}
 
 	newRoles := make(map[string]Role)
 	newUsers := make(map[string]User)
@@ -61,27 +111,192 @@ func (ps *PolicyStore) LoadPolicies(filePath string) error {
 	for _, policy := range config.Policies {
 		newPolicies[policy.ID] = policy
 	}
+	idx, err := buildIndex(newPolicies, newRoles, newUsers, alg, schema)
+	if err != nil {
+		return err
+	}
+	idx.commit = b.Commit
 
 	ps.mu.Lock()
 	ps.Roles = newRoles
 	ps.Users = newUsers
 	ps.Policies = newPolicies
+	ps.Algorithm = alg
+	ps.Schema = schema
+	ps.schemaSource = config.Schema
+	ps.Revision = b.Revision
+	ps.Digest = b.Digest
+	ps.Commit = b.Commit
+	ps.gen++
+	ps.index.Store(idx)
 	ps.mu.Unlock()
 
 	return nil
 }
 
 // ReplacePolicies swaps the current policies with the provided list. Roles and
-// users remain untouched. This is primarily used when loading policies from a
-// database backend.
-func (ps *PolicyStore) ReplacePolicies(policies []Policy) {
+// users remain untouched; use ReplaceAll to replace them too. Policies with
+// invalid `when` expressions are rejected and leave the store unchanged.
+func (ps *PolicyStore) ReplacePolicies(policies []Policy) error {
 	newPolicies := make(map[string]Policy)
 	for _, p := range policies {
 		newPolicies[p.ID] = p
 	}
 	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	idx, err := buildIndex(newPolicies, ps.Roles, ps.Users, ps.Algorithm, ps.Schema)
+	if err != nil {
+		return err
+	}
+	idx.commit = ps.Commit
 	ps.Policies = newPolicies
+	ps.gen++
+	ps.index.Store(idx)
+	return nil
+}
+
+// ReplaceAll swaps the current policies, roles and users with the provided
+// lists, as loaded from a database backend. The lists are validated as a
+// whole, like Apply, but replace whatever the store holds; on error the store
+// is left unchanged.
+func (ps *PolicyStore) ReplaceAll(policies []Policy, roles []Role, users []User) error {
+	s := Snapshot{
+		Policies: make(map[string]Policy, len(policies)),
+		Roles:    make(map[string]Role, len(roles)),
+		Users:    make(map[string]User, len(users)),
+	}
+	for _, p := range policies {
+		s.Policies[p.ID] = p
+	}
+	for _, r := range roles {
+		s.Roles[r.Name] = r
+	}
+	for _, u := range users {
+		s.Users[u.Username] = u
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.applyLocked(s, nil)
+}
+
+// Snapshot is a copy of a PolicyStore's policies, roles and users that can
+// be changed and passed to Apply.
+type Snapshot struct {
+	Policies map[string]Policy
+	Roles    map[string]Role
+	Users    map[string]User
+	gen      uint64
+}
+
+// Snapshot returns a copy of the store's policies, roles and users.
+func (ps *PolicyStore) Snapshot() Snapshot {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	s := Snapshot{
+		Policies: make(map[string]Policy, len(ps.Policies)),
+		Roles:    make(map[string]Role, len(ps.Roles)),
+		Users:    make(map[string]User, len(ps.Users)),
+		gen:      ps.gen,
+	}
+	for id, p := range ps.Policies {
+		s.Policies[id] = p
+	}
+	for name, r := range ps.Roles {
+		s.Roles[name] = r
+	}
+	for name, u := range ps.Users {
+		s.Users[name] = u
+	}
+	return s
+}
+
+// SchemaNamespaces returns the namespaces the relation schema was declared
+// with.
+func (ps *PolicyStore) SchemaNamespaces() []rebac.Namespace {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return append([]rebac.Namespace(nil), ps.schemaSource...)
+}
+
+// Configure replaces the combining algorithm and relation schema and
+// rebuilds the index. On error the store is left unchanged.
+func (ps *PolicyStore) Configure(alg CombiningAlgorithm, namespaces []rebac.Namespace) error {
+	alg, err := ParseCombiningAlgorithm(string(alg))
+	if err != nil {
+		return err
+	}
+	schema, err := rebac.Compile(namespaces)
+	if err != nil {
+		return err
+	}
+	ps.mu.Lock()
+	ps.Algorithm, ps.Schema, ps.schemaSource = alg, schema, namespaces
 	ps.mu.Unlock()
+	return ps.Rebuild()
+}
+
+// Apply validates s as a whole together with the store's combining algorithm
+// and schema, then calls commit, typically to persist the change, and swaps
+// s into the store if commit succeeds. commit may be nil. If the store
+// changed after s was taken, Apply returns ErrStaleSnapshot without calling
+// commit. On error the store is left unchanged.
+func (ps *PolicyStore) Apply(s Snapshot, commit func() error) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if s.gen != ps.gen {
+		return ErrStaleSnapshot
+	}
+	return ps.applyLocked(s, commit)
+}
+
+// applyLocked is Apply without the snapshot check. ps.mu must be held.
+func (ps *PolicyStore) applyLocked(s Snapshot, commit func() error) error {
+	cfg := struct {
+		Combining string            `yaml:"combining"`
+		Schema    []rebac.Namespace `yaml:"schema"`
+		Roles     []Role            `yaml:"roles"`
+		Users     []User            `yaml:"users"`
+		Policies  []Policy          `yaml:"policies"`
+	}{Combining: string(ps.Algorithm), Schema: ps.schemaSource}
+	for _, name := range sortedKeys(s.Roles) {
+		cfg.Roles = append(cfg.Roles, s.Roles[name])
+	}
+	for _, name := range sortedKeys(s.Users) {
+		cfg.Users = append(cfg.Users, s.Users[name])
+	}
+	for _, id := range sortedKeys(s.Policies) {
+		cfg.Policies = append(cfg.Policies, s.Policies[id])
+	}
+	data, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidatePolicyData(data); err != nil {
+		return err
+	}
+	idx, err := buildIndex(s.Policies, s.Roles, s.Users, ps.Algorithm, ps.Schema)
+	if err != nil {
+		return err
+	}
+	idx.commit = ps.Commit
+	if commit != nil {
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+	ps.Policies, ps.Roles, ps.Users = s.Policies, s.Roles, s.Users
+	ps.gen++
+	ps.index.Store(idx)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // GetPolicy retrieves a policy by its ID.
@@ -92,3 +307,24 @@ func (ps *PolicyStore) GetPolicy(id string) (Policy, bool) {
 	policy, exists := ps.Policies[id]
 	return policy, exists
 }
+
+// Rebuild recompiles the evaluation index from the Policies, Roles and Users
+// maps. The new index is always installed; the returned error reports a `when`
+// expression that failed to compile, whose policy will never apply.
+func (ps *PolicyStore) Rebuild() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	idx, err := buildIndex(ps.Policies, ps.Roles, ps.Users, ps.Algorithm, ps.Schema)
+	idx.commit = ps.Commit
+	ps.index.Store(idx)
+	return err
+}
+
+// snapshot returns the current evaluation index, building it on first use.
+func (ps *PolicyStore) snapshot() *policyIndex {
+	if idx := ps.index.Load(); idx != nil {
+		return idx
+	}
+	ps.Rebuild()
+	return ps.index.Load()
+}
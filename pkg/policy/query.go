@@ -0,0 +1,358 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bradtumy/authorization-service/pkg/pattern"
+	authuser "github.com/bradtumy/authorization-service/pkg/user"
+)
+
+// Permission describes a resource/action grant held by a subject.
+type Permission struct {
+	PolicyID string `json:"policy_id"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	// Members lists resources reached through a graph group named by
+	// Resource.
+	Members   []string `json:"members,omitempty"`
+	Role      string   `json:"role"`
+	Delegator string   `json:"delegator,omitempty"`
+	// InheritedVia names the directly assigned role through which Role is
+	// inherited, if any.
+	InheritedVia string `json:"inherited_via,omitempty"`
+	// Conditional is set when the grant depends on policy conditions, on a
+	// deny policy whose conditions may apply, or on a delegation limited in
+	// scope or time.
+	Conditional bool `json:"conditional"`
+	// Exceptions lists deny policies that may override part of the grant.
+	Exceptions []string `json:"exceptions,omitempty"`
+}
+
+// Access describes a role or user able to perform an action on a resource.
+type Access struct {
+	Kind        string   `json:"kind"`
+	Name        string   `json:"name"`
+	PolicyIDs   []string `json:"policy_ids"`
+	Conditional bool     `json:"conditional"`
+	// Delegators lists the users whose roles were needed via delegation.
+	Delegators []string `json:"delegators,omitempty"`
+}
+
+// grant is an allow or deny policy reachable by a subject.
+type grant struct {
+	policy    *compiledPolicy
+	role      roleGrant
+	delegator string
+	// bounded is set when the grant relies on a scoped or expiring
+	// delegation.
+	bounded bool
+}
+
+// SubjectPermissions lists what subject may do in the tenant. Allow policies
+// reachable through the subject's roles, graph groups and delegations are
+// reported per resource/action pattern. Grants fully covered by an
+// unconditional deny that wins under the tenant's combining algorithm are
+// omitted, and grants that depend on conditions are marked conditional.
+func (pe *PolicyEngine) SubjectPermissions(tenantID, subject string) ([]Permission, bool) {
+	idx := pe.store.snapshot()
+	var allows, denies []grant
+	seen := make(map[string]struct{})
+	found := false
+	for i, link := range pe.delegationChain(subject, "", "", pe.now()) {
+		subj := link.user
+		roles, ok := pe.subjectRoles(idx, tenantID, subj)
+		if !ok {
+			if i == 0 {
+				return nil, false
+			}
+			continue
+		}
+		found = true
+		delegator := ""
+		if subj != subject {
+			delegator = subj
+		}
+		for _, rg := range idx.expandRoles(roles) {
+			for _, p := range idx.rolePolicies[rg.role] {
+				if _, ok := seen[p.ID]; ok {
+					continue
+				}
+				seen[p.ID] = struct{}{}
+				g := grant{policy: p, role: rg, delegator: delegator, bounded: link.bounded}
+				if p.Effect == "allow" {
+					allows = append(allows, g)
+				} else {
+					denies = append(denies, g)
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	out := []Permission{}
+	for _, a := range allows {
+		for _, res := range a.policy.Resource {
+			for _, act := range a.policy.Action {
+				perm := Permission{
+					PolicyID:     a.policy.ID,
+					Resource:     res,
+					Action:       act,
+					Members:      pe.groupMembers(res),
+					Role:         a.role.role,
+					InheritedVia: a.role.via,
+					Delegator:    a.delegator,
+					Conditional:  a.policy.conditional() || a.bounded,
+				}
+				overridden := false
+				for _, d := range denies {
+					if !denyWins(idx.algorithm, d.policy.Policy, a.policy.Policy) || !d.policy.overlaps(res, act) {
+						continue
+					}
+					if !d.policy.conditional() && d.policy.covers(res, act) {
+						overridden = true
+						break
+					}
+					perm.Exceptions = append(perm.Exceptions, d.policy.ID)
+					if d.policy.covers(res, act) {
+						perm.Conditional = true
+					}
+				}
+				if !overridden {
+					out = append(out, perm)
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].PolicyID != out[j].PolicyID {
+			return out[i].PolicyID < out[j].PolicyID
+		}
+		if out[i].Resource != out[j].Resource {
+			return out[i].Resource < out[j].Resource
+		}
+		return out[i].Action < out[j].Action
+	})
+	return out, true
+}
+
+// ResourceAccess lists the roles and users that may perform action on
+// resource. Users are gathered from the policy file, the identity provider
+// and graph membership edges, and delegated access is included. Entries whose
+// access depends on conditions are marked conditional.
+func (pe *PolicyEngine) ResourceAccess(tenantID, resource, action string) []Access {
+	idx := pe.store.snapshot()
+	groups := pe.resourceGroups(resource)
+	out := []Access{}
+
+	roleNames := make([]string, 0, len(idx.roles))
+	for name := range idx.roles {
+		roleNames = append(roleNames, name)
+	}
+	sort.Strings(roleNames)
+	for _, role := range roleNames {
+		if ok, cond, ids := pe.resolve(idx, []string{role}, resource, action, groups); ok {
+			out = append(out, Access{Kind: "role", Name: role, PolicyIDs: ids, Conditional: cond})
+		}
+	}
+
+	for _, name := range pe.knownUsers(idx, tenantID) {
+		var roles, delegators []string
+		for i, link := range pe.delegationChain(name, resource, action, pe.now()) {
+			subj := link.user
+			r, ok := pe.subjectRoles(idx, tenantID, subj)
+			if !ok {
+				continue
+			}
+			if i > 0 {
+				delegators = append(delegators, subj)
+			}
+			roles = append(roles, r...)
+		}
+		ok, cond, ids := pe.resolve(idx, roles, resource, action, groups)
+		if !ok {
+			continue
+		}
+		entry := Access{Kind: "user", Name: name, PolicyIDs: ids, Conditional: cond}
+		if direct, _ := pe.subjectRoles(idx, tenantID, name); len(delegators) > 0 {
+			if ok, _, _ := pe.resolve(idx, direct, resource, action, groups); !ok {
+				entry.Delegators = delegators
+			}
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// resolve decides whether holders of roles may perform action on resource
+// without evaluating conditions. Conditional policies are considered both
+// applicable and inapplicable: access is granted if the most favourable case
+// allows it, and marked conditional if the least favourable case does not.
+// It returns the contributing policy IDs of the favourable case.
+func (pe *PolicyEngine) resolve(idx *policyIndex, roles []string, resource, action string, groups []string) (bool, bool, []string) {
+	var best, worst []applicable
+	seen := make(map[string]struct{})
+	for _, g := range idx.expandRoles(roles) {
+		for _, p := range idx.candidates(g.role, resource, action, groups) {
+			if _, ok := seen[p.ID]; ok || p.err != nil || !matchTarget(p.Policy, resource, action, groups) {
+				continue
+			}
+			seen[p.ID] = struct{}{}
+			a := applicable{policy: p.Policy}
+			cond := p.conditional()
+			if p.Effect == "allow" {
+				best = append(best, a)
+				if !cond {
+					worst = append(worst, a)
+				}
+			} else {
+				worst = append(worst, a)
+				if !cond {
+					best = append(best, a)
+				}
+			}
+		}
+	}
+	if len(best) == 0 {
+		return false, false, nil
+	}
+	sortApplicable(best)
+	dec := combine(idx.algorithm, best)
+	if !dec.Allow {
+		return false, false, nil
+	}
+	conditional := true
+	if len(worst) > 0 {
+		sortApplicable(worst)
+		conditional = !combine(idx.algorithm, worst).Allow
+	}
+	return true, conditional, dec.DecidingPolicyIDs
+}
+
+// knownUsers returns the sorted usernames from the policy file, the identity
+// provider and user nodes in the graph.
+func (pe *PolicyEngine) knownUsers(idx *policyIndex, tenantID string) []string {
+	set := make(map[string]struct{})
+	for name := range idx.users {
+		set[name] = struct{}{}
+	}
+	if tenantID != "" {
+		for _, u := range authuser.List(tenantID) {
+			set[u.Username] = struct{}{}
+		}
+	}
+	if pe.graph != nil {
+		for src, targets := range pe.graph.List() {
+			for _, n := range append(targets, src) {
+				if strings.HasPrefix(n, "user:") {
+					set[strings.TrimPrefix(n, "user:")] = struct{}{}
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for name := range set {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// groupMembers returns the resources reachable from the graph group name.
+func (pe *PolicyEngine) groupMembers(name string) []string {
+	if pe.graph == nil || !pattern.IsLiteral(name) {
+		return nil
+	}
+	var out []string
+	visited := map[string]struct{}{}
+	queue := []string{"group:" + name}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, t := range pe.graph.Targets(n) {
+			if _, ok := visited[t]; ok {
+				continue
+			}
+			visited[t] = struct{}{}
+			switch {
+			case strings.HasPrefix(t, "resource:"):
+				out = append(out, strings.TrimPrefix(t, "resource:"))
+			case strings.HasPrefix(t, "group:"):
+				queue = append(queue, t)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// conditional reports whether the policy only applies under conditions or
+// when a relation holds.
+func (p Policy) conditional() bool {
+	return len(p.Conditions) > 0 || len(p.When) > 0 || p.Relation != ""
+}
+
+// covers reports whether the policy's patterns match every resource and
+// action matched by the given patterns.
+func (p Policy) covers(res, act string) bool {
+	return anyCovers(p.Resource, res, pattern.ResourceSeparator) && anyCovers(p.Action, act, pattern.ActionSeparator)
+}
+
+// overlaps reports whether the policy's patterns may match some of the
+// resources and actions matched by the given patterns.
+func (p Policy) overlaps(res, act string) bool {
+	return anyOverlaps(p.Resource, res, pattern.ResourceSeparator) && anyOverlaps(p.Action, act, pattern.ActionSeparator)
+}
+
+func anyCovers(patterns []string, q string, sep byte) bool {
+	for _, p := range patterns {
+		if patternCovers(p, q, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyOverlaps(patterns []string, q string, sep byte) bool {
+	for _, p := range patterns {
+		if patternCovers(p, q, sep) || patternCovers(q, p, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternCovers approximates pattern containment by matching q as if it were
+// a literal value. A `**` segment in q is only covered by a pattern that can
+// also span segments.
+func patternCovers(p, q string, sep byte) bool {
+	if p == "*" {
+		return true
+	}
+	if q == "*" {
+		return false
+	}
+	if !pattern.Match(p, q, sep) {
+		return false
+	}
+	if strings.Contains(q, "**") && !strings.Contains(p, "**") {
+		return false
+	}
+	return true
+}
+
+// denyWins reports whether deny takes precedence over allow when both apply.
+func denyWins(alg CombiningAlgorithm, deny, allow Policy) bool {
+	switch alg {
+	case PermitOverrides:
+		return false
+	case FirstApplicable:
+		if deny.Priority != allow.Priority {
+			return deny.Priority > allow.Priority
+		}
+		return deny.ID < allow.ID
+	}
+	return true
+}
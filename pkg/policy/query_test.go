@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+)
+
+// newQueryStore grants alice, a viewer, read on files outside files/secret
+// and write from the office. bob is an auditor through the graph and carol
+// acts for alice.
+func newQueryStore() (*PolicyStore, *graph.Graph) {
+	store := testStore(
+		map[string][]string{"viewer": {"read-files", "deny-secret", "write-office"}, "auditor": {"read-reports"}},
+		map[string][]string{"alice": {"viewer"}, "bob": nil, "carol": nil},
+		Policy{ID: "read-files", Resource: []string{"files/**"}, Action: []string{"read"}, Effect: "allow"},
+		Policy{ID: "deny-secret", Resource: []string{"files/secret/**"}, Action: []string{"read"}, Effect: "deny"},
+		Policy{ID: "write-office", Resource: []string{"files/**"}, Action: []string{"write"}, Effect: "allow", When: []string{`context.network == "office"`}},
+		Policy{ID: "read-reports", Resource: []string{"reports"}, Action: []string{"read"}, Effect: "allow"},
+	)
+	g := graph.New()
+	g.AddRelation("user:bob", "group:auditor")
+	g.AddRelation("group:reports", "resource:q1")
+	g.AddRelation("user:carol", "user:alice")
+	return store, g
+}
+
+func TestSubjectPermissions(t *testing.T) {
+	store, g := newQueryStore()
+	engine := NewPolicyEngine(store, g)
+
+	perms, ok := engine.SubjectPermissions("", "alice")
+	if !ok {
+		t.Fatalf("expected alice to be found")
+	}
+	if len(perms) != 2 {
+		t.Fatalf("expected 2 permissions, got %#v", perms)
+	}
+	read, write := perms[0], perms[1]
+	if read.PolicyID != "read-files" || read.Conditional || len(read.Exceptions) != 1 || read.Exceptions[0] != "deny-secret" {
+		t.Fatalf("unexpected read permission %#v", read)
+	}
+	if write.PolicyID != "write-office" || !write.Conditional {
+		t.Fatalf("expected conditional write permission, got %#v", write)
+	}
+
+	perms, _ = engine.SubjectPermissions("", "bob")
+	if len(perms) != 1 || perms[0].Role != "auditor" || len(perms[0].Members) != 1 || perms[0].Members[0] != "q1" {
+		t.Fatalf("expected group-based permission on reports, got %#v", perms)
+	}
+
+	perms, _ = engine.SubjectPermissions("", "carol")
+	if len(perms) != 2 || perms[0].Delegator != "alice" {
+		t.Fatalf("expected delegated permissions, got %#v", perms)
+	}
+
+	if _, ok := engine.SubjectPermissions("", "mallory"); ok {
+		t.Fatalf("expected unknown subject")
+	}
+}
+
+func TestSubjectPermissionsDenyOverridden(t *testing.T) {
+	store, g := newQueryStore()
+	store.Policies["deny-secret"] = Policy{ID: "deny-secret", Resource: []string{"files/**"}, Action: []string{"read"}, Effect: "deny"}
+	engine := NewPolicyEngine(store, g)
+	perms, _ := engine.SubjectPermissions("", "alice")
+	if len(perms) != 1 || perms[0].PolicyID != "write-office" {
+		t.Fatalf("expected read grant to be removed by deny, got %#v", perms)
+	}
+
+	store.Algorithm = PermitOverrides
+	if err := store.Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	perms, _ = engine.SubjectPermissions("", "alice")
+	if len(perms) != 2 {
+		t.Fatalf("expected permit-overrides to keep read grant, got %#v", perms)
+	}
+}
+
+func TestResourceAccess(t *testing.T) {
+	store, g := newQueryStore()
+	engine := NewPolicyEngine(store, g)
+
+	access := engine.ResourceAccess("", "files/a", "read")
+	names := map[string]Access{}
+	for _, a := range access {
+		names[a.Kind+":"+a.Name] = a
+	}
+	if _, ok := names["role:viewer"]; !ok {
+		t.Fatalf("expected viewer role, got %#v", access)
+	}
+	if _, ok := names["user:alice"]; !ok {
+		t.Fatalf("expected alice, got %#v", access)
+	}
+	if c, ok := names["user:carol"]; !ok || len(c.Delegators) != 1 || c.Delegators[0] != "alice" {
+		t.Fatalf("expected carol via delegation, got %#v", access)
+	}
+	if _, ok := names["user:bob"]; ok {
+		t.Fatalf("bob should not have access")
+	}
+
+	if access := engine.ResourceAccess("", "files/secret/plan", "read"); len(access) != 0 {
+		t.Fatalf("expected deny to remove access, got %#v", access)
+	}
+
+	access = engine.ResourceAccess("", "files/a", "write")
+	if len(access) == 0 || !access[0].Conditional {
+		t.Fatalf("expected conditional write access, got %#v", access)
+	}
+
+	access = engine.ResourceAccess("", "q1", "read")
+	if len(access) != 2 || access[0].Name != "auditor" || access[1].Name != "bob" {
+		t.Fatalf("expected auditor and bob via resource group, got %#v", access)
+	}
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
+)
+
+const relationPolicies = `schema:
+  - type: group
+    relations:
+      - name: member
+  - type: document
+    relations:
+      - name: owner
+      - name: editor
+        union: ["owner"]
+      - name: viewer
+        union: ["editor"]
+policies:
+  - id: view-documents
+    resource: ["document:*"]
+    action: ["read"]
+    relation: viewer
+    effect: allow
+  - id: edit-documents
+    resource: ["document:*"]
+    action: ["write"]
+    relation: editor
+    effect: allow
+`
+
+func TestEvaluateRelation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte(relationPolicies), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store := NewPolicyStore()
+	if err := store.LoadPolicies(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	g := graph.New()
+	for _, s := range []string{
+		"document:123#owner@user:alice",
+		"document:123#viewer@group:eng#member",
+		"group:eng#member@user:bob",
+	} {
+		tup, err := rebac.ParseTuple(s)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		g.AddRelation(tup.ObjectRelation(), tup.Subject.String())
+	}
+	engine := NewPolicyEngine(store, g)
+
+	if dec := engine.Evaluate("alice", "document:123", "write", nil); !dec.Allow || dec.PolicyID != "edit-documents" {
+		t.Fatalf("expected owner to edit, got %v", dec)
+	}
+	if dec := engine.Evaluate("bob", "document:123", "read", nil); !dec.Allow {
+		t.Fatalf("expected group member to read, got %v", dec)
+	}
+	dec := engine.Evaluate("bob", "document:123", "write", nil)
+	if dec.Allow || dec.Reason != "missing relation editor" {
+		t.Fatalf("expected viewer not to edit, got %v", dec)
+	}
+	if dec := engine.Evaluate("carol", "document:456", "read", nil); dec.Allow {
+		t.Fatalf("expected unrelated user to be denied, got %v", dec)
+	}
+
+	if ok, err := engine.Check("document:123", "viewer", "user:alice"); !ok || err != nil {
+		t.Fatalf("expected alice to be a viewer, got %v %v", ok, err)
+	}
+	if _, err := engine.Check("document", "viewer", "user:alice"); err == nil {
+		t.Fatalf("expected invalid object to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const inheritancePolicies = `roles:
+  - name: viewer
+    policies: ["read"]
+  - name: editor
+    inherits: ["viewer"]
+    policies: ["write"]
+  - name: senior-editor
+    inherits: ["editor"]
+    policies: ["publish"]
+users:
+  - username: alice
+    roles: ["senior-editor"]
+  - username: bob
+    roles: ["editor", "viewer"]
+policies:
+  - id: read
+    resource: ["docs/**"]
+    action: ["read"]
+    effect: allow
+  - id: write
+    subjects:
+      - role: editor
+    resource: ["docs/**"]
+    action: ["write"]
+    effect: allow
+  - id: publish
+    subjects:
+      - role: editor
+    resource: ["docs/**"]
+    action: ["publish"]
+    effect: allow
+`
+
+func TestRoleInheritance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte(inheritancePolicies), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store := NewPolicyStore()
+	if err := store.LoadPolicies(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	engine := NewPolicyEngine(store, nil)
+
+	cases := []struct {
+		user, action, role, via string
+	}{
+		{"alice", "read", "viewer", "senior-editor"},
+		{"alice", "write", "editor", "senior-editor"},
+		// Scoped to editor, granted through senior-editor which inherits it.
+		{"alice", "publish", "senior-editor", ""},
+		// Direct roles take precedence over inherited ones.
+		{"bob", "read", "viewer", ""},
+	}
+	for _, tc := range cases {
+		dec := engine.Evaluate(tc.user, "docs/a", tc.action, nil)
+		if !dec.Allow || dec.Role != tc.role || dec.InheritedVia != tc.via {
+			t.Errorf("%s %s: expected allow via %s/%s, got %#v", tc.user, tc.action, tc.role, tc.via, dec)
+		}
+	}
+	if dec := engine.Evaluate("bob", "docs/a", "publish", nil); dec.Allow {
+		t.Fatalf("expected editor not to inherit senior-editor policies")
+	}
+
+	perms, _ := engine.SubjectPermissions("", "alice")
+	if len(perms) != 3 {
+		t.Fatalf("expected inherited permissions, got %#v", perms)
+	}
+}
+
+func TestRoleInheritanceCycle(t *testing.T) {
+	store := NewPolicyStore()
+	store.Roles["a"] = Role{Name: "a", Inherits: []string{"b"}}
+	store.Roles["b"] = Role{Name: "b", Inherits: []string{"a"}}
+	if err := store.Rebuild(); err == nil {
+		t.Fatalf("expected cycle to be reported")
+	}
+}
+
+func TestRequestRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte(inheritancePolicies), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	store := NewPolicyStore()
+	if err := store.LoadPolicies(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	engine := NewPolicyEngine(store, nil)
+	engine.SetDecisionCache(NewDecisionCache(10))
+	ctx := context.Background()
+
+	dec := engine.EvaluateRequest(ctx, Request{Subject: "carol", Resource: "docs/a", Action: "write", Roles: []string{"editor"}})
+	if !dec.Allow || dec.Role != "editor" {
+		t.Fatalf("expected supplied role to allow an unknown subject, got %#v", dec)
+	}
+	// Supplied roles replace the subject's own and are not cached.
+	dec = engine.EvaluateRequest(ctx, Request{Subject: "alice", Resource: "docs/a", Action: "write", Roles: []string{}})
+	if dec.Allow || dec.Cached {
+		t.Fatalf("expected empty roles to deny, got %#v", dec)
+	}
+	if dec := engine.Evaluate("alice", "docs/a", "write", nil); !dec.Allow {
+		t.Fatalf("expected alice's own roles to allow, got %#v", dec)
+	}
+}
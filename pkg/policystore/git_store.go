@@ -1,60 +1,138 @@
 package policystore
 
 import (
-    "fmt"
-    "os/exec"
-    "strings"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
 )
 
+// defaultSchemes are the URL schemes CloneRepo accepts unless CloneOptions
+// lists others.
+var defaultSchemes = []string{"https", "ssh"}
+
+// CloneOptions configures CloneRepo.
+type CloneOptions struct {
+	// Schemes lists the repository URL schemes accepted, https and ssh when
+	// empty. Git is also told to refuse other transports, so a repository
+	// cannot redirect fetches to local files or helper programs.
+	Schemes []string
+}
+
 // GitStore clones and tracks a policy repository.
 type GitStore struct {
-    repoURL   string
-    branch    string
-    path      string
-    commitSHA string
-}
-
-// CloneRepo clones the given repository branch to the local path and returns a store.
-func CloneRepo(url, branch, path string) (*GitStore, error) {
-    if branch == "" {
-        branch = "main"
-    }
-    cmd := exec.Command("git", "clone", "--depth", "1", "--branch", branch, url, path)
-    if out, err := cmd.CombinedOutput(); err != nil {
-        return nil, fmt.Errorf("git clone: %v: %s", err, string(out))
-    }
-    gs := &GitStore{repoURL: url, branch: branch, path: path}
-    if err := gs.updateCommit(); err != nil {
-        return nil, err
-    }
-    return gs, nil
+	repoURL   string
+	branch    string
+	path      string
+	schemes   []string
+	mu        sync.Mutex
+	commitSHA string
+}
+
+// CloneRepo clones the given repository branch to the local path and returns
+// a store. ctx bounds the clone.
+func CloneRepo(ctx context.Context, url, branch, path string, opts CloneOptions) (*GitStore, error) {
+	if branch == "" {
+		branch = "main"
+	}
+	schemes := opts.Schemes
+	if len(schemes) == 0 {
+		schemes = defaultSchemes
+	}
+	if err := checkRepo(url, branch, schemes); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--branch", branch, "--", url, path)
+	cmd.Env = gitEnv(schemes)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git clone: %v: %s", err, string(out))
+	}
+	gs := &GitStore{repoURL: url, branch: branch, path: path, schemes: schemes}
+	if err := gs.updateCommit(); err != nil {
+		return nil, err
+	}
+	return gs, nil
 }
 
 // PullLatest fetches and resets to the latest commit on the tracked branch.
-func (g *GitStore) PullLatest() error {
-    fetch := exec.Command("git", "-C", g.path, "fetch", "origin", g.branch)
-    if out, err := fetch.CombinedOutput(); err != nil {
-        return fmt.Errorf("git fetch: %v: %s", err, string(out))
-    }
-    reset := exec.Command("git", "-C", g.path, "reset", "--hard", "origin/"+g.branch)
-    if out, err := reset.CombinedOutput(); err != nil {
-        return fmt.Errorf("git reset: %v: %s", err, string(out))
-    }
-    return g.updateCommit()
+// ctx bounds the fetch.
+func (g *GitStore) PullLatest(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	fetch := exec.CommandContext(ctx, "git", "-C", g.path, "fetch", "origin", g.branch)
+	fetch.Env = gitEnv(g.schemes)
+	if out, err := fetch.CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch: %v: %s", err, string(out))
+	}
+	reset := exec.Command("git", "-C", g.path, "reset", "--hard", "origin/"+g.branch)
+	if out, err := reset.CombinedOutput(); err != nil {
+		return fmt.Errorf("git reset: %v: %s", err, string(out))
+	}
+	return g.updateCommit()
+}
+
+// ResetTo moves the working tree back to a commit that was checked out
+// before, such as the last revision that passed validation.
+func (g *GitStore) ResetTo(sha string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	reset := exec.Command("git", "-C", g.path, "reset", "--hard", sha)
+	if out, err := reset.CombinedOutput(); err != nil {
+		return fmt.Errorf("git reset: %v: %s", err, string(out))
+	}
+	return g.updateCommit()
 }
 
 // CommitSHA returns the current repository revision.
 func (g *GitStore) CommitSHA() string {
-    return g.commitSHA
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.commitSHA
+}
+
+// Path returns the directory of the local clone.
+func (g *GitStore) Path() string {
+	return g.path
 }
 
+// updateCommit records the checked out revision. g.mu must be held or g not
+// yet shared.
 func (g *GitStore) updateCommit() error {
-    rev := exec.Command("git", "-C", g.path, "rev-parse", "HEAD")
-    out, err := rev.Output()
-    if err != nil {
-        return fmt.Errorf("git rev-parse: %w", err)
-    }
-    g.commitSHA = strings.TrimSpace(string(out))
-    return nil
+	rev := exec.Command("git", "-C", g.path, "rev-parse", "HEAD")
+	out, err := rev.Output()
+	if err != nil {
+		return fmt.Errorf("git rev-parse: %w", err)
+	}
+	g.commitSHA = strings.TrimSpace(string(out))
+	return nil
 }
 
+// checkRepo rejects repository URLs with a scheme outside schemes and URLs
+// or branches that git would read as options.
+func checkRepo(repoURL, branch string, schemes []string) error {
+	if strings.HasPrefix(repoURL, "-") {
+		return fmt.Errorf("invalid repository URL %q", repoURL)
+	}
+	if strings.HasPrefix(branch, "-") {
+		return fmt.Errorf("invalid branch %q", branch)
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL %q: %v", repoURL, err)
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported repository URL %q: expected one of %s", repoURL, strings.Join(schemes, ", "))
+}
+
+// gitEnv returns the environment for git commands that reach the remote,
+// limited to schemes.
+func gitEnv(schemes []string) []string {
+	return append(os.Environ(), "GIT_ALLOW_PROTOCOL="+strings.Join(schemes, ":"))
+}
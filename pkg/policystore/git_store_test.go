@@ -0,0 +1,28 @@
+package policystore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCloneRepoRejectsUnsafeArguments(t *testing.T) {
+	cases := []struct{ url, branch string }{
+		{"--upload-pack=touch /tmp/pwned", "main"},
+		{"https://example.com/policies.git", "--upload-pack=touch /tmp/pwned"},
+		{"file:///srv/policies", "main"},
+		{"ext::sh -c touch% /tmp/pwned", "main"},
+		{"/srv/policies", "main"},
+	}
+	for _, c := range cases {
+		if _, err := CloneRepo(context.Background(), c.url, c.branch, t.TempDir(), CloneOptions{}); err == nil {
+			t.Errorf("expected %q on branch %q to be rejected", c.url, c.branch)
+		}
+	}
+}
+
+func TestCloneRepoSchemesOption(t *testing.T) {
+	opts := CloneOptions{Schemes: []string{"file"}}
+	if _, err := CloneRepo(context.Background(), "https://example.com/policies.git", "main", t.TempDir(), opts); err == nil {
+		t.Fatalf("expected https to be rejected when only file is allowed")
+	}
+}
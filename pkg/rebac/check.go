@@ -0,0 +1,99 @@
+package rebac
+
+import "errors"
+
+// DefaultMaxDepth bounds the number of nested usersets and rewrites followed
+// by a check when Checker.MaxDepth is zero.
+const DefaultMaxDepth = 25
+
+// ErrMaxDepth is returned when a check could not be decided within the depth
+// limit.
+var ErrMaxDepth = errors.New("maximum relation check depth exceeded")
+
+// TupleReader returns the subjects written for an object relation, keyed as
+// `type:id#relation`. *graph.Graph satisfies it when tuples are stored as
+// edges from the object relation to the subject.
+type TupleReader interface {
+	Targets(src string) []string
+}
+
+// Checker answers relationship checks against stored tuples and a schema.
+type Checker struct {
+	Schema   *Schema
+	Tuples   TupleReader
+	MaxDepth int
+}
+
+// Check reports whether subject holds relation on object, directly, through
+// a userset, or through the schema's rewrites. Cycles in the tuples or the
+// schema are cut rather than followed. If no path grants the relation and
+// some branch hit the depth limit, Check returns ErrMaxDepth.
+func (c *Checker) Check(object Object, relation string, subject Subject) (bool, error) {
+	max := c.MaxDepth
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	w := walker{c: c, subject: subject, max: max, visiting: make(map[string]struct{})}
+	ok := w.check(object, relation, 0)
+	if !ok && w.truncated {
+		return false, ErrMaxDepth
+	}
+	return ok, nil
+}
+
+type walker struct {
+	c         *Checker
+	subject   Subject
+	max       int
+	visiting  map[string]struct{}
+	truncated bool
+}
+
+func (w *walker) check(object Object, relation string, depth int) bool {
+	if depth > w.max {
+		w.truncated = true
+		return false
+	}
+	key := object.String() + "#" + relation
+	if _, ok := w.visiting[key]; ok {
+		return false
+	}
+	w.visiting[key] = struct{}{}
+	defer delete(w.visiting, key)
+
+	for _, sub := range w.subjects(key) {
+		if sub == w.subject {
+			return true
+		}
+		if sub.IsUserset() && w.check(sub.Object, sub.Relation, depth+1) {
+			return true
+		}
+	}
+	for _, rw := range w.c.Schema.rewrites(object.Type, relation) {
+		if rw.tupleset == "" {
+			if w.check(object, rw.relation, depth+1) {
+				return true
+			}
+			continue
+		}
+		for _, parent := range w.subjects(object.String() + "#" + rw.tupleset) {
+			if w.check(parent.Object, rw.relation, depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *walker) subjects(key string) []Subject {
+	if w.c.Tuples == nil {
+		return nil
+	}
+	var out []Subject
+	for _, t := range w.c.Tuples.Targets(key) {
+		if s, err := ParseSubject(t); err == nil {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package rebac
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bradtumy/authorization-service/pkg/graph"
+)
+
+func mustTuple(t *testing.T, g *graph.Graph, s string) {
+	t.Helper()
+	tup, err := ParseTuple(s)
+	if err != nil {
+		t.Fatalf("parse %s: %v", s, err)
+	}
+	g.AddRelation(tup.ObjectRelation(), tup.Subject.String())
+}
+
+func TestParseTuple(t *testing.T) {
+	tup, err := ParseTuple("document:123#viewer@group:eng#member")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if tup.Object != (Object{Type: "document", ID: "123"}) || tup.Relation != "viewer" || tup.Subject.String() != "group:eng#member" {
+		t.Fatalf("unexpected tuple %#v", tup)
+	}
+	if tup.String() != "document:123#viewer@group:eng#member" {
+		t.Fatalf("unexpected string %s", tup.String())
+	}
+	for _, bad := range []string{"document:123#viewer", "document#viewer@user:a", "document:1@user:a", "document:1#viewer@user", "document:1#viewer@user:a#"} {
+		if _, err := ParseTuple(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+var testSchema = []Namespace{
+	{Type: "group", Relations: []RelationDef{{Name: "member"}}},
+	{Type: "folder", Relations: []RelationDef{{Name: "viewer"}}},
+	{Type: "document", Relations: []RelationDef{
+		{Name: "parent"},
+		{Name: "owner"},
+		{Name: "editor", Union: []string{"owner"}},
+		{Name: "viewer", Union: []string{"editor", "parent->viewer"}},
+	}},
+}
+
+func TestCheck(t *testing.T) {
+	schema, err := Compile(testSchema)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	g := graph.New()
+	mustTuple(t, g, "document:123#owner@user:alice")
+	mustTuple(t, g, "document:123#viewer@group:eng#member")
+	mustTuple(t, g, "group:eng#member@user:bob")
+	mustTuple(t, g, "document:123#parent@folder:reports")
+	mustTuple(t, g, "folder:reports#viewer@user:carol")
+	c := &Checker{Schema: schema, Tuples: g}
+
+	doc := Object{Type: "document", ID: "123"}
+	cases := []struct {
+		relation string
+		subject  string
+		want     bool
+	}{
+		{"owner", "user:alice", true},
+		{"editor", "user:alice", true},
+		{"viewer", "user:alice", true},
+		{"viewer", "user:bob", true},
+		{"editor", "user:bob", false},
+		{"viewer", "user:carol", true},
+		{"viewer", "user:dave", false},
+		{"viewer", "group:eng#member", true},
+	}
+	for _, tc := range cases {
+		sub, _ := ParseSubject(tc.subject)
+		got, err := c.Check(doc, tc.relation, sub)
+		if err != nil || got != tc.want {
+			t.Errorf("%s %s: got %v %v, want %v", tc.relation, tc.subject, got, err, tc.want)
+		}
+	}
+}
+
+func TestCheckCycleAndDepth(t *testing.T) {
+	g := graph.New()
+	mustTuple(t, g, "group:a#member@group:b#member")
+	mustTuple(t, g, "group:b#member@group:a#member")
+	c := &Checker{Tuples: g}
+	ok, err := c.Check(Object{Type: "group", ID: "a"}, "member", Subject{Object: Object{Type: "user", ID: "x"}})
+	if ok || err != nil {
+		t.Fatalf("expected cycle to resolve to false, got %v %v", ok, err)
+	}
+
+	mustTuple(t, g, "group:g0#member@group:g1#member")
+	mustTuple(t, g, "group:g1#member@group:g2#member")
+	mustTuple(t, g, "group:g2#member@group:g3#member")
+	mustTuple(t, g, "group:g3#member@user:x")
+	c.MaxDepth = 2
+	_, err = c.Check(Object{Type: "group", ID: "g0"}, "member", Subject{Object: Object{Type: "user", ID: "x"}})
+	if !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("expected depth error, got %v", err)
+	}
+	c.MaxDepth = 0
+	if ok, err := c.Check(Object{Type: "group", ID: "g0"}, "member", Subject{Object: Object{Type: "user", ID: "x"}}); !ok || err != nil {
+		t.Fatalf("expected nested membership, got %v %v", ok, err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	bad := [][]Namespace{
+		{{Type: "doc", Relations: []RelationDef{{Name: "viewer", Union: []string{"editor"}}}}},
+		{{Type: "doc", Relations: []RelationDef{{Name: "viewer", Union: []string{"parent->viewer"}}}}},
+		{{Type: "doc", Relations: []RelationDef{{Name: "viewer"}, {Name: "viewer"}}}},
+		{{Type: "doc"}, {Type: "doc"}},
+	}
+	for i, ns := range bad {
+		if _, err := Compile(ns); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+	schema, _ := Compile(testSchema)
+	tup, _ := ParseTuple("document:1#commenter@user:a")
+	if err := schema.ValidateTuple(tup); err == nil {
+		t.Fatalf("expected undeclared relation to be rejected")
+	}
+}
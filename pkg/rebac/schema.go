@@ -0,0 +1,128 @@
+package rebac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Namespace declares the relations of an object type. It is the YAML form
+// used under `schema:` in policy files:
+//
+//	schema:
+//	  - type: document
+//	    relations:
+//	      - name: parent
+//	      - name: owner
+//	      - name: editor
+//	        union: ["owner"]
+//	      - name: viewer
+//	        union: ["editor", "parent->viewer"]
+type Namespace struct {
+	Type      string        `yaml:"type" json:"type"`
+	Relations []RelationDef `yaml:"relations" json:"relations"`
+}
+
+// RelationDef declares a relation. Subjects hold it through direct tuples or
+// through any rewrite in Union: a relation name (computed userset, e.g.
+// editors are viewers) or `tupleset->relation` (tuple to userset, e.g. viewers
+// of the parent folder are viewers of the document).
+type RelationDef struct {
+	Name  string   `yaml:"name" json:"name"`
+	Union []string `yaml:"union" json:"union,omitempty"`
+}
+
+// rewrite is a compiled union entry. An empty tupleset means a computed
+// userset on the same object.
+type rewrite struct {
+	tupleset string
+	relation string
+}
+
+// Schema is a compiled set of namespaces. A nil or empty Schema accepts any
+// type and relation and only follows direct tuples.
+type Schema struct {
+	types map[string]map[string][]rewrite
+}
+
+// Compile validates namespaces and returns the resulting Schema.
+func Compile(namespaces []Namespace) (*Schema, error) {
+	s := &Schema{types: make(map[string]map[string][]rewrite)}
+	for _, ns := range namespaces {
+		if ns.Type == "" {
+			return nil, fmt.Errorf("schema namespace type is required")
+		}
+		if _, dup := s.types[ns.Type]; dup {
+			return nil, fmt.Errorf("schema namespace %s declared twice", ns.Type)
+		}
+		rels := make(map[string][]rewrite)
+		for _, r := range ns.Relations {
+			if r.Name == "" || strings.ContainsAny(r.Name, ":#@>") {
+				return nil, fmt.Errorf("schema namespace %s has invalid relation name %q", ns.Type, r.Name)
+			}
+			if _, dup := rels[r.Name]; dup {
+				return nil, fmt.Errorf("schema namespace %s declares relation %s twice", ns.Type, r.Name)
+			}
+			rels[r.Name] = nil
+		}
+		for _, r := range ns.Relations {
+			for _, u := range r.Union {
+				rw := rewrite{relation: u}
+				if ts, rel, ok := strings.Cut(u, "->"); ok {
+					rw = rewrite{tupleset: ts, relation: rel}
+					if _, ok := rels[ts]; !ok {
+						return nil, fmt.Errorf("schema relation %s#%s references undefined relation %s", ns.Type, r.Name, ts)
+					}
+					if rel == "" {
+						return nil, fmt.Errorf("schema relation %s#%s has empty rewrite %q", ns.Type, r.Name, u)
+					}
+				} else if _, ok := rels[u]; !ok {
+					return nil, fmt.Errorf("schema relation %s#%s references undefined relation %s", ns.Type, r.Name, u)
+				}
+				rels[r.Name] = append(rels[r.Name], rw)
+			}
+		}
+		s.types[ns.Type] = rels
+	}
+	return s, nil
+}
+
+// empty reports whether the schema declares no namespaces.
+func (s *Schema) empty() bool {
+	return s == nil || len(s.types) == 0
+}
+
+// rewrites returns the union rewrites of a relation.
+func (s *Schema) rewrites(typ, relation string) []rewrite {
+	if s == nil {
+		return nil
+	}
+	return s.types[typ][relation]
+}
+
+// ValidateTuple checks that the tuple's relation, and the relation of a
+// userset subject, are declared in the schema. An empty schema accepts any
+// tuple.
+func (s *Schema) ValidateTuple(t Tuple) error {
+	if s.empty() {
+		return nil
+	}
+	if err := s.checkRelation(t.Object.Type, t.Relation); err != nil {
+		return err
+	}
+	if t.Subject.IsUserset() {
+		return s.checkRelation(t.Subject.Type, t.Subject.Relation)
+	}
+	return nil
+}
+
+// checkRelation reports an error if typ is undeclared or lacks relation.
+func (s *Schema) checkRelation(typ, relation string) error {
+	rels, ok := s.types[typ]
+	if !ok {
+		return fmt.Errorf("type %s is not declared in the schema", typ)
+	}
+	if _, ok := rels[relation]; !ok {
+		return fmt.Errorf("relation %s is not declared on type %s", relation, typ)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package rebac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Object identifies a typed object such as `document:123`.
+type Object struct {
+	Type string
+	ID   string
+}
+
+// String renders the object as `type:id`.
+func (o Object) String() string { return o.Type + ":" + o.ID }
+
+// Subject is either a concrete object such as `user:alice` or a userset such
+// as `group:eng#member`, meaning every subject holding `member` on `group:eng`.
+type Subject struct {
+	Object
+	Relation string
+}
+
+// String renders the subject as `type:id` or `type:id#relation`.
+func (s Subject) String() string {
+	if s.Relation == "" {
+		return s.Object.String()
+	}
+	return s.Object.String() + "#" + s.Relation
+}
+
+// IsUserset reports whether the subject denotes a set of subjects.
+func (s Subject) IsUserset() bool { return s.Relation != "" }
+
+// Tuple states that Subject holds Relation on Object, written
+// `document:123#viewer@group:eng#member`.
+type Tuple struct {
+	Object   Object
+	Relation string
+	Subject  Subject
+}
+
+// String renders the tuple in its canonical text form.
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ObjectRelation returns the `type:id#relation` key of the tuple.
+func (t Tuple) ObjectRelation() string {
+	return t.Object.String() + "#" + t.Relation
+}
+
+// ParseObject parses `type:id`.
+func ParseObject(s string) (Object, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || typ == "" || id == "" || strings.ContainsAny(s, "#@") {
+		return Object{}, fmt.Errorf("invalid object %q: want type:id", s)
+	}
+	return Object{Type: typ, ID: id}, nil
+}
+
+// ParseSubject parses `type:id` or `type:id#relation`.
+func ParseSubject(s string) (Subject, error) {
+	obj, rel, hasRel := strings.Cut(s, "#")
+	o, err := ParseObject(obj)
+	if err != nil {
+		return Subject{}, fmt.Errorf("invalid subject %q: want type:id or type:id#relation", s)
+	}
+	if hasRel && rel == "" {
+		return Subject{}, fmt.Errorf("invalid subject %q: empty relation", s)
+	}
+	return Subject{Object: o, Relation: rel}, nil
+}
+
+// ParseTuple parses `type:id#relation@subject`.
+func ParseTuple(s string) (Tuple, error) {
+	lhs, rhs, ok := strings.Cut(s, "@")
+	if !ok {
+		return Tuple{}, fmt.Errorf("invalid tuple %q: missing @subject", s)
+	}
+	obj, rel, ok := strings.Cut(lhs, "#")
+	if !ok || rel == "" {
+		return Tuple{}, fmt.Errorf("invalid tuple %q: missing #relation", s)
+	}
+	o, err := ParseObject(obj)
+	if err != nil {
+		return Tuple{}, err
+	}
+	sub, err := ParseSubject(rhs)
+	if err != nil {
+		return Tuple{}, err
+	}
+	return Tuple{Object: o, Relation: rel, Subject: sub}, nil
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+// AttributeProvider serves resource attributes persisted in a Store to the
+// policy engine.
+type AttributeProvider struct {
+	store Store
+}
+
+var _ policy.ResourceAttributeProvider = (*AttributeProvider)(nil)
+
+// NewAttributeProvider returns an AttributeProvider backed by s.
+func NewAttributeProvider(s Store) *AttributeProvider {
+	return &AttributeProvider{store: s}
+}
+
+// ResourceAttributes implements policy.ResourceAttributeProvider.
+func (p *AttributeProvider) ResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error) {
+	return p.store.LoadResourceAttributes(ctx, tenantID, resource)
+}
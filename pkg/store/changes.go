@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PolicyChannel is the Postgres notification channel that carries changes.
+const PolicyChannel = "policy_changes"
+
+// defaultPollInterval is how often SQLiteStore checks the revision table.
+const defaultPollInterval = 500 * time.Millisecond
+
+// Change reports that a tenant's stored policies, roles, users,
+// relationships or delegations changed. Revision is the tenant's revision
+// after the change. A Change with an empty TenantID means changes may have
+// been missed, for example while reconnecting, and every tenant should be
+// reloaded.
+type Change struct {
+	TenantID string `json:"tenantID"`
+	Revision int64  `json:"revision"`
+}
+
+// feed fans changes out to watchers. Publishing never blocks: a watcher
+// that falls behind keeps only the latest revision of each tenant.
+type feed struct {
+	mu   sync.Mutex
+	subs map[*watcher]struct{}
+}
+
+type watcher struct {
+	mu      sync.Mutex
+	pending map[string]int64
+	wake    chan struct{}
+}
+
+// watch returns a channel of the changes published until ctx is done, when
+// the channel is closed.
+func (f *feed) watch(ctx context.Context) <-chan Change {
+	w := &watcher{pending: make(map[string]int64), wake: make(chan struct{}, 1)}
+	f.mu.Lock()
+	if f.subs == nil {
+		f.subs = make(map[*watcher]struct{})
+	}
+	f.subs[w] = struct{}{}
+	f.mu.Unlock()
+	out := make(chan Change)
+	go func() {
+		defer close(out)
+		defer func() {
+			f.mu.Lock()
+			delete(f.subs, w)
+			f.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.wake:
+			}
+			w.mu.Lock()
+			pending := w.pending
+			w.pending = make(map[string]int64)
+			w.mu.Unlock()
+			for id, rev := range pending {
+				select {
+				case out <- Change{TenantID: id, Revision: rev}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func (f *feed) publish(c Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for w := range f.subs {
+		w.mu.Lock()
+		if c.Revision > w.pending[c.TenantID] {
+			w.pending[c.TenantID] = c.Revision
+		}
+		w.mu.Unlock()
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
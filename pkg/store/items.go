@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Kinds of versioned items, as used by ItemKey.
+const (
+	KindPolicy = "policy"
+	KindRole   = "role"
+	KindUser   = "user"
+)
+
+// ErrConflict is returned by a write made with IfVersion when the stored
+// item has another version.
+var ErrConflict = errors.New("stored item was modified")
+
+// ItemKey identifies a policy, role or policy user in the map returned by
+// ItemVersions.
+func ItemKey(kind, id string) string {
+	return kind + "/" + id
+}
+
+type versionKey struct{}
+
+// IfVersion returns a context that makes a SavePolicy, SaveRole,
+// SavePolicyUser, DeletePolicy, DeleteRole or DeletePolicyUser call made
+// with it conditional: the write fails with ErrConflict unless the item's
+// stored version is version. Items that were never stored have version 0.
+func IfVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// checkVersion reports ErrConflict if ctx carries an expected version other
+// than prev, the version of the item before the write.
+func checkVersion(ctx context.Context, prev int64) error {
+	if want, ok := ctx.Value(versionKey{}).(int64); ok && want != prev {
+		return ErrConflict
+	}
+	return nil
+}
+
+// scanItemVersions reads the kind, item_id and version columns selected by
+// rows and closes them.
+func scanItemVersions(rows *sql.Rows) (map[string]int64, error) {
+	defer rows.Close()
+	out := map[string]int64{}
+	for rows.Next() {
+		var kind, id string
+		var v int64
+		if err := rows.Scan(&kind, &id, &v); err != nil {
+			return nil, err
+		}
+		out[ItemKey(kind, id)] = v
+	}
+	return out, rows.Err()
+}
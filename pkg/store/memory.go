@@ -3,7 +3,10 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/bradtumy/authorization-service/pkg/policy"
 	"github.com/bradtumy/authorization-service/pkg/tenant"
@@ -13,8 +16,16 @@ import (
 type MemoryStore struct {
 	mu       sync.RWMutex
 	tenants  map[string]tenant.Tenant
-	policies map[string]map[string]policy.Policy       // tenantID -> policyID -> policy
-	edges    map[string]map[string]map[string]struct{} // tenantID -> src -> dst set
+	policies map[string]map[string]policy.Policy          // tenantID -> policyID -> policy
+	edges    map[string]map[string]map[string]struct{}    // tenantID -> src -> dst set
+	attrs    map[string]map[string]map[string]interface{} // tenantID -> resource -> attributes
+	delegs   map[string]map[string]policy.Delegation      // tenantID -> delegationID -> delegation
+	versions map[string][]PolicyVersion                   // tenantID -> versions, oldest first
+	roles    map[string]map[string]policy.Role            // tenantID -> role name -> role
+	users    map[string]map[string]policy.User            // tenantID -> username -> user
+	revs     map[string]int64                             // tenantID -> revision
+	items    map[string]map[string]int64                  // tenantID -> item key -> version
+	changes  feed
 }
 
 // NewMemory returns a new MemoryStore instance.
@@ -23,6 +34,13 @@ func NewMemory() *MemoryStore {
 		tenants:  make(map[string]tenant.Tenant),
 		policies: make(map[string]map[string]policy.Policy),
 		edges:    make(map[string]map[string]map[string]struct{}),
+		attrs:    make(map[string]map[string]map[string]interface{}),
+		delegs:   make(map[string]map[string]policy.Delegation),
+		versions: make(map[string][]PolicyVersion),
+		roles:    make(map[string]map[string]policy.Role),
+		users:    make(map[string]map[string]policy.User),
+		revs:     make(map[string]int64),
+		items:    make(map[string]map[string]int64),
 	}
 }
 
@@ -30,6 +48,7 @@ func (m *MemoryStore) SaveTenant(ctx context.Context, t tenant.Tenant) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.tenants[t.ID] = t
+	m.bumpLocked(t.ID)
 	return nil
 }
 
@@ -59,16 +78,28 @@ func (m *MemoryStore) DeleteTenant(ctx context.Context, id string) error {
 	delete(m.tenants, id)
 	delete(m.policies, id)
 	delete(m.edges, id)
+	delete(m.attrs, id)
+	delete(m.delegs, id)
+	delete(m.versions, id)
+	delete(m.roles, id)
+	delete(m.users, id)
+	delete(m.revs, id)
+	delete(m.items, id)
 	return nil
 }
 
 func (m *MemoryStore) SavePolicy(ctx context.Context, tenantID string, p policy.Policy) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err := m.bumpItemLocked(ctx, tenantID, KindPolicy, p.ID); err != nil {
+		return err
+	}
 	if m.policies[tenantID] == nil {
 		m.policies[tenantID] = make(map[string]policy.Policy)
 	}
 	m.policies[tenantID][p.ID] = p
+	m.recordVersionLocked(ctx, tenantID, "save "+p.ID)
+	m.bumpLocked(tenantID)
 	return nil
 }
 
@@ -83,10 +114,181 @@ func (m *MemoryStore) LoadPolicies(ctx context.Context, tenantID string) ([]poli
 	return out, nil
 }
 
+func (m *MemoryStore) DeletePolicy(ctx context.Context, tenantID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.bumpItemLocked(ctx, tenantID, KindPolicy, id); err != nil {
+		return err
+	}
+	delete(m.policies[tenantID], id)
+	m.recordVersionLocked(ctx, tenantID, "delete "+id)
+	m.bumpLocked(tenantID)
+	return nil
+}
+
 func (m *MemoryStore) ClearPolicies(ctx context.Context, tenantID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	for id := range m.policies[tenantID] {
+		m.bumpItemLocked(context.Background(), tenantID, KindPolicy, id)
+	}
 	delete(m.policies, tenantID)
+	m.recordVersionLocked(ctx, tenantID, "clear")
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) PolicyVersions(ctx context.Context, tenantID string) ([]PolicyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]PolicyVersion{}, m.versions[tenantID]...), nil
+}
+
+func (m *MemoryStore) LoadPolicyVersion(ctx context.Context, tenantID string, version int) (PolicyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.versions[tenantID]
+	if version < 1 || version > len(list) {
+		return PolicyVersion{}, ErrVersionNotFound
+	}
+	return list[version-1], nil
+}
+
+func (m *MemoryStore) RollbackPolicies(ctx context.Context, tenantID string, version int) (PolicyVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.versions[tenantID]
+	if version < 1 || version > len(list) {
+		return PolicyVersion{}, ErrVersionNotFound
+	}
+	mpol := make(map[string]policy.Policy)
+	for _, p := range list[version-1].Policies {
+		mpol[p.ID] = p
+	}
+	for _, set := range []map[string]policy.Policy{m.policies[tenantID], mpol} {
+		for id := range set {
+			m.bumpItemLocked(context.Background(), tenantID, KindPolicy, id)
+		}
+	}
+	m.policies[tenantID] = mpol
+	m.bumpLocked(tenantID)
+	return m.recordVersionLocked(ctx, tenantID, fmt.Sprintf("rollback to %d", version)), nil
+}
+
+// recordVersionLocked appends the tenant's current policy set as a new
+// version. m.mu must be held.
+func (m *MemoryStore) recordVersionLocked(ctx context.Context, tenantID, message string) PolicyVersion {
+	list := make([]policy.Policy, 0, len(m.policies[tenantID]))
+	for _, p := range m.policies[tenantID] {
+		list = append(list, p)
+	}
+	v := PolicyVersion{
+		Version:   len(m.versions[tenantID]) + 1,
+		Author:    authorFromContext(ctx),
+		CreatedAt: time.Now().UTC(),
+		Message:   message,
+		Policies:  sortPolicies(list),
+	}
+	m.versions[tenantID] = append(m.versions[tenantID], v)
+	return v
+}
+
+func (m *MemoryStore) SaveRole(ctx context.Context, tenantID string, r policy.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.bumpItemLocked(ctx, tenantID, KindRole, r.Name); err != nil {
+		return err
+	}
+	if m.roles[tenantID] == nil {
+		m.roles[tenantID] = make(map[string]policy.Role)
+	}
+	r.Policies = uniquePolicies(r)
+	r.Inherits = append([]string(nil), r.Inherits...)
+	m.roles[tenantID][r.Name] = r
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) LoadRoles(ctx context.Context, tenantID string) ([]policy.Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]policy.Role, 0, len(m.roles[tenantID]))
+	for _, r := range m.roles[tenantID] {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *MemoryStore) DeleteRole(ctx context.Context, tenantID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.bumpItemLocked(ctx, tenantID, KindRole, name); err != nil {
+		return err
+	}
+	delete(m.roles[tenantID], name)
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) SavePolicyUser(ctx context.Context, tenantID string, u policy.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.bumpItemLocked(ctx, tenantID, KindUser, u.Username); err != nil {
+		return err
+	}
+	if m.users[tenantID] == nil {
+		m.users[tenantID] = make(map[string]policy.User)
+	}
+	u.Roles = append([]string(nil), u.Roles...)
+	m.users[tenantID][u.Username] = u
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) LoadPolicyUsers(ctx context.Context, tenantID string) ([]policy.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]policy.User, 0, len(m.users[tenantID]))
+	for _, u := range m.users[tenantID] {
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Username < out[j].Username })
+	return out, nil
+}
+
+func (m *MemoryStore) DeletePolicyUser(ctx context.Context, tenantID, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.bumpItemLocked(ctx, tenantID, KindUser, username); err != nil {
+		return err
+	}
+	delete(m.users[tenantID], username)
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) ItemVersions(ctx context.Context, tenantID string) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]int64, len(m.items[tenantID]))
+	for k, v := range m.items[tenantID] {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// bumpItemLocked increments an item's version, after checking the version
+// expected by ctx. m.mu must be held.
+func (m *MemoryStore) bumpItemLocked(ctx context.Context, tenantID, kind, id string) error {
+	if m.items[tenantID] == nil {
+		m.items[tenantID] = make(map[string]int64)
+	}
+	key := ItemKey(kind, id)
+	if err := checkVersion(ctx, m.items[tenantID][key]); err != nil {
+		return err
+	}
+	m.items[tenantID][key]++
 	return nil
 }
 
@@ -100,6 +302,15 @@ func (m *MemoryStore) SaveEdge(ctx context.Context, tenantID, src, dst string) e
 		m.edges[tenantID][src] = make(map[string]struct{})
 	}
 	m.edges[tenantID][src][dst] = struct{}{}
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) DeleteEdge(ctx context.Context, tenantID, src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.edges[tenantID][src], dst)
+	m.bumpLocked(tenantID)
 	return nil
 }
 
@@ -120,5 +331,97 @@ func (m *MemoryStore) ClearEdges(ctx context.Context, tenantID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.edges, tenantID)
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) SaveResourceAttributes(ctx context.Context, tenantID, resource string, attrs map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.attrs[tenantID] == nil {
+		m.attrs[tenantID] = make(map[string]map[string]interface{})
+	}
+	cp := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		cp[k] = v
+	}
+	m.attrs[tenantID][resource] = cp
+	return nil
+}
+
+func (m *MemoryStore) LoadResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]interface{})
+	for k, v := range m.attrs[tenantID][resource] {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) DeleteResourceAttributes(ctx context.Context, tenantID, resource string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attrs[tenantID], resource)
 	return nil
 }
+
+func (m *MemoryStore) ListResourceAttributes(ctx context.Context, tenantID string) (map[string]map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]map[string]interface{}, len(m.attrs[tenantID]))
+	for resource, attrs := range m.attrs[tenantID] {
+		cp := make(map[string]interface{}, len(attrs))
+		for k, v := range attrs {
+			cp[k] = v
+		}
+		out[resource] = cp
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) SaveDelegation(ctx context.Context, tenantID string, d policy.Delegation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.delegs[tenantID] == nil {
+		m.delegs[tenantID] = make(map[string]policy.Delegation)
+	}
+	m.delegs[tenantID][d.ID] = d
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) LoadDelegations(ctx context.Context, tenantID string) ([]policy.Delegation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]policy.Delegation, 0, len(m.delegs[tenantID]))
+	for _, d := range m.delegs[tenantID] {
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) DeleteDelegation(ctx context.Context, tenantID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.delegs[tenantID], id)
+	m.bumpLocked(tenantID)
+	return nil
+}
+
+func (m *MemoryStore) Revision(ctx context.Context, tenantID string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.revs[tenantID], nil
+}
+
+func (m *MemoryStore) Watch(ctx context.Context) (<-chan Change, error) {
+	return m.changes.watch(ctx), nil
+}
+
+// bumpLocked increments the tenant's revision and publishes the change.
+// m.mu must be held.
+func (m *MemoryStore) bumpLocked(tenantID string) {
+	m.revs[tenantID]++
+	m.changes.publish(Change{TenantID: tenantID, Revision: m.revs[tenantID]})
+}
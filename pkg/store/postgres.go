@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/bradtumy/authorization-service/pkg/policy"
 	"github.com/bradtumy/authorization-service/pkg/tenant"
@@ -15,7 +18,8 @@ import (
 
 // PostgresStore implements Store backed by a PostgreSQL database.
 type PostgresStore struct {
-	db *sql.DB
+	db  *sql.DB
+	dsn string
 }
 
 // NewPostgres creates a new PostgresStore using the provided DSN.
@@ -24,45 +28,45 @@ func NewPostgres(dsn string) (*PostgresStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &PostgresStore{db: db}, nil
+	return &PostgresStore{db: db, dsn: dsn}, nil
 }
 
 func (s *PostgresStore) SaveTenant(ctx context.Context, t tenant.Tenant) error {
+	status, deleted, quota, issuers := tenantValues(t)
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO tenants(id, name, created_at) VALUES($1,$2,$3)
-         ON CONFLICT(id) DO UPDATE SET name=EXCLUDED.name, created_at=EXCLUDED.created_at`,
-		t.ID, t.Name, t.CreatedAt.Unix())
-	return err
+		`INSERT INTO tenants(id, name, created_at, template, template_version, policy_repo, policy_branch, status, deleted_at, quota, trusted_issuers)
+         VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+         ON CONFLICT(id) DO UPDATE SET name=EXCLUDED.name, created_at=EXCLUDED.created_at, template=EXCLUDED.template,
+         template_version=EXCLUDED.template_version, policy_repo=EXCLUDED.policy_repo, policy_branch=EXCLUDED.policy_branch,
+         status=EXCLUDED.status, deleted_at=EXCLUDED.deleted_at, quota=EXCLUDED.quota, trusted_issuers=EXCLUDED.trusted_issuers`,
+		t.ID, t.Name, t.CreatedAt.Unix(), t.Template, t.TemplateVersion, t.PolicyRepo, t.PolicyBranch, status, deleted, quota, issuers)
+	if err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, t.ID)
 }
 
 func (s *PostgresStore) LoadTenant(ctx context.Context, id string) (tenant.Tenant, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id, name, created_at FROM tenants WHERE id=$1`, id)
-	var t tenant.Tenant
-	var created int64
-	if err := row.Scan(&t.ID, &t.Name, &created); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return tenant.Tenant{}, errors.New("tenant not found")
-		}
-		return tenant.Tenant{}, err
+	row := s.db.QueryRowContext(ctx, `SELECT `+tenantColumns+` FROM tenants WHERE id=$1`, id)
+	t, err := scanTenant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tenant.Tenant{}, errors.New("tenant not found")
 	}
-	t.CreatedAt = time.Unix(created, 0).UTC()
-	return t, nil
+	return t, err
 }
 
 func (s *PostgresStore) ListTenants(ctx context.Context) ([]tenant.Tenant, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, name, created_at FROM tenants`)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+tenantColumns+` FROM tenants`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	out := []tenant.Tenant{}
 	for rows.Next() {
-		var t tenant.Tenant
-		var created int64
-		if err := rows.Scan(&t.ID, &t.Name, &created); err != nil {
+		t, err := scanTenant(rows)
+		if err != nil {
 			return nil, err
 		}
-		t.CreatedAt = time.Unix(created, 0).UTC()
 		out = append(out, t)
 	}
 	return out, rows.Err()
@@ -75,7 +79,38 @@ func (s *PostgresStore) DeleteTenant(ctx context.Context, id string) error {
 	if _, err := s.db.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=$1`, id); err != nil {
 		return err
 	}
-	_, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=$1`, id)
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=$1`, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM resources WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM delegations WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM policy_versions WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM role_policies WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM policy_users WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM item_versions WHERE tenant_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM policy_revisions WHERE tenant_id=$1`, id)
 	return err
 }
 
@@ -84,11 +119,26 @@ func (s *PostgresStore) SavePolicy(ctx context.Context, tenantID string, p polic
 	if err != nil {
 		return err
 	}
-	_, err = s.db.ExecContext(ctx,
-		`INSERT INTO policies(tenant_id, policy_id, policy) VALUES($1,$2,$3)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO policies(tenant_id, policy_id, policy) VALUES($1,$2,$3)
          ON CONFLICT(tenant_id, policy_id) DO UPDATE SET policy=EXCLUDED.policy`,
-		tenantID, p.ID, string(b))
-	return err
+		tenantID, p.ID, string(b)); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindPolicy, p.ID); err != nil {
+		return err
+	}
+	if _, err := s.recordVersion(ctx, tx, tenantID, "save "+p.ID); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *PostgresStore) LoadPolicies(ctx context.Context, tenantID string) ([]policy.Policy, error) {
@@ -96,33 +146,295 @@ func (s *PostgresStore) LoadPolicies(ctx context.Context, tenantID string) ([]po
 	if err != nil {
 		return nil, err
 	}
+	return scanPolicies(rows)
+}
+
+func (s *PostgresStore) DeletePolicy(ctx context.Context, tenantID, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=$1 AND policy_id=$2`, tenantID, id); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindPolicy, id); err != nil {
+		return err
+	}
+	if _, err := s.recordVersion(ctx, tx, tenantID, "delete "+id); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) ClearPolicies(ctx context.Context, tenantID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.bumpPolicyItems(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=$1`, tenantID); err != nil {
+		return err
+	}
+	if _, err := s.recordVersion(ctx, tx, tenantID, "clear"); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) PolicyVersions(ctx context.Context, tenantID string) ([]PolicyVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT version, author, created_at, message, policies FROM policy_versions WHERE tenant_id=$1 ORDER BY version`, tenantID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
-	out := []policy.Policy{}
+	out := []PolicyVersion{}
 	for rows.Next() {
-		var js string
-		if err := rows.Scan(&js); err != nil {
+		v, err := scanVersion(rows)
+		if err != nil {
 			return nil, err
 		}
-		var p policy.Policy
-		if err := json.Unmarshal([]byte(js), &p); err != nil {
-			return nil, err
-		}
-		out = append(out, p)
+		out = append(out, v)
 	}
 	return out, rows.Err()
 }
 
-func (s *PostgresStore) ClearPolicies(ctx context.Context, tenantID string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=$1`, tenantID)
+func (s *PostgresStore) LoadPolicyVersion(ctx context.Context, tenantID string, version int) (PolicyVersion, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT version, author, created_at, message, policies FROM policy_versions WHERE tenant_id=$1 AND version=$2`, tenantID, version)
+	v, err := scanVersion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PolicyVersion{}, ErrVersionNotFound
+	}
+	return v, err
+}
+
+func (s *PostgresStore) RollbackPolicies(ctx context.Context, tenantID string, version int) (PolicyVersion, error) {
+	target, err := s.LoadPolicyVersion(ctx, tenantID, version)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	defer tx.Rollback()
+	if err := s.bumpPolicyItems(ctx, tx, tenantID); err != nil {
+		return PolicyVersion{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=$1`, tenantID); err != nil {
+		return PolicyVersion{}, err
+	}
+	for _, p := range target.Policies {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return PolicyVersion{}, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO policies(tenant_id, policy_id, policy) VALUES($1,$2,$3)`, tenantID, p.ID, string(b)); err != nil {
+			return PolicyVersion{}, err
+		}
+		if err := s.bumpItem(ctx, tx, tenantID, KindPolicy, p.ID); err != nil {
+			return PolicyVersion{}, err
+		}
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return PolicyVersion{}, err
+	}
+	v, err := s.recordVersion(ctx, tx, tenantID, fmt.Sprintf("rollback to %d", version))
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	return v, tx.Commit()
+}
+
+// recordVersion stores the tenant's policy set as seen by tx as a new
+// version.
+func (s *PostgresStore) recordVersion(ctx context.Context, tx *sql.Tx, tenantID, message string) (PolicyVersion, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT policy FROM policies WHERE tenant_id=$1`, tenantID)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	list, err := scanPolicies(rows)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	v := PolicyVersion{
+		Author:    authorFromContext(ctx),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Message:   message,
+		Policies:  sortPolicies(list),
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM policy_versions WHERE tenant_id=$1`, tenantID).Scan(&v.Version); err != nil {
+		return PolicyVersion{}, err
+	}
+	b, err := json.Marshal(v.Policies)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO policy_versions(tenant_id, version, author, created_at, message, policies) VALUES($1,$2,$3,$4,$5,$6)`,
+		tenantID, v.Version, v.Author, v.CreatedAt.Unix(), v.Message, string(b))
+	return v, err
+}
+
+func (s *PostgresStore) SaveRole(ctx context.Context, tenantID string, r policy.Role) error {
+	inherits, err := inheritsJSON(r)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO roles(tenant_id, name, inherits) VALUES($1,$2,$3)
+         ON CONFLICT(tenant_id, name) DO UPDATE SET inherits=EXCLUDED.inherits`, tenantID, r.Name, inherits); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindRole, r.Name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_policies WHERE tenant_id=$1 AND role=$2`, tenantID, r.Name); err != nil {
+		return err
+	}
+	for _, id := range uniquePolicies(r) {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO role_policies(tenant_id, role, policy_id) VALUES($1,$2,$3)`, tenantID, r.Name, id); err != nil {
+			return err
+		}
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) LoadRoles(ctx context.Context, tenantID string) ([]policy.Role, error) {
+	return loadRoles(ctx, s.db,
+		`SELECT name, inherits FROM roles WHERE tenant_id=$1 ORDER BY name`,
+		`SELECT role, policy_id FROM role_policies WHERE tenant_id=$1 ORDER BY role, policy_id`,
+		tenantID)
+}
+
+func (s *PostgresStore) DeleteRole(ctx context.Context, tenantID, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id=$1 AND name=$2`, tenantID, name); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindRole, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_policies WHERE tenant_id=$1 AND role=$2`, tenantID, name); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) SavePolicyUser(ctx context.Context, tenantID string, u policy.User) error {
+	b, err := json.Marshal(u.Roles)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO policy_users(tenant_id, username, roles) VALUES($1,$2,$3)
+         ON CONFLICT(tenant_id, username) DO UPDATE SET roles=EXCLUDED.roles`, tenantID, u.Username, string(b)); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindUser, u.Username); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) LoadPolicyUsers(ctx context.Context, tenantID string) ([]policy.User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT username, roles FROM policy_users WHERE tenant_id=$1 ORDER BY username`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPolicyUsers(rows)
+}
+
+func (s *PostgresStore) DeletePolicyUser(ctx context.Context, tenantID, username string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policy_users WHERE tenant_id=$1 AND username=$2`, tenantID, username); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindUser, username); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) ItemVersions(ctx context.Context, tenantID string) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, item_id, version FROM item_versions WHERE tenant_id=$1`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanItemVersions(rows)
+}
+
+// bumpItem increments an item's version, after checking the version
+// expected by ctx. The upsert locks the row until tx ends.
+func (s *PostgresStore) bumpItem(ctx context.Context, tx *sql.Tx, tenantID, kind, id string) error {
+	var v int64
+	if err := tx.QueryRowContext(ctx, `INSERT INTO item_versions(tenant_id, kind, item_id, version) VALUES($1,$2,$3,1)
+         ON CONFLICT(tenant_id, kind, item_id) DO UPDATE SET version=item_versions.version+1 RETURNING version`,
+		tenantID, kind, id).Scan(&v); err != nil {
+		return err
+	}
+	return checkVersion(ctx, v-1)
+}
+
+// bumpPolicyItems increments the version of every policy the tenant holds.
+func (s *PostgresStore) bumpPolicyItems(ctx context.Context, tx *sql.Tx, tenantID string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO item_versions(tenant_id, kind, item_id, version)
+         SELECT tenant_id, '`+KindPolicy+`', policy_id, 1 FROM policies WHERE tenant_id=$1
+         ON CONFLICT(tenant_id, kind, item_id) DO UPDATE SET version=item_versions.version+1`, tenantID)
 	return err
 }
 
 func (s *PostgresStore) SaveEdge(ctx context.Context, tenantID, src, dst string) error {
-	_, err := s.db.ExecContext(ctx,
+	if _, err := s.db.ExecContext(ctx,
 		`INSERT INTO edges(tenant_id, src, dst) VALUES($1,$2,$3)
          ON CONFLICT DO NOTHING`,
-		tenantID, src, dst)
-	return err
+		tenantID, src, dst); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *PostgresStore) DeleteEdge(ctx context.Context, tenantID, src, dst string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=$1 AND src=$2 AND dst=$3`, tenantID, src, dst); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
 }
 
 func (s *PostgresStore) LoadEdges(ctx context.Context, tenantID string) ([]Edge, error) {
@@ -143,6 +455,164 @@ func (s *PostgresStore) LoadEdges(ctx context.Context, tenantID string) ([]Edge,
 }
 
 func (s *PostgresStore) ClearEdges(ctx context.Context, tenantID string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=$1`, tenantID)
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=$1`, tenantID); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *PostgresStore) SaveResourceAttributes(ctx context.Context, tenantID, resource string, attrs map[string]interface{}) error {
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO resources(tenant_id, resource_id, attributes) VALUES($1,$2,$3)
+         ON CONFLICT(tenant_id, resource_id) DO UPDATE SET attributes=EXCLUDED.attributes`,
+		tenantID, resource, string(b))
+	return err
+}
+
+func (s *PostgresStore) LoadResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT attributes FROM resources WHERE tenant_id=$1 AND resource_id=$2`, tenantID, resource)
+	var js string
+	if err := row.Scan(&js); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(js), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) DeleteResourceAttributes(ctx context.Context, tenantID, resource string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM resources WHERE tenant_id=$1 AND resource_id=$2`, tenantID, resource)
+	return err
+}
+
+func (s *PostgresStore) ListResourceAttributes(ctx context.Context, tenantID string) (map[string]map[string]interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT resource_id, attributes FROM resources WHERE tenant_id=$1`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var resource, js string
+		if err := rows.Scan(&resource, &js); err != nil {
+			return nil, err
+		}
+		attrs := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(js), &attrs); err != nil {
+			return nil, err
+		}
+		out[resource] = attrs
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) SaveDelegation(ctx context.Context, tenantID string, d policy.Delegation) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO delegations(tenant_id, delegation_id, delegation) VALUES($1,$2,$3)
+         ON CONFLICT(tenant_id, delegation_id) DO UPDATE SET delegation=EXCLUDED.delegation`,
+		tenantID, d.ID, string(b)); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *PostgresStore) LoadDelegations(ctx context.Context, tenantID string) ([]policy.Delegation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT delegation FROM delegations WHERE tenant_id=$1`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []policy.Delegation{}
+	for rows.Next() {
+		var js string
+		if err := rows.Scan(&js); err != nil {
+			return nil, err
+		}
+		var d policy.Delegation
+		if err := json.Unmarshal([]byte(js), &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) DeleteDelegation(ctx context.Context, tenantID, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM delegations WHERE tenant_id=$1 AND delegation_id=$2`, tenantID, id); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *PostgresStore) Revision(ctx context.Context, tenantID string) (int64, error) {
+	var rev int64
+	err := s.db.QueryRowContext(ctx, `SELECT revision FROM policy_revisions WHERE tenant_id=$1`, tenantID).Scan(&rev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return rev, err
+}
+
+// Watch listens on PolicyChannel, which every write notifies with
+// `tenantID:revision` when its transaction commits. After the listener
+// reconnects it reports a Change without a tenant, since notifications sent
+// while it was disconnected are lost.
+func (s *PostgresStore) Watch(ctx context.Context) (<-chan Change, error) {
+	l := pq.NewListener(s.dsn, time.Second, time.Minute, nil)
+	if err := l.Listen(PolicyChannel); err != nil {
+		l.Close()
+		return nil, err
+	}
+	out := make(chan Change)
+	go func() {
+		defer close(out)
+		defer l.Close()
+		for {
+			var c Change
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-l.Notify:
+				if n != nil {
+					// Tenant IDs may contain colons; the revision never does.
+					c.TenantID = n.Extra
+					if i := strings.LastIndex(n.Extra, ":"); i >= 0 {
+						c.TenantID = n.Extra[:i]
+						c.Revision, _ = strconv.ParseInt(n.Extra[i+1:], 10, 64)
+					}
+				}
+			case <-time.After(time.Minute):
+				go l.Ping()
+				continue
+			}
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// bumpRevision increments the tenant's revision and notifies PolicyChannel.
+func (s *PostgresStore) bumpRevision(ctx context.Context, db execer, tenantID string) error {
+	_, err := db.ExecContext(ctx, `WITH r AS (
+            INSERT INTO policy_revisions(tenant_id, revision) VALUES($1,1)
+            ON CONFLICT(tenant_id) DO UPDATE SET revision=policy_revisions.revision+1
+            RETURNING revision)
+         SELECT pg_notify($2, $1 || ':' || revision) FROM r`, tenantID, PolicyChannel)
 	return err
 }
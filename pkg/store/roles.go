@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+// loadRoles reads a tenant's roles with rolesQuery, selecting name and
+// inherits, and attaches the policy bindings selected by bindingsQuery as
+// role and policy_id. Both queries take the tenant ID as their only argument.
+func loadRoles(ctx context.Context, db *sql.DB, rolesQuery, bindingsQuery, tenantID string) ([]policy.Role, error) {
+	rows, err := db.QueryContext(ctx, rolesQuery, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []policy.Role{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var r policy.Role
+		var inherits string
+		if err := rows.Scan(&r.Name, &inherits); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(inherits), &r.Inherits); err != nil {
+			return nil, err
+		}
+		r.Policies = []string{}
+		index[r.Name] = len(out)
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	bindings, err := db.QueryContext(ctx, bindingsQuery, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer bindings.Close()
+	for bindings.Next() {
+		var role, policyID string
+		if err := bindings.Scan(&role, &policyID); err != nil {
+			return nil, err
+		}
+		if i, ok := index[role]; ok {
+			out[i].Policies = append(out[i].Policies, policyID)
+		}
+	}
+	return out, bindings.Err()
+}
+
+// scanPolicyUsers reads the username and JSON encoded roles selected by rows
+// and closes them.
+func scanPolicyUsers(rows *sql.Rows) ([]policy.User, error) {
+	defer rows.Close()
+	out := []policy.User{}
+	for rows.Next() {
+		var u policy.User
+		var roles string
+		if err := rows.Scan(&u.Username, &roles); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(roles), &u.Roles); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// inheritsJSON encodes a role's parents for the roles table.
+func inheritsJSON(r policy.Role) (string, error) {
+	list := r.Inherits
+	if list == nil {
+		list = []string{}
+	}
+	b, err := json.Marshal(list)
+	return string(b), err
+}
+
+// uniquePolicies returns a role's policy IDs sorted and without duplicates,
+// as stored in role_policies.
+func uniquePolicies(r policy.Role) []string {
+	seen := make(map[string]struct{}, len(r.Policies))
+	out := make([]string, 0, len(r.Policies))
+	for _, id := range r.Policies {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -16,6 +17,8 @@ import (
 // SQLiteStore implements Store backed by a SQLite database.
 type SQLiteStore struct {
 	db *sql.DB
+	// pollInterval is how often Watch checks the revision table.
+	pollInterval time.Duration
 }
 
 // NewSQLite creates a new SQLiteStore using the given datasource name.
@@ -24,42 +27,41 @@ func NewSQLite(dsn string) (*SQLiteStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &SQLiteStore{db: db}, nil
+	return &SQLiteStore{db: db, pollInterval: defaultPollInterval}, nil
 }
 
 func (s *SQLiteStore) SaveTenant(ctx context.Context, t tenant.Tenant) error {
-	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO tenants(id, name, created_at) VALUES(?,?,?)`, t.ID, t.Name, t.CreatedAt.Unix())
-	return err
+	status, deleted, quota, issuers := tenantValues(t)
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO tenants(id, name, created_at, template, template_version, policy_repo, policy_branch,
+         status, deleted_at, quota, trusted_issuers) VALUES(?,?,?,?,?,?,?,?,?,?,?)`,
+		t.ID, t.Name, t.CreatedAt.Unix(), t.Template, t.TemplateVersion, t.PolicyRepo, t.PolicyBranch, status, deleted, quota, issuers)
+	if err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, t.ID)
 }
 
 func (s *SQLiteStore) LoadTenant(ctx context.Context, id string) (tenant.Tenant, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id, name, created_at FROM tenants WHERE id=?`, id)
-	var t tenant.Tenant
-	var created int64
-	if err := row.Scan(&t.ID, &t.Name, &created); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return tenant.Tenant{}, errors.New("tenant not found")
-		}
-		return tenant.Tenant{}, err
+	row := s.db.QueryRowContext(ctx, `SELECT `+tenantColumns+` FROM tenants WHERE id=?`, id)
+	t, err := scanTenant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tenant.Tenant{}, errors.New("tenant not found")
 	}
-	t.CreatedAt = time.Unix(created, 0).UTC()
-	return t, nil
+	return t, err
 }
 
 func (s *SQLiteStore) ListTenants(ctx context.Context) ([]tenant.Tenant, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, name, created_at FROM tenants`)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+tenantColumns+` FROM tenants`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	out := []tenant.Tenant{}
 	for rows.Next() {
-		var t tenant.Tenant
-		var created int64
-		if err := rows.Scan(&t.ID, &t.Name, &created); err != nil {
+		t, err := scanTenant(rows)
+		if err != nil {
 			return nil, err
 		}
-		t.CreatedAt = time.Unix(created, 0).UTC()
 		out = append(out, t)
 	}
 	return out, rows.Err()
@@ -75,6 +77,38 @@ func (s *SQLiteStore) DeleteTenant(ctx context.Context, id string) error {
 		return err
 	}
 	_, err = s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM resources WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM delegations WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM policy_versions WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM role_policies WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM policy_users WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM item_versions WHERE tenant_id=?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM policy_revisions WHERE tenant_id=?`, id)
 	return err
 }
 
@@ -83,8 +117,25 @@ func (s *SQLiteStore) SavePolicy(ctx context.Context, tenantID string, p policy.
 	if err != nil {
 		return err
 	}
-	_, err = s.db.ExecContext(ctx, `INSERT OR REPLACE INTO policies(tenant_id, policy_id, policy) VALUES(?,?,?)`, tenantID, p.ID, string(b))
-	return err
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO policies(tenant_id, policy_id, policy) VALUES(?,?,?)`,
+		tenantID, p.ID, string(b)); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindPolicy, p.ID); err != nil {
+		return err
+	}
+	if _, err := s.recordVersion(ctx, tx, tenantID, "save "+p.ID); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *SQLiteStore) LoadPolicies(ctx context.Context, tenantID string) ([]policy.Policy, error) {
@@ -92,30 +143,290 @@ func (s *SQLiteStore) LoadPolicies(ctx context.Context, tenantID string) ([]poli
 	if err != nil {
 		return nil, err
 	}
+	return scanPolicies(rows)
+}
+
+func (s *SQLiteStore) DeletePolicy(ctx context.Context, tenantID, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=? AND policy_id=?`, tenantID, id); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindPolicy, id); err != nil {
+		return err
+	}
+	if _, err := s.recordVersion(ctx, tx, tenantID, "delete "+id); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ClearPolicies(ctx context.Context, tenantID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.bumpPolicyItems(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=?`, tenantID); err != nil {
+		return err
+	}
+	if _, err := s.recordVersion(ctx, tx, tenantID, "clear"); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) PolicyVersions(ctx context.Context, tenantID string) ([]PolicyVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT version, author, created_at, message, policies FROM policy_versions WHERE tenant_id=? ORDER BY version`, tenantID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
-	out := []policy.Policy{}
+	out := []PolicyVersion{}
 	for rows.Next() {
-		var js string
-		if err := rows.Scan(&js); err != nil {
+		v, err := scanVersion(rows)
+		if err != nil {
 			return nil, err
 		}
-		var p policy.Policy
-		if err := json.Unmarshal([]byte(js), &p); err != nil {
-			return nil, err
-		}
-		out = append(out, p)
+		out = append(out, v)
 	}
 	return out, rows.Err()
 }
 
-func (s *SQLiteStore) ClearPolicies(ctx context.Context, tenantID string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=?`, tenantID)
+func (s *SQLiteStore) LoadPolicyVersion(ctx context.Context, tenantID string, version int) (PolicyVersion, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT version, author, created_at, message, policies FROM policy_versions WHERE tenant_id=? AND version=?`, tenantID, version)
+	v, err := scanVersion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PolicyVersion{}, ErrVersionNotFound
+	}
+	return v, err
+}
+
+func (s *SQLiteStore) RollbackPolicies(ctx context.Context, tenantID string, version int) (PolicyVersion, error) {
+	target, err := s.LoadPolicyVersion(ctx, tenantID, version)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	defer tx.Rollback()
+	if err := s.bumpPolicyItems(ctx, tx, tenantID); err != nil {
+		return PolicyVersion{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policies WHERE tenant_id=?`, tenantID); err != nil {
+		return PolicyVersion{}, err
+	}
+	for _, p := range target.Policies {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return PolicyVersion{}, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO policies(tenant_id, policy_id, policy) VALUES(?,?,?)`, tenantID, p.ID, string(b)); err != nil {
+			return PolicyVersion{}, err
+		}
+		if err := s.bumpItem(ctx, tx, tenantID, KindPolicy, p.ID); err != nil {
+			return PolicyVersion{}, err
+		}
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return PolicyVersion{}, err
+	}
+	v, err := s.recordVersion(ctx, tx, tenantID, fmt.Sprintf("rollback to %d", version))
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	return v, tx.Commit()
+}
+
+// recordVersion stores the tenant's policy set as seen by tx as a new
+// version.
+func (s *SQLiteStore) recordVersion(ctx context.Context, tx *sql.Tx, tenantID, message string) (PolicyVersion, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT policy FROM policies WHERE tenant_id=?`, tenantID)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	list, err := scanPolicies(rows)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	v := PolicyVersion{
+		Author:    authorFromContext(ctx),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Message:   message,
+		Policies:  sortPolicies(list),
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM policy_versions WHERE tenant_id=?`, tenantID).Scan(&v.Version); err != nil {
+		return PolicyVersion{}, err
+	}
+	b, err := json.Marshal(v.Policies)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO policy_versions(tenant_id, version, author, created_at, message, policies) VALUES(?,?,?,?,?,?)`,
+		tenantID, v.Version, v.Author, v.CreatedAt.Unix(), v.Message, string(b))
+	return v, err
+}
+
+func (s *SQLiteStore) SaveRole(ctx context.Context, tenantID string, r policy.Role) error {
+	inherits, err := inheritsJSON(r)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO roles(tenant_id, name, inherits) VALUES(?,?,?)`, tenantID, r.Name, inherits); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindRole, r.Name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_policies WHERE tenant_id=? AND role=?`, tenantID, r.Name); err != nil {
+		return err
+	}
+	for _, id := range uniquePolicies(r) {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO role_policies(tenant_id, role, policy_id) VALUES(?,?,?)`, tenantID, r.Name, id); err != nil {
+			return err
+		}
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) LoadRoles(ctx context.Context, tenantID string) ([]policy.Role, error) {
+	return loadRoles(ctx, s.db,
+		`SELECT name, inherits FROM roles WHERE tenant_id=? ORDER BY name`,
+		`SELECT role, policy_id FROM role_policies WHERE tenant_id=? ORDER BY role, policy_id`,
+		tenantID)
+}
+
+func (s *SQLiteStore) DeleteRole(ctx context.Context, tenantID, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id=? AND name=?`, tenantID, name); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindRole, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_policies WHERE tenant_id=? AND role=?`, tenantID, name); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SavePolicyUser(ctx context.Context, tenantID string, u policy.User) error {
+	b, err := json.Marshal(u.Roles)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO policy_users(tenant_id, username, roles) VALUES(?,?,?)`, tenantID, u.Username, string(b)); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindUser, u.Username); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) LoadPolicyUsers(ctx context.Context, tenantID string) ([]policy.User, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT username, roles FROM policy_users WHERE tenant_id=? ORDER BY username`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPolicyUsers(rows)
+}
+
+func (s *SQLiteStore) DeletePolicyUser(ctx context.Context, tenantID, username string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM policy_users WHERE tenant_id=? AND username=?`, tenantID, username); err != nil {
+		return err
+	}
+	if err := s.bumpItem(ctx, tx, tenantID, KindUser, username); err != nil {
+		return err
+	}
+	if err := s.bumpRevision(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ItemVersions(ctx context.Context, tenantID string) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, item_id, version FROM item_versions WHERE tenant_id=?`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanItemVersions(rows)
+}
+
+// bumpItem increments an item's version, after checking the version
+// expected by ctx.
+func (s *SQLiteStore) bumpItem(ctx context.Context, tx *sql.Tx, tenantID, kind, id string) error {
+	var v int64
+	if err := tx.QueryRowContext(ctx, `INSERT INTO item_versions(tenant_id, kind, item_id, version) VALUES(?,?,?,1)
+         ON CONFLICT(tenant_id, kind, item_id) DO UPDATE SET version=item_versions.version+1 RETURNING version`,
+		tenantID, kind, id).Scan(&v); err != nil {
+		return err
+	}
+	return checkVersion(ctx, v-1)
+}
+
+// bumpPolicyItems increments the version of every policy the tenant holds.
+func (s *SQLiteStore) bumpPolicyItems(ctx context.Context, tx *sql.Tx, tenantID string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO item_versions(tenant_id, kind, item_id, version)
+         SELECT tenant_id, '`+KindPolicy+`', policy_id, 1 FROM policies WHERE tenant_id=?
+         ON CONFLICT(tenant_id, kind, item_id) DO UPDATE SET version=item_versions.version+1`, tenantID)
 	return err
 }
 
 func (s *SQLiteStore) SaveEdge(ctx context.Context, tenantID, src, dst string) error {
-	_, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO edges(tenant_id, src, dst) VALUES(?,?,?)`, tenantID, src, dst)
-	return err
+	if _, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO edges(tenant_id, src, dst) VALUES(?,?,?)`, tenantID, src, dst); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *SQLiteStore) DeleteEdge(ctx context.Context, tenantID, src, dst string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=? AND src=? AND dst=?`, tenantID, src, dst); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
 }
 
 func (s *SQLiteStore) LoadEdges(ctx context.Context, tenantID string) ([]Edge, error) {
@@ -136,6 +447,171 @@ func (s *SQLiteStore) LoadEdges(ctx context.Context, tenantID string) ([]Edge, e
 }
 
 func (s *SQLiteStore) ClearEdges(ctx context.Context, tenantID string) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=?`, tenantID)
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM edges WHERE tenant_id=?`, tenantID); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *SQLiteStore) SaveResourceAttributes(ctx context.Context, tenantID, resource string, attrs map[string]interface{}) error {
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT OR REPLACE INTO resources(tenant_id, resource_id, attributes) VALUES(?,?,?)`, tenantID, resource, string(b))
+	return err
+}
+
+func (s *SQLiteStore) LoadResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT attributes FROM resources WHERE tenant_id=? AND resource_id=?`, tenantID, resource)
+	var js string
+	if err := row.Scan(&js); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(js), &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) DeleteResourceAttributes(ctx context.Context, tenantID, resource string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM resources WHERE tenant_id=? AND resource_id=?`, tenantID, resource)
+	return err
+}
+
+func (s *SQLiteStore) ListResourceAttributes(ctx context.Context, tenantID string) (map[string]map[string]interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT resource_id, attributes FROM resources WHERE tenant_id=?`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var resource, js string
+		if err := rows.Scan(&resource, &js); err != nil {
+			return nil, err
+		}
+		attrs := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(js), &attrs); err != nil {
+			return nil, err
+		}
+		out[resource] = attrs
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) SaveDelegation(ctx context.Context, tenantID string, d policy.Delegation) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO delegations(tenant_id, delegation_id, delegation) VALUES(?,?,?)`, tenantID, d.ID, string(b)); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *SQLiteStore) LoadDelegations(ctx context.Context, tenantID string) ([]policy.Delegation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT delegation FROM delegations WHERE tenant_id=?`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []policy.Delegation{}
+	for rows.Next() {
+		var js string
+		if err := rows.Scan(&js); err != nil {
+			return nil, err
+		}
+		var d policy.Delegation
+		if err := json.Unmarshal([]byte(js), &d); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteDelegation(ctx context.Context, tenantID, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM delegations WHERE tenant_id=? AND delegation_id=?`, tenantID, id); err != nil {
+		return err
+	}
+	return s.bumpRevision(ctx, s.db, tenantID)
+}
+
+func (s *SQLiteStore) Revision(ctx context.Context, tenantID string) (int64, error) {
+	var rev int64
+	err := s.db.QueryRowContext(ctx, `SELECT revision FROM policy_revisions WHERE tenant_id=?`, tenantID).Scan(&rev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return rev, err
+}
+
+// Watch polls the policy_revisions table, which every write increments, and
+// reports the tenants whose revision moved since the previous poll. This
+// also picks up changes made by other processes sharing the database file.
+func (s *SQLiteStore) Watch(ctx context.Context) (<-chan Change, error) {
+	seen, err := s.revisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan Change)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			revs, err := s.revisions(ctx)
+			if err != nil {
+				continue
+			}
+			for id, rev := range revs {
+				if rev == seen[id] {
+					continue
+				}
+				select {
+				case out <- Change{TenantID: id, Revision: rev}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			seen = revs
+		}
+	}()
+	return out, nil
+}
+
+func (s *SQLiteStore) revisions(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT tenant_id, revision FROM policy_revisions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]int64)
+	for rows.Next() {
+		var id string
+		var rev int64
+		if err := rows.Scan(&id, &rev); err != nil {
+			return nil, err
+		}
+		out[id] = rev
+	}
+	return out, rows.Err()
+}
+
+// bumpRevision increments the tenant's revision.
+func (s *SQLiteStore) bumpRevision(ctx context.Context, db execer, tenantID string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO policy_revisions(tenant_id, revision) VALUES(?,1)
+         ON CONFLICT(tenant_id) DO UPDATE SET revision=policy_revisions.revision+1`, tenantID)
 	return err
 }
@@ -13,7 +13,16 @@ type Edge struct {
 	Dst string
 }
 
-// Store defines operations for persisting tenants, policies and graph edges.
+// Store defines operations for persisting tenants, policies, graph edges,
+// resource attributes and delegations.
+//
+// Every SavePolicy, DeletePolicy and ClearPolicies call records a new PolicyVersion holding
+// the tenant's resulting policy set, attributed to the author set on ctx
+// with WithAuthor.
+//
+// Every write of a policy, role or policy user increments that item's
+// version; writes made with IfVersion fail with ErrConflict if another
+// write came first.
 type Store interface {
 	SaveTenant(ctx context.Context, t tenant.Tenant) error
 	LoadTenant(ctx context.Context, id string) (tenant.Tenant, error)
@@ -22,9 +31,50 @@ type Store interface {
 
 	SavePolicy(ctx context.Context, tenantID string, p policy.Policy) error
 	LoadPolicies(ctx context.Context, tenantID string) ([]policy.Policy, error)
+	DeletePolicy(ctx context.Context, tenantID, id string) error
 	ClearPolicies(ctx context.Context, tenantID string) error
+	// PolicyVersions lists a tenant's policy versions, oldest first.
+	PolicyVersions(ctx context.Context, tenantID string) ([]PolicyVersion, error)
+	LoadPolicyVersion(ctx context.Context, tenantID string, version int) (PolicyVersion, error)
+	// RollbackPolicies restores the policy set of a previous version and
+	// records it as a new version.
+	RollbackPolicies(ctx context.Context, tenantID string, version int) (PolicyVersion, error)
+
+	// SaveRole stores a role with its policy bindings, replacing the
+	// bindings of an existing role with the same name.
+	SaveRole(ctx context.Context, tenantID string, r policy.Role) error
+	LoadRoles(ctx context.Context, tenantID string) ([]policy.Role, error)
+	DeleteRole(ctx context.Context, tenantID, name string) error
+	// SavePolicyUser stores the roles assigned to a username by the policy
+	// set, as opposed to the identity provider.
+	SavePolicyUser(ctx context.Context, tenantID string, u policy.User) error
+	LoadPolicyUsers(ctx context.Context, tenantID string) ([]policy.User, error)
+	DeletePolicyUser(ctx context.Context, tenantID, username string) error
+	// ItemVersions returns the version of every policy, role and policy
+	// user stored for a tenant, keyed by ItemKey. Deleted items keep their
+	// version.
+	ItemVersions(ctx context.Context, tenantID string) (map[string]int64, error)
 
 	SaveEdge(ctx context.Context, tenantID, src, dst string) error
+	DeleteEdge(ctx context.Context, tenantID, src, dst string) error
 	LoadEdges(ctx context.Context, tenantID string) ([]Edge, error)
 	ClearEdges(ctx context.Context, tenantID string) error
+
+	SaveResourceAttributes(ctx context.Context, tenantID, resource string, attrs map[string]interface{}) error
+	LoadResourceAttributes(ctx context.Context, tenantID, resource string) (map[string]interface{}, error)
+	DeleteResourceAttributes(ctx context.Context, tenantID, resource string) error
+	// ListResourceAttributes returns the attributes of every resource of a
+	// tenant, keyed by resource.
+	ListResourceAttributes(ctx context.Context, tenantID string) (map[string]map[string]interface{}, error)
+
+	SaveDelegation(ctx context.Context, tenantID string, d policy.Delegation) error
+	LoadDelegations(ctx context.Context, tenantID string) ([]policy.Delegation, error)
+	DeleteDelegation(ctx context.Context, tenantID, id string) error
+
+	// Revision returns a counter that increases with every change to a
+	// tenant's policies, roles, users, relationships or delegations.
+	Revision(ctx context.Context, tenantID string) (int64, error)
+	// Watch reports the changes made through any store sharing the same
+	// backend until ctx is done.
+	Watch(ctx context.Context) (<-chan Change, error)
 }
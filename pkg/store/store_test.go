@@ -2,7 +2,8 @@ package store
 
 import (
 	"context"
-	"os"
+	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -12,14 +13,31 @@ import (
 
 func runStoreTests(t *testing.T, s Store) {
 	ctx := context.Background()
-	tnt := tenant.Tenant{ID: "t1", Name: "t1", CreatedAt: time.Now().UTC()}
+	tnt := tenant.Tenant{ID: "t1", Name: "t1", CreatedAt: time.Now().UTC(), Template: "saas-basic", TemplateVersion: "1.0"}
 	if err := s.SaveTenant(ctx, tnt); err != nil {
 		t.Fatalf("SaveTenant: %v", err)
 	}
 	got, err := s.LoadTenant(ctx, "t1")
-	if err != nil || got.ID != "t1" {
-		t.Fatalf("LoadTenant: %v", err)
+	if err != nil || got.ID != "t1" || got.Template != "saas-basic" || got.TemplateVersion != "1.0" {
+		t.Fatalf("LoadTenant: %v %v", got, err)
 	}
+	if got.State() != tenant.StatusActive || got.DeletedAt != nil {
+		t.Fatalf("expected new tenant to be active, got %q %v", got.Status, got.DeletedAt)
+	}
+	deleted := time.Unix(time.Now().Unix(), 0).UTC()
+	tnt.Status, tnt.DeletedAt = tenant.StatusPendingDeletion, &deleted
+	tnt.Quota = tenant.Quota{MaxPolicies: 5, RequestsPerSecond: 2.5}
+	tnt.TrustedIssuers = []string{"did:web:issuer.example"}
+	if err := s.SaveTenant(ctx, tnt); err != nil {
+		t.Fatalf("SaveTenant: %v", err)
+	}
+	got, err = s.LoadTenant(ctx, "t1")
+	if err != nil || got.Status != tenant.StatusPendingDeletion || got.DeletedAt == nil || !got.DeletedAt.Equal(deleted) || got.Quota != tnt.Quota ||
+		len(got.TrustedIssuers) != 1 || got.TrustedIssuers[0] != tnt.TrustedIssuers[0] {
+		t.Fatalf("expected lifecycle fields to round-trip, got %#v %v", got, err)
+	}
+	tnt.Status, tnt.DeletedAt = tenant.StatusActive, nil
+	s.SaveTenant(ctx, tnt)
 	list, err := s.ListTenants(ctx)
 	if err != nil || len(list) == 0 {
 		t.Fatalf("ListTenants: %v", err)
@@ -32,6 +50,10 @@ func runStoreTests(t *testing.T, s Store) {
 	if err != nil || len(pList) != 1 {
 		t.Fatalf("LoadPolicies: %v", err)
 	}
+	runVersionTests(t, s)
+	runRoleTests(t, s)
+	runItemVersionTests(t, s)
+	runWatchTests(t, s)
 	if err := s.SaveEdge(ctx, "t1", "a", "b"); err != nil {
 		t.Fatalf("SaveEdge: %v", err)
 	}
@@ -39,6 +61,48 @@ func runStoreTests(t *testing.T, s Store) {
 	if err != nil || len(edges) != 1 {
 		t.Fatalf("LoadEdges: %v", err)
 	}
+	if err := s.DeleteEdge(ctx, "t1", "a", "b"); err != nil {
+		t.Fatalf("DeleteEdge: %v", err)
+	}
+	if edges, _ := s.LoadEdges(ctx, "t1"); len(edges) != 0 {
+		t.Fatalf("expected edge to be deleted, got %v", edges)
+	}
+	attrs := map[string]interface{}{"owner": "alice", "classification": "internal"}
+	if err := s.SaveResourceAttributes(ctx, "t1", "file1", attrs); err != nil {
+		t.Fatalf("SaveResourceAttributes: %v", err)
+	}
+	got2, err := NewAttributeProvider(s).ResourceAttributes(ctx, "t1", "file1")
+	if err != nil || got2["owner"] != "alice" {
+		t.Fatalf("ResourceAttributes: %v %v", got2, err)
+	}
+	if all, err := s.ListResourceAttributes(ctx, "t1"); err != nil || len(all) != 1 || all["file1"] == nil {
+		t.Fatalf("ListResourceAttributes: %v %v", all, err)
+	}
+	missing, err := s.LoadResourceAttributes(ctx, "t1", "nope")
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("LoadResourceAttributes missing: %v %v", missing, err)
+	}
+	if err := s.DeleteResourceAttributes(ctx, "t1", "file1"); err != nil {
+		t.Fatalf("DeleteResourceAttributes: %v", err)
+	}
+	if got2, _ := s.LoadResourceAttributes(ctx, "t1", "file1"); len(got2) != 0 {
+		t.Fatalf("expected attributes to be deleted, got %v", got2)
+	}
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	d := policy.Delegation{ID: "d1", Delegator: "alice", Delegate: "bob", Actions: []string{"read"}, ExpiresAt: &expires}
+	if err := s.SaveDelegation(ctx, "t1", d); err != nil {
+		t.Fatalf("SaveDelegation: %v", err)
+	}
+	delegs, err := s.LoadDelegations(ctx, "t1")
+	if err != nil || len(delegs) != 1 || delegs[0].Delegate != "bob" || !delegs[0].ExpiresAt.Equal(expires) {
+		t.Fatalf("LoadDelegations: %v %v", delegs, err)
+	}
+	if err := s.DeleteDelegation(ctx, "t1", "d1"); err != nil {
+		t.Fatalf("DeleteDelegation: %v", err)
+	}
+	if delegs, _ := s.LoadDelegations(ctx, "t1"); len(delegs) != 0 {
+		t.Fatalf("expected delegation to be deleted, got %v", delegs)
+	}
 	if err := s.DeleteTenant(ctx, "t1"); err != nil {
 		t.Fatalf("DeleteTenant: %v", err)
 	}
@@ -47,18 +111,193 @@ func runStoreTests(t *testing.T, s Store) {
 	}
 }
 
+// runVersionTests expects tenant t1 to hold only policy p1, saved once.
+func runVersionTests(t *testing.T, s Store) {
+	ctx := WithAuthor(context.Background(), "alice")
+	if err := s.SavePolicy(ctx, "t1", policy.Policy{ID: "p1", Description: "changed"}); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	if err := s.SavePolicy(ctx, "t1", policy.Policy{ID: "p2"}); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	versions, err := s.PolicyVersions(ctx, "t1")
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("PolicyVersions: %v %v", versions, err)
+	}
+	if v := versions[2]; v.Version != 3 || v.Author != "alice" || v.Message != "save p2" || len(v.Policies) != 2 {
+		t.Fatalf("unexpected version %#v", v)
+	}
+	changes := DiffPolicies(versions[0].Policies, versions[2].Policies)
+	if len(changes) != 2 || changes[0].Type != "modified" || changes[1].Type != "added" || changes[1].PolicyID != "p2" {
+		t.Fatalf("unexpected diff %#v", changes)
+	}
+
+	v, err := s.RollbackPolicies(ctx, "t1", 1)
+	if err != nil || v.Version != 4 || v.Message != "rollback to 1" {
+		t.Fatalf("RollbackPolicies: %#v %v", v, err)
+	}
+	pList, err := s.LoadPolicies(ctx, "t1")
+	if err != nil || len(pList) != 1 || pList[0].Description != "d" {
+		t.Fatalf("expected rollback to restore p1, got %v %v", pList, err)
+	}
+	if _, err := s.LoadPolicyVersion(ctx, "t1", 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("expected ErrVersionNotFound, got %v", err)
+	}
+	if _, err := s.RollbackPolicies(ctx, "t1", 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("expected ErrVersionNotFound, got %v", err)
+	}
+	if err := s.DeletePolicy(ctx, "t1", "p1"); err != nil {
+		t.Fatalf("DeletePolicy: %v", err)
+	}
+	if v, err := s.LoadPolicyVersion(ctx, "t1", 5); err != nil || len(v.Policies) != 0 || v.Message != "delete p1" {
+		t.Fatalf("expected delete to be recorded, got %#v %v", v, err)
+	}
+	if err := s.ClearPolicies(ctx, "t1"); err != nil {
+		t.Fatalf("ClearPolicies: %v", err)
+	}
+	if v, err := s.LoadPolicyVersion(ctx, "t1", 6); err != nil || v.Message != "clear" {
+		t.Fatalf("expected clear to be recorded, got %#v %v", v, err)
+	}
+	if _, err := s.RollbackPolicies(ctx, "t1", 4); err != nil {
+		t.Fatalf("RollbackPolicies: %v", err)
+	}
+}
+
+func runRoleTests(t *testing.T, s Store) {
+	ctx := context.Background()
+	r := policy.Role{Name: "editor", Policies: []string{"p2", "p1", "p2"}, Inherits: []string{"viewer"}}
+	if err := s.SaveRole(ctx, "t1", r); err != nil {
+		t.Fatalf("SaveRole: %v", err)
+	}
+	if err := s.SaveRole(ctx, "t1", policy.Role{Name: "viewer", Policies: []string{"p1"}}); err != nil {
+		t.Fatalf("SaveRole: %v", err)
+	}
+	roles, err := s.LoadRoles(ctx, "t1")
+	if err != nil || len(roles) != 2 || roles[0].Name != "editor" || len(roles[0].Policies) != 2 || roles[0].Policies[0] != "p1" || roles[0].Inherits[0] != "viewer" {
+		t.Fatalf("LoadRoles: %v %v", roles, err)
+	}
+	r.Policies = []string{"p2"}
+	if err := s.SaveRole(ctx, "t1", r); err != nil {
+		t.Fatalf("SaveRole: %v", err)
+	}
+	if roles, _ := s.LoadRoles(ctx, "t1"); len(roles[0].Policies) != 1 || roles[0].Policies[0] != "p2" {
+		t.Fatalf("expected role bindings to be replaced, got %v", roles)
+	}
+	if err := s.DeleteRole(ctx, "t1", "viewer"); err != nil {
+		t.Fatalf("DeleteRole: %v", err)
+	}
+	if roles, _ := s.LoadRoles(ctx, "t1"); len(roles) != 1 {
+		t.Fatalf("expected role to be deleted, got %v", roles)
+	}
+	if err := s.SavePolicyUser(ctx, "t1", policy.User{Username: "alice", Roles: []string{"editor"}}); err != nil {
+		t.Fatalf("SavePolicyUser: %v", err)
+	}
+	users, err := s.LoadPolicyUsers(ctx, "t1")
+	if err != nil || len(users) != 1 || users[0].Username != "alice" || users[0].Roles[0] != "editor" {
+		t.Fatalf("LoadPolicyUsers: %v %v", users, err)
+	}
+	if err := s.DeletePolicyUser(ctx, "t1", "alice"); err != nil {
+		t.Fatalf("DeletePolicyUser: %v", err)
+	}
+	if users, _ := s.LoadPolicyUsers(ctx, "t1"); len(users) != 0 {
+		t.Fatalf("expected user to be deleted, got %v", users)
+	}
+}
+
+func runItemVersionTests(t *testing.T, s Store) {
+	ctx := context.Background()
+	defer s.DeleteTenant(ctx, "t2")
+	p := policy.Policy{ID: "p1", Effect: "allow"}
+	if err := s.SavePolicy(IfVersion(ctx, 0), "t2", p); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	p.Effect = "deny"
+	if err := s.SavePolicy(IfVersion(ctx, 0), "t2", p); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected stale save to conflict, got %v", err)
+	}
+	if list, _ := s.LoadPolicies(ctx, "t2"); len(list) != 1 || list[0].Effect != "allow" {
+		t.Fatalf("expected conflicting save to leave the policy unchanged, got %v", list)
+	}
+	if err := s.SavePolicy(IfVersion(ctx, 1), "t2", p); err != nil {
+		t.Fatalf("SavePolicy: %v", err)
+	}
+	if err := s.DeletePolicy(IfVersion(ctx, 1), "t2", "p1"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected stale delete to conflict, got %v", err)
+	}
+	if err := s.SaveRole(IfVersion(ctx, 0), "t2", policy.Role{Name: "reader", Policies: []string{"p1"}}); err != nil {
+		t.Fatalf("SaveRole: %v", err)
+	}
+	if err := s.DeleteRole(IfVersion(ctx, 0), "t2", "reader"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected stale role delete to conflict, got %v", err)
+	}
+	if err := s.SavePolicyUser(ctx, "t2", policy.User{Username: "bob", Roles: []string{"reader"}}); err != nil {
+		t.Fatalf("SavePolicyUser: %v", err)
+	}
+	if err := s.DeletePolicyUser(IfVersion(ctx, 1), "t2", "bob"); err != nil {
+		t.Fatalf("DeletePolicyUser: %v", err)
+	}
+	if err := s.ClearPolicies(ctx, "t2"); err != nil {
+		t.Fatalf("ClearPolicies: %v", err)
+	}
+	versions, err := s.ItemVersions(ctx, "t2")
+	want := map[string]int64{ItemKey(KindPolicy, "p1"): 3, ItemKey(KindRole, "reader"): 1, ItemKey(KindUser, "bob"): 2}
+	if err != nil || len(versions) != len(want) {
+		t.Fatalf("ItemVersions: %v %v", versions, err)
+	}
+	for k, v := range want {
+		if versions[k] != v {
+			t.Fatalf("expected %s at version %d, got %v", k, v, versions)
+		}
+	}
+}
+
+// runWatchTests expects tenant t1 to exist.
+func runWatchTests(t *testing.T, s Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := s.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	before, err := s.Revision(ctx, "t1")
+	if err != nil || before == 0 {
+		t.Fatalf("Revision: %d %v", before, err)
+	}
+	if err := s.SaveEdge(ctx, "t1", "x", "y"); err != nil {
+		t.Fatalf("SaveEdge: %v", err)
+	}
+	select {
+	case c := <-changes:
+		if c.TenantID != "t1" || c.Revision != before+1 {
+			t.Fatalf("unexpected change %#v, revision was %d", c, before)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no change reported")
+	}
+	if rev, _ := s.Revision(ctx, "t1"); rev != before+1 {
+		t.Fatalf("expected revision %d, got %d", before+1, rev)
+	}
+	if rev, _ := s.Revision(ctx, "unknown"); rev != 0 {
+		t.Fatalf("expected unknown tenant to have revision 0, got %d", rev)
+	}
+	if err := s.ClearEdges(ctx, "t1"); err != nil {
+		t.Fatalf("ClearEdges: %v", err)
+	}
+	cancel()
+	for range changes {
+	}
+}
+
 func TestMemoryStore(t *testing.T) {
 	runStoreTests(t, NewMemory())
 }
 
 func TestSQLiteStore(t *testing.T) {
-	os.Remove("test.db")
-	s, err := NewSQLite("test.db")
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
 	if err != nil {
 		t.Fatalf("new sqlite: %v", err)
 	}
 	// run migration
-	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS tenants(id TEXT PRIMARY KEY, name TEXT, created_at INTEGER);`)
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS tenants(id TEXT PRIMARY KEY, name TEXT, created_at INTEGER, template TEXT, template_version TEXT, policy_repo TEXT, policy_branch TEXT, status TEXT, deleted_at INTEGER, quota TEXT, trusted_issuers TEXT);`)
 	if err != nil {
 		t.Fatalf("migrate tenants: %v", err)
 	}
@@ -70,5 +309,32 @@ func TestSQLiteStore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("migrate edges: %v", err)
 	}
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS resources(tenant_id TEXT, resource_id TEXT, attributes TEXT, PRIMARY KEY(tenant_id, resource_id));`)
+	if err != nil {
+		t.Fatalf("migrate resources: %v", err)
+	}
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS delegations(tenant_id TEXT, delegation_id TEXT, delegation TEXT, PRIMARY KEY(tenant_id, delegation_id));`)
+	if err != nil {
+		t.Fatalf("migrate delegations: %v", err)
+	}
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS policy_versions(tenant_id TEXT, version INTEGER, author TEXT, created_at INTEGER, message TEXT, policies TEXT, PRIMARY KEY(tenant_id, version));`)
+	if err != nil {
+		t.Fatalf("migrate policy_versions: %v", err)
+	}
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS roles(tenant_id TEXT, name TEXT, inherits TEXT, PRIMARY KEY(tenant_id, name));
+		CREATE TABLE IF NOT EXISTS role_policies(tenant_id TEXT, role TEXT, policy_id TEXT, PRIMARY KEY(tenant_id, role, policy_id));
+		CREATE TABLE IF NOT EXISTS policy_users(tenant_id TEXT, username TEXT, roles TEXT, PRIMARY KEY(tenant_id, username));`)
+	if err != nil {
+		t.Fatalf("migrate roles: %v", err)
+	}
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS policy_revisions(tenant_id TEXT PRIMARY KEY, revision INTEGER);`)
+	if err != nil {
+		t.Fatalf("migrate policy_revisions: %v", err)
+	}
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS item_versions(tenant_id TEXT, kind TEXT, item_id TEXT, version INTEGER, PRIMARY KEY(tenant_id, kind, item_id));`)
+	if err != nil {
+		t.Fatalf("migrate item_versions: %v", err)
+	}
+	s.pollInterval = 10 * time.Millisecond
 	runStoreTests(t, s)
 }
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/tenant"
+)
+
+// tenantColumns selects the columns read by scanTenant. Columns added by
+// later migrations may be NULL in older rows.
+const tenantColumns = `id, name, created_at, COALESCE(template, ''), COALESCE(template_version, ''),
+        COALESCE(policy_repo, ''), COALESCE(policy_branch, ''), COALESCE(status, ''),
+        COALESCE(deleted_at, 0), COALESCE(quota, ''), COALESCE(trusted_issuers, '')`
+
+// scanTenant reads a tenants row selected with tenantColumns.
+func scanTenant(row interface{ Scan(...any) error }) (tenant.Tenant, error) {
+	var t tenant.Tenant
+	var created, deleted int64
+	var status, quota, issuers string
+	if err := row.Scan(&t.ID, &t.Name, &created, &t.Template, &t.TemplateVersion, &t.PolicyRepo, &t.PolicyBranch,
+		&status, &deleted, &quota, &issuers); err != nil {
+		return tenant.Tenant{}, err
+	}
+	t.CreatedAt = time.Unix(created, 0).UTC()
+	t.Status = tenant.Status(status)
+	if deleted != 0 {
+		at := time.Unix(deleted, 0).UTC()
+		t.DeletedAt = &at
+	}
+	if quota != "" {
+		if err := json.Unmarshal([]byte(quota), &t.Quota); err != nil {
+			return tenant.Tenant{}, err
+		}
+	}
+	if issuers != "" {
+		if err := json.Unmarshal([]byte(issuers), &t.TrustedIssuers); err != nil {
+			return tenant.Tenant{}, err
+		}
+	}
+	return t, nil
+}
+
+// tenantValues returns the status, deletion time, quota and trusted issuers
+// columns of t.
+func tenantValues(t tenant.Tenant) (string, any, string, string) {
+	var deleted any
+	if t.DeletedAt != nil {
+		deleted = t.DeletedAt.Unix()
+	}
+	quota, _ := json.Marshal(t.Quota)
+	issuers := []byte("[]")
+	if len(t.TrustedIssuers) > 0 {
+		issuers, _ = json.Marshal(t.TrustedIssuers)
+	}
+	return string(t.State()), deleted, string(quota), string(issuers)
+}
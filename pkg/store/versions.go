@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/bradtumy/authorization-service/pkg/policy"
+)
+
+// ErrVersionNotFound is returned when a tenant has no policy version with
+// the requested number.
+var ErrVersionNotFound = errors.New("policy version not found")
+
+// PolicyVersion is an immutable record of a tenant's policy set after a
+// change. Versions are numbered from 1 per tenant.
+type PolicyVersion struct {
+	Version   int       `json:"version"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Message describes the change, for example `save p1`.
+	Message string `json:"message,omitempty"`
+	// Policies is the tenant's full policy set, sorted by ID.
+	Policies []policy.Policy `json:"policies"`
+}
+
+// PolicyChange describes how one policy differs between two versions.
+type PolicyChange struct {
+	PolicyID string `json:"policyID"`
+	// Type is `added`, `removed` or `modified`.
+	Type   string         `json:"type"`
+	Before *policy.Policy `json:"before,omitempty"`
+	After  *policy.Policy `json:"after,omitempty"`
+}
+
+type authorKey struct{}
+
+// WithAuthor returns a context that attributes the policy changes made with
+// it to author, typically the authenticated subject.
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+func authorFromContext(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey{}).(string)
+	return author
+}
+
+// DiffPolicies lists the policies added, removed or modified from one policy
+// set to another, sorted by ID.
+func DiffPolicies(from, to []policy.Policy) []PolicyChange {
+	before := make(map[string]policy.Policy, len(from))
+	for _, p := range from {
+		before[p.ID] = p
+	}
+	after := make(map[string]policy.Policy, len(to))
+	for _, p := range to {
+		after[p.ID] = p
+	}
+	out := []PolicyChange{}
+	for id, b := range before {
+		b := b
+		a, ok := after[id]
+		if !ok {
+			out = append(out, PolicyChange{PolicyID: id, Type: "removed", Before: &b})
+			continue
+		}
+		if !samePolicy(a, b) {
+			out = append(out, PolicyChange{PolicyID: id, Type: "modified", Before: &b, After: &a})
+		}
+	}
+	for id, a := range after {
+		a := a
+		if _, ok := before[id]; !ok {
+			out = append(out, PolicyChange{PolicyID: id, Type: "added", After: &a})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PolicyID < out[j].PolicyID })
+	return out
+}
+
+// samePolicy compares policies by their stored form, so that nil and empty
+// fields are equal.
+func samePolicy(a, b policy.Policy) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+// sortPolicies orders a policy set by ID for storing it as a version.
+func sortPolicies(list []policy.Policy) []policy.Policy {
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// scanPolicies reads the JSON encoded policies selected by rows and closes
+// them.
+func scanPolicies(rows *sql.Rows) ([]policy.Policy, error) {
+	defer rows.Close()
+	out := []policy.Policy{}
+	for rows.Next() {
+		var js string
+		if err := rows.Scan(&js); err != nil {
+			return nil, err
+		}
+		var p policy.Policy
+		if err := json.Unmarshal([]byte(js), &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// scanVersion reads a policy_versions row selected as version, author,
+// created_at, message, policies.
+func scanVersion(row interface{ Scan(...any) error }) (PolicyVersion, error) {
+	var v PolicyVersion
+	var created int64
+	var js string
+	if err := row.Scan(&v.Version, &v.Author, &created, &v.Message, &js); err != nil {
+		return PolicyVersion{}, err
+	}
+	v.CreatedAt = time.Unix(created, 0).UTC()
+	if err := json.Unmarshal([]byte(js), &v.Policies); err != nil {
+		return PolicyVersion{}, err
+	}
+	return v, nil
+}
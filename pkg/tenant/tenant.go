@@ -1,10 +1,69 @@
 package tenant
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// Status is the lifecycle state of a tenant.
+type Status string
+
+const (
+	// StatusActive tenants are served normally.
+	StatusActive Status = "active"
+	// StatusSuspended tenants keep their data but every access check is
+	// denied until they are resumed.
+	StatusSuspended Status = "suspended"
+	// StatusPendingDeletion tenants are deleted but can be restored until
+	// their retention window ends, when their data is removed.
+	StatusPendingDeletion Status = "pending-deletion"
+)
+
+// Quota limits the resources a tenant may use. Zero values are unlimited.
+type Quota struct {
+	MaxPolicies int `json:"maxPolicies,omitempty"`
+	MaxUsers    int `json:"maxUsers,omitempty"`
+	MaxEdges    int `json:"maxEdges,omitempty"`
+	// RequestsPerSecond limits the rate of API requests made with the
+	// tenant's tokens.
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+}
+
+// Validate reports an error if any limit is negative.
+func (q Quota) Validate() error {
+	if q.MaxPolicies < 0 || q.MaxUsers < 0 || q.MaxEdges < 0 || q.RequestsPerSecond < 0 {
+		return errors.New("quota values must not be negative")
+	}
+	return nil
+}
 
 // Tenant represents a tenant in the system.
 type Tenant struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"createdAt"`
+	Status    Status    `json:"status,omitempty"`
+	// DeletedAt is when the tenant was deleted, while it is pending
+	// deletion.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	Quota     Quota      `json:"quota"`
+	// TrustedIssuers are the DIDs whose verifiable credentials /authorize
+	// accepts for the tenant.
+	TrustedIssuers []string `json:"trustedIssuers,omitempty"`
+	// Template and TemplateVersion record the template the tenant's
+	// policies were created from, if any.
+	Template        string `json:"template,omitempty"`
+	TemplateVersion string `json:"templateVersion,omitempty"`
+	// PolicyRepo and PolicyBranch locate the tenant's policy repository
+	// when policies are served from Git.
+	PolicyRepo   string `json:"policyRepo,omitempty"`
+	PolicyBranch string `json:"policyBranch,omitempty"`
+}
+
+// State returns the tenant's status. Tenants stored without one are active.
+func (t Tenant) State() Status {
+	if t.Status == "" {
+		return StatusActive
+	}
+	return t.Status
 }
@@ -0,0 +1,93 @@
+package validator
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+)
+
+// ValidateBundle validates the files of b as one policy set and returns them
+// merged into a single policy document.
+//
+// Policies declared in a file with a namespace get the ID `namespace.id`.
+// Policy references in a role resolve to the role's file namespace first and
+// are otherwise taken as written, so other namespaces are referenced by their
+// qualified ID. Roles and users are shared by all files. A policy ID, role or
+// user declared twice anywhere in the bundle is an error, as are files that
+// set different combining algorithms.
+func ValidateBundle(b *bundle.Bundle) ([]byte, error) {
+	cfgs := make([]Config, len(b.Files))
+	ids := make(map[string]string)
+	for i, f := range b.Files {
+		if err := yaml.UnmarshalStrict(f.Data, &cfgs[i]); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Path, err)
+		}
+		for _, p := range cfgs[i].Policies {
+			if p.ID == "" {
+				continue
+			}
+			id := qualify(f.Namespace, p.ID)
+			if prev, dup := ids[id]; dup {
+				return nil, fmt.Errorf("duplicate policy id %s in %s and %s", id, prev, f.Path)
+			}
+			ids[id] = f.Path
+		}
+	}
+
+	var merged Config
+	roles := make(map[string]string)
+	users := make(map[string]string)
+	combiningFrom := ""
+	for i, f := range b.Files {
+		cfg := cfgs[i]
+		if cfg.Combining != "" {
+			if merged.Combining != "" && merged.Combining != cfg.Combining {
+				return nil, fmt.Errorf("%s sets combining %s but %s sets %s", f.Path, cfg.Combining, combiningFrom, merged.Combining)
+			}
+			merged.Combining, combiningFrom = cfg.Combining, f.Path
+		}
+		merged.Schema = append(merged.Schema, cfg.Schema...)
+		for _, r := range cfg.Roles {
+			if prev, dup := roles[r.Name]; dup {
+				return nil, fmt.Errorf("role %s is declared in %s and %s", r.Name, prev, f.Path)
+			}
+			roles[r.Name] = f.Path
+			refs := make([]string, len(r.Policies))
+			for j, ref := range r.Policies {
+				refs[j] = ref
+				if _, ok := ids[qualify(f.Namespace, ref)]; ok {
+					refs[j] = qualify(f.Namespace, ref)
+				}
+			}
+			r.Policies = refs
+			merged.Roles = append(merged.Roles, r)
+		}
+		for _, u := range cfg.Users {
+			if prev, dup := users[u.Username]; dup {
+				return nil, fmt.Errorf("user %s is declared in %s and %s", u.Username, prev, f.Path)
+			}
+			users[u.Username] = f.Path
+			merged.Users = append(merged.Users, u)
+		}
+		for _, p := range cfg.Policies {
+			if p.ID != "" {
+				p.ID = qualify(f.Namespace, p.ID)
+			}
+			merged.Policies = append(merged.Policies, p)
+		}
+	}
+	if err := ValidateConfig(&merged); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&merged)
+}
+
+// qualify prefixes id with namespace, if any.
+func qualify(namespace, id string) string {
+	if namespace == "" {
+		return id
+	}
+	return namespace + "." + id
+}
@@ -0,0 +1,77 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+)
+
+const financePolicies = `namespace: finance
+roles:
+  - name: accountant
+    policies: ["read", "hr.read"]
+policies:
+  - id: read
+    resource: ["ledger/**"]
+    action: ["read"]
+    effect: allow
+`
+
+const hrPolicies = `namespace: hr
+policies:
+  - id: read
+    resource: ["staff/**"]
+    action: ["read"]
+    effect: allow
+`
+
+func TestValidateBundleNamespaces(t *testing.T) {
+	b := &bundle.Bundle{Files: []bundle.File{
+		{Path: "hr.yaml", Namespace: "hr", Data: []byte(hrPolicies)},
+		{Path: "finance.yaml", Namespace: "finance", Data: []byte(financePolicies)},
+	}}
+	data, err := ValidateBundle(b)
+	if err != nil {
+		t.Fatalf("expected valid bundle, got %v", err)
+	}
+	var merged Config
+	if err := yaml.UnmarshalStrict(data, &merged); err != nil {
+		t.Fatalf("unmarshal merged: %v", err)
+	}
+	if len(merged.Policies) != 2 || merged.Policies[0].ID != "hr.read" || merged.Policies[1].ID != "finance.read" {
+		t.Fatalf("unexpected policies %#v", merged.Policies)
+	}
+	if refs := strings.Join(merged.Roles[0].Policies, ","); refs != "finance.read,hr.read" {
+		t.Fatalf("unexpected role references %s", refs)
+	}
+}
+
+func TestValidateBundleDuplicates(t *testing.T) {
+	dup := &bundle.Bundle{Files: []bundle.File{
+		{Path: "a.yaml", Namespace: "hr", Data: []byte(hrPolicies)},
+		{Path: "b.yaml", Namespace: "hr", Data: []byte(hrPolicies)},
+	}}
+	_, err := ValidateBundle(dup)
+	if err == nil || !strings.Contains(err.Error(), "duplicate policy id hr.read in a.yaml and b.yaml") {
+		t.Fatalf("expected duplicate policy error, got %v", err)
+	}
+
+	roles := &bundle.Bundle{Files: []bundle.File{
+		{Path: "a.yaml", Data: []byte("roles:\n  - name: admin\n")},
+		{Path: "b.yaml", Data: []byte("roles:\n  - name: admin\n")},
+	}}
+	if _, err := ValidateBundle(roles); err == nil {
+		t.Fatalf("expected duplicate role error")
+	}
+
+	combining := &bundle.Bundle{Files: []bundle.File{
+		{Path: "a.yaml", Data: []byte("combining: deny-overrides\n")},
+		{Path: "b.yaml", Data: []byte("combining: permit-overrides\n")},
+	}}
+	if _, err := ValidateBundle(combining); err == nil {
+		t.Fatalf("expected conflicting combining error")
+	}
+}
@@ -0,0 +1,81 @@
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleHierarchy resolves role inheritance. parents maps every role to the
+// roles it inherits directly. The result maps every role to the roles it
+// inherits transitively, nearest first and without duplicates. An error is
+// returned if a role inherits an undefined role or the hierarchy contains a
+// cycle.
+func RoleHierarchy(parents map[string][]string) (map[string][]string, error) {
+	names := make([]string, 0, len(parents))
+	for name := range parents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(parents))
+	var path []string
+	var visit func(string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+				}
+			}
+			cycle := append(append([]string(nil), path[start:]...), name)
+			return fmt.Errorf("role inheritance cycle: %s", strings.Join(cycle, " -> "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, p := range parents[name] {
+			if _, ok := parents[p]; !ok {
+				return fmt.Errorf("role %s inherits undefined role %s", name, p)
+			}
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make(map[string][]string, len(parents))
+	for _, name := range names {
+		var inherited []string
+		seen := map[string]struct{}{name: {}}
+		queue := append([]string(nil), parents[name]...)
+		for len(queue) > 0 {
+			r := queue[0]
+			queue = queue[1:]
+			if _, ok := seen[r]; ok {
+				continue
+			}
+			seen[r] = struct{}{}
+			inherited = append(inherited, r)
+			queue = append(queue, parents[r]...)
+		}
+		out[name] = inherited
+	}
+	return out, nil
+}
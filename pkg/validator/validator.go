@@ -2,15 +2,21 @@ package validator
 
 import (
 	"fmt"
-	"io/ioutil"
+	"strings"
 
 	"gopkg.in/yaml.v2"
+
+	"github.com/bradtumy/authorization-service/pkg/bundle"
+	"github.com/bradtumy/authorization-service/pkg/expr"
+	"github.com/bradtumy/authorization-service/pkg/pattern"
+	"github.com/bradtumy/authorization-service/pkg/rebac"
 )
 
 // Config represents the structure of the policy file.
 type role struct {
 	Name     string   `yaml:"name"`
 	Policies []string `yaml:"policies"`
+	Inherits []string `yaml:"inherits"`
 }
 
 type subject struct {
@@ -31,35 +37,106 @@ type policy struct {
 	Effect      string            `yaml:"effect"`
 	Conditions  map[string]string `yaml:"conditions"`
 	When        []string          `yaml:"when"`
+	Priority    int               `yaml:"priority"`
+	Relation    string            `yaml:"relation"`
 }
 
 // Config represents the structure of the policy file.
 type Config struct {
-	Roles    []role   `yaml:"roles"`
-	Users    []user   `yaml:"users"`
-	Policies []policy `yaml:"policies"`
+	// Namespace and Import only appear in the files of a bundle (see
+	// ValidateBundle).
+	Namespace string            `yaml:"namespace,omitempty"`
+	Import    []string          `yaml:"import,omitempty"`
+	Combining string            `yaml:"combining"`
+	Schema    []rebac.Namespace `yaml:"schema"`
+	Roles     []role            `yaml:"roles"`
+	Users     []user            `yaml:"users"`
+	Policies  []policy          `yaml:"policies"`
 }
 
 // ValidateConfig performs schema validation on the provided configuration.
 func ValidateConfig(cfg *Config) error {
+	switch cfg.Combining {
+	case "", "deny-overrides", "permit-overrides", "first-applicable":
+	default:
+		return fmt.Errorf("unknown combining algorithm %s", cfg.Combining)
+	}
+
+	if _, err := rebac.Compile(cfg.Schema); err != nil {
+		return err
+	}
+	relationSet := make(map[string]struct{})
+	for _, ns := range cfg.Schema {
+		for _, r := range ns.Relations {
+			relationSet[r.Name] = struct{}{}
+		}
+	}
+
 	roleSet := make(map[string]struct{})
+	parents := make(map[string][]string)
 	for _, r := range cfg.Roles {
+		if r.Name == "" {
+			return fmt.Errorf("role name is required")
+		}
+		if _, dup := roleSet[r.Name]; dup {
+			return fmt.Errorf("role %s is declared twice", r.Name)
+		}
 		roleSet[r.Name] = struct{}{}
+		parents[r.Name] = r.Inherits
+	}
+	if _, err := RoleHierarchy(parents); err != nil {
+		return err
 	}
 
+	userSet := make(map[string]struct{})
+	for _, u := range cfg.Users {
+		if _, dup := userSet[u.Username]; dup {
+			return fmt.Errorf("user %s is declared twice", u.Username)
+		}
+		userSet[u.Username] = struct{}{}
+	}
+
+	policySet := make(map[string]struct{})
 	for _, p := range cfg.Policies {
 		if p.ID == "" {
 			return fmt.Errorf("policy id is required")
 		}
+		if _, dup := policySet[p.ID]; dup {
+			return fmt.Errorf("duplicate policy id %s", p.ID)
+		}
+		policySet[p.ID] = struct{}{}
 		if len(p.Action) == 0 {
 			return fmt.Errorf("policy %s must have at least one action", p.ID)
 		}
 		if len(p.Resource) == 0 {
 			return fmt.Errorf("policy %s must have at least one resource", p.ID)
 		}
+		for _, r := range p.Resource {
+			if err := pattern.Validate(r, pattern.ResourceSeparator); err != nil {
+				return fmt.Errorf("policy %s has invalid resource: %v", p.ID, err)
+			}
+		}
+		for _, a := range p.Action {
+			if err := pattern.Validate(a, pattern.ActionSeparator); err != nil {
+				return fmt.Errorf("policy %s has invalid action: %v", p.ID, err)
+			}
+		}
 		if p.Effect == "" {
 			return fmt.Errorf("policy %s must have an effect", p.ID)
 		}
+		if p.Effect != "allow" && p.Effect != "deny" {
+			return fmt.Errorf("policy %s has invalid effect %s", p.ID, p.Effect)
+		}
+		for _, w := range p.When {
+			if _, err := CompileWhen(w); err != nil {
+				return fmt.Errorf("policy %s has invalid when expression %q: %v", p.ID, w, err)
+			}
+		}
+		if p.Relation != "" && len(cfg.Schema) > 0 {
+			if _, ok := relationSet[p.Relation]; !ok {
+				return fmt.Errorf("policy %s requires relation %s which is not declared in the schema", p.ID, p.Relation)
+			}
+		}
 		for _, subj := range p.Subjects {
 			if subj.Role == "" {
 				return fmt.Errorf("policy %s has subject with empty role", p.ID)
@@ -72,6 +149,25 @@ func ValidateConfig(cfg *Config) error {
 	return nil
 }
 
+// CompileWhen compiles a `when` expression and checks that it reads only the
+// attributes available to policies: `context.*`, `subject.id`,
+// `subject.roles`, `resource.*` and `action`.
+func CompileWhen(src string) (*expr.Program, error) {
+	prog, err := expr.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range prog.References() {
+		switch {
+		case r == "subject.id", r == "subject.roles", r == "action":
+		case strings.HasPrefix(r, "context."), strings.HasPrefix(r, "resource."):
+		default:
+			return nil, fmt.Errorf("unknown attribute %s", r)
+		}
+	}
+	return prog, nil
+}
+
 // ValidatePolicyData validates the given YAML policy data.
 func ValidatePolicyData(data []byte) error {
 	var cfg Config
@@ -81,11 +177,13 @@ func ValidatePolicyData(data []byte) error {
 	return ValidateConfig(&cfg)
 }
 
-// ValidatePolicyFile validates a policy file at the given path.
+// ValidatePolicyFile validates the policy file or bundle directory at the
+// given path, including the files it imports.
 func ValidatePolicyFile(path string) error {
-	data, err := ioutil.ReadFile(path)
+	b, err := bundle.Load(path)
 	if err != nil {
 		return err
 	}
-	return ValidatePolicyData(data)
+	_, err = ValidateBundle(b)
+	return err
 }
@@ -1,6 +1,9 @@
 package validator
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestValidatePolicyValid(t *testing.T) {
 	yaml := []byte(`
@@ -55,3 +58,151 @@ policies:
 		t.Fatalf("expected error for empty action")
 	}
 }
+
+func TestValidatePolicyMalformedPattern(t *testing.T) {
+	yaml := []byte(`
+roles:
+  - name: "admin"
+    policies: ["policy1"]
+policies:
+  - id: "policy1"
+    subjects:
+      - role: "admin"
+    resource: ["files/reports**"]
+    action: ["read"]
+    effect: "allow"
+`)
+	if err := ValidatePolicyData(yaml); err == nil {
+		t.Fatalf("expected error for malformed resource pattern")
+	}
+}
+
+func TestValidatePolicyUnknownCombining(t *testing.T) {
+	yaml := []byte(`
+combining: "majority"
+roles:
+  - name: "admin"
+    policies: ["policy1"]
+policies:
+  - id: "policy1"
+    resource: ["*"]
+    action: ["read"]
+    effect: "allow"
+`)
+	if err := ValidatePolicyData(yaml); err == nil {
+		t.Fatalf("expected error for unknown combining algorithm")
+	}
+}
+
+func TestValidatePolicyInvalidWhen(t *testing.T) {
+	yaml := []byte(`
+roles:
+  - name: "admin"
+    policies: ["policy1"]
+policies:
+  - id: "policy1"
+    resource: ["*"]
+    action: ["read"]
+    effect: "allow"
+    when:
+      - 'context.risk <= '
+`)
+	if err := ValidatePolicyData(yaml); err == nil {
+		t.Fatalf("expected error for invalid when expression")
+	}
+}
+
+func TestValidatePolicyUnknownWhenAttribute(t *testing.T) {
+	yaml := []byte(`
+roles:
+  - name: "admin"
+    policies: ["policy1"]
+policies:
+  - id: "policy1"
+    resource: ["*"]
+    action: ["read"]
+    effect: "allow"
+    when:
+      - 'subject.department == "sales"'
+`)
+	if err := ValidatePolicyData(yaml); err == nil || !strings.Contains(err.Error(), "subject.department") {
+		t.Fatalf("expected error for unknown subject attribute, got %v", err)
+	}
+}
+
+func TestValidateUndeclaredRelation(t *testing.T) {
+	data := []byte(`schema:
+  - type: document
+    relations:
+      - name: viewer
+policies:
+  - id: p1
+    resource: ["document:*"]
+    action: ["read"]
+    relation: editor
+    effect: allow
+`)
+	if err := ValidatePolicyData(data); err == nil {
+		t.Fatalf("expected undeclared relation to be rejected")
+	}
+}
+
+func TestValidateRoleInheritance(t *testing.T) {
+	valid := []byte(`
+roles:
+  - name: "editor"
+    policies: ["policy1"]
+  - name: "senior-editor"
+    inherits: ["editor"]
+policies:
+  - id: "policy1"
+    resource: ["*"]
+    action: ["read"]
+    effect: "allow"
+`)
+	if err := ValidatePolicyData(valid); err != nil {
+		t.Fatalf("expected valid hierarchy, got %v", err)
+	}
+
+	cyclic := []byte(`
+roles:
+  - name: "a"
+    inherits: ["b"]
+  - name: "b"
+    inherits: ["c"]
+  - name: "c"
+    inherits: ["a"]
+`)
+	err := ValidatePolicyData(cyclic)
+	if err == nil || err.Error() != "role inheritance cycle: a -> b -> c -> a" {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+
+	undefined := []byte(`
+roles:
+  - name: "a"
+    inherits: ["missing"]
+`)
+	if err := ValidatePolicyData(undefined); err == nil {
+		t.Fatalf("expected error for undefined inherited role")
+	}
+}
+
+func TestRoleHierarchy(t *testing.T) {
+	got, err := RoleHierarchy(map[string][]string{
+		"viewer":        nil,
+		"editor":        {"viewer"},
+		"reviewer":      {"viewer"},
+		"senior-editor": {"editor", "reviewer"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inherited := got["senior-editor"]
+	if len(inherited) != 3 || inherited[0] != "editor" || inherited[1] != "reviewer" || inherited[2] != "viewer" {
+		t.Fatalf("unexpected inherited roles %v", inherited)
+	}
+	if len(got["viewer"]) != 0 {
+		t.Fatalf("expected viewer to inherit nothing, got %v", got["viewer"])
+	}
+}
@@ -0,0 +1,175 @@
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Document is the part of a DID document used to verify proofs.
+type Document struct {
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+}
+
+// VerificationMethod is a public key of a DID document, published either as
+// publicKeyMultibase (Ed25519VerificationKey2020) or as an OKP publicKeyJwk
+// (JsonWebKey2020).
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
+	PublicKeyJwk       *struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+	} `json:"publicKeyJwk,omitempty"`
+}
+
+// publicKey decodes the method's Ed25519 key.
+func (m VerificationMethod) publicKey() (ed25519.PublicKey, error) {
+	if m.PublicKeyMultibase != "" {
+		return decodePublicKey(m.PublicKeyMultibase)
+	}
+	if jwk := m.PublicKeyJwk; jwk != nil && jwk.Kty == "OKP" && jwk.Crv == "Ed25519" {
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err == nil && len(x) == ed25519.PublicKeySize {
+			return ed25519.PublicKey(x), nil
+		}
+	}
+	return nil, fmt.Errorf("verification method %s has no Ed25519 key", m.ID)
+}
+
+// Resolver resolves did:key and did:web identifiers.
+type Resolver struct {
+	// Client fetches did:web documents. Nil uses DefaultClient.
+	Client *http.Client
+}
+
+// Resolve returns the DID document of did.
+func (r *Resolver) Resolve(ctx context.Context, did string) (*Document, error) {
+	switch {
+	case strings.HasPrefix(did, "did:key:"):
+		return resolveKey(did)
+	case strings.HasPrefix(did, "did:web:"):
+		return r.resolveWeb(ctx, did)
+	}
+	return nil, fmt.Errorf("unsupported DID method: %s", did)
+}
+
+// PublicKey returns the Ed25519 key of the verification method id, a DID URL.
+// A bare DID selects the document's only verification method.
+func (r *Resolver) PublicKey(ctx context.Context, id string) (ed25519.PublicKey, error) {
+	did, _, _ := strings.Cut(id, "#")
+	doc, err := r.Resolve(ctx, did)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range doc.VerificationMethod {
+		mid := m.ID
+		if strings.HasPrefix(mid, "#") {
+			mid = did + mid
+		}
+		if mid == id || (id == did && len(doc.VerificationMethod) == 1) {
+			return m.publicKey()
+		}
+	}
+	return nil, fmt.Errorf("verification method %s not found", id)
+}
+
+// KeyDID returns the did:key identifier of an Ed25519 public key.
+func KeyDID(pub ed25519.PublicKey) string {
+	return "did:key:" + EncodePublicKey(pub)
+}
+
+// resolveKey expands a did:key identifier into its document.
+func resolveKey(did string) (*Document, error) {
+	fragment := strings.TrimPrefix(did, "did:key:")
+	if _, err := decodePublicKey(fragment); err != nil {
+		return nil, fmt.Errorf("invalid did:key: %v", err)
+	}
+	return &Document{ID: did, VerificationMethod: []VerificationMethod{{
+		ID:                 did + "#" + fragment,
+		Type:               "Ed25519VerificationKey2020",
+		Controller:         did,
+		PublicKeyMultibase: fragment,
+	}}}, nil
+}
+
+// WebDIDURL returns the location of a did:web document:
+// did:web:example.com%3A8443:users:alice is served at
+// https://example.com:8443/users/alice/did.json, and did:web:example.com at
+// https://example.com/.well-known/did.json.
+func WebDIDURL(did string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	host, err := url.PathUnescape(parts[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", errors.New("invalid did:web host")
+	}
+	path := "/.well-known"
+	if segs := parts[1:]; len(segs) > 0 {
+		for i := range segs {
+			seg, err := url.PathUnescape(segs[i])
+			if err != nil || seg == "" || strings.ContainsAny(seg, "/?#") {
+				return "", errors.New("invalid did:web path")
+			}
+			segs[i] = seg
+		}
+		path = "/" + strings.Join(segs, "/")
+	}
+	return "https://" + host + path + "/did.json", nil
+}
+
+// resolveWeb fetches a did:web document.
+func (r *Resolver) resolveWeb(ctx context.Context, did string) (*Document, error) {
+	loc, err := WebDIDURL(did)
+	if err != nil {
+		return nil, err
+	}
+	var doc Document
+	if err := fetchJSON(ctx, r.Client, loc, &doc); err != nil {
+		return nil, err
+	}
+	if doc.ID != did {
+		return nil, fmt.Errorf("DID document at %s is for %s", loc, doc.ID)
+	}
+	return &doc, nil
+}
+
+// maxDocumentSize bounds the DID documents and status lists fetched.
+const maxDocumentSize = 1 << 20
+
+// DefaultClient fetches DID documents and status lists when no client is
+// configured. Its timeout keeps an unresponsive issuer from stalling
+// verification.
+var DefaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// fetchJSON decodes the JSON document at loc into v.
+func fetchJSON(ctx context.Context, client *http.Client, loc string, v any) error {
+	if client == nil {
+		client = DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", loc, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v)
+}
@@ -0,0 +1,810 @@
+package vc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The JSON-LD processor below implements what credentials need of JSON-LD
+// 1.1 expansion and RDF serialization: embedded contexts, protected, type
+// scoped and property scoped term definitions, type coercion and @graph
+// containers. Like the safe mode of jsonld-signatures, it fails instead of
+// dropping terms and relative IRIs that no context defines, so that every
+// claim of a credential is signed.
+
+// errUnsupportedJSONLD marks contexts and JSON-LD features the processor
+// does not implement.
+var errUnsupportedJSONLD = errors.New("unsupported JSON-LD")
+
+const (
+	rdfType     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	xsdBoolean  = "http://www.w3.org/2001/XMLSchema#boolean"
+	xsdDouble   = "http://www.w3.org/2001/XMLSchema#double"
+	xsdInteger  = "http://www.w3.org/2001/XMLSchema#integer"
+	xsdString   = "http://www.w3.org/2001/XMLSchema#string"
+	defaultName = "@default"
+)
+
+var (
+	absoluteIRI    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:`)
+	keywordPattern = regexp.MustCompile(`^@[A-Za-z]+$`)
+)
+
+func isAbsoluteIRI(s string) bool { return absoluteIRI.MatchString(s) }
+
+func isBlankNode(s string) bool { return strings.HasPrefix(s, "_:") }
+
+func isKeyword(s string) bool {
+	switch s {
+	case "@base", "@container", "@context", "@direction", "@graph", "@id", "@import", "@included",
+		"@index", "@json", "@language", "@list", "@nest", "@none", "@prefix", "@propagate",
+		"@protected", "@reverse", "@set", "@type", "@value", "@version", "@vocab":
+		return true
+	}
+	return false
+}
+
+// termDefinition maps a term to an IRI or keyword. An empty id maps the
+// term to null.
+type termDefinition struct {
+	id         string
+	typ        string
+	container  []string
+	context    interface{}
+	hasContext bool
+	protected  bool
+	prefix     bool
+}
+
+// equal reports whether d and o are the same definition, other than
+// their protection.
+func (d *termDefinition) equal(o *termDefinition) bool {
+	return d.id == o.id && d.typ == o.typ && d.prefix == o.prefix && d.hasContext == o.hasContext &&
+		reflect.DeepEqual(d.container, o.container) && reflect.DeepEqual(d.context, o.context)
+}
+
+func (d *termDefinition) hasContainer(c string) bool {
+	for _, v := range d.container {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// activeContext is the JSON-LD active context. previous is the context to
+// return to in nested node objects while a type scoped context applies.
+type activeContext struct {
+	terms    map[string]*termDefinition
+	vocab    string
+	previous *activeContext
+}
+
+func newActiveContext() *activeContext {
+	return &activeContext{terms: make(map[string]*termDefinition)}
+}
+
+func (c *activeContext) clone() *activeContext {
+	terms := make(map[string]*termDefinition, len(c.terms))
+	for k, v := range c.terms {
+		terms[k] = v
+	}
+	return &activeContext{terms: terms, vocab: c.vocab, previous: c.previous}
+}
+
+// process returns the active context updated with local, a context, a
+// context URL or an array of them. Protected terms may only be redefined
+// by property scoped contexts, with overrideProtected set. Unless
+// propagate is set the result reverts in nested node objects.
+func (c *activeContext) process(local interface{}, overrideProtected, propagate bool) (*activeContext, error) {
+	result := c.clone()
+	if !propagate && result.previous == nil {
+		result.previous = c
+	}
+	contexts, ok := local.([]interface{})
+	if !ok {
+		contexts = []interface{}{local}
+	}
+	for _, ctx := range contexts {
+		switch ctx := ctx.(type) {
+		case nil:
+			if !overrideProtected {
+				for term, def := range result.terms {
+					if def.protected {
+						return nil, fmt.Errorf("context nullifies protected term %s", term)
+					}
+				}
+			}
+			reset := newActiveContext()
+			if !propagate {
+				reset.previous = result
+			}
+			result = reset
+		case string:
+			doc, ok := contextDocuments[ctx]
+			if !ok {
+				return nil, fmt.Errorf("%w: context %s", errUnsupportedJSONLD, ctx)
+			}
+			var err error
+			if result, err = result.process(doc, false, true); err != nil {
+				return nil, err
+			}
+		case map[string]interface{}:
+			if err := result.define(ctx, overrideProtected); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid local context %v", ctx)
+		}
+	}
+	return result, nil
+}
+
+// localContext is a context being added to an active context. defined
+// tracks the terms being and already defined.
+type localContext struct {
+	terms             map[string]interface{}
+	defined           map[string]bool
+	overrideProtected bool
+}
+
+// define adds the term definitions of the context ctx.
+func (c *activeContext) define(ctx map[string]interface{}, overrideProtected bool) error {
+	for key, value := range ctx {
+		switch key {
+		case "@version":
+			if value != 1.1 {
+				return fmt.Errorf("invalid @version %v", value)
+			}
+		case "@protected":
+			if _, ok := value.(bool); !ok {
+				return errors.New("invalid @protected value")
+			}
+		case "@vocab":
+			switch v := value.(type) {
+			case nil:
+				c.vocab = ""
+			case string:
+				iri, err := c.expandIRI(v, true, nil)
+				if err != nil {
+					return err
+				}
+				if !isAbsoluteIRI(iri) && !isBlankNode(iri) {
+					return fmt.Errorf("invalid @vocab %s", v)
+				}
+				c.vocab = iri
+			default:
+				return errors.New("invalid @vocab value")
+			}
+		default:
+			if strings.HasPrefix(key, "@") {
+				return fmt.Errorf("%w: %s in context", errUnsupportedJSONLD, key)
+			}
+		}
+	}
+	local := &localContext{terms: ctx, defined: make(map[string]bool), overrideProtected: overrideProtected}
+	for _, term := range sortedKeys(ctx) {
+		if strings.HasPrefix(term, "@") {
+			continue
+		}
+		if err := c.defineTerm(local, term); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defineTerm creates the definition of term in the local context, first
+// defining the terms it depends on.
+func (c *activeContext) defineTerm(local *localContext, term string) error {
+	defined := local.defined
+	if done, ok := defined[term]; ok {
+		if !done {
+			return fmt.Errorf("cyclic IRI mapping for %s", term)
+		}
+		return nil
+	}
+	defined[term] = false
+	if term == "" || isKeyword(term) {
+		return fmt.Errorf("%w: definition of %q", errUnsupportedJSONLD, term)
+	}
+	previous := c.terms[term]
+	delete(c.terms, term)
+
+	protected, _ := local.terms["@protected"].(bool)
+	def := &termDefinition{protected: protected}
+	simple := false
+	var entries map[string]interface{}
+	switch value := local.terms[term].(type) {
+	case nil:
+		entries = map[string]interface{}{"@id": nil}
+	case string:
+		entries = map[string]interface{}{"@id": value}
+		simple = true
+	case map[string]interface{}:
+		entries = value
+	default:
+		return fmt.Errorf("invalid term definition for %s", term)
+	}
+	for key := range entries {
+		switch key {
+		case "@id", "@type", "@container", "@context", "@protected", "@prefix":
+		default:
+			return fmt.Errorf("%w: %s in definition of %s", errUnsupportedJSONLD, key, term)
+		}
+	}
+	if v, ok := entries["@protected"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("invalid @protected for %s", term)
+		}
+		def.protected = b
+	}
+	if v, ok := entries["@type"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("invalid @type for %s", term)
+		}
+		iri, err := c.expandIRI(s, true, local)
+		if err != nil {
+			return err
+		}
+		if iri != "@id" && iri != "@vocab" && !isAbsoluteIRI(iri) {
+			return fmt.Errorf("%w: type %s of %s", errUnsupportedJSONLD, s, term)
+		}
+		def.typ = iri
+	}
+	if v, ok := entries["@id"]; ok && v != term {
+		switch id := v.(type) {
+		case nil:
+		case string:
+			iri, err := c.expandIRI(id, true, local)
+			if err != nil {
+				return err
+			}
+			if iri == "@context" || !isKeyword(iri) && !isAbsoluteIRI(iri) && !isBlankNode(iri) {
+				return fmt.Errorf("invalid IRI mapping %s for %s", id, term)
+			}
+			def.id = iri
+			if simple && !strings.ContainsAny(term, ":/") && (strings.ContainsAny(iri[len(iri)-1:], ":/?#[]@") || isBlankNode(iri)) {
+				def.prefix = true
+			}
+		default:
+			return fmt.Errorf("invalid @id for %s", term)
+		}
+	} else if i := strings.IndexByte(term, ':'); i > 0 {
+		prefix, suffix := term[:i], term[i+1:]
+		if _, ok := local.terms[prefix]; ok {
+			if err := c.defineTerm(local, prefix); err != nil {
+				return err
+			}
+		}
+		if p, ok := c.terms[prefix]; ok && p.id != "" {
+			def.id = p.id + suffix
+		} else {
+			def.id = term
+		}
+	} else if c.vocab != "" && !strings.Contains(term, "/") {
+		def.id = c.vocab + term
+	} else {
+		return fmt.Errorf("invalid IRI mapping for %s", term)
+	}
+	if v, ok := entries["@container"]; ok {
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, item := range values {
+			s, _ := item.(string)
+			if s != "@set" && s != "@graph" {
+				return fmt.Errorf("%w: container %v of %s", errUnsupportedJSONLD, item, term)
+			}
+			def.container = append(def.container, s)
+		}
+	}
+	if v, ok := entries["@context"]; ok {
+		def.context, def.hasContext = v, true
+	}
+	if v, ok := entries["@prefix"]; ok {
+		b, ok := v.(bool)
+		if !ok || strings.ContainsAny(term, ":/") {
+			return fmt.Errorf("invalid @prefix for %s", term)
+		}
+		def.prefix = b
+	}
+	if previous != nil && previous.protected && !local.overrideProtected {
+		if !def.equal(previous) {
+			return fmt.Errorf("protected term %s redefined", term)
+		}
+		def = previous
+	}
+	c.terms[term] = def
+	defined[term] = true
+	return nil
+}
+
+// expandIRI expands a term, compact IRI or IRI. Terms are only looked up
+// for vocabulary positions, and those of the local context being processed,
+// if any, are defined first. The result is relative when nothing maps
+// value.
+func (c *activeContext) expandIRI(value string, vocab bool, local *localContext) (string, error) {
+	if isKeyword(value) {
+		return value, nil
+	}
+	if keywordPattern.MatchString(value) {
+		return "", nil
+	}
+	if local != nil {
+		if _, ok := local.terms[value]; ok && !local.defined[value] {
+			if err := c.defineTerm(local, value); err != nil {
+				return "", err
+			}
+		}
+	}
+	if def, ok := c.terms[value]; ok && (vocab || isKeyword(def.id)) {
+		return def.id, nil
+	}
+	if i := strings.IndexByte(value, ':'); i > 0 {
+		prefix, suffix := value[:i], value[i+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, nil
+		}
+		if local != nil {
+			if _, ok := local.terms[prefix]; ok && !local.defined[prefix] {
+				if err := c.defineTerm(local, prefix); err != nil {
+					return "", err
+				}
+			}
+		}
+		if def, ok := c.terms[prefix]; ok && def.id != "" && def.prefix {
+			return def.id + suffix, nil
+		}
+		if isAbsoluteIRI(value) {
+			return value, nil
+		}
+	}
+	if vocab && c.vocab != "" {
+		return c.vocab + value, nil
+	}
+	return value, nil
+}
+
+// expandDocument expands a JSON-LD document into an array of node objects.
+func expandDocument(doc interface{}) ([]interface{}, error) {
+	expanded, err := newActiveContext().expand("", doc)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := expanded.(map[string]interface{}); ok && len(m) == 1 && m["@graph"] != nil {
+		expanded = m["@graph"]
+	}
+	return asArray(expanded), nil
+}
+
+// expand expands element, the value of activeProperty, or the document
+// when activeProperty is empty.
+func (c *activeContext) expand(activeProperty string, element interface{}) (interface{}, error) {
+	switch element := element.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		result := []interface{}{}
+		for _, item := range element {
+			v, err := c.expand(activeProperty, item)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				result = append(result, asArray(v)...)
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		return c.expandObject(activeProperty, element)
+	default:
+		if activeProperty == "" || activeProperty == "@graph" {
+			return nil, nil
+		}
+		ac := c
+		if def := c.terms[activeProperty]; def != nil && def.hasContext {
+			var err error
+			if ac, err = c.process(def.context, true, true); err != nil {
+				return nil, err
+			}
+		}
+		return ac.expandValue(activeProperty, element)
+	}
+}
+
+// expandObject expands a node or value object.
+func (c *activeContext) expandObject(activeProperty string, element map[string]interface{}) (interface{}, error) {
+	var err error
+	ac := c
+	if ac.previous != nil && !ac.keepsScope(element) {
+		ac = ac.previous
+	}
+	if def := c.terms[activeProperty]; def != nil && def.hasContext {
+		if ac, err = ac.process(def.context, true, true); err != nil {
+			return nil, err
+		}
+	}
+	if local, ok := element["@context"]; ok {
+		if ac, err = ac.process(local, false, true); err != nil {
+			return nil, err
+		}
+	}
+	typeScoped := ac
+	keys := sortedKeys(element)
+	for _, key := range keys {
+		if iri, _ := ac.expandIRI(key, true, nil); iri != "@type" {
+			continue
+		}
+		var types []string
+		for _, t := range asArray(element[key]) {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			if def := typeScoped.terms[t]; def != nil && def.hasContext {
+				if ac, err = ac.process(def.context, false, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	result := make(map[string]interface{})
+	for _, key := range keys {
+		if key == "@context" {
+			continue
+		}
+		value := element[key]
+		property, err := ac.expandIRI(key, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		if isKeyword(property) {
+			switch property {
+			case "@id":
+				s, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid @id %v", value)
+				}
+				iri, err := ac.expandIRI(s, false, nil)
+				if err != nil {
+					return nil, err
+				}
+				if !isAbsoluteIRI(iri) && !isBlankNode(iri) {
+					return nil, fmt.Errorf("relative @id %s", s)
+				}
+				result["@id"] = iri
+			case "@type":
+				var types []interface{}
+				for _, t := range asArray(value) {
+					s, ok := t.(string)
+					if !ok {
+						return nil, fmt.Errorf("invalid @type %v", t)
+					}
+					iri, err := typeScoped.expandIRI(s, true, nil)
+					if err != nil {
+						return nil, err
+					}
+					if !isAbsoluteIRI(iri) && !isBlankNode(iri) {
+						return nil, fmt.Errorf("undefined type %s", s)
+					}
+					types = append(types, iri)
+				}
+				result["@type"] = append(asArray(result["@type"]), types...)
+			case "@value":
+				switch value.(type) {
+				case nil, bool, float64, string:
+				default:
+					return nil, errors.New("invalid @value")
+				}
+				result["@value"] = value
+			case "@graph":
+				v, err := ac.expand("@graph", value)
+				if err != nil {
+					return nil, err
+				}
+				result["@graph"] = asArray(v)
+			default:
+				return nil, fmt.Errorf("%w: %s", errUnsupportedJSONLD, property)
+			}
+			continue
+		}
+		if !isAbsoluteIRI(property) {
+			return nil, fmt.Errorf("undefined term %s", key)
+		}
+		expanded, err := ac.expand(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if expanded == nil {
+			continue
+		}
+		items := asArray(expanded)
+		if def := ac.terms[key]; def != nil && def.hasContainer("@graph") {
+			for i, item := range items {
+				items[i] = map[string]interface{}{"@graph": []interface{}{item}}
+			}
+		}
+		result[property] = append(asArray(result[property]), items...)
+	}
+
+	if v, ok := result["@value"]; ok {
+		for k := range result {
+			if k != "@value" && k != "@type" {
+				return nil, fmt.Errorf("%w: %s in value object", errUnsupportedJSONLD, k)
+			}
+		}
+		if v == nil || activeProperty == "" || activeProperty == "@graph" {
+			return nil, nil
+		}
+		if t, ok := result["@type"]; ok {
+			types := asArray(t)
+			if len(types) != 1 {
+				return nil, errors.New("invalid value object type")
+			}
+			result["@type"] = types[0]
+		}
+		return result, nil
+	}
+	if activeProperty == "" || activeProperty == "@graph" {
+		if _, ok := result["@id"]; len(result) == 0 || ok && len(result) == 1 {
+			return nil, nil
+		}
+	}
+	return result, nil
+}
+
+// keepsScope reports whether element is a value object or a node
+// reference, in which a type scoped context still applies.
+func (c *activeContext) keepsScope(element map[string]interface{}) bool {
+	for key := range element {
+		iri, _ := c.expandIRI(key, true, nil)
+		if iri == "@value" || iri == "@id" && len(element) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// expandValue expands a scalar value of activeProperty, coercing it to its
+// defined type.
+func (c *activeContext) expandValue(activeProperty string, value interface{}) (interface{}, error) {
+	def := c.terms[activeProperty]
+	if s, ok := value.(string); ok && def != nil && (def.typ == "@id" || def.typ == "@vocab") {
+		iri, err := c.expandIRI(s, def.typ == "@vocab", nil)
+		if err != nil {
+			return nil, err
+		}
+		if !isAbsoluteIRI(iri) && !isBlankNode(iri) {
+			return nil, fmt.Errorf("relative IRI %s", s)
+		}
+		return map[string]interface{}{"@id": iri}, nil
+	}
+	result := map[string]interface{}{"@value": value}
+	if def != nil && def.typ != "" && def.typ != "@id" && def.typ != "@vocab" {
+		result["@type"] = def.typ
+	}
+	return result, nil
+}
+
+// nodeMap holds the nodes of each graph of an expanded document by
+// subject.
+type nodeMap map[string]map[string]map[string]interface{}
+
+// add flattens element, a value of property of subject in graph, into the
+// map, labelling blank nodes with labels.
+func (m nodeMap) add(element interface{}, graph, subject, property string, labels *identifierIssuer) {
+	if items, ok := element.([]interface{}); ok {
+		for _, item := range items {
+			m.add(item, graph, subject, property, labels)
+		}
+		return
+	}
+	obj, ok := element.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if m[graph] == nil {
+		m[graph] = make(map[string]map[string]interface{})
+	}
+	nodes := m[graph]
+	if _, ok := obj["@value"]; ok {
+		addUnique(nodes[subject], property, obj)
+		return
+	}
+	id, _ := obj["@id"].(string)
+	if id == "" || isBlankNode(id) {
+		id = labels.issue(id)
+	}
+	node := nodes[id]
+	if node == nil {
+		node = map[string]interface{}{"@id": id}
+		nodes[id] = node
+	}
+	if property != "" {
+		addUnique(nodes[subject], property, map[string]interface{}{"@id": id})
+	}
+	for _, t := range asArray(obj["@type"]) {
+		s, _ := t.(string)
+		if isBlankNode(s) {
+			s = labels.issue(s)
+		}
+		addUnique(node, "@type", s)
+	}
+	if g, ok := obj["@graph"]; ok {
+		m.add(g, id, "", "", labels)
+	}
+	for _, key := range sortedKeys(obj) {
+		if isKeyword(key) {
+			continue
+		}
+		if _, ok := node[key]; !ok {
+			node[key] = []interface{}{}
+		}
+		m.add(obj[key], graph, id, key, labels)
+	}
+}
+
+// addUnique appends value to the property of node unless present.
+func addUnique(node map[string]interface{}, property string, value interface{}) {
+	values := asArray(node[property])
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return
+		}
+	}
+	node[property] = append(values, value)
+}
+
+// quads returns the RDF dataset of the node map.
+func (m nodeMap) quads() []quad {
+	var quads []quad
+	for _, name := range sortedKeys(m) {
+		var graph rdfTerm
+		if name != defaultName {
+			var ok bool
+			if graph, ok = nodeTerm(name); !ok {
+				continue
+			}
+		}
+		nodes := m[name]
+		for _, id := range sortedKeys(nodes) {
+			subject, ok := nodeTerm(id)
+			if !ok {
+				continue
+			}
+			node := nodes[id]
+			for _, property := range sortedKeys(node) {
+				switch {
+				case property == "@type":
+					for _, t := range asArray(node[property]) {
+						if object, ok := nodeTerm(t.(string)); ok {
+							quads = append(quads, quad{subject, rdfTerm{value: rdfType}, object, graph})
+						}
+					}
+				case isKeyword(property) || !isAbsoluteIRI(property):
+				default:
+					for _, item := range asArray(node[property]) {
+						if object, ok := objectTerm(item); ok {
+							quads = append(quads, quad{subject, rdfTerm{value: property}, object, graph})
+						}
+					}
+				}
+			}
+		}
+	}
+	return quads
+}
+
+// nodeTerm converts a node identifier, reporting relative IRIs.
+func nodeTerm(id string) (rdfTerm, bool) {
+	switch {
+	case isBlankNode(id):
+		return rdfTerm{kind: blankTerm, value: id}, true
+	case isAbsoluteIRI(id):
+		return rdfTerm{value: id}, true
+	}
+	return rdfTerm{}, false
+}
+
+// objectTerm converts a node reference or value object.
+func objectTerm(item interface{}) (rdfTerm, bool) {
+	obj, _ := item.(map[string]interface{})
+	if id, ok := obj["@id"].(string); ok {
+		return nodeTerm(id)
+	}
+	datatype, _ := obj["@type"].(string)
+	var lexical string
+	switch v := obj["@value"].(type) {
+	case bool:
+		lexical = strconv.FormatBool(v)
+		if datatype == "" {
+			datatype = xsdBoolean
+		}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e21 && datatype != xsdDouble {
+			lexical = strconv.FormatFloat(v, 'f', 0, 64)
+			if datatype == "" {
+				datatype = xsdInteger
+			}
+		} else {
+			lexical = canonicalDouble(v)
+			if datatype == "" {
+				datatype = xsdDouble
+			}
+		}
+	case string:
+		lexical = v
+		if datatype == "" {
+			datatype = xsdString
+		}
+	default:
+		return rdfTerm{}, false
+	}
+	return rdfTerm{kind: literalTerm, value: lexical, datatype: datatype}, true
+}
+
+// canonicalDouble formats v in the canonical xsd:double form, such as 1.5E2.
+func canonicalDouble(v float64) string {
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(v, 'e', 15, 64), "e")
+	mantissa = strings.TrimRight(mantissa, "0")
+	if strings.HasSuffix(mantissa, ".") {
+		mantissa += "0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
+
+// canonicalizeLinkedData returns the URDNA2015 canonical N-Quads of a
+// JSON-LD document.
+func canonicalizeLinkedData(doc interface{}) ([]byte, error) {
+	// Round trip so that every number is a float64 and every object a map.
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	expanded, err := expandDocument(generic)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(nodeMap)
+	nodes.add(expanded, defaultName, "", "", newIdentifierIssuer("_:b"))
+	return []byte(canonicalizeRDF(nodes.quads())), nil
+}
+
+func asArray(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package vc
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"math/big"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ed25519Multicodec prefixes multicodec encoded Ed25519 public keys.
+var ed25519Multicodec = []byte{0xed, 0x01}
+
+// encodeMultibase encodes data as base58btc multibase, prefixed with 'z'.
+func encodeMultibase(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	var out []byte
+	mod := new(big.Int)
+	radix := big.NewInt(58)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return "z" + string(out)
+}
+
+// decodeMultibase decodes a base58btc multibase string.
+func decodeMultibase(s string) ([]byte, error) {
+	if len(s) < 2 || s[0] != 'z' {
+		return nil, errors.New("unsupported multibase encoding")
+	}
+	s = s[1:]
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for i := 0; i < len(s); i++ {
+		d := -1
+		for j := 0; j < len(base58Alphabet); j++ {
+			if base58Alphabet[j] == s[i] {
+				d = j
+				break
+			}
+		}
+		if d < 0 {
+			return nil, errors.New("invalid base58 character")
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(d)))
+	}
+	var zeros int
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// EncodePublicKey returns the multibase multicodec form of an Ed25519 public
+// key, as used by did:key and publicKeyMultibase.
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return encodeMultibase(append(append([]byte(nil), ed25519Multicodec...), pub...))
+}
+
+// decodePublicKey reads a key encoded by EncodePublicKey. Raw 32 byte keys,
+// as published by some Ed25519VerificationKey2018 documents, are accepted
+// too.
+func decodePublicKey(s string) (ed25519.PublicKey, error) {
+	data, err := decodeMultibase(s)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(data) == ed25519.PublicKeySize+2 && data[0] == ed25519Multicodec[0] && data[1] == ed25519Multicodec[1]:
+		return ed25519.PublicKey(data[2:]), nil
+	case len(data) == ed25519.PublicKeySize:
+		return ed25519.PublicKey(data), nil
+	}
+	return nil, errors.New("not an Ed25519 public key")
+}
@@ -0,0 +1,175 @@
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Data Integrity proof types and cryptosuite accepted by the verifier.
+const (
+	ProofDataIntegrity        = "DataIntegrityProof"
+	ProofEd25519Signature2020 = "Ed25519Signature2020"
+	CryptosuiteEdDSAJCS       = "eddsa-jcs-2022"
+)
+
+// verifyDataIntegrity checks the proof embedded in a credential. The proof
+// must be a DataIntegrityProof of the eddsa-jcs-2022 suite, verified over
+// the JSON Canonicalization Scheme (RFC 8785) forms of the proof options and
+// the credential, or an Ed25519Signature2020, verified over their URDNA2015
+// canonicalized JSON-LD. It must be for the assertionMethod purpose and made
+// by a key of issuer.
+func (v *Verifier) verifyDataIntegrity(ctx context.Context, fields map[string]interface{}, issuer string) error {
+	rawProof, ok := fields["proof"]
+	if !ok {
+		return reject(ReasonMissingProof, nil)
+	}
+	proof, ok := rawProof.(map[string]interface{})
+	if !ok {
+		return reject(ReasonUnsupportedProof, errors.New("expected a single proof"))
+	}
+	typ, _ := proof["type"].(string)
+	suite, _ := proof["cryptosuite"].(string)
+	if !(typ == ProofDataIntegrity && suite == CryptosuiteEdDSAJCS || typ == ProofEd25519Signature2020 && suite == "") {
+		return reject(ReasonUnsupportedProof, fmt.Errorf("proof type %s %s", typ, suite))
+	}
+	if purpose, _ := proof["proofPurpose"].(string); purpose != "assertionMethod" {
+		return reject(ReasonInvalidSignature, fmt.Errorf("proof purpose %q", purpose))
+	}
+	method, _ := proof["verificationMethod"].(string)
+	if did, _, _ := strings.Cut(method, "#"); did != issuer {
+		return reject(ReasonInvalidSignature, fmt.Errorf("key %s is not the issuer's", method))
+	}
+	value, _ := proof["proofValue"].(string)
+	sig, err := decodeMultibase(value)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return reject(ReasonInvalidSignature, errors.New("invalid proofValue"))
+	}
+	key, err := v.publicKey(ctx, method)
+	if err != nil {
+		return err
+	}
+	data, err := signingInput(fields, proof)
+	if errors.Is(err, errUnsupportedJSONLD) {
+		return reject(ReasonUnsupportedProof, err)
+	}
+	if err != nil {
+		return reject(ReasonMalformed, err)
+	}
+	if !ed25519.Verify(key.(ed25519.PublicKey), data, sig) {
+		return reject(ReasonInvalidSignature, nil)
+	}
+	return nil
+}
+
+// signingInput returns the hash of the proof options, the proof without its
+// value, followed by the hash of the credential without its proof. The
+// options of an Ed25519Signature2020 take the credential's context.
+func signingInput(fields, proof map[string]interface{}) ([]byte, error) {
+	unsigned := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if k != "proof" {
+			unsigned[k] = v
+		}
+	}
+	options := make(map[string]interface{}, len(proof))
+	for k, v := range proof {
+		if k != "proofValue" {
+			options[k] = v
+		}
+	}
+	canon := canonicalize
+	if proof["type"] == ProofEd25519Signature2020 {
+		options["@context"] = fields["@context"]
+		canon = canonicalizeLinkedData
+	}
+	docData, err := canon(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	optData, err := canon(options)
+	if err != nil {
+		return nil, err
+	}
+	optHash, docHash := sha256.Sum256(optData), sha256.Sum256(docData)
+	return append(optHash[:], docHash[:]...), nil
+}
+
+// AddProof signs a credential with an eddsa-jcs-2022 DataIntegrityProof for
+// the assertionMethod purpose, made by the key identified by
+// verificationMethod, a DID URL of the issuer.
+func AddProof(credential map[string]interface{}, key ed25519.PrivateKey, verificationMethod string, created time.Time) error {
+	return addProof(credential, key, map[string]interface{}{
+		"type":               ProofDataIntegrity,
+		"cryptosuite":        CryptosuiteEdDSAJCS,
+		"created":            created.UTC().Format(time.RFC3339),
+		"verificationMethod": verificationMethod,
+		"proofPurpose":       "assertionMethod",
+	})
+}
+
+// AddEd25519Signature2020 signs a credential with an Ed25519Signature2020
+// proof for the assertionMethod purpose, made by the key identified by
+// verificationMethod. The credential's @context must include
+// ContextCredentialsV1 and ContextEd25519V1, and define every other term
+// it uses inline.
+func AddEd25519Signature2020(credential map[string]interface{}, key ed25519.PrivateKey, verificationMethod string, created time.Time) error {
+	return addProof(credential, key, map[string]interface{}{
+		"type":               ProofEd25519Signature2020,
+		"created":            created.UTC().Format(time.RFC3339),
+		"verificationMethod": verificationMethod,
+		"proofPurpose":       "assertionMethod",
+	})
+}
+
+// addProof signs the credential with the proof options.
+func addProof(credential map[string]interface{}, key ed25519.PrivateKey, proof map[string]interface{}) error {
+	data, err := signingInput(credential, proof)
+	if err != nil {
+		return err
+	}
+	proof["proofValue"] = encodeMultibase(ed25519.Sign(key, data))
+	credential["proof"] = proof
+	return nil
+}
+
+// SignJWT encodes a credential as a JWT-VC signed with EdDSA by the key
+// identified by kid, a DID URL of the issuer. The issuer, subject id, id and
+// validity period are copied into the registered claims.
+func SignJWT(credential map[string]interface{}, key ed25519.PrivateKey, kid string) (string, error) {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return "", err
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	c, err := doc.credential()
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{"iss": c.Issuer, "vc": credential}
+	if c.ID != "" {
+		claims["jti"] = c.ID
+	}
+	if sub, ok := c.Subject["id"].(string); ok {
+		claims["sub"] = sub
+	}
+	if !c.ValidFrom.IsZero() {
+		claims["nbf"] = c.ValidFrom.Unix()
+	}
+	if !c.ValidUntil.IsZero() {
+		claims["exp"] = c.ValidUntil.Unix()
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
@@ -0,0 +1,100 @@
+package vc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Status entry types accepted by the verifier.
+const (
+	StatusList2021Entry      = "StatusList2021Entry"
+	BitstringStatusListEntry = "BitstringStatusListEntry"
+)
+
+// checkStatus fetches the credential's status list and rejects it when its
+// bit is set. The status list credential must be signed by the same issuer
+// and within its own validity period; credentials whose status cannot be
+// established are rejected.
+func (v *Verifier) checkStatus(ctx context.Context, c *Credential) error {
+	s := c.Status
+	if s.Type != StatusList2021Entry && s.Type != BitstringStatusListEntry {
+		return reject(ReasonStatusUnavailable, fmt.Errorf("status type %s", s.Type))
+	}
+	index, err := strconv.Atoi(strings.Trim(string(s.StatusListIndex), `"`))
+	if err != nil || index < 0 {
+		return reject(ReasonStatusUnavailable, errors.New("invalid statusListIndex"))
+	}
+	client := v.Client
+	if client == nil && v.Resolver != nil {
+		client = v.Resolver.Client
+	}
+	var raw json.RawMessage
+	if err := fetchJSON(ctx, client, s.StatusListCredential, &raw); err != nil {
+		return reject(ReasonStatusUnavailable, err)
+	}
+	list, err := v.verifyProof(ctx, raw, []string{c.Issuer})
+	if err != nil {
+		return reject(ReasonStatusUnavailable, fmt.Errorf("status list: %v", err))
+	}
+	if err := list.checkValidity(v.now()); err != nil {
+		return reject(ReasonStatusUnavailable, fmt.Errorf("status list: %v", err))
+	}
+	if purpose, _ := list.Subject["statusPurpose"].(string); purpose != s.StatusPurpose {
+		return reject(ReasonStatusUnavailable, fmt.Errorf("status list purpose %q", purpose))
+	}
+	encoded, _ := list.Subject["encodedList"].(string)
+	bits, err := decodeStatusList(encoded)
+	if err != nil {
+		return reject(ReasonStatusUnavailable, err)
+	}
+	if index/8 >= len(bits) {
+		return reject(ReasonStatusUnavailable, errors.New("statusListIndex out of range"))
+	}
+	if bits[index/8]&(0x80>>(index%8)) != 0 {
+		return reject(ReasonRevoked, fmt.Errorf("status purpose %s", s.StatusPurpose))
+	}
+	return nil
+}
+
+// decodeStatusList decodes a GZIP compressed bitstring, encoded as base64url
+// with an optional multibase 'u' prefix.
+func decodeStatusList(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimPrefix(s, "u"), "=")
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encodedList: %v", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encodedList: %v", err)
+	}
+	return io.ReadAll(io.LimitReader(zr, maxDocumentSize))
+}
+
+// EncodeStatusList encodes a bitstring in which the bits at the given
+// indexes are set, for the encodedList of a status list credential of
+// length bits.
+func EncodeStatusList(length int, set ...int) (string, error) {
+	bits := make([]byte, (length+7)/8)
+	for _, i := range set {
+		if i < 0 || i >= length {
+			return "", fmt.Errorf("index %d out of range", i)
+		}
+		bits[i/8] |= 0x80 >> (i % 8)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bits)
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return "u" + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
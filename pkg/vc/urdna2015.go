@@ -0,0 +1,292 @@
+package vc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type termKind int
+
+const (
+	iriTerm termKind = iota
+	blankTerm
+	literalTerm
+)
+
+// rdfTerm is an IRI, a blank node labelled _:name, or a typed literal.
+type rdfTerm struct {
+	kind     termKind
+	value    string
+	datatype string
+}
+
+var literalEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// String returns the N-Quads form of the term.
+func (t rdfTerm) String() string {
+	switch t.kind {
+	case blankTerm:
+		return t.value
+	case literalTerm:
+		s := `"` + literalEscaper.Replace(t.value) + `"`
+		if t.datatype != xsdString {
+			s += "^^<" + t.datatype + ">"
+		}
+		return s
+	default:
+		return "<" + t.value + ">"
+	}
+}
+
+// quad is an RDF statement. The graph is empty in the default graph.
+type quad struct {
+	subject, predicate, object, graph rdfTerm
+}
+
+// nquad returns the N-Quads line of q.
+func (q quad) nquad() string {
+	s := q.subject.String() + " " + q.predicate.String() + " " + q.object.String()
+	if q.graph.value != "" {
+		s += " " + q.graph.String()
+	}
+	return s + " .\n"
+}
+
+// relabel returns q with its blank nodes renamed by label.
+func (q quad) relabel(label func(string) string) quad {
+	for _, t := range []*rdfTerm{&q.subject, &q.object, &q.graph} {
+		if t.kind == blankTerm {
+			t.value = label(t.value)
+		}
+	}
+	return q
+}
+
+// identifierIssuer issues blank node identifiers with a prefix, in order.
+type identifierIssuer struct {
+	prefix string
+	issued map[string]string
+	order  []string
+	next   int
+}
+
+func newIdentifierIssuer(prefix string) *identifierIssuer {
+	return &identifierIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+// issue returns the identifier of id, issuing one the first time. An empty
+// id always gets a new identifier.
+func (i *identifierIssuer) issue(id string) string {
+	if v, ok := i.issued[id]; ok {
+		return v
+	}
+	v := i.prefix + strconv.Itoa(i.next)
+	i.next++
+	if id != "" {
+		i.issued[id] = v
+		i.order = append(i.order, id)
+	}
+	return v
+}
+
+func (i *identifierIssuer) clone() *identifierIssuer {
+	issued := make(map[string]string, len(i.issued))
+	for k, v := range i.issued {
+		issued[k] = v
+	}
+	return &identifierIssuer{prefix: i.prefix, issued: issued, order: append([]string(nil), i.order...), next: i.next}
+}
+
+// urdna2015 holds the state of the URDNA2015 canonicalization algorithm.
+type urdna2015 struct {
+	quads      []quad
+	blankQuads map[string][]int
+	firstHash  map[string]string
+	canonical  *identifierIssuer
+}
+
+// canonicalizeRDF returns the sorted N-Quads of the dataset with its blank
+// nodes labelled by the URDNA2015 algorithm.
+func canonicalizeRDF(quads []quad) string {
+	c := &urdna2015{
+		quads:      quads,
+		blankQuads: make(map[string][]int),
+		firstHash:  make(map[string]string),
+		canonical:  newIdentifierIssuer("_:c14n"),
+	}
+	for i, q := range quads {
+		for _, t := range []rdfTerm{q.subject, q.object, q.graph} {
+			if t.kind != blankTerm {
+				continue
+			}
+			if refs := c.blankQuads[t.value]; len(refs) == 0 || refs[len(refs)-1] != i {
+				c.blankQuads[t.value] = append(refs, i)
+			}
+		}
+	}
+
+	byHash := make(map[string][]string)
+	for _, id := range sortedKeys(c.blankQuads) {
+		h := c.hashFirstDegree(id)
+		byHash[h] = append(byHash[h], id)
+	}
+	hashes := sortedKeys(byHash)
+	for _, h := range hashes {
+		if ids := byHash[h]; len(ids) == 1 {
+			c.canonical.issue(ids[0])
+			delete(byHash, h)
+		}
+	}
+	for _, h := range hashes {
+		type result struct {
+			hash   string
+			issuer *identifierIssuer
+		}
+		var paths []result
+		for _, id := range byHash[h] {
+			if _, ok := c.canonical.issued[id]; ok {
+				continue
+			}
+			issuer := newIdentifierIssuer("_:b")
+			issuer.issue(id)
+			hash, issuer := c.hashNDegree(id, issuer)
+			paths = append(paths, result{hash, issuer})
+		}
+		sort.SliceStable(paths, func(i, j int) bool { return paths[i].hash < paths[j].hash })
+		for _, p := range paths {
+			for _, id := range p.issuer.order {
+				c.canonical.issue(id)
+			}
+		}
+	}
+
+	lines := make([]string, len(quads))
+	for i, q := range quads {
+		lines[i] = q.relabel(func(id string) string { return c.canonical.issued[id] }).nquad()
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+// hashFirstDegree hashes the quads of a blank node, with the node labelled
+// _:a and other blank nodes _:z.
+func (c *urdna2015) hashFirstDegree(id string) string {
+	if h, ok := c.firstHash[id]; ok {
+		return h
+	}
+	var lines []string
+	for _, i := range c.blankQuads[id] {
+		lines = append(lines, c.quads[i].relabel(func(b string) string {
+			if b == id {
+				return "_:a"
+			}
+			return "_:z"
+		}).nquad())
+	}
+	sort.Strings(lines)
+	h := hashHex(strings.Join(lines, ""))
+	c.firstHash[id] = h
+	return h
+}
+
+// hashRelated hashes the blank node related to another through q, at
+// position s, o or g.
+func (c *urdna2015) hashRelated(related string, q quad, issuer *identifierIssuer, position string) string {
+	id, ok := c.canonical.issued[related]
+	if !ok {
+		if id, ok = issuer.issued[related]; !ok {
+			id = c.hashFirstDegree(related)
+		}
+	}
+	input := position
+	if position != "g" {
+		input += "<" + q.predicate.value + ">"
+	}
+	return hashHex(input + id)
+}
+
+// hashNDegree hashes the blank node id by the paths to its related blank
+// nodes, returning the issuer that labelled them along the chosen paths.
+func (c *urdna2015) hashNDegree(id string, issuer *identifierIssuer) (string, *identifierIssuer) {
+	related := make(map[string][]string)
+	for _, i := range c.blankQuads[id] {
+		q := c.quads[i]
+		for _, comp := range []struct {
+			term     rdfTerm
+			position string
+		}{{q.subject, "s"}, {q.object, "o"}, {q.graph, "g"}} {
+			if comp.term.kind == blankTerm && comp.term.value != id {
+				h := c.hashRelated(comp.term.value, q, issuer, comp.position)
+				related[h] = append(related[h], comp.term.value)
+			}
+		}
+	}
+
+	var data strings.Builder
+	for _, h := range sortedKeys(related) {
+		data.WriteString(h)
+		var chosenPath string
+		var chosenIssuer *identifierIssuer
+		permute(related[h], func(perm []string) {
+			copied := issuer.clone()
+			path := ""
+			var recursion []string
+			worse := func() bool {
+				return chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath
+			}
+			for _, r := range perm {
+				if v, ok := c.canonical.issued[r]; ok {
+					path += v
+				} else {
+					if _, ok := copied.issued[r]; !ok {
+						recursion = append(recursion, r)
+					}
+					path += copied.issue(r)
+				}
+				if worse() {
+					return
+				}
+			}
+			for _, r := range recursion {
+				hash, result := c.hashNDegree(r, copied)
+				path += copied.issue(r) + "<" + hash + ">"
+				copied = result
+				if worse() {
+					return
+				}
+			}
+			if chosenPath == "" || path < chosenPath {
+				chosenPath, chosenIssuer = path, copied
+			}
+		})
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return hashHex(data.String()), issuer
+}
+
+// permute calls f with every permutation of items.
+func permute(items []string, f func([]string)) {
+	perm := append([]string(nil), items...)
+	var generate func(k int)
+	generate = func(k int) {
+		if k == len(perm) {
+			f(perm)
+			return
+		}
+		for i := k; i < len(perm); i++ {
+			perm[k], perm[i] = perm[i], perm[k]
+			generate(k + 1)
+			perm[k], perm[i] = perm[i], perm[k]
+		}
+	}
+	generate(0)
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,291 @@
+// Package vc verifies W3C Verifiable Credentials: JWT-VC and Data Integrity
+// (eddsa-jcs-2022 and Ed25519Signature2020) proofs by issuers identified
+// with did:key or did:web, their validity period and their status list
+// revocation entry.
+package vc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Reasons a credential is rejected, reported in authorization decisions.
+const (
+	ReasonMalformed          = "credential malformed"
+	ReasonMissingProof       = "credential proof missing"
+	ReasonUnsupportedProof   = "credential proof unsupported"
+	ReasonUntrustedIssuer    = "credential issuer not trusted"
+	ReasonUnresolvableIssuer = "credential issuer unresolvable"
+	ReasonInvalidSignature   = "credential signature invalid"
+	ReasonNotYetValid        = "credential not yet valid"
+	ReasonExpired            = "credential expired"
+	ReasonRevoked            = "credential revoked"
+	ReasonStatusUnavailable  = "credential status unavailable"
+)
+
+// Error is a rejected credential. Reason is one of the Reason constants.
+type Error struct {
+	Reason string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Reason
+	}
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func reject(reason string, err error) *Error {
+	return &Error{Reason: reason, Err: err}
+}
+
+// Credential is a verified credential.
+type Credential struct {
+	ID     string
+	Issuer string
+	// Subject is the credentialSubject, whose id identifies the holder.
+	Subject   map[string]interface{}
+	ValidFrom time.Time
+	// ValidUntil is zero for credentials that do not expire.
+	ValidUntil time.Time
+	Status     *Status
+}
+
+// Status is a credentialStatus entry of a StatusList2021Entry or
+// BitstringStatusListEntry type.
+type Status struct {
+	ID                   string          `json:"id"`
+	Type                 string          `json:"type"`
+	StatusPurpose        string          `json:"statusPurpose"`
+	StatusListIndex      json.RawMessage `json:"statusListIndex"`
+	StatusListCredential string          `json:"statusListCredential"`
+}
+
+// document is the JSON form of a credential, in either the 1.1 or the 2.0
+// data model.
+type document struct {
+	ID                string                 `json:"id"`
+	Issuer            json.RawMessage        `json:"issuer"`
+	IssuanceDate      *time.Time             `json:"issuanceDate"`
+	ValidFrom         *time.Time             `json:"validFrom"`
+	ExpirationDate    *time.Time             `json:"expirationDate"`
+	ValidUntil        *time.Time             `json:"validUntil"`
+	CredentialSubject map[string]interface{} `json:"credentialSubject"`
+	CredentialStatus  *Status                `json:"credentialStatus"`
+}
+
+// credential converts the document. The issuer is a URI or an object with
+// an id.
+func (d document) credential() (*Credential, error) {
+	c := &Credential{ID: d.ID, Subject: d.CredentialSubject, Status: d.CredentialStatus}
+	if err := json.Unmarshal(d.Issuer, &c.Issuer); err != nil {
+		var obj struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(d.Issuer, &obj) != nil {
+			return nil, errors.New("invalid issuer")
+		}
+		c.Issuer = obj.ID
+	}
+	if c.Issuer == "" {
+		return nil, errors.New("missing issuer")
+	}
+	for _, t := range []*time.Time{d.IssuanceDate, d.ValidFrom} {
+		if t != nil && t.After(c.ValidFrom) {
+			c.ValidFrom = *t
+		}
+	}
+	for _, t := range []*time.Time{d.ExpirationDate, d.ValidUntil} {
+		if t != nil && (c.ValidUntil.IsZero() || t.Before(c.ValidUntil)) {
+			c.ValidUntil = *t
+		}
+	}
+	return c, nil
+}
+
+// Verifier verifies credentials.
+type Verifier struct {
+	// Resolver resolves issuer DIDs.
+	Resolver *Resolver
+	// Client fetches status list credentials. Nil uses the resolver's
+	// client, or DefaultClient.
+	Client *http.Client
+	// Now returns the current time. Nil uses time.Now.
+	Now func() time.Time
+}
+
+// Verify checks the credential raw, a JSON object with an embedded proof or
+// a JSON string holding a JWT-VC, and returns it. The credential must be
+// issued by one of trusted, signed by a key of the issuer's DID document,
+// within its validity period and not revoked. Rejections are *Error values.
+func (v *Verifier) Verify(ctx context.Context, raw json.RawMessage, trusted []string) (*Credential, error) {
+	c, err := v.verifyProof(ctx, raw, trusted)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkValidity(v.now()); err != nil {
+		return nil, err
+	}
+	if c.Status != nil {
+		if err := v.checkStatus(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// now returns the current time.
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// checkValidity rejects the credential outside its validity period.
+func (c *Credential) checkValidity(now time.Time) error {
+	if now.Before(c.ValidFrom) {
+		return reject(ReasonNotYetValid, nil)
+	}
+	if !c.ValidUntil.IsZero() && !now.Before(c.ValidUntil) {
+		return reject(ReasonExpired, nil)
+	}
+	return nil
+}
+
+// verifyProof checks the credential's proof and issuer.
+func (v *Verifier) verifyProof(ctx context.Context, raw json.RawMessage, trusted []string) (*Credential, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '"' {
+		var token string
+		if err := json.Unmarshal(raw, &token); err != nil {
+			return nil, reject(ReasonMalformed, err)
+		}
+		return v.verifyJWT(ctx, token, trusted)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil, reject(ReasonMalformed, err)
+	}
+	var doc document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, reject(ReasonMalformed, err)
+	}
+	c, err := doc.credential()
+	if err != nil {
+		return nil, reject(ReasonMalformed, err)
+	}
+	if !isTrusted(c.Issuer, trusted) {
+		return nil, reject(ReasonUntrustedIssuer, fmt.Errorf("issuer %s", c.Issuer))
+	}
+	if err := v.verifyDataIntegrity(ctx, fields, c.Issuer); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// verifyJWT checks a JWT-VC signed with EdDSA by a key of its iss DID. The
+// registered claims take the place of the credential's id, issuer,
+// subject id and validity period.
+func (v *Verifier) verifyJWT(ctx context.Context, token string, trusted []string) (*Credential, error) {
+	var claims struct {
+		jwt.RegisteredClaims
+		VC json.RawMessage `json:"vc"`
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}), jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if !isTrusted(claims.Issuer, trusted) {
+			return nil, reject(ReasonUntrustedIssuer, fmt.Errorf("issuer %s", claims.Issuer))
+		}
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" || strings.HasPrefix(kid, "#") {
+			kid = claims.Issuer + kid
+		}
+		if did, _, _ := strings.Cut(kid, "#"); did != claims.Issuer {
+			return nil, reject(ReasonInvalidSignature, fmt.Errorf("key %s is not the issuer's", kid))
+		}
+		return v.publicKey(ctx, kid)
+	})
+	if err != nil {
+		var rej *Error
+		if errors.As(err, &rej) {
+			return nil, rej
+		}
+		var verr *jwt.ValidationError
+		if errors.As(err, &verr) && verr.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+			return nil, reject(ReasonInvalidSignature, err)
+		}
+		if errors.As(err, &verr) && verr.Errors&jwt.ValidationErrorMalformed == 0 {
+			return nil, reject(ReasonUnsupportedProof, err)
+		}
+		return nil, reject(ReasonMalformed, err)
+	}
+	var doc document
+	if len(claims.VC) == 0 {
+		return nil, reject(ReasonMalformed, errors.New("missing vc claim"))
+	}
+	if err := json.Unmarshal(claims.VC, &doc); err != nil {
+		return nil, reject(ReasonMalformed, err)
+	}
+	if len(doc.Issuer) == 0 {
+		doc.Issuer, _ = json.Marshal(claims.Issuer)
+	}
+	c, err := doc.credential()
+	if err != nil {
+		return nil, reject(ReasonMalformed, err)
+	}
+	if c.Issuer != claims.Issuer {
+		return nil, reject(ReasonMalformed, errors.New("vc issuer does not match iss"))
+	}
+	if claims.ID != "" {
+		c.ID = claims.ID
+	}
+	if claims.Subject != "" {
+		if c.Subject == nil {
+			c.Subject = make(map[string]interface{})
+		}
+		c.Subject["id"] = claims.Subject
+	}
+	if claims.NotBefore != nil {
+		c.ValidFrom = claims.NotBefore.Time
+	}
+	if claims.ExpiresAt != nil {
+		c.ValidUntil = claims.ExpiresAt.Time
+	}
+	return c, nil
+}
+
+// publicKey resolves a verification method, reporting failures as
+// unresolvable issuers.
+func (v *Verifier) publicKey(ctx context.Context, id string) (interface{}, error) {
+	r := v.Resolver
+	if r == nil {
+		r = &Resolver{}
+	}
+	key, err := r.PublicKey(ctx, id)
+	if err != nil {
+		return nil, reject(ReasonUnresolvableIssuer, err)
+	}
+	return key, nil
+}
+
+// isTrusted reports whether issuer is listed in trusted.
+func isTrusted(issuer string, trusted []string) bool {
+	for _, t := range trusted {
+		if t == issuer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIssuer is a did:web issuer served by a local TLS server, publishing
+// its DID document and a revocation status list, valid until listExpires
+// when set.
+type testIssuer struct {
+	did         string
+	key         ed25519.PrivateKey
+	server      *httptest.Server
+	revoked     []int
+	listExpires time.Time
+	verifier    *Verifier
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	iss := &testIssuer{key: priv}
+	iss.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/issuer/did.json":
+			json.NewEncoder(w).Encode(Document{ID: iss.did, VerificationMethod: []VerificationMethod{{
+				ID: iss.did + "#key-1", Type: "Ed25519VerificationKey2020", Controller: iss.did, PublicKeyMultibase: EncodePublicKey(pub),
+			}}})
+		case "/status/1":
+			list, _ := EncodeStatusList(1024, iss.revoked...)
+			cred := iss.credential(map[string]interface{}{"id": iss.server.URL + "/status/1#list", "type": "BitstringStatusList", "statusPurpose": "revocation", "encodedList": list})
+			if !iss.listExpires.IsZero() {
+				cred["validUntil"] = iss.listExpires.UTC().Format(time.RFC3339)
+			}
+			if err := AddProof(cred, iss.key, iss.did+"#key-1", time.Now()); err != nil {
+				t.Errorf("sign status list: %v", err)
+			}
+			json.NewEncoder(w).Encode(cred)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(iss.server.Close)
+	u, _ := url.Parse(iss.server.URL)
+	iss.did = "did:web:" + strings.ReplaceAll(u.Host, ":", "%3A") + ":issuer"
+	iss.verifier = &Verifier{Resolver: &Resolver{Client: iss.server.Client()}}
+	return iss
+}
+
+func (iss *testIssuer) credential(subject map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"@context":          []interface{}{"https://www.w3.org/ns/credentials/v2"},
+		"id":                "urn:uuid:3732",
+		"type":              []interface{}{"VerifiableCredential"},
+		"issuer":            iss.did,
+		"validFrom":         time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		"credentialSubject": subject,
+	}
+}
+
+func marshal(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
+
+func reason(err error) string {
+	var rej *Error
+	if errors.As(err, &rej) {
+		return rej.Reason
+	}
+	return ""
+}
+
+func TestDataIntegrityProof(t *testing.T) {
+	iss := newTestIssuer(t)
+	ctx := context.Background()
+	cred := iss.credential(map[string]interface{}{"id": "did:example:alice", "department": "sales"})
+	cred["credentialStatus"] = map[string]interface{}{
+		"id": iss.server.URL + "/status/1#7", "type": BitstringStatusListEntry, "statusPurpose": "revocation",
+		"statusListIndex": "7", "statusListCredential": iss.server.URL + "/status/1",
+	}
+	if err := AddProof(cred, iss.key, iss.did+"#key-1", time.Now()); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	raw := marshal(t, cred)
+
+	c, err := iss.verifier.Verify(ctx, raw, []string{iss.did})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if c.Issuer != iss.did || c.Subject["department"] != "sales" {
+		t.Fatalf("unexpected credential %#v", c)
+	}
+	if _, err := iss.verifier.Verify(ctx, raw, []string{"did:web:other.example"}); reason(err) != ReasonUntrustedIssuer {
+		t.Fatalf("expected untrusted issuer, got %v", err)
+	}
+
+	tampered := strings.Replace(string(raw), `"sales"`, `"finance"`, 1)
+	if _, err := iss.verifier.Verify(ctx, json.RawMessage(tampered), []string{iss.did}); reason(err) != ReasonInvalidSignature {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+
+	iss.listExpires = time.Now().Add(-time.Minute)
+	if _, err := iss.verifier.Verify(ctx, raw, []string{iss.did}); reason(err) != ReasonStatusUnavailable {
+		t.Fatalf("expected expired status list to be unavailable, got %v", err)
+	}
+	iss.listExpires = time.Time{}
+
+	iss.revoked = []int{7}
+	if _, err := iss.verifier.Verify(ctx, raw, []string{iss.did}); reason(err) != ReasonRevoked {
+		t.Fatalf("expected revoked, got %v", err)
+	}
+
+	cred["proof"].(map[string]interface{})["cryptosuite"] = "eddsa-rdfc-2022"
+	if _, err := iss.verifier.Verify(ctx, marshal(t, cred), []string{iss.did}); reason(err) != ReasonUnsupportedProof {
+		t.Fatalf("expected unsupported proof, got %v", err)
+	}
+
+	delete(cred, "proof")
+	if _, err := iss.verifier.Verify(ctx, marshal(t, cred), []string{iss.did}); reason(err) != ReasonMissingProof {
+		t.Fatalf("expected missing proof, got %v", err)
+	}
+}
+
+func TestEd25519Signature2020(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	did := KeyDID(pub)
+	method := did + "#" + strings.TrimPrefix(did, "did:key:")
+	v := &Verifier{}
+	ctx := context.Background()
+	cred := map[string]interface{}{
+		"@context":          []interface{}{ContextCredentialsV1, ContextEd25519V1, map[string]interface{}{"department": "https://example.org/vocab#department"}},
+		"id":                "urn:uuid:3732",
+		"type":              []interface{}{"VerifiableCredential"},
+		"issuer":            did,
+		"issuanceDate":      "2023-01-01T19:23:24Z",
+		"credentialSubject": map[string]interface{}{"id": "did:example:alice", "department": "sales"},
+	}
+	want := "<did:example:alice> <https://example.org/vocab#department> \"sales\" .\n" +
+		"<urn:uuid:3732> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/2018/credentials#VerifiableCredential> .\n" +
+		"<urn:uuid:3732> <https://www.w3.org/2018/credentials#credentialSubject> <did:example:alice> .\n" +
+		"<urn:uuid:3732> <https://www.w3.org/2018/credentials#issuanceDate> \"2023-01-01T19:23:24Z\"^^<http://www.w3.org/2001/XMLSchema#dateTime> .\n" +
+		"<urn:uuid:3732> <https://www.w3.org/2018/credentials#issuer> <" + did + "> .\n"
+	if got, err := canonicalizeLinkedData(cred); err != nil || string(got) != want {
+		t.Fatalf("unexpected canonical form %q (%v)", got, err)
+	}
+	if err := AddEd25519Signature2020(cred, priv, method, time.Now()); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	raw := marshal(t, cred)
+
+	c, err := v.Verify(ctx, raw, []string{did})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if c.Subject["department"] != "sales" {
+		t.Fatalf("unexpected credential %#v", c)
+	}
+	tampered := strings.Replace(string(raw), `"sales"`, `"finance"`, 1)
+	if _, err := v.Verify(ctx, json.RawMessage(tampered), []string{did}); reason(err) != ReasonInvalidSignature {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+	// Claims without a definition would not be signed.
+	tampered = strings.Replace(string(raw), `"department":"sales"`, `"department":"sales","role":"admin"`, 1)
+	if _, err := v.Verify(ctx, json.RawMessage(tampered), []string{did}); reason(err) != ReasonMalformed {
+		t.Fatalf("expected undefined term to be malformed, got %v", err)
+	}
+	cred["@context"] = []interface{}{"https://www.w3.org/ns/credentials/v2"}
+	if _, err := v.Verify(ctx, marshal(t, cred), []string{did}); reason(err) != ReasonUnsupportedProof {
+		t.Fatalf("expected unknown context to be unsupported, got %v", err)
+	}
+}
+
+func TestURDNA2015(t *testing.T) {
+	// A cycle of blank nodes that no quad of their own tells apart.
+	cycle := func(labels ...string) []quad {
+		p := rdfTerm{value: "http://example.org/p"}
+		var quads []quad
+		for i, l := range labels {
+			next := labels[(i+1)%len(labels)]
+			quads = append(quads, quad{subject: rdfTerm{kind: blankTerm, value: l}, predicate: p, object: rdfTerm{kind: blankTerm, value: next}})
+		}
+		return quads
+	}
+	want := "_:c14n0 <http://example.org/p> _:c14n1 .\n" +
+		"_:c14n1 <http://example.org/p> _:c14n2 .\n" +
+		"_:c14n2 <http://example.org/p> _:c14n0 .\n"
+	for _, quads := range [][]quad{cycle("_:x", "_:y", "_:z"), cycle("_:b2", "_:b0", "_:b1")} {
+		quads[0], quads[2] = quads[2], quads[0]
+		if got := canonicalizeRDF(quads); got != want {
+			t.Fatalf("unexpected canonical form\n%s", got)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	cases := map[string]string{
+		// RFC 8785 section 3.2.3: names sort by UTF-16 code units.
+		`{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		// RFC 8785 section 3.2.2.
+		`{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000000000000000000000000001],"string":"\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/","literals":[null,true,false]}`: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		`{"separators":"\u2028\u2029","html":"<a&b>"}`:                    "{\"html\":\"<a&b>\",\"separators\":\"\u2028\u2029\"}",
+		`[-0,5e-324,1.7976931348623157e308,1e21,1e20,1e-7,0.000001,-1.5]`: `[0,5e-324,1.7976931348623157e+308,1e+21,100000000000000000000,1e-7,0.000001,-1.5]`,
+	}
+	for in, want := range cases {
+		got, err := canonicalize(json.RawMessage(in))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: expected %s, got %s (%v)", in, want, got, err)
+		}
+	}
+}
+
+func TestJWTCredential(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	did := KeyDID(pub)
+	kid := did + "#" + strings.TrimPrefix(did, "did:key:")
+	v := &Verifier{}
+	ctx := context.Background()
+	sign := func(validUntil time.Time) json.RawMessage {
+		t.Helper()
+		cred := map[string]interface{}{
+			"@context":          []interface{}{"https://www.w3.org/2018/credentials/v1"},
+			"type":              []interface{}{"VerifiableCredential"},
+			"issuer":            did,
+			"issuanceDate":      time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+			"expirationDate":    validUntil.UTC().Format(time.RFC3339),
+			"credentialSubject": map[string]interface{}{"id": "did:example:bob", "role": "admin"},
+		}
+		token, err := SignJWT(cred, priv, kid)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return marshal(t, token)
+	}
+
+	c, err := v.Verify(ctx, sign(time.Now().Add(time.Hour)), []string{did})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if c.Subject["id"] != "did:example:bob" || c.Subject["role"] != "admin" {
+		t.Fatalf("unexpected credential %#v", c)
+	}
+	if _, err := v.Verify(ctx, sign(time.Now().Add(-time.Minute)), []string{did}); reason(err) != ReasonExpired {
+		t.Fatalf("expected expired, got %v", err)
+	}
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	forged, _ := SignJWT(map[string]interface{}{"issuer": did, "credentialSubject": map[string]interface{}{"id": "x"}}, other, kid)
+	if _, err := v.Verify(ctx, marshal(t, forged), []string{did}); reason(err) != ReasonInvalidSignature {
+		t.Fatalf("expected invalid signature, got %v", err)
+	}
+}
+
+func TestWebDIDURL(t *testing.T) {
+	cases := map[string]string{
+		"did:web:example.com":                    "https://example.com/.well-known/did.json",
+		"did:web:example.com%3A8443:users:alice": "https://example.com:8443/users/alice/did.json",
+	}
+	for did, want := range cases {
+		if got, err := WebDIDURL(did); err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", did, want, got, err)
+		}
+	}
+	if _, err := WebDIDURL("did:web:example.com:a%2Fb"); err == nil {
+		t.Errorf("expected path separators to be rejected")
+	}
+}
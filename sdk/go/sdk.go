@@ -18,11 +18,12 @@ func NewClient(baseURL string) *Client {
 }
 
 type AccessRequest struct {
-	TenantID   string            `json:"tenantID"`
-	Subject    string            `json:"subject"`
-	Resource   string            `json:"resource"`
-	Action     string            `json:"action"`
-	Conditions map[string]string `json:"conditions,omitempty"`
+	TenantID           string                 `json:"tenantID"`
+	Subject            string                 `json:"subject"`
+	Resource           string                 `json:"resource"`
+	Action             string                 `json:"action"`
+	Conditions         map[string]string      `json:"conditions,omitempty"`
+	ResourceAttributes map[string]interface{} `json:"resourceAttributes,omitempty"`
 }
 
 type Decision struct {
@@ -32,6 +33,21 @@ type Decision struct {
 	Remediation []string `json:"remediation"`
 }
 
+// BatchItem is a single resource/action pair in a batch access check.
+type BatchItem struct {
+	Resource           string                 `json:"resource"`
+	Action             string                 `json:"action"`
+	ResourceAttributes map[string]interface{} `json:"resourceAttributes,omitempty"`
+}
+
+// BatchAccessRequest asks for decisions on many items for one subject.
+type BatchAccessRequest struct {
+	TenantID   string            `json:"tenantID"`
+	Subject    string            `json:"subject"`
+	Items      []BatchItem       `json:"items"`
+	Conditions map[string]string `json:"conditions,omitempty"`
+}
+
 func (c *Client) post(path string, payload any) (*http.Response, error) {
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -58,6 +74,30 @@ func (c *Client) CheckAccess(req AccessRequest) (*Decision, error) {
 	return &dec, nil
 }
 
+// CheckAccessBatch evaluates all items in a single request and returns the
+// decisions in the same order as req.Items.
+func (c *Client) CheckAccessBatch(req BatchAccessRequest) ([]Decision, error) {
+	resp, err := c.post("/check-access/batch", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+	var out struct {
+		Decisions []Decision `json:"decisions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Decisions) != len(req.Items) {
+		return nil, fmt.Errorf("expected %d decisions, got %d", len(req.Items), len(out.Decisions))
+	}
+	return out.Decisions, nil
+}
+
 func (c *Client) CompileRule(tenantID, rule string) (string, error) {
 	resp, err := c.post("/compile", map[string]string{"tenantID": tenantID, "rule": rule})
 	if err != nil {
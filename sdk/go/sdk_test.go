@@ -19,6 +19,18 @@ func TestClient(t *testing.T) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(checkAccessResponse{Allow: true, PolicyID: "p1", Reason: "ok"})
 	})
+	mux.HandleFunc("/check-access/batch", func(w http.ResponseWriter, r *http.Request) {
+		var req BatchAccessRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		out := struct {
+			Decisions []checkAccessResponse `json:"decisions"`
+		}{}
+		for _, item := range req.Items {
+			out.Decisions = append(out.Decisions, checkAccessResponse{Allow: item.Action == "read"})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	})
 	mux.HandleFunc("/compile", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("policy: allow"))
 	})
@@ -34,6 +46,13 @@ func TestClient(t *testing.T) {
 	if err != nil || !dec.Allow {
 		t.Fatalf("CheckAccess failed: %v", err)
 	}
+	decs, err := c.CheckAccessBatch(BatchAccessRequest{TenantID: "t", Subject: "s", Items: []BatchItem{
+		{Resource: "r1", Action: "read"},
+		{Resource: "r2", Action: "write"},
+	}})
+	if err != nil || len(decs) != 2 || !decs[0].Allow || decs[1].Allow {
+		t.Fatalf("CheckAccessBatch failed: %v %#v", err, decs)
+	}
 	if _, err := c.CompileRule("t", "rule"); err != nil {
 		t.Fatalf("CompileRule failed: %v", err)
 	}